@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"goapi/internal/api/coap"
+	"goapi/internal/api/mqtt"
 	"goapi/internal/api/repository/DAL/SQLite"
 
 	//"goapi/internal/api/repository/DAL/PostgreSQL"
 	"goapi/internal/api/server"
 	"goapi/internal/api/service"
+	"goapi/internal/api/udp"
 	"io"
 	"log"
 	"net/http"
@@ -53,8 +56,7 @@ func main() {
 	/* Create a database connection using SQLite */
 	db, err := SQLite.NewSqlite("production.db")
 	if err != nil {
-		logger.Println("Error setting up database:", err)
-		return
+		logger.Fatal("Error setting up database: ", err)
 	}
 	defer db.Close()
 	dsType := service.SQLiteDataService
@@ -88,6 +90,43 @@ func main() {
 	// * Create the API server *
 	server := server.NewServer(ctx, sf, logger, dsType)
 
+	// * A transport that is configured but can't be set up or listen exits with an error status, like the HTTP server *
+
+	// * Start the MQTT subscriber if a broker is configured (MQTT_BROKER_URL) *
+	if mqttCfg, ok := mqtt.ConfigFromEnv(); ok {
+		subscriber, err := mqtt.NewSubscriber(mqttCfg, server.DataService(), logger)
+		if err != nil {
+			logger.Fatal("Error setting up MQTT subscriber: ", err)
+		}
+		subscriber.Start(ctx)
+	}
+
+	// * Start the UDP listener for low-power sensors if configured (UDP_ADDR) *
+	if udpCfg, ok := udp.ConfigFromEnv(); ok {
+		udpServer, err := udp.NewServer(udpCfg, server.DataService(), logger)
+		if err != nil {
+			logger.Fatal("Error setting up UDP listener: ", err)
+		}
+		go func() {
+			if err := udpServer.ListenAndServe(ctx); err != nil {
+				logger.Fatal("UDP listener error: ", err)
+			}
+		}()
+	}
+
+	// * Start the CoAP endpoint if configured (COAP_ADDR) *
+	if coapCfg, ok := coap.ConfigFromEnv(); ok {
+		coapServer, err := coap.NewServer(coapCfg, server.DataService(), sf.Hub(), logger)
+		if err != nil {
+			logger.Fatal("Error setting up CoAP server: ", err)
+		}
+		go func() {
+			if err := coapServer.ListenAndServe(ctx); err != nil {
+				logger.Fatal("CoAP server error: ", err)
+			}
+		}()
+	}
+
 	// * Setup graceful shutdown *
 	gracefullShutdown(server, cancel, logger)
 
@@ -96,7 +135,7 @@ func main() {
 	if err := server.ListenAndServe(":" + port); err != nil {
 		// If the server was shutdown gracefully, don't log a startup error
 		if err != http.ErrServerClosed {
-			logger.Println("Server startup error:", err)
+			logger.Fatal("Server startup error: ", err)
 		}
 		logger.Println("Server gracefully shutdown complete.")
 		return
@@ -0,0 +1,82 @@
+// Command backfill computes the hourly and daily rollups and the room baselines of the readings
+// stored before they existed. It can be run again at any time, both are recomputed, not added to.
+//
+// Usage (from backend/cmd/api, next to production.db):
+//
+//	go run ../backfill                 # SQLite production.db
+//	go run ../backfill -db other.db    # another SQLite database
+//	go run ../backfill -postgres       # PostgreSQL at EXTERNAL_DATABASE_URL
+package main
+
+import (
+	"context"
+	"flag"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/DAL/PostgreSQL"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	"log"
+	"os"
+)
+
+func main() {
+	dbFile := flag.String("db", "production.db", "SQLite database file")
+	postgres := flag.Bool("postgres", false, "Use the PostgreSQL database at EXTERNAL_DATABASE_URL instead of SQLite")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	// * Cancelling the context closes the repository *
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var db DAL.SQLDatabase
+	var repo models.DataRepository
+	var err error
+	if *postgres {
+		dbURL := os.Getenv("EXTERNAL_DATABASE_URL")
+		if dbURL == "" {
+			logger.Fatal("EXTERNAL_DATABASE_URL environment variable is not set")
+		}
+		if db, err = PostgreSQL.NewPostgreSQL(dbURL); err != nil {
+			logger.Fatal("Error setting up database: ", err)
+		}
+		repo, err = PostgreSQL.NewDataRepository(dbURL, db, ctx)
+		// * The rooms' time zones are read from the schedules of their locations *
+		if err == nil {
+			_, err = PostgreSQL.NewLocationRepository(dbURL, db, ctx)
+		}
+		if err == nil {
+			_, err = PostgreSQL.NewScheduleRepository(dbURL, db, ctx)
+		}
+	} else {
+		if db, err = SQLite.NewSqlite(*dbFile); err != nil {
+			logger.Fatal("Error setting up database: ", err)
+		}
+		repo, err = SQLite.NewDataRepository(db, ctx)
+		// * The rooms' time zones are read from the schedules of their locations *
+		if err == nil {
+			_, err = SQLite.NewLocationRepository(db, ctx)
+		}
+		if err == nil {
+			_, err = SQLite.NewScheduleRepository(db, ctx)
+		}
+	}
+	if err != nil {
+		logger.Fatal("Error setting up data repository: ", err)
+	}
+	defer db.Close()
+
+	logger.Println("Rebuilding rollups...")
+	n, err := repo.RebuildRollups(ctx)
+	if err != nil {
+		logger.Fatal("Error rebuilding rollups: ", err)
+	}
+	logger.Printf("Rebuilt %d rollups", n)
+
+	logger.Println("Relearning room baselines...")
+	if n, err = repo.RebuildBaselines(ctx); err != nil {
+		logger.Fatal("Error relearning baselines: ", err)
+	}
+	logger.Printf("Relearned %d baselines", n)
+}
@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"errors"
+	"goapi/internal/api/repository/models"
+	"math"
+)
+
+// MinSampleRate is the lowest sample rate the weightings are accurate enough for
+const MinSampleRate = 8000
+
+// blockSeconds is the length of the blocks LAMax and LAMin are taken from, as the fast time weighting
+const blockSeconds = 0.125
+
+// Analyze computes the levels of a clip. fullScaleDB is the level in dB SPL a full scale sine wave represents,
+// which calibrates the recording: a 94 dB calibrator recorded at -26 dBFS means 120.
+func Analyze(clip *Clip, fullScaleDB float64) (*models.AudioAnalysis, error) {
+	if len(clip.Samples) == 0 {
+		return nil, errors.New("the recording has no samples")
+	}
+	if clip.SampleRate < MinSampleRate {
+		return nil, errors.New("the sample rate must be at least 8000 Hz")
+	}
+
+	// * The unweighted levels ignore a DC offset of the recording, the weightings remove it *
+	var mean float64
+	for _, x := range clip.Samples {
+		mean += x
+	}
+	mean /= float64(len(clip.Samples))
+	unweighted := make([]float64, len(clip.Samples))
+	var peak float64
+	for i, x := range clip.Samples {
+		unweighted[i] = x - mean
+		peak = math.Max(peak, math.Abs(x))
+	}
+
+	aWeighted := AWeighting(clip.SampleRate).Apply(clip.Samples)
+	cWeighted := CWeighting(clip.SampleRate).Apply(clip.Samples)
+
+	analysis := &models.AudioAnalysis{
+		SampleRate:    clip.SampleRate,
+		Channels:      clip.Channels,
+		BitsPerSample: clip.BitsPerSample,
+		DurationMs:    int64(math.Round(clip.Duration() * 1000)),
+		FullScaleDB:   fullScaleDB,
+		LAeq:          level(meanSquare(aWeighted), fullScaleDB),
+		LCeq:          level(meanSquare(cWeighted), fullScaleDB),
+		LZeq:          level(meanSquare(unweighted), fullScaleDB),
+		Peak:          level(peak*peak, fullScaleDB), // The peak of a sine is 3 dB above its level
+		Bands:         []models.Band{},
+	}
+
+	// * Loudest and quietest 125 ms, a shorter recording is a single block *
+	block := max(int(blockSeconds*float64(clip.SampleRate)), 1)
+	analysis.LAMax, analysis.LAMin = math.Inf(-1), math.Inf(1)
+	for start := 0; start == 0 || start+block <= len(aWeighted); start += block {
+		l := level(meanSquare(aWeighted[start:min(start+block, len(aWeighted))]), fullScaleDB)
+		analysis.LAMax = math.Max(analysis.LAMax, l)
+		analysis.LAMin = math.Min(analysis.LAMin, l)
+	}
+
+	centers, meanSquares := octaveMeanSquares(unweighted, clip.SampleRate)
+	for i, center := range centers {
+		analysis.Bands = append(analysis.Bands, models.Band{Frequency: center, Level: level(meanSquares[i], fullScaleDB)})
+	}
+	return analysis, nil
+}
+
+func meanSquare(samples []float64) float64 {
+	var sum float64
+	for _, x := range samples {
+		sum += x * x
+	}
+	return sum / float64(len(samples))
+}
+
+// level converts a mean square to dB SPL, rounded to 0.01 dB.
+// A full scale sine has a mean square of 1/2. Silence is 0 dB.
+func level(meanSquare, fullScaleDB float64) float64 {
+	if meanSquare <= 0 {
+		return 0
+	}
+	l := 10*math.Log10(2*meanSquare) + fullScaleDB
+	return math.Max(math.Round(l*100)/100, 0)
+}
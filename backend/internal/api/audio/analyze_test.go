@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns seconds of a sine wave with the given peak amplitude (1 is full scale)
+func sine(freq, amplitude float64, sampleRate int, seconds float64) *Clip {
+	clip := &Clip{SampleRate: sampleRate, Channels: 1, BitsPerSample: 16}
+	for i := 0; i < int(seconds*float64(sampleRate)); i++ {
+		clip.Samples = append(clip.Samples, amplitude*math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+	}
+	return clip
+}
+
+func TestAnalyzeCalibration(t *testing.T) {
+	// * Half of full scale is 6.02 dB below the full scale level *
+	analysis, err := Analyze(sine(1000, 0.5, 48000, 2), 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string]float64{"LAeq": analysis.LAeq, "LCeq": analysis.LCeq, "LZeq": analysis.LZeq, "LAMax": analysis.LAMax, "LAMin": analysis.LAMin} {
+		if math.Abs(got-113.98) > 0.1 {
+			t.Errorf("%s: got %v want 113.98", name, got)
+		}
+	}
+	if math.Abs(analysis.Peak-116.99) > 0.1 {
+		t.Errorf("peak: got %v want 116.99", analysis.Peak)
+	}
+	if analysis.DurationMs != 2000 {
+		t.Errorf("duration: got %v", analysis.DurationMs)
+	}
+}
+
+func TestAnalyzeWeightings(t *testing.T) {
+	// * IEC 61672-1 weightings, with the tolerance the bilinear transform needs at high frequencies *
+	for _, tc := range []struct {
+		freq, a, c, tolerance float64
+	}{
+		{63, -26.2, -0.8, 0.3},
+		{100, -19.1, -0.3, 0.3},
+		{4000, 1.0, -0.8, 0.3},
+		{8000, -1.1, -3.0, 1.5},
+	} {
+		analysis, err := Analyze(sine(tc.freq, 0.5, 48000, 2), 120)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := analysis.LAeq - analysis.LZeq; math.Abs(a-tc.a) > tc.tolerance {
+			t.Errorf("A weighting at %v Hz: got %.2f want %.1f", tc.freq, a, tc.a)
+		}
+		if c := analysis.LCeq - analysis.LZeq; math.Abs(c-tc.c) > tc.tolerance {
+			t.Errorf("C weighting at %v Hz: got %.2f want %.1f", tc.freq, c, tc.c)
+		}
+	}
+}
+
+func TestAnalyzeOctaveBands(t *testing.T) {
+	analysis, err := Analyze(sine(250, 0.5, 16000, 2), 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// * Bands above the Nyquist frequency of 8 kHz are left out *
+	if len(analysis.Bands) != 8 || analysis.Bands[7].Frequency != 4000 {
+		t.Fatalf("unexpected bands: %+v", analysis.Bands)
+	}
+	for _, band := range analysis.Bands {
+		if band.Frequency == 250 && math.Abs(band.Level-113.98) > 0.2 {
+			t.Errorf("250 Hz band: got %v want 113.98", band.Level)
+		}
+		if band.Frequency != 250 && band.Level > 90 {
+			t.Errorf("%v Hz band: got %v, the tone should not leak", band.Frequency, band.Level)
+		}
+	}
+}
+
+func TestAnalyzeInvalid(t *testing.T) {
+	if _, err := Analyze(&Clip{SampleRate: 48000}, 120); err == nil {
+		t.Error("expected an error for an empty clip")
+	}
+	if _, err := Analyze(sine(100, 0.5, 4000, 1), 120); err == nil {
+		t.Error("expected an error for a 4 kHz sample rate")
+	}
+}
@@ -0,0 +1,96 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// OctaveBands are the nominal center frequencies of the 1/1 octave bands, in Hz.
+// The exact centers are 1000 * 2^k.
+var OctaveBands = []float64{31.5, 63, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// maxSegment is the FFT length the spectrum is averaged over
+const maxSegment = 8192
+
+// octaveMeanSquares returns the mean square of the samples in each octave band below the Nyquist frequency,
+// from their power spectrum averaged over Hann windowed segments overlapping by half (Welch's method)
+func octaveMeanSquares(samples []float64, sampleRate int) ([]float64, []float64) {
+	n := maxSegment
+	for n > len(samples) && n > 256 {
+		n /= 2
+	}
+	if len(samples) < n {
+		padded := make([]float64, n)
+		copy(padded, samples)
+		samples = padded
+	}
+
+	window := make([]float64, n)
+	var windowPower float64
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		windowPower += window[i] * window[i]
+	}
+
+	// * One sided power spectrum, scaled so the bins add up to the mean square *
+	power := make([]float64, n/2+1)
+	segments := 0
+	buf := make([]complex128, n)
+	for start := 0; start+n <= len(samples); start += n / 2 {
+		for i := range buf {
+			buf[i] = complex(samples[start+i]*window[i], 0)
+		}
+		fft(buf)
+		for k := range power {
+			p := real(buf[k])*real(buf[k]) + imag(buf[k])*imag(buf[k])
+			if k > 0 && k < n/2 {
+				p *= 2
+			}
+			power[k] += p / (float64(n) * windowPower)
+		}
+		segments++
+	}
+
+	binWidth := float64(sampleRate) / float64(n)
+	var centers, meanSquares []float64
+	for i, nominal := range OctaveBands {
+		center := 1000 * math.Pow(2, float64(i-5))
+		lower, upper := center/math.Sqrt2, center*math.Sqrt2
+		if upper > float64(sampleRate)/2 {
+			break
+		}
+		var sum float64
+		for k := int(math.Ceil(lower / binWidth)); k < len(power) && float64(k)*binWidth < upper; k++ {
+			sum += power[k]
+		}
+		centers = append(centers, nominal)
+		meanSquares = append(meanSquares, sum/float64(segments))
+	}
+	return centers, meanSquares
+}
+
+// fft transforms x in place, len(x) must be a power of 2
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
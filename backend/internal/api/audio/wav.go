@@ -0,0 +1,157 @@
+// Package audio analyzes PCM WAV recordings: weighted equivalent levels, peak and octave bands,
+// computed in pure Go so uploads need no external tools.
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV sample formats
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xFFFE
+)
+
+// ErrNotWAV is returned for data that isn't a RIFF WAVE file
+var ErrNotWAV = errors.New("not a RIFF WAVE file")
+
+// ErrSampleValue is returned for IEEE float samples that are NaN or infinite, they would make every level NaN
+var ErrSampleValue = errors.New("sample is not a finite number")
+
+// Clip is a decoded recording, mixed down to mono
+type Clip struct {
+	SampleRate    int
+	Channels      int // Of the file, Samples is their average
+	BitsPerSample int
+	Samples       []float64 // Full scale is -1 to 1
+}
+
+// Duration returns the length of the recording in seconds
+func (c *Clip) Duration() float64 {
+	if c.SampleRate == 0 {
+		return 0
+	}
+	return float64(len(c.Samples)) / float64(c.SampleRate)
+}
+
+// Decode reads a PCM (8, 16, 24 or 32 bit) or IEEE float (32 or 64 bit) WAV file.
+// Chunks other than fmt and data are skipped.
+func Decode(r io.Reader) (*Clip, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, ErrNotWAV
+	}
+
+	var clip *Clip
+	var format, blockAlign int
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8
+		// * Streaming writers leave the size of the last chunk unset *
+		if size < 0 || pos+size > len(data) {
+			size = len(data) - pos
+		}
+		body := data[pos : pos+size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("fmt chunk too short")
+			}
+			format = int(binary.LittleEndian.Uint16(body[0:2]))
+			clip = &Clip{
+				Channels:      int(binary.LittleEndian.Uint16(body[2:4])),
+				SampleRate:    int(binary.LittleEndian.Uint32(body[4:8])),
+				BitsPerSample: int(binary.LittleEndian.Uint16(body[14:16])),
+			}
+			blockAlign = int(binary.LittleEndian.Uint16(body[12:14]))
+			if format == formatExtensible && size >= 26 {
+				// * The actual format is the start of the sub format GUID *
+				format = int(binary.LittleEndian.Uint16(body[24:26]))
+			}
+			if err := checkFormat(format, clip, blockAlign); err != nil {
+				return nil, err
+			}
+		case "data":
+			if clip == nil {
+				return nil, fmt.Errorf("data chunk before fmt chunk")
+			}
+			samples, err := decodeSamples(body, format, clip, blockAlign)
+			if err != nil {
+				return nil, err
+			}
+			clip.Samples = samples
+			return clip, nil
+		}
+
+		// * Chunks are padded to an even size *
+		pos += size + size%2
+	}
+	return nil, fmt.Errorf("no data chunk")
+}
+
+// checkFormat reports whether the samples can be decoded
+func checkFormat(format int, clip *Clip, blockAlign int) error {
+	switch {
+	case format == formatPCM && (clip.BitsPerSample == 8 || clip.BitsPerSample == 16 || clip.BitsPerSample == 24 || clip.BitsPerSample == 32):
+	case format == formatFloat && (clip.BitsPerSample == 32 || clip.BitsPerSample == 64):
+	default:
+		return fmt.Errorf("unsupported WAV format %d with %d bits per sample", format, clip.BitsPerSample)
+	}
+	if clip.Channels < 1 || clip.SampleRate < 1 {
+		return fmt.Errorf("invalid WAV with %d channels at %d Hz", clip.Channels, clip.SampleRate)
+	}
+	if blockAlign < clip.Channels*clip.BitsPerSample/8 {
+		return fmt.Errorf("invalid WAV block size %d", blockAlign)
+	}
+	return nil
+}
+
+// decodeSamples converts the frames of a data chunk to mono samples, a trailing partial frame is dropped
+func decodeSamples(body []byte, format int, clip *Clip, blockAlign int) ([]float64, error) {
+	width := clip.BitsPerSample / 8
+	samples := make([]float64, 0, len(body)/blockAlign)
+	for frame := 0; frame+blockAlign <= len(body); frame += blockAlign {
+		var sum float64
+		for ch := 0; ch < clip.Channels; ch++ {
+			b := body[frame+ch*width : frame+(ch+1)*width]
+			sum += decodeSample(b, format)
+		}
+		// * Only float samples can be NaN or infinite, the sum of a frame is if any of them is *
+		if math.IsNaN(sum) || math.IsInf(sum, 0) {
+			return nil, ErrSampleValue
+		}
+		samples = append(samples, sum/float64(clip.Channels))
+	}
+	return samples, nil
+}
+
+func decodeSample(b []byte, format int) float64 {
+	if format == formatFloat {
+		if len(b) == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	switch len(b) {
+	case 1:
+		// * 8 bit samples are unsigned *
+		return (float64(b[0]) - 128) / 128
+	case 2:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case 3:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
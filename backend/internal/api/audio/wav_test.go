@@ -0,0 +1,115 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+)
+
+// encodeWAV writes 16 bit PCM frames as a WAV file with a JUNK chunk before fmt, like many recorders do
+func encodeWAV(sampleRate, channels int, frames [][]float64) []byte {
+	var data bytes.Buffer
+	for _, frame := range frames {
+		for _, x := range frame {
+			binary.Write(&data, binary.LittleEndian, int16(math.Round(x*32767)))
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(4+8+3+1+8+16+8+data.Len()))
+	b.WriteString("WAVE")
+	b.WriteString("JUNK")
+	binary.Write(&b, binary.LittleEndian, uint32(3))
+	b.Write([]byte{0, 0, 0, 0}) // Odd chunk and its padding byte
+	b.WriteString("fmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	binary.Write(&b, binary.LittleEndian, uint16(formatPCM))
+	binary.Write(&b, binary.LittleEndian, uint16(channels))
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate*channels*2))
+	binary.Write(&b, binary.LittleEndian, uint16(channels*2))
+	binary.Write(&b, binary.LittleEndian, uint16(16))
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(data.Len()))
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+// encodeFloatWAV writes 32 bit IEEE float mono samples as a WAV file
+func encodeFloatWAV(sampleRate int, samples []float32) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(4+8+16+8+4*len(samples)))
+	b.WriteString("WAVE")
+	b.WriteString("fmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	binary.Write(&b, binary.LittleEndian, uint16(formatFloat))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate*4))
+	binary.Write(&b, binary.LittleEndian, uint16(4))
+	binary.Write(&b, binary.LittleEndian, uint16(32))
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(4*len(samples)))
+	binary.Write(&b, binary.LittleEndian, samples)
+	return b.Bytes()
+}
+
+func TestDecodeStereoPCM(t *testing.T) {
+	wav := encodeWAV(8000, 2, [][]float64{{0.5, 0.5}, {-0.5, 0.5}, {1, 1}})
+
+	clip, err := Decode(bytes.NewReader(wav))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clip.SampleRate != 8000 || clip.Channels != 2 || clip.BitsPerSample != 16 || len(clip.Samples) != 3 {
+		t.Fatalf("unexpected clip: %+v", clip)
+	}
+	// * Channels are averaged *
+	if math.Abs(clip.Samples[0]-0.5) > 1e-4 || math.Abs(clip.Samples[1]) > 1e-4 || math.Abs(clip.Samples[2]-1) > 1e-4 {
+		t.Errorf("unexpected samples: %v", clip.Samples)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte(`{"sound_level": 60}`))); err != ErrNotWAV {
+		t.Errorf("expected ErrNotWAV, got %v", err)
+	}
+
+	// * Only the RIFF header and the fmt chunk *
+	wav := encodeWAV(8000, 1, nil)
+	if _, err := Decode(bytes.NewReader(wav[:len(wav)-8])); err == nil {
+		t.Error("expected an error without data chunk")
+	}
+}
+
+func TestDecodeNonFiniteFloat(t *testing.T) {
+	clip, err := Decode(bytes.NewReader(encodeFloatWAV(8000, []float32{0.25, -0.5})))
+	if err != nil || len(clip.Samples) != 2 || clip.Samples[1] != -0.5 {
+		t.Fatalf("unexpected float clip: %+v, %v", clip, err)
+	}
+	for _, x := range []float32{float32(math.NaN()), float32(math.Inf(1)), float32(math.Inf(-1))} {
+		if _, err := Decode(bytes.NewReader(encodeFloatWAV(8000, []float32{0.25, x}))); err != ErrSampleValue {
+			t.Errorf("sample %v: expected ErrSampleValue, got %v", x, err)
+		}
+	}
+}
+
+func TestDecodeShippedAlert(t *testing.T) {
+	f, err := os.Open("../../../../build/alert.wav")
+	if err != nil {
+		t.Skip("build/alert.wav not found")
+	}
+	defer f.Close()
+
+	clip, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clip.SampleRate != 48000 || clip.Channels != 2 || clip.Duration() < 1 {
+		t.Errorf("unexpected clip: %d Hz, %d channels, %.2f s", clip.SampleRate, clip.Channels, clip.Duration())
+	}
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Pole frequencies of the IEC 61672-1 frequency weightings, in Hz
+const (
+	poleF1 = 20.598997
+	poleF2 = 107.65265
+	poleF3 = 737.86223
+	poleF4 = 12194.217
+)
+
+// Filter is a frequency weighting as a cascade of first order sections,
+// the analog weighting mapped to the sample rate with the bilinear transform
+type Filter struct {
+	zeros []float64
+	poles []float64
+	gain  float64
+}
+
+// AWeighting returns the A weighting filter for the sample rate, 0 dB at 1 kHz
+func AWeighting(sampleRate int) *Filter {
+	fs := float64(sampleRate)
+	f := &Filter{
+		zeros: []float64{1, 1, 1, 1, -1, -1},
+		poles: []float64{pole(poleF1, fs), pole(poleF1, fs), pole(poleF2, fs), pole(poleF3, fs), pole(poleF4, fs), pole(poleF4, fs)},
+	}
+	return f.normalize(fs)
+}
+
+// CWeighting returns the C weighting filter for the sample rate, 0 dB at 1 kHz
+func CWeighting(sampleRate int) *Filter {
+	fs := float64(sampleRate)
+	f := &Filter{
+		zeros: []float64{1, 1, -1, -1},
+		poles: []float64{pole(poleF1, fs), pole(poleF1, fs), pole(poleF4, fs), pole(poleF4, fs)},
+	}
+	return f.normalize(fs)
+}
+
+// pole maps the analog pole at -2*pi*f to the z plane
+func pole(f, fs float64) float64 {
+	w := 2 * math.Pi * f
+	return (2*fs - w) / (2*fs + w)
+}
+
+// Response returns the gain of the filter at frequency f
+func (f *Filter) Response(freq float64, sampleRate int) float64 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*freq/float64(sampleRate))) // z^-1
+	h := complex(f.gain, 0)
+	for i := range f.zeros {
+		h *= (1 - complex(f.zeros[i], 0)*z) / (1 - complex(f.poles[i], 0)*z)
+	}
+	return cmplx.Abs(h)
+}
+
+// normalize sets the gain so the filter is 0 dB at 1 kHz
+func (f *Filter) normalize(fs float64) *Filter {
+	f.gain = 1
+	f.gain = 1 / f.Response(1000, int(fs))
+	return f
+}
+
+// Apply returns the weighted samples
+func (f *Filter) Apply(samples []float64) []float64 {
+	out := make([]float64, len(samples))
+	for i, x := range samples {
+		out[i] = x * f.gain
+	}
+	for k := range f.zeros {
+		zero, pole := f.zeros[k], f.poles[k]
+		var prevIn, prevOut float64
+		for i, x := range out {
+			y := x - zero*prevIn + pole*prevOut
+			prevIn, prevOut = x, y
+			out[i] = y
+		}
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package coap
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// TagSize is the length of the Auth option
+const TagSize = 16
+
+// Sign sets the Auth option of a request to the first 16 bytes of HMAC-SHA256(key, ...) of
+// its code, its options but Auth in order of their number, each as number, length and value,
+// and its payload. The message ID and token are not signed, they change on every request.
+func Sign(msg *Message, key []byte) {
+	var options []Option
+	for _, opt := range msg.Options {
+		if opt.ID != Auth {
+			options = append(options, opt)
+		}
+	}
+	msg.Options = append(options, Option{ID: Auth, Value: tag(msg, key)})
+}
+
+// verify reports whether the request carries a valid Auth option
+func verify(msg *Message, key []byte) bool {
+	value, ok := msg.Option(Auth)
+	return ok && hmac.Equal(value, tag(msg, key))
+}
+
+func tag(msg *Message, key []byte) []byte {
+	options := append([]Option(nil), msg.Options...)
+	sort.SliceStable(options, func(i, j int) bool { return options[i].ID < options[j].ID })
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte{byte(msg.Code)})
+	for _, opt := range options {
+		if opt.ID == Auth {
+			continue
+		}
+		mac.Write(binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, uint16(opt.ID)), uint16(len(opt.Value))))
+		mac.Write(opt.Value)
+	}
+	mac.Write(msg.Payload)
+	return mac.Sum(nil)[:TagSize]
+}
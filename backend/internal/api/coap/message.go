@@ -0,0 +1,274 @@
+// Package coap exposes the /data resource over CoAP (RFC 7252),
+// with Observe (RFC 7641) on the latest reading of a device.
+// Only the parts of the protocol the sensors and displays use are implemented:
+// piggybacked responses, no block-wise transfer and no DTLS.
+// Requests are authenticated with an HMAC tag in the Auth option instead, see Sign.
+package coap
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Type is the message type
+type Type uint8
+
+const (
+	Confirmable     Type = 0
+	NonConfirmable  Type = 1
+	Acknowledgement Type = 2
+	Reset           Type = 3
+)
+
+// Code is the request method or response code, class.detail packed as class<<5 | detail
+type Code uint8
+
+const (
+	Empty Code = 0
+
+	GET    Code = 0<<5 | 1
+	POST   Code = 0<<5 | 2
+	PUT    Code = 0<<5 | 3
+	DELETE Code = 0<<5 | 4
+
+	Created    Code = 2<<5 | 1
+	Deleted    Code = 2<<5 | 2
+	Changed    Code = 2<<5 | 4
+	Content    Code = 2<<5 | 5
+	BadRequest Code = 4<<5 | 0
+
+	Unauthorized        Code = 4<<5 | 1
+	NotFound            Code = 4<<5 | 4
+	MethodNotAllowed    Code = 4<<5 | 5
+	RequestEntityTooBig Code = 4<<5 | 13
+	UnsupportedFormat   Code = 4<<5 | 15
+	InternalServerError Code = 5<<5 | 0
+	ServiceUnavailable  Code = 5<<5 | 3
+)
+
+// IsRequest reports whether the code is a request method
+func (c Code) IsRequest() bool {
+	return c>>5 == 0 && c != Empty
+}
+
+// OptionID is a CoAP option number
+type OptionID uint16
+
+const (
+	Observe       OptionID = 6
+	URIPath       OptionID = 11
+	ContentFormat OptionID = 12
+	URIQuery      OptionID = 15
+	// Auth carries the tag of a request (see Sign), the number is in the experimental range
+	// and odd, i.e. critical, so a server that doesn't know it rejects the request
+	Auth OptionID = 65001
+)
+
+// AppJSON is the Content-Format of application/json
+const AppJSON = 50
+
+// Option is a single option, repeatable options appear once per value
+type Option struct {
+	ID    OptionID
+	Value []byte
+}
+
+// Message is a CoAP message
+type Message struct {
+	Type      Type
+	Code      Code
+	MessageID uint16
+	Token     []byte
+	Options   []Option
+	Payload   []byte
+}
+
+var (
+	ErrMessageFormat = errors.New("coap: message format error")
+	ErrVersion       = errors.New("coap: unsupported version")
+)
+
+const payloadMarker = 0xFF
+
+// Marshal encodes the message
+func (m *Message) Marshal() ([]byte, error) {
+	if len(m.Token) > 8 {
+		return nil, ErrMessageFormat
+	}
+
+	b := []byte{1<<6 | byte(m.Type)<<4 | byte(len(m.Token)), byte(m.Code), 0, 0}
+	binary.BigEndian.PutUint16(b[2:4], m.MessageID)
+	b = append(b, m.Token...)
+
+	// * Options are encoded in order of their number, as deltas from the previous one *
+	options := append([]Option(nil), m.Options...)
+	sort.SliceStable(options, func(i, j int) bool { return options[i].ID < options[j].ID })
+
+	var previous OptionID
+	for _, opt := range options {
+		delta, deltaExt := splitOptionNibble(int(opt.ID - previous))
+		length, lengthExt := splitOptionNibble(len(opt.Value))
+		b = append(b, delta<<4|length)
+		b = append(b, deltaExt...)
+		b = append(b, lengthExt...)
+		b = append(b, opt.Value...)
+		previous = opt.ID
+	}
+
+	if len(m.Payload) > 0 {
+		b = append(b, payloadMarker)
+		b = append(b, m.Payload...)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes a message
+func Unmarshal(b []byte) (*Message, error) {
+	if len(b) < 4 {
+		return nil, ErrMessageFormat
+	}
+	if b[0]>>6 != 1 {
+		return nil, ErrVersion
+	}
+
+	tokenLength := int(b[0] & 0x0F)
+	if tokenLength > 8 || len(b) < 4+tokenLength {
+		return nil, ErrMessageFormat
+	}
+
+	m := &Message{
+		Type:      Type(b[0] >> 4 & 0x03),
+		Code:      Code(b[1]),
+		MessageID: binary.BigEndian.Uint16(b[2:4]),
+		Token:     append([]byte(nil), b[4:4+tokenLength]...),
+	}
+
+	b = b[4+tokenLength:]
+	var previous int
+	for len(b) > 0 {
+		if b[0] == payloadMarker {
+			if len(b) == 1 {
+				// * A marker followed by an empty payload is a format error *
+				return nil, ErrMessageFormat
+			}
+			m.Payload = append([]byte(nil), b[1:]...)
+			break
+		}
+
+		delta, rest, err := readOptionNibble(int(b[0]>>4), b[1:])
+		if err != nil {
+			return nil, err
+		}
+		length, rest, err := readOptionNibble(int(b[0]&0x0F), rest)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) < length {
+			return nil, ErrMessageFormat
+		}
+
+		previous += delta
+		m.Options = append(m.Options, Option{ID: OptionID(previous), Value: append([]byte(nil), rest[:length]...)})
+		b = rest[length:]
+	}
+
+	return m, nil
+}
+
+// splitOptionNibble returns the 4 bit value and the extended bytes of an option delta or length
+func splitOptionNibble(v int) (byte, []byte) {
+	switch {
+	case v < 13:
+		return byte(v), nil
+	case v < 269:
+		return 13, []byte{byte(v - 13)}
+	default:
+		ext := make([]byte, 2)
+		binary.BigEndian.PutUint16(ext, uint16(v-269))
+		return 14, ext
+	}
+}
+
+func readOptionNibble(nibble int, b []byte) (int, []byte, error) {
+	switch nibble {
+	case 13:
+		if len(b) < 1 {
+			return 0, nil, ErrMessageFormat
+		}
+		return int(b[0]) + 13, b[1:], nil
+	case 14:
+		if len(b) < 2 {
+			return 0, nil, ErrMessageFormat
+		}
+		return int(binary.BigEndian.Uint16(b[:2])) + 269, b[2:], nil
+	case 15:
+		// * 15 is reserved for the payload marker *
+		return 0, nil, ErrMessageFormat
+	default:
+		return nibble, b, nil
+	}
+}
+
+// Option returns the first value of an option
+func (m *Message) Option(id OptionID) ([]byte, bool) {
+	for _, opt := range m.Options {
+		if opt.ID == id {
+			return opt.Value, true
+		}
+	}
+	return nil, false
+}
+
+// UintOption returns the first value of an option as an unsigned integer
+func (m *Message) UintOption(id OptionID) (uint32, bool) {
+	value, ok := m.Option(id)
+	if !ok || len(value) > 4 {
+		return 0, false
+	}
+	var v uint32
+	for _, b := range value {
+		v = v<<8 | uint32(b)
+	}
+	return v, true
+}
+
+// AddUintOption adds an option with the shortest encoding of v
+func (m *Message) AddUintOption(id OptionID, v uint32) {
+	var value []byte
+	for v > 0 {
+		value = append([]byte{byte(v)}, value...)
+		v >>= 8
+	}
+	m.Options = append(m.Options, Option{ID: id, Value: value})
+}
+
+// Path returns the Uri-Path options joined with '/'
+func (m *Message) Path() string {
+	return "/" + strings.Join(m.values(URIPath), "/")
+}
+
+// SetPath adds a Uri-Path option for each segment of path
+func (m *Message) SetPath(path string) {
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment != "" {
+			m.Options = append(m.Options, Option{ID: URIPath, Value: []byte(segment)})
+		}
+	}
+}
+
+// Queries returns the Uri-Query options
+func (m *Message) Queries() []string {
+	return m.values(URIQuery)
+}
+
+func (m *Message) values(id OptionID) []string {
+	var values []string
+	for _, opt := range m.Options {
+		if opt.ID == id {
+			values = append(values, string(opt.Value))
+		}
+	}
+	return values
+}
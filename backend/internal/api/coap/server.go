@@ -0,0 +1,406 @@
+package coap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/pubsub"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// exchangeLifetime is how long a confirmable request can be retransmitted (RFC 7252, 4.8.2)
+	exchangeLifetime = 247 * time.Second
+	maxObservers     = 256
+	maxMessageSize   = 1152
+)
+
+// Config is where the server listens and the key requests are signed with
+type Config struct {
+	Addr string
+	Key  []byte
+}
+
+// ConfigFromEnv reads COAP_ADDR (e.g. :5683) and COAP_HMAC_KEY.
+// ok is false when the address is not set, in which case CoAP stays disabled.
+func ConfigFromEnv() (cfg Config, ok bool) {
+	cfg = Config{
+		Addr: os.Getenv("COAP_ADDR"),
+		Key:  []byte(os.Getenv("COAP_HMAC_KEY")),
+	}
+	return cfg, cfg.Addr != ""
+}
+
+// Server translates CoAP requests to the HTTP handlers of the /data resource
+type Server struct {
+	cfg     Config
+	handler http.Handler
+	hub     *pubsub.Hub
+	logger  *log.Logger
+
+	conn      net.PacketConn
+	mu        sync.Mutex
+	messageID uint16
+	observers map[string]*observer // By client address and token
+	exchanges map[string]exchange  // Responses to recent confirmable requests, by client address and message ID
+}
+
+type observer struct {
+	key       string
+	addr      net.Addr
+	token     []byte
+	sub       *pubsub.Subscription
+	messageID uint16 // Of the last notification, a Reset to it cancels the observation
+}
+
+type exchange struct {
+	response []byte
+	expires  time.Time
+}
+
+// NewServer creates a CoAP server storing readings through ds.
+// Stored latest readings are taken from hub for observers.
+func NewServer(cfg Config, ds service.DataService, hub *pubsub.Hub, logger *log.Logger) (*Server, error) {
+	if len(cfg.Key) < 16 {
+		return nil, fmt.Errorf("coap: the HMAC key must be at least 16 bytes")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /data", func(w http.ResponseWriter, r *http.Request) {
+		data.PostHandler(w, r, logger, ds)
+	})
+	mux.HandleFunc("GET /data/{id}", func(w http.ResponseWriter, r *http.Request) {
+		data.GetByIDHandler(w, r, logger, ds)
+	})
+
+	return &Server{
+		cfg:       cfg,
+		handler:   mux,
+		hub:       hub,
+		logger:    logger,
+		messageID: uint16(time.Now().UnixNano()),
+		observers: make(map[string]*observer),
+		exchanges: make(map[string]exchange),
+	}, nil
+}
+
+// ListenAndServe listens on the configured address until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Println("Listening for CoAP requests on " + conn.LocalAddr().String())
+	return s.Serve(ctx, conn)
+}
+
+// Serve handles messages from conn until ctx is cancelled, conn is closed when Serve returns
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	s.conn = conn
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		for _, o := range s.observers {
+			s.removeObserver(o)
+		}
+		s.mu.Unlock()
+	}()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.logger.Println("Error reading CoAP message:", err)
+			continue
+		}
+
+		msg, err := Unmarshal(buf[:n])
+		if err != nil {
+			// * Messages we can't parse are silently ignored (RFC 7252, 4.2) *
+			continue
+		}
+		s.handle(ctx, msg, addr)
+	}
+}
+
+func (s *Server) handle(ctx context.Context, msg *Message, addr net.Addr) {
+	switch {
+	case msg.Type == Reset:
+		s.cancelByReset(addr, msg.MessageID)
+		return
+	case msg.Type == Acknowledgement:
+		return
+	case msg.Code == Empty:
+		// * An empty confirmable message is a ping, it is answered with a Reset *
+		if msg.Type == Confirmable {
+			s.send(&Message{Type: Reset, MessageID: msg.MessageID}, addr)
+		}
+		return
+	case !msg.Code.IsRequest():
+		return
+	}
+
+	exchangeKey := addr.String() + "|" + strconv.Itoa(int(msg.MessageID))
+	if msg.Type == Confirmable {
+		if response, ok := s.recentResponse(exchangeKey); ok {
+			// * A retransmission, the client didn't get our response *
+			s.conn.WriteTo(response, addr)
+			return
+		}
+	}
+
+	response := s.serveRequest(ctx, msg, addr)
+	if msg.Type == Confirmable {
+		response.Type = Acknowledgement
+		response.MessageID = msg.MessageID
+	} else {
+		response.Type = NonConfirmable
+		response.MessageID = s.nextMessageID()
+	}
+	response.Token = msg.Token
+
+	b := s.send(response, addr)
+	if b != nil && msg.Type == Confirmable {
+		s.mu.Lock()
+		s.exchanges[exchangeKey] = exchange{response: b, expires: time.Now().Add(exchangeLifetime)}
+		s.mu.Unlock()
+	}
+}
+
+// serveRequest runs the request through the HTTP handlers and registers observers
+func (s *Server) serveRequest(ctx context.Context, msg *Message, addr net.Addr) *Message {
+	if !verify(msg, s.cfg.Key) {
+		return &Message{Code: Unauthorized}
+	}
+
+	var method string
+	switch msg.Code {
+	case GET:
+		method = http.MethodGet
+	case POST:
+		method = http.MethodPost
+		if format, ok := msg.UintOption(ContentFormat); ok && format != AppJSON {
+			return &Message{Code: UnsupportedFormat}
+		}
+	default:
+		return &Message{Code: MethodNotAllowed}
+	}
+
+	target := url.URL{Path: msg.Path(), RawQuery: strings.Join(msg.Queries(), "&")}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(msg.Payload))
+	if err != nil {
+		return &Message{Code: BadRequest}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := &responseRecorder{header: make(http.Header), code: http.StatusOK}
+	s.handler.ServeHTTP(rec, req)
+
+	response := &Message{Code: codeFromStatus(rec.code), Payload: bytes.TrimSpace(rec.body.Bytes())}
+	if len(response.Payload) > 0 && strings.HasPrefix(strings.TrimSpace(string(response.Payload)), "{") {
+		response.AddUintOption(ContentFormat, AppJSON)
+	}
+
+	// * Observe: 0 registers, 1 deregisters (RFC 7641, 2) *
+	if observe, ok := msg.UintOption(Observe); ok && msg.Code == GET {
+		key := addr.String() + "|" + string(msg.Token)
+		segments := msg.values(URIPath)
+		switch {
+		case observe == 0 && response.Code == Content && len(segments) == 2 && segments[0] == "data":
+			if s.addObserver(key, addr, msg.Token, segments[1]) {
+				response.AddUintOption(Observe, 0)
+			}
+		case observe == 1:
+			s.cancel(key)
+		}
+	}
+
+	return response
+}
+
+// addObserver subscribes to the latest readings of deviceID for a client
+func (s *Server) addObserver(key string, addr net.Addr, token []byte, deviceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.observers[key]; ok {
+		s.removeObserver(existing)
+	}
+	if len(s.observers) >= maxObservers {
+		return false
+	}
+
+	sub, _ := s.hub.Subscribe(0, func(d *models.Data) bool {
+		return !d.IsPeriodic && d.DeviceID == deviceID
+	})
+	o := &observer{key: key, addr: addr, token: token, sub: sub}
+	s.observers[key] = o
+
+	go s.notify(o)
+	return true
+}
+
+// notify sends a notification for each reading until the observation is cancelled
+func (s *Server) notify(o *observer) {
+	var seq uint32
+	for event := range o.sub.C {
+		payload, err := json.Marshal(event.Data)
+		if err != nil {
+			continue
+		}
+
+		// * The sequence number is 24 bits and only used for ordering, so it may wrap *
+		seq = (seq + 1) & 0xFFFFFF
+		msg := &Message{
+			Type:      NonConfirmable,
+			Code:      Content,
+			MessageID: s.nextMessageID(),
+			Token:     o.token,
+			Payload:   payload,
+		}
+		msg.AddUintOption(Observe, seq)
+		msg.AddUintOption(ContentFormat, AppJSON)
+
+		s.mu.Lock()
+		o.messageID = msg.MessageID
+		s.mu.Unlock()
+
+		s.send(msg, o.addr)
+	}
+}
+
+func (s *Server) cancel(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o, ok := s.observers[key]; ok {
+		s.removeObserver(o)
+	}
+}
+
+// cancelByReset cancels the observation a client rejected a notification of
+func (s *Server) cancelByReset(addr net.Addr, messageID uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.observers {
+		if o.addr.String() == addr.String() && o.messageID == messageID {
+			s.removeObserver(o)
+		}
+	}
+}
+
+// removeObserver must be called with s.mu held
+func (s *Server) removeObserver(o *observer) {
+	delete(s.observers, o.key)
+	o.sub.Close()
+}
+
+func (s *Server) recentResponse(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range s.exchanges {
+		if now.After(e.expires) {
+			delete(s.exchanges, k)
+		}
+	}
+	e, ok := s.exchanges[key]
+	return e.response, ok
+}
+
+func (s *Server) nextMessageID() uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messageID++
+	return s.messageID
+}
+
+func (s *Server) send(msg *Message, addr net.Addr) []byte {
+	b, err := msg.Marshal()
+	if err != nil {
+		s.logger.Println("Error encoding CoAP message:", err)
+		return nil
+	}
+	if _, err := s.conn.WriteTo(b, addr); err != nil {
+		s.logger.Println("Error sending CoAP message:", err)
+	}
+	return b
+}
+
+// codeFromStatus maps the HTTP status of a handler to a CoAP response code
+func codeFromStatus(status int) Code {
+	switch status {
+	case http.StatusOK:
+		return Content
+	case http.StatusCreated:
+		return Created
+	case http.StatusNoContent:
+		return Deleted
+	case http.StatusBadRequest:
+		return BadRequest
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return RequestEntityTooBig
+	case http.StatusUnsupportedMediaType:
+		return UnsupportedFormat
+	case http.StatusServiceUnavailable:
+		return ServiceUnavailable
+	default:
+		if status >= 200 && status < 300 {
+			return Changed
+		}
+		if status >= 400 && status < 500 {
+			return BadRequest
+		}
+		return InternalServerError
+	}
+}
+
+// responseRecorder captures the response of an HTTP handler
+type responseRecorder struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code = code
+		r.wroteHeader = true
+	}
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
@@ -0,0 +1,235 @@
+package coap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"goapi/internal/api/pubsub"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := &Message{Type: Confirmable, Code: GET, MessageID: 0x1234, Token: []byte{1, 2, 3}}
+	msg.SetPath("/data/a_rather_long_device_identifier")
+	msg.AddUintOption(Observe, 0)
+	msg.Options = append(msg.Options, Option{ID: 300, Value: []byte("extended delta")})
+	msg.Payload = []byte(`{"sound_level": 60}`)
+
+	b, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Type != Confirmable || got.Code != GET || got.MessageID != 0x1234 || !bytes.Equal(got.Token, msg.Token) {
+		t.Errorf("unexpected header: %+v", got)
+	}
+	if got.Path() != "/data/a_rather_long_device_identifier" {
+		t.Errorf("unexpected path %q", got.Path())
+	}
+	if observe, ok := got.UintOption(Observe); !ok || observe != 0 {
+		t.Errorf("unexpected observe option %d %v", observe, ok)
+	}
+	if value, ok := got.Option(300); !ok || string(value) != "extended delta" {
+		t.Errorf("unexpected option 300 %q", value)
+	}
+	if string(got.Payload) != `{"sound_level": 60}` {
+		t.Errorf("unexpected payload %q", got.Payload)
+	}
+
+	if _, err := Unmarshal([]byte{0x40, 0x01, 0x00}); err != ErrMessageFormat {
+		t.Errorf("short message: expected %v, got %v", ErrMessageFormat, err)
+	}
+}
+
+// * countingDataService counts stored readings *
+type countingDataService struct {
+	service.MockDataServiceSuccessful
+	mu      sync.Mutex
+	created int
+}
+
+func (c *countingDataService) CreateLatest(d *models.Data, ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.created++
+	return nil
+}
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (c *testClient) send(msg *Message) {
+	c.t.Helper()
+	b, err := msg.Marshal()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) receive() *Message {
+	c.t.Helper()
+	buf := make([]byte, maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := c.conn.Read(buf)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	msg, err := Unmarshal(buf[:n])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+func startServer(t *testing.T, ds service.DataService, hub *pubsub.Hub) *testClient {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv, err := NewServer(Config{Key: testKey}, ds, hub, log.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ctx, conn)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return &testClient{t: t, conn: client}
+}
+
+func TestPostReading(t *testing.T) {
+	ds := &countingDataService{}
+	client := startServer(t, ds, pubsub.NewHub(10))
+
+	post := &Message{Type: Confirmable, Code: POST, MessageID: 7, Token: []byte{0xAB}, Payload: []byte(`{"device_id": "arduino_001", "sound_level": 60}`)}
+	post.SetPath("data")
+	post.AddUintOption(ContentFormat, AppJSON)
+	Sign(post, testKey)
+	client.send(post)
+
+	ack := client.receive()
+	if ack.Type != Acknowledgement || ack.MessageID != 7 || ack.Code != Created || !bytes.Equal(ack.Token, []byte{0xAB}) {
+		t.Errorf("unexpected response: %+v", ack)
+	}
+
+	// * A retransmission gets the same response without storing the reading again *
+	client.send(post)
+	if again := client.receive(); again.Code != Created || again.MessageID != 7 {
+		t.Errorf("unexpected response to retransmission: %+v", again)
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.created != 1 {
+		t.Errorf("expected 1 stored reading, got %d", ds.created)
+	}
+}
+
+func TestPostUnauthenticated(t *testing.T) {
+	ds := &countingDataService{}
+	client := startServer(t, ds, pubsub.NewHub(10))
+
+	unsigned := &Message{Type: Confirmable, Code: POST, MessageID: 10, Payload: []byte(`{"device_id": "arduino_001", "sound_level": 60}`)}
+	unsigned.SetPath("data")
+
+	wrongKey := &Message{Type: Confirmable, Code: POST, MessageID: 11, Payload: unsigned.Payload}
+	wrongKey.SetPath("data")
+	Sign(wrongKey, []byte("another key 1234"))
+
+	// * The payload is changed after signing *
+	tampered := &Message{Type: Confirmable, Code: POST, MessageID: 12, Payload: unsigned.Payload}
+	tampered.SetPath("data")
+	Sign(tampered, testKey)
+	tampered.Payload = []byte(`{"device_id": "arduino_001", "sound_level": 120}`)
+
+	for _, msg := range []*Message{unsigned, wrongKey, tampered} {
+		client.send(msg)
+		if response := client.receive(); response.Code != Unauthorized || response.MessageID != msg.MessageID {
+			t.Errorf("message %d: unexpected response: %+v", msg.MessageID, response)
+		}
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.created != 0 {
+		t.Errorf("expected no stored readings, got %d", ds.created)
+	}
+}
+
+func TestPostUnsupportedFormat(t *testing.T) {
+	client := startServer(t, &countingDataService{}, pubsub.NewHub(10))
+
+	post := &Message{Type: NonConfirmable, Code: POST, MessageID: 8, Payload: []byte("60")}
+	post.SetPath("data")
+	post.AddUintOption(ContentFormat, 0)
+	Sign(post, testKey)
+	client.send(post)
+
+	if response := client.receive(); response.Code != UnsupportedFormat || response.Type != NonConfirmable {
+		t.Errorf("unexpected response: %+v", response)
+	}
+}
+
+func TestObserveLatestReading(t *testing.T) {
+	hub := pubsub.NewHub(10)
+	client := startServer(t, &service.MockDataServiceSuccessful{}, hub)
+
+	get := &Message{Type: Confirmable, Code: GET, MessageID: 9, Token: []byte{0x01, 0x02}}
+	get.SetPath("data/arduino_001")
+	get.AddUintOption(Observe, 0)
+	Sign(get, testKey)
+	client.send(get)
+
+	response := client.receive()
+	if response.Code != Content {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	if _, ok := response.UintOption(Observe); !ok {
+		t.Fatal("observation was not established")
+	}
+
+	// * Readings of other devices are not notified *
+	hub.Publish(&models.Data{DeviceID: "arduino_002", SoundLevel: 50})
+	hub.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 72.5})
+
+	notification := client.receive()
+	if seq, _ := notification.UintOption(Observe); seq != 1 || !bytes.Equal(notification.Token, get.Token) {
+		t.Errorf("unexpected notification: %+v", notification)
+	}
+	var data models.Data
+	if err := json.Unmarshal(notification.Payload, &data); err != nil || data.SoundLevel != 72.5 {
+		t.Errorf("unexpected notification payload %s", notification.Payload)
+	}
+
+	// * A Reset to a notification cancels the observation *
+	client.send(&Message{Type: Reset, MessageID: notification.MessageID})
+	time.Sleep(50 * time.Millisecond)
+	hub.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 73})
+
+	client.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := client.conn.Read(make([]byte, maxMessageSize)); err == nil {
+		t.Error("expected no notification after Reset")
+	}
+}
@@ -0,0 +1,158 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultLimit is how many incidents are returned without a limit
+const DefaultLimit = 50
+
+// Response is who responds to an incident, by defaults to the user of the request
+type Response struct {
+	By   string `json:"by"`
+	Note string `json:"note"`
+}
+
+// GetAlertsHandler returns the newest alert incidents (limit, default 50), in one state (open, acknowledged or resolved) and room if set
+// Example: curl -X GET "http://localhost:8080/api/alerts?state=open&room=Room1" -u admin:password
+func GetAlertsHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, is service.IncidentService) {
+	query := r.URL.Query()
+	limit := DefaultLimit
+	if raw := query.Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid limit"}`))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	incidents, err := is.GetIncidents(query.Get("state"), query.Get("room"), limit, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error reading incidents:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(incidents); err != nil {
+		logger.Println("Error encoding incidents:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAlertHandler returns the alert incident with the ID
+// Example: curl -X GET "http://localhost:8080/api/alerts/1" -u admin:password
+func GetAlertHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, is service.IncidentService) {
+	id, ok := incidentID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	incident, err := is.GetIncident(id, ctx)
+	if err != nil {
+		logger.Println("Error reading incident:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	writeIncident(w, logger, incident)
+}
+
+// AcknowledgeAlertHandler records who is responding to an alert incident, the body is optional
+// Example: curl -X POST "http://localhost:8080/api/alerts/1/acknowledge" -u admin:password -H "Content-Type: application/json" -d '{"by": "Ms. Smith", "note": "Checking on the room"}'
+func AcknowledgeAlertHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, is service.IncidentService) {
+	respond(w, r, logger, is.Acknowledge)
+}
+
+// ResolveAlertHandler closes an alert incident, the body is optional
+// Example: curl -X POST "http://localhost:8080/api/alerts/1/resolve" -u admin:password -H "Content-Type: application/json" -d '{"by": "Ms. Smith", "note": "Window closed"}'
+func ResolveAlertHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, is service.IncidentService) {
+	respond(w, r, logger, is.Resolve)
+}
+
+// respond acknowledges or resolves the incident of the request
+func respond(w http.ResponseWriter, r *http.Request, logger *log.Logger,
+	action func(id int, by, note string, ctx context.Context) (*models.Incident, error)) {
+	id, ok := incidentID(w, r)
+	if !ok {
+		return
+	}
+	var response Response
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+	if response.By == "" {
+		response.By, _, _ = r.BasicAuth()
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	incident, err := action(id, response.By, response.Note, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error updating incident:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	if incident != nil {
+		logger.Printf("Incident %d of %s %s by %s", incident.ID, incident.RoomName, incident.State, response.By)
+	}
+	writeIncident(w, logger, incident)
+}
+
+func writeIncident(w http.ResponseWriter, logger *log.Logger, incident *models.Incident) {
+	if incident == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(incident); err != nil {
+		logger.Println("Error encoding incident:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// incidentID reads the incident ID path parameter, writing the error if it is invalid
+func incidentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid incident ID."}`))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,121 @@
+package alerts_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/alerts"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetAlerts(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  int
+		count int
+	}{
+		{"?state=open&room=Office", http.StatusOK, 1},
+		{"?state=resolved", http.StatusOK, 0},
+		{"?state=closed", http.StatusBadRequest, 0},
+		{"?limit=ten", http.StatusBadRequest, 0},
+	} {
+		req, err := http.NewRequest("GET", "/alerts"+tc.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		alerts.GetAlertsHandler(rr, req, log.Default(), &service.MockIncidentService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.query, rr.Code, tc.want)
+			continue
+		}
+		if tc.want != http.StatusOK {
+			continue
+		}
+		var incidents []models.Incident
+		if err := json.Unmarshal(rr.Body.Bytes(), &incidents); err != nil {
+			t.Fatal(err)
+		}
+		if len(incidents) != tc.count {
+			t.Errorf("%s: handler returned unexpected body: %v", tc.query, rr.Body.String())
+		}
+	}
+}
+
+func TestGetAlert(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusNotFound},
+		{"one", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", "/alerts/"+tc.id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		alerts.GetAlertHandler(rr, req, log.Default(), &service.MockIncidentService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.id, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestAcknowledgeAlertDefaultsToUser(t *testing.T) {
+	req, err := http.NewRequest("POST", "/alerts/1/acknowledge", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1")
+	req.SetBasicAuth("admin", "password")
+	rr := httptest.NewRecorder()
+
+	alerts.AcknowledgeAlertHandler(rr, req, log.Default(), &service.MockIncidentService{})
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var incident models.Incident
+	if err := json.Unmarshal(rr.Body.Bytes(), &incident); err != nil {
+		t.Fatal(err)
+	}
+	if incident.State != models.IncidentAcknowledged || incident.AcknowledgedBy != "admin" {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestResolveAlert(t *testing.T) {
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{"1", `{"by": "Ms. Smith", "note": "Window closed"}`, http.StatusOK},
+		{"2", `{"by": "Ms. Smith"}`, http.StatusNotFound},
+		{"1", `{"note": "Nobody"}`, http.StatusBadRequest},
+		{"1", `not json`, http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("POST", "/alerts/"+tc.id+"/resolve", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		alerts.ResolveAlertHandler(rr, req, log.Default(), &service.MockIncidentService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tc.id, tc.body, rr.Code, tc.want)
+		}
+	}
+}
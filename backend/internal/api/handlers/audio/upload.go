@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UploadResponse is the reading a recording was stored as and its analysis
+type UploadResponse struct {
+	Data     *models.Data          `json:"data"`
+	Analysis *models.AudioAnalysis `json:"analysis"`
+}
+
+// PostAudioHandler analyzes an uploaded PCM WAV recording and stores its A-weighted level as a reading of the device,
+// full_scale_db is the level in dB SPL of a full scale sine wave (AUDIO_FULL_SCALE_DB, 120 by default)
+// Example: curl -X POST "http://localhost:8080/api/audio?device_id=arduino_001&room_name=Room1&file_name=alert.wav" -H "Content-Type: audio/wav" --data-binary @alert.wav -u admin:password
+func PostAudioHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, as service.AudioService) {
+	query := r.URL.Query()
+	upload := &service.AudioUpload{
+		DeviceID:    query.Get("device_id"),
+		RoomName:    query.Get("room_name"),
+		MeasureTime: query.Get("measure_time"),
+		FileName:    query.Get("file_name"),
+	}
+	if upload.FileName == "" {
+		upload.FileName = "upload.wav"
+	}
+	if upload.MeasureTime != "" {
+		if _, err := time.Parse(time.RFC3339, upload.MeasureTime); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid measure_time format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}`))
+			return
+		}
+	}
+	if raw := query.Get("full_scale_db"); raw != "" {
+		var err error
+		if upload.FullScaleDB, err = strconv.ParseFloat(raw, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid full_scale_db"}`))
+			return
+		}
+	}
+
+	// * Read the whole recording first, so a too large one is told apart from an invalid one *
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, service.MaxAudioUploadBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(`{"error": "The recording must be at most 32 MB."}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Could not read the recording."}`))
+		return
+	}
+	upload.Body = bytes.NewReader(body)
+
+	// * Analysis takes longer than storing a reading *
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	data, analysis, err := as.AnalyzeUpload(upload, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error storing recording:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(UploadResponse{Data: data, Analysis: analysis}); err != nil {
+		logger.Printf("Error encoding recording analysis: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAudioAnalysisHandler returns the analysis of the recording stored as the data row with the ID
+// Example: curl -X GET "http://localhost:8080/api/audio/42" -u admin:password
+func GetAudioAnalysisHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, as service.AudioService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid ID"}`))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	analysis, err := as.GetAnalysis(id, ctx)
+	if err != nil {
+		logger.Printf("Could not get recording analysis: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if analysis == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "No recording analysis found for the specified ID"}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(analysis); err != nil {
+		logger.Printf("Error encoding recording analysis: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,111 @@
+package audio_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"goapi/internal/api/handlers/audio"
+	service "goapi/internal/api/service/data"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// toneWAV returns a second of a 1 kHz tone at half of full scale as a 16 bit mono WAV file
+func toneWAV() []byte {
+	const rate = 16000
+	var data bytes.Buffer
+	for i := 0; i < rate; i++ {
+		binary.Write(&data, binary.LittleEndian, int16(16384*math.Sin(2*math.Pi*1000*float64(i)/rate)))
+	}
+
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+data.Len()))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), uint32(rate), uint32(rate * 2), uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(data.Len()))
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+func TestPostAudioSuccessful(t *testing.T) {
+	req, err := http.NewRequest("POST", "/audio?device_id=arduino_001&room_name=Room_A&file_name=tone.wav", bytes.NewReader(toneWAV()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "audio/wav")
+	rr := httptest.NewRecorder()
+
+	audio.PostAudioHandler(rr, req, log.Default(), &service.MockAudioService{})
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	var response audio.UploadResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	// * Half of full scale at the default 120 dB full scale level *
+	if math.Abs(response.Analysis.LAeq-113.98) > 0.1 || response.Data.SoundLevel != response.Analysis.LAeq {
+		t.Errorf("unexpected levels: %v", rr.Body.String())
+	}
+	if response.Data.RoomName != "Room_A" || response.Analysis.FileName != "tone.wav" || response.Analysis.SampleRate != 16000 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestPostAudioInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name, query, body string
+		want              int
+	}{
+		{"not a WAV file", "", `{"sound_level": 60}`, http.StatusBadRequest},
+		{"invalid measure_time", "measure_time=yesterday", string(toneWAV()), http.StatusBadRequest},
+		{"invalid full scale", "full_scale_db=loud", string(toneWAV()), http.StatusBadRequest},
+		{"too large", "", strings.Repeat("x", service.MaxAudioUploadBytes+1), http.StatusRequestEntityTooLarge},
+	} {
+		req, err := http.NewRequest("POST", "/audio?"+tc.query, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		audio.PostAudioHandler(rr, req, log.Default(), &service.MockAudioService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.name, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestGetAudioAnalysis(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusNotFound},
+		{"abc", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", "/audio/"+tc.id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		audio.GetAudioAnalysisHandler(rr, req, log.Default(), &service.MockAudioService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.id, rr.Code, tc.want)
+		}
+	}
+}
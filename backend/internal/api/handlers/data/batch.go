@@ -0,0 +1,153 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	maxBatchSize  = 1000
+	maxBatchBytes = 4 << 20
+)
+
+// BatchResponse is the body returned by PostBatchHandler
+type BatchResponse struct {
+	Stored  int                   `json:"stored"`
+	Failed  int                   `json:"failed"`
+	Results []service.BatchResult `json:"results"`
+}
+
+// PostBatchHandler stores readings a device buffered while it was offline.
+// The body is either a JSON array of readings or NDJSON (one reading per line).
+// With ?mode=atomic nothing is stored if any reading is invalid, by default the valid readings are stored.
+// Example: curl -X POST "http://localhost:8080/api/data/batch?mode=atomic" -u admin:password -H "Content-Type: application/json" -d '[{"sound_level": 61.2, "is_periodic": true}]'
+func PostBatchHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+	atomic := false
+	switch r.URL.Query().Get("mode") {
+	case "", "partial":
+	case "atomic":
+		atomic = true
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid mode. Use 'atomic' or 'partial'."}`))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(`{"error": "Request body is too large."}`))
+		return
+	}
+
+	items, err := splitBatch(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+	if len(items) == 0 || len(items) > maxBatchSize {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "A batch must contain between 1 and 1000 readings."}`))
+		return
+	}
+
+	// * Decode every reading on its own, so one malformed reading doesn't fail the others *
+	results := make([]service.BatchResult, len(items))
+	var readings []*models.Data
+	var indexes []int
+	for i, item := range items {
+		var data models.Data
+		if err := json.Unmarshal(item, &data); err != nil {
+			results[i] = service.BatchResult{Index: i, Status: service.BatchInvalid, Error: "Invalid reading: " + err.Error()}
+			continue
+		}
+		readings = append(readings, &data)
+		indexes = append(indexes, i)
+	}
+
+	if atomic && len(readings) < len(items) {
+		for _, i := range indexes {
+			results[i] = service.BatchResult{Index: i, Status: service.BatchSkipped}
+		}
+		writeBatchResponse(w, logger, results, atomic)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	stored, err := ds.CreateBatch(readings, atomic, ctx)
+	if err != nil {
+		logger.Println("Error creating batch:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	// * Map the results back to the position of the reading in the request *
+	for j, result := range stored {
+		result.Index = indexes[j]
+		results[indexes[j]] = result
+	}
+
+	writeBatchResponse(w, logger, results, atomic)
+}
+
+// splitBatch returns the raw readings of a JSON array or an NDJSON body
+func splitBatch(body []byte) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(body)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+
+	var items []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 64*1024), maxBatchBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		items = append(items, json.RawMessage(append([]byte(nil), line...)))
+	}
+	return items, scanner.Err()
+}
+
+func writeBatchResponse(w http.ResponseWriter, logger *log.Logger, results []service.BatchResult, atomic bool) {
+	resp := BatchResponse{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case service.BatchCreated, service.BatchExisting, service.BatchLatest:
+			resp.Stored++
+		case service.BatchInvalid, service.BatchFailed:
+			resp.Failed++
+		}
+	}
+
+	// * 201 when everything was stored, 207 when only some of it, 400 when nothing *
+	status := http.StatusCreated
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+		if atomic || resp.Stored == 0 {
+			status = http.StatusBadRequest
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Println("Error encoding batch results:", err)
+	}
+}
@@ -0,0 +1,117 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// * batchDataService validates readings like the real services: the sound level must be at most 150 dB *
+type batchDataService struct {
+	service.MockDataServiceSuccessful
+	received int
+}
+
+func (m *batchDataService) CreateBatch(d []*models.Data, atomic bool, ctx context.Context) ([]service.BatchResult, error) {
+	m.received = len(d)
+	results := make([]service.BatchResult, len(d))
+	for i, reading := range d {
+		results[i] = service.BatchResult{Index: i, Status: service.BatchCreated, ID: i + 1}
+		if reading.SoundLevel > 150 {
+			results[i] = service.BatchResult{Index: i, Status: service.BatchInvalid, Error: "Invalid data"}
+		}
+	}
+	return results, nil
+}
+
+func postBatch(t *testing.T, ds service.DataService, url, body string) (*httptest.ResponseRecorder, data.BatchResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", url, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	data.PostBatchHandler(rr, req, log.Default(), ds)
+
+	var resp data.BatchResponse
+	if rr.Code != http.StatusInternalServerError {
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("could not decode response %q: %v", rr.Body.String(), err)
+		}
+	}
+	return rr, resp
+}
+
+func TestPostBatchJSONArray(t *testing.T) {
+	ds := &batchDataService{}
+	rr, resp := postBatch(t, ds, "/data/batch", `[{"sound_level": 61.2, "is_periodic": true}, {"sound_level": 62.4, "is_periodic": true}]`)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	if resp.Stored != 2 || resp.Failed != 0 || resp.Results[1].ID != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestPostBatchNDJSONPartial(t *testing.T) {
+	ds := &batchDataService{}
+	body := "{\"sound_level\": 61.2}\n\nnot json\n{\"sound_level\": 200}\n{\"sound_level\": 63}\n"
+	rr, resp := postBatch(t, ds, "/data/batch", body)
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusMultiStatus)
+	}
+	if ds.received != 3 {
+		t.Errorf("expected 3 decoded readings to be passed to the service, got %d", ds.received)
+	}
+
+	// * Results are reported by position in the request, blank lines are ignored *
+	expected := []string{service.BatchCreated, service.BatchInvalid, service.BatchInvalid, service.BatchCreated}
+	if len(resp.Results) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), resp.Results)
+	}
+	for i, status := range expected {
+		if resp.Results[i].Index != i || resp.Results[i].Status != status {
+			t.Errorf("result %d: got %+v want status %s", i, resp.Results[i], status)
+		}
+	}
+}
+
+func TestPostBatchAtomicRejectsMalformedReading(t *testing.T) {
+	ds := &batchDataService{}
+	rr, resp := postBatch(t, ds, "/data/batch?mode=atomic", "{\"sound_level\": 61.2}\n{\"sound_level\": \"loud\"}\n")
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if ds.received != 0 {
+		t.Errorf("nothing should be passed to the service, got %d readings", ds.received)
+	}
+	if resp.Results[0].Status != service.BatchSkipped || resp.Results[1].Status != service.BatchInvalid {
+		t.Errorf("unexpected results: %+v", resp.Results)
+	}
+}
+
+func TestPostBatchInvalidMode(t *testing.T) {
+	req := httptest.NewRequest("POST", "/data/batch?mode=some", strings.NewReader(`[]`))
+	rr := httptest.NewRecorder()
+	data.PostBatchHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPostBatchErrorCreatingData(t *testing.T) {
+	rr, _ := postBatch(t, &service.MockDataServiceError{}, "/data/batch", `[{"sound_level": 61.2}]`)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+}
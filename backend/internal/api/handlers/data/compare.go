@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"time"
+)
+
+// CompareHandler compares two periods of a room, or of all rooms without room, as a whole, day by day and per hour of the day:
+// from and to (default the last 7 days) with compare_from and compare_to (default the period of the same length just before)
+// Example: curl -X GET "http://localhost:8080/api/data/compare?room=Room1&from=2025-11-10T00:00:00%2B01:00&to=2025-11-17T00:00:00%2B01:00" -u admin:password
+func CompareHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+	roomName := r.URL.Query().Get("room")
+
+	var from, to, compareFrom, compareTo time.Time
+	for _, p := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"from", &from},
+		{"to", &to},
+		{"compare_from", &compareFrom},
+		{"compare_to", &compareTo},
+	} {
+		raw := r.URL.Query().Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid ` + p.name + ` format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}`))
+			return
+		}
+		*p.value = t
+	}
+
+	// * By default this week is compared with the week before *
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -7)
+	}
+	if compareTo.IsZero() {
+		compareTo = from
+	}
+	if compareFrom.IsZero() {
+		compareFrom = compareTo.Add(-to.Sub(from))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	comparison, err := ds.ComparePeriods(roomName, from, to, compareFrom, compareTo, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error comparing periods:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(comparison); err != nil {
+		logger.Printf("Error encoding period comparison: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
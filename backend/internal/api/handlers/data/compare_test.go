@@ -0,0 +1,58 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/compare?room=Room_A&from=2025-11-10T00:00:00Z&to=2025-11-17T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	data.CompareHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var comparison service.PeriodComparison
+	if err := json.Unmarshal(rr.Body.Bytes(), &comparison); err != nil {
+		t.Fatal(err)
+	}
+	// * Without compare_from and compare_to the week before is compared *
+	if comparison.CompareFrom != "2025-11-03T00:00:00Z" || comparison.CompareTo != "2025-11-10T00:00:00Z" {
+		t.Errorf("unexpected previous period: %v - %v", comparison.CompareFrom, comparison.CompareTo)
+	}
+	if comparison.RoomName != "Room_A" || comparison.Delta.LAeq != -5 || len(comparison.Days) != 7 || len(comparison.Hours) != 24 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestCompareInvalidPeriods(t *testing.T) {
+	for _, query := range []string{
+		"from=last-week",
+		"from=2025-11-17T00:00:00Z&to=2025-11-10T00:00:00Z",
+		"from=2025-09-01T00:00:00Z&to=2025-11-10T00:00:00Z",
+		"from=2025-11-10T00:00:00Z&to=2025-11-17T00:00:00Z&compare_from=2025-11-03T00:00:00Z&compare_to=2025-11-01T00:00:00Z",
+	} {
+		req, err := http.NewRequest("GET", "/data/compare?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		data.CompareHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
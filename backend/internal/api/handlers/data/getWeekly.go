@@ -3,12 +3,19 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
 	"log"
 	"net/http"
 	"time"
 )
 
+// Values of the source query parameter of the weekly and daily endpoints
+const (
+	SourceRaw    = "raw"    // The stored readings
+	SourceRollup = "rollup" // The hourly or daily rollups, much less rows
+)
+
 // GetDailySummaryHandler retrieves noise data for a specific room and date
 // Example: curl -X GET "http://localhost:8080/data/daily/Room1?date=2025-11-07T00:00:00Z" -u admin:password -H "Content-Type: application/json"
 func GetByRoomHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
@@ -20,11 +27,31 @@ func GetByRoomHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger
 		return
 	}
 
+	// Read raw rows (default) or one row per device and day from the rollups
+	source := r.URL.Query().Get("source")
+	if source != "" && source != SourceRaw && source != SourceRollup {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid source. Use raw or rollup"}`))
+		return
+	}
+
+	// With points=N the rows are downsampled to a compact chart series
+	points, ok := parsePoints(w, r)
+	if !ok {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
 	// Get the data for the last 5 weeks from the service
-	data, err := ds.GetByRoom(roomName, ctx)
+	var data []*models.Data
+	var err error
+	if source == SourceRollup {
+		data, err = ds.GetByRoomRollups(roomName, ctx)
+	} else {
+		data, err = ds.GetByRoom(roomName, ctx)
+	}
 	if err != nil {
 		logger.Printf("Could not get weekly summary: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -37,6 +64,11 @@ func GetByRoomHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger
 		return
 	}
 
+	if points > 0 {
+		writeSeries(w, logger, roomName, data, points)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
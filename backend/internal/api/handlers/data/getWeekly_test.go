@@ -0,0 +1,111 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetByRoomFromRollups(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/weekly/Room_A?source=rollup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetByRoomHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var rows []models.Data
+	if err := json.Unmarshal(rr.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Description != "Leq of 96 readings, 2 alerts" {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetDailySummaryFromRollups(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/daily/Room_A?date=2025-11-07T00:00:00Z&source=rollup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetDailySummaryHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var rows []models.Data
+	if err := json.Unmarshal(rr.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Description != "Leq of 4 readings, 0 alerts" {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetByRoomInvalidSource(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/weekly/Room_A?source=cache", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetByRoomHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetByRoomDownsampled(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/weekly/Room_A?points=100", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetByRoomHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response data.SeriesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Points != 100 || len(response.Series) != 1 || response.Series[0].Total != 1 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetByRoomInvalidPoints(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/weekly/Room_A?points=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetByRoomHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AnomaliesResponse lists the readings of a room that were unusual for their hour of the week
+type AnomaliesResponse struct {
+	RoomName  string         `json:"room_name"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	MinScore  float64        `json:"min_score"`
+	Anomalies []*models.Data `json:"anomalies"`
+}
+
+// GetAnomaliesHandler returns the readings of a room scoring at least min_score (default 3) standard deviations
+// from the room's usual level at their hour of the week, between from and to (default the last 7 days)
+// Example: curl -X GET "http://localhost:8080/api/data/anomalies/Room1?from=2025-11-01T00:00:00Z&min_score=4" -u admin:password
+func GetAnomaliesHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+	// Get room name from path parameter
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Room name is required"}`))
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	for _, p := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"from", &from},
+		{"to", &to},
+	} {
+		raw := r.URL.Query().Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid ` + p.name + ` format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}`))
+			return
+		}
+		*p.value = t
+	}
+
+	minScore := models.AnomalyScoreThreshold
+	if raw := r.URL.Query().Get("min_score"); raw != "" {
+		var err error
+		if minScore, err = strconv.ParseFloat(raw, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid min_score"}`))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	anomalies, err := ds.GetAnomalies(roomName, from, to, minScore, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error fetching anomalies:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	if anomalies == nil {
+		anomalies = []*models.Data{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := AnomaliesResponse{
+		RoomName:  roomName,
+		From:      from.Format(time.RFC3339),
+		To:        to.Format(time.RFC3339),
+		MinScore:  minScore,
+		Anomalies: anomalies,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Printf("Error encoding anomalies: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetBaselinesHandler returns the learned level of a room for each hour of the week that has readings
+// Example: curl -X GET "http://localhost:8080/api/data/baseline/Room1" -u admin:password
+func GetBaselinesHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+	// Get room name from path parameter
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Room name is required"}`))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	baselines, err := ds.GetBaselines(roomName, ctx)
+	if err != nil {
+		logger.Printf("Could not get baselines: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(baselines) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "No baseline learned for the specified room"}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(baselines); err != nil {
+		logger.Printf("Error encoding baselines: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
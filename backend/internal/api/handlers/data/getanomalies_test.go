@@ -0,0 +1,105 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetAnomaliesSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/anomalies/Room_A?from=2025-11-01T00:00:00Z&to=2025-11-08T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetAnomaliesHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response data.AnomaliesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.RoomName != "Room_A" || response.MinScore != models.AnomalyScoreThreshold || response.From != "2025-11-01T00:00:00Z" {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+	if len(response.Anomalies) != 1 || response.Anomalies[0].AnomalyScore != 4.2 {
+		t.Errorf("handler returned unexpected anomalies: %v", rr.Body.String())
+	}
+}
+
+func TestGetAnomaliesNone(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/anomalies/Room_A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetAnomaliesHandler(rr, req, log.Default(), &service.MockDataServiceNotFound{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response data.AnomaliesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Anomalies == nil || len(response.Anomalies) != 0 {
+		t.Errorf("expected an empty list of anomalies, got %v", rr.Body.String())
+	}
+}
+
+func TestGetAnomaliesInvalidParameters(t *testing.T) {
+	for _, query := range []string{"from=yesterday", "to=2025-11-08", "min_score=high"} {
+		req, err := http.NewRequest("GET", "/data/anomalies/Room_A?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("room", "Room_A")
+		rr := httptest.NewRecorder()
+
+		data.GetAnomaliesHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetBaselines(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/baseline/Room_A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetBaselinesHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var baselines []models.Baseline
+	if err := json.Unmarshal(rr.Body.Bytes(), &baselines); err != nil {
+		t.Fatal(err)
+	}
+	if len(baselines) != 1 || baselines[0].HourOfWeek != 33 || baselines[0].StdDev != 3.1 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	data.GetBaselinesHandler(rr, req, log.Default(), &service.MockDataServiceNotFound{})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
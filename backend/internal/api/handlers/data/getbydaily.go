@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
 	"log"
 	"net/http"
@@ -36,11 +37,30 @@ func GetDailySummaryHandler(w http.ResponseWriter, r *http.Request, logger *log.
 		return
 	}
 
+	// Read raw rows (default) or one row per device and hour from the rollups
+	source := r.URL.Query().Get("source")
+	if source != "" && source != SourceRaw && source != SourceRollup {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid source. Use raw or rollup"}`))
+		return
+	}
+
+	// With points=N the rows are downsampled to a compact chart series
+	points, ok := parsePoints(w, r)
+	if !ok {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
 	// Get the daily summary from the service
-	data, err := ds.GetDailySummary(roomName, date, ctx)
+	var data []*models.Data
+	if source == SourceRollup {
+		data, err = ds.GetDailySummaryRollups(roomName, date, ctx)
+	} else {
+		data, err = ds.GetDailySummary(roomName, date, ctx)
+	}
 	if err != nil {
 		logger.Printf("Could not get daily summary: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -53,6 +73,11 @@ func GetDailySummaryHandler(w http.ResponseWriter, r *http.Request, logger *log.
 		return
 	}
 
+	if points > 0 {
+		writeSeries(w, logger, roomName, data, points)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
@@ -0,0 +1,64 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetDailyStatsSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/daily/Room_A/summary?date=2025-11-07T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetDailyStatsHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var stats service.DailyStats
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.RoomName != "Room_A" || stats.Date != "2025-11-07" || stats.LAeq != 63.5 || len(stats.Hours) != 1 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetDailyStatsNoData(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/daily/Room_A/summary?date=2025-11-07T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetDailyStatsHandler(rr, req, log.Default(), &service.MockDataServiceNotFound{})
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetDailyStatsInvalidDate(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/daily/Room_A/summary?date=2025-11-07", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetDailyStatsHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
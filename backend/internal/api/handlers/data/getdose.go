@@ -0,0 +1,83 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetDoseHandler returns the noise dose of a room for a day, today if no date is given.
+// criterion, exchange_rate and criterion_hours override the configured criterion (85 dB / 3 dB / 8 h by default)
+// Example: curl -X GET "http://localhost:8080/api/data/dose/Room1?criterion=85&exchange_rate=3" -u admin:password
+func GetDoseHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, dose service.DoseService) {
+	// Get room name from path parameter
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Room name is required"}`))
+		return
+	}
+
+	date := time.Now()
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		var err error
+		if date, err = time.Parse(time.RFC3339, dateStr); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid date format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}`))
+			return
+		}
+	}
+
+	// * Criterion overrides, unset values stay 0 and the configured ones are used *
+	var cfg service.DoseConfig
+	for _, p := range []struct {
+		name  string
+		value *float64
+	}{
+		{"criterion", &cfg.CriterionLevel},
+		{"exchange_rate", &cfg.ExchangeRate},
+		{"criterion_hours", &cfg.CriterionHours},
+	} {
+		raw := r.URL.Query().Get(p.name)
+		if raw == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || f <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid ` + p.name + `"}`))
+			return
+		}
+		*p.value = f
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	result, err := dose.GetDose(roomName, date, &cfg, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error computing noise dose:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logger.Printf("Error encoding noise dose: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,67 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetDoseSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/dose/Room_A?date=2025-11-07T00:00:00Z&exchange_rate=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetDoseHandler(rr, req, log.Default(), &service.MockDoseService{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var dose models.NoiseDose
+	if err := json.Unmarshal(rr.Body.Bytes(), &dose); err != nil {
+		t.Fatal(err)
+	}
+	if dose.RoomName != "Room_A" || dose.Date != "2025-11-07" || dose.CriterionLevel != 85 || dose.ExchangeRate != 5 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetDoseInvalidCriterion(t *testing.T) {
+	for _, query := range []string{"criterion=loud", "criterion=200", "exchange_rate=-3", "criterion_hours=25"} {
+		req, err := http.NewRequest("GET", "/data/dose/Room_A?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("room", "Room_A")
+		rr := httptest.NewRecorder()
+
+		data.GetDoseHandler(rr, req, log.Default(), &service.MockDoseService{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetDoseInvalidDate(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/dose/Room_A?date=2025-11-07", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetDoseHandler(rr, req, log.Default(), &service.MockDoseService{})
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
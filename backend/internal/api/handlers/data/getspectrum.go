@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetSpectrumHandler returns the energy averaged octave band levels of a room between from and to
+// (default the last 24 hours), as a whole and per hour or day with bucket=hour or bucket=day
+// Example: curl -X GET "http://localhost:8080/api/data/spectrum/Room1?from=2025-11-10T00:00:00Z&bucket=hour" -u admin:password
+func GetSpectrumHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+	// Get room name from path parameter
+	roomName := r.PathValue("room")
+	if roomName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Room name is required"}`))
+		return
+	}
+
+	var from, to time.Time
+	for _, p := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"from", &from},
+		{"to", &to},
+	} {
+		raw := r.URL.Query().Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid ` + p.name + ` format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}`))
+			return
+		}
+		*p.value = t
+	}
+	if to.IsZero() {
+		to = time.Now()
+		if !from.IsZero() {
+			to = from.Add(24 * time.Hour)
+		}
+	}
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	spectrum, err := ds.GetSpectrum(roomName, from, to, r.URL.Query().Get("bucket"), ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error fetching spectrum:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(spectrum); err != nil {
+		logger.Printf("Error encoding spectrum: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
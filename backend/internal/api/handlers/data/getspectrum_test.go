@@ -0,0 +1,77 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetSpectrumSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/spectrum/Room_A?from=2025-11-10T08:00:00Z&bucket=hour", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetSpectrumHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var spectrum service.RoomSpectrum
+	if err := json.Unmarshal(rr.Body.Bytes(), &spectrum); err != nil {
+		t.Fatal(err)
+	}
+	// * Without to the 24 hours after from are averaged *
+	if spectrum.To != "2025-11-11T08:00:00Z" || spectrum.Bucket != "hour" {
+		t.Errorf("unexpected period: %v - %v", spectrum.From, spectrum.To)
+	}
+	if spectrum.Readings != 1 || len(spectrum.Levels) != 8 || spectrum.Levels[0] != 58 || len(spectrum.Frequencies) != 8 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+	if len(spectrum.Buckets) != 1 || spectrum.Buckets[0].Start != "2025-11-10T08:00:00Z" {
+		t.Errorf("handler returned unexpected buckets: %v", rr.Body.String())
+	}
+}
+
+func TestGetSpectrumInvalidQuery(t *testing.T) {
+	for _, query := range []string{
+		"from=yesterday",
+		"from=2025-11-17T00:00:00Z&to=2025-11-10T00:00:00Z",
+		"bucket=week",
+	} {
+		req, err := http.NewRequest("GET", "/data/spectrum/Room_A?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("room", "Room_A")
+		rr := httptest.NewRecorder()
+
+		data.GetSpectrumHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetSpectrumError(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/spectrum/Room_A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("room", "Room_A")
+	rr := httptest.NewRecorder()
+
+	data.GetSpectrumHandler(rr, req, log.Default(), &service.MockDataServiceError{})
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+}
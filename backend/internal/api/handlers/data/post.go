@@ -21,62 +21,47 @@ func PostHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds
 		return
 	}
 
-	// Fill timestamp if missing
-	if data.MeasureTime == "" {
-		data.MeasureTime = time.Now().Format(time.RFC3339)
-	}
-
-	// Fill default device ID if missing
-	if data.DeviceID == "" {
-		data.DeviceID = "arduino_001"
-	}
-
-	if data.Threshold == 0 {
-		data.Threshold = 70 // default threshold
-	}
-
 	logger.Println("Received POST /api/data from Arduino:")
 	logger.Printf("%+v\n", data)
 
+	// * Devices retry after timeouts, the key makes the retry return the stored reading *
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > 255 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Idempotency-Key must be at most 255 characters."}`))
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
-	// Constantly sent data for current sound level card in UI
-	// Only has 1 row per device that gets updated
-	// So it doesn't need to be periodically cleared
-	if !data.IsPeriodic {
-		// Save latest_data to repository
-		if err := ds.CreateLatest(&data, ctx); err != nil {
-			switch err.(type) {
-			case service.DataError:
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(`{"error": "` + err.Error() + `"}`))
-				return
-			default:
-				logger.Println("Error updating latest measurement data:", err, data)
-				http.Error(w, "Internal server error.", http.StatusInternalServerError)
-				return
-			}
-		}
-	} else {
-		// Data sent periodically (every 10 minutes)
-		// Save data to repository
-		if err := ds.Create(&data, ctx); err != nil {
-			switch err.(type) {
-			case service.DataError:
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(`{"error": "` + err.Error() + `"}`))
-				return
-			default:
-				logger.Println("Error creating data:", err, data)
-				http.Error(w, "Internal server error.", http.StatusInternalServerError)
-				return
-			}
+	// Save to latest_data or data depending on IsPeriodic
+	created, err := service.Ingest(ds, &data, key, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		case service.ConflictError:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error storing data:", err, data)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
 		}
 	}
 
-	// Return the created record as JSON
-	w.WriteHeader(http.StatusCreated)
+	// Return the created record as JSON,
+	// or the already stored one with 200 so the device stops retrying
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(http.StatusOK)
+	}
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		logger.Println("Error encoding data:", err, data)
 		http.Error(w, "Internal server error.", http.StatusInternalServerError)
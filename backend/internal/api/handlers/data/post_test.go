@@ -1,6 +1,7 @@
 package data_test
 
 import (
+	"context"
 	"encoding/json"
 	"goapi/internal/api/handlers/data"
 	"goapi/internal/api/repository/models"
@@ -101,3 +102,89 @@ func TestPostSuccessful(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
+
+// * replayingDataService reports every periodic reading as already stored *
+type replayingDataService struct {
+	service.MockDataServiceSuccessful
+	key string
+}
+
+func (r *replayingDataService) CreateIdempotent(d *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	r.key = key.Key
+	d.ID = 42
+	return false, nil
+}
+
+func TestPostIdempotentReplay(t *testing.T) {
+	mockDS := &replayingDataService{}
+
+	body := `{"device_id":"arduino_001","room_name":"eating room","sound_level":65.5,"measure_time":"2024-06-01T14:00:00+02:00","is_periodic":true}`
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", "reading-123")
+
+	rr := httptest.NewRecorder()
+	data.PostHandler(rr, req, log.Default(), mockDS)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("handler returned unexpected header: got %v want %v", rr.Header().Get("Idempotent-Replayed"), "true")
+	}
+	if mockDS.key != "reading-123" {
+		t.Errorf("service got wrong idempotency key: got %v want %v", mockDS.key, "reading-123")
+	}
+
+	var stored models.Data
+	if err := json.Unmarshal(rr.Body.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	// * The stored reading is returned, with its time in UTC *
+	if stored.ID != 42 || stored.MeasureTime != "2024-06-01T12:00:00Z" {
+		t.Errorf("handler returned unexpected reading: %+v", stored)
+	}
+}
+
+// * conflictingDataService reports every key as used for another reading *
+type conflictingDataService struct {
+	service.MockDataServiceSuccessful
+}
+
+func (c *conflictingDataService) CreateIdempotent(d *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	return false, service.ConflictError{Message: "The Idempotency-Key was already used for a different reading of the device."}
+}
+
+func TestPostIdempotencyKeyReused(t *testing.T) {
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(`{"device_id":"arduino_001","sound_level":65.5,"is_periodic":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", "reading-123")
+
+	rr := httptest.NewRecorder()
+	data.PostHandler(rr, req, log.Default(), &conflictingDataService{})
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestPostIdempotencyKeyTooLong(t *testing.T) {
+	mockDS := &service.MockDataServiceSuccessful{}
+
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(`{"sound_level":65.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+
+	rr := httptest.NewRecorder()
+	data.PostHandler(rr, req, log.Default(), mockDS)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
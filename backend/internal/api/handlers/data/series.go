@@ -0,0 +1,51 @@
+package data
+
+import (
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// SeriesResponse is returned instead of the rows when a chart asks for points=N
+type SeriesResponse struct {
+	RoomName string           `json:"room_name"`
+	Points   int              `json:"points"`
+	Series   []service.Series `json:"series"`
+}
+
+// parsePoints reads the points query parameter, 0 if it isn't set.
+// ok is false if it is invalid, the error response has been written then.
+func parsePoints(w http.ResponseWriter, r *http.Request) (points int, ok bool) {
+	raw := r.URL.Query().Get("points")
+	if raw == "" {
+		return 0, true
+	}
+	points, err := strconv.Atoi(raw)
+	if err != nil || points < 3 || points > service.MaxSeriesPoints {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "points must be a number between 3 and ` + strconv.Itoa(service.MaxSeriesPoints) + `"}`))
+		return 0, false
+	}
+	return points, true
+}
+
+// writeSeries writes the rows downsampled to about points points per device
+func writeSeries(w http.ResponseWriter, logger *log.Logger, roomName string, rows []*models.Data, points int) {
+	response := SeriesResponse{
+		RoomName: roomName,
+		Points:   points,
+		Series:   service.DownsampleSeries(rows, points),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Printf("Error encoding series: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,44 @@
+package devices
+
+import (
+	"encoding/json"
+	"fmt"
+	"goapi/internal/api/ws"
+	"io"
+	"log"
+	"net/http"
+)
+
+// Alerter sends alert commands to connected devices, e.g. the WebSocket server
+type Alerter interface {
+	PlayAlert(deviceID string, alert *ws.Alert) int
+}
+
+// * Make a device connected over the WebSocket play an alert, the body is optional *
+// * curl -X POST http://127.0.0.1:8080/api/devices/arduino_001/alert -i -u admin:password -H "Content-Type: application/json" -d '{"sound": "chime", "duration_ms": 3000, "message": "Too loud!"}'
+func PlayAlertHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, alerter Alerter) {
+	deviceID := r.PathValue("id")
+
+	alert := &ws.Alert{}
+	if err := json.NewDecoder(r.Body).Decode(alert); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+	if alert.DurationMs < 0 || alert.DurationMs > ws.MaxAlertDurationMs {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": "duration_ms must be between 0 and %d."}`, ws.MaxAlertDurationMs)
+		return
+	}
+
+	sent := alerter.PlayAlert(deviceID, alert)
+	if sent == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Device not connected."}`))
+		return
+	}
+
+	logger.Printf("Alert sent to %d connection(s) of %s", sent, deviceID)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, `{"connections": %d}`, sent)
+}
@@ -0,0 +1,73 @@
+package devices_test
+
+import (
+	"goapi/internal/api/handlers/devices"
+	"goapi/internal/api/ws"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// * fakeAlerter has one device connected *
+type fakeAlerter struct {
+	alert *ws.Alert
+}
+
+func (f *fakeAlerter) PlayAlert(deviceID string, alert *ws.Alert) int {
+	if deviceID != "arduino_001" {
+		return 0
+	}
+	f.alert = alert
+	return 1
+}
+
+func TestPlayAlertAccepted(t *testing.T) {
+	req, err := http.NewRequest("POST", "/devices/arduino_001/alert", strings.NewReader(`{"sound": "chime", "duration_ms": 3000}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "arduino_001")
+	rr := httptest.NewRecorder()
+	alerter := &fakeAlerter{}
+
+	devices.PlayAlertHandler(rr, req, log.Default(), alerter)
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusAccepted)
+	}
+	if alerter.alert == nil || alerter.alert.Sound != "chime" || alerter.alert.DurationMs != 3000 {
+		t.Errorf("unexpected alert: %+v", alerter.alert)
+	}
+}
+
+func TestPlayAlertNotConnected(t *testing.T) {
+	req, err := http.NewRequest("POST", "/devices/arduino_404/alert", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "arduino_404")
+	rr := httptest.NewRecorder()
+
+	devices.PlayAlertHandler(rr, req, log.Default(), &fakeAlerter{})
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestPlayAlertInvalidDuration(t *testing.T) {
+	req, err := http.NewRequest("POST", "/devices/arduino_001/alert", strings.NewReader(`{"duration_ms": 600000}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "arduino_001")
+	rr := httptest.NewRecorder()
+
+	devices.PlayAlertHandler(rr, req, log.Default(), &fakeAlerter{})
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,146 @@
+package devices
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Recalibrating the readings of a device can take a while
+const recalibrateTimeout = 30 * time.Second
+
+// CalibrationResponse is a stored calibration and how many stored readings were recomputed with it
+type CalibrationResponse struct {
+	Calibration *models.Calibration `json:"calibration,omitempty"`
+	Recomputed  int                 `json:"recomputed"`
+}
+
+// * Calibrations of every device *
+// * curl -X GET http://127.0.0.1:8080/api/devices/calibration -i -u admin:password
+func GetAllCalibrationsHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, cal service.CalibrationService) {
+	getCalibrations(w, r, logger, cal, "")
+}
+
+// * Calibrations of one device, ordered by the time they are valid from *
+// * curl -X GET http://127.0.0.1:8080/api/devices/arduino_001/calibration -i -u admin:password
+func GetCalibrationsHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, cal service.CalibrationService) {
+	getCalibrations(w, r, logger, cal, r.PathValue("id"))
+}
+
+func getCalibrations(w http.ResponseWriter, r *http.Request, logger *log.Logger, cal service.CalibrationService, deviceID string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	calibrations, err := cal.GetCalibrations(deviceID, ctx)
+	if err != nil {
+		logger.Println("Error reading calibrations:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(calibrations); err != nil {
+		logger.Println("Error encoding calibrations:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// * Calibrate a device from valid_from on (default now), with recompute=true its stored readings are recomputed from their raw levels *
+// * curl -X POST "http://127.0.0.1:8080/api/devices/arduino_001/calibration?recompute=true" -i -u admin:password -H "Content-Type: application/json" -d '{"offset": -2.5, "gain": 1, "curve": [{"raw": 40, "calibrated": 42}, {"raw": 90, "calibrated": 88}], "valid_from": "2024-06-01T00:00:00Z"}'
+func PostCalibrationHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, cal service.CalibrationService) {
+	recompute, ok := recomputeParam(w, r)
+	if !ok {
+		return
+	}
+
+	var calibration models.Calibration
+	if err := json.NewDecoder(r.Body).Decode(&calibration); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+	calibration.DeviceID = r.PathValue("id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), recalibrateTimeout)
+	defer cancel()
+
+	recomputed, err := cal.CreateCalibration(&calibration, recompute, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error storing calibration:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logger.Printf("Calibration %d of %s stored, %d readings recomputed", calibration.ID, calibration.DeviceID, recomputed)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(CalibrationResponse{Calibration: &calibration, Recomputed: recomputed}); err != nil {
+		logger.Println("Error encoding calibration:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// * Remove a calibration of a device, with recompute=true its stored readings are recomputed without it *
+// * curl -X DELETE "http://127.0.0.1:8080/api/devices/arduino_001/calibration/1?recompute=true" -i -u admin:password
+func DeleteCalibrationHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, cal service.CalibrationService) {
+	id, err := strconv.Atoi(r.PathValue("calibration"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid calibration ID."}`))
+		return
+	}
+	recompute, ok := recomputeParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), recalibrateTimeout)
+	defer cancel()
+
+	deleted, recomputed, err := cal.DeleteCalibration(r.PathValue("id"), id, recompute, ctx)
+	if err != nil {
+		logger.Println("Error deleting calibration:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(CalibrationResponse{Recomputed: recomputed}); err != nil {
+		logger.Println("Error encoding calibration:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// recomputeParam reads the optional recompute query parameter, writing the error if it is invalid
+func recomputeParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := r.URL.Query().Get("recompute")
+	if raw == "" {
+		return false, true
+	}
+	recompute, err := strconv.ParseBool(raw)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "recompute must be true or false."}`))
+		return false, false
+	}
+	return recompute, true
+}
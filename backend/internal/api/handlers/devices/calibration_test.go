@@ -0,0 +1,107 @@
+package devices_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/devices"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetCalibrationsSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/devices/arduino_001/calibration", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "arduino_001")
+	rr := httptest.NewRecorder()
+
+	devices.GetCalibrationsHandler(rr, req, log.Default(), &service.MockCalibrationService{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var calibrations []models.Calibration
+	if err := json.Unmarshal(rr.Body.Bytes(), &calibrations); err != nil {
+		t.Fatal(err)
+	}
+	if len(calibrations) != 1 || calibrations[0].Offset != -2.5 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestPostCalibrationSuccessful(t *testing.T) {
+	body := `{"offset": -2.5, "curve": [{"raw": 40, "calibrated": 42}, {"raw": 90, "calibrated": 88}], "valid_from": "2024-06-01T00:00:00Z"}`
+	req, err := http.NewRequest("POST", "/devices/arduino_001/calibration?recompute=true", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "arduino_001")
+	rr := httptest.NewRecorder()
+
+	devices.PostCalibrationHandler(rr, req, log.Default(), &service.MockCalibrationService{})
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+
+	var response devices.CalibrationResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Recomputed != 42 || response.Calibration.DeviceID != "arduino_001" || response.Calibration.Gain != 1 || len(response.Calibration.Curve) != 2 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestPostCalibrationInvalid(t *testing.T) {
+	for _, tc := range []struct{ query, body string }{
+		{"", `{"gain": 5}`},
+		{"", `{"valid_from": "June"}`},
+		{"", `not json`},
+		{"?recompute=maybe", `{"offset": 1}`},
+	} {
+		req, err := http.NewRequest("POST", "/devices/arduino_001/calibration"+tc.query, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", "arduino_001")
+		rr := httptest.NewRecorder()
+
+		devices.PostCalibrationHandler(rr, req, log.Default(), &service.MockCalibrationService{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tc.query, tc.body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestDeleteCalibration(t *testing.T) {
+	for _, tc := range []struct {
+		device, id string
+		want       int
+	}{
+		{"arduino_001", "1", http.StatusOK},
+		{"arduino_002", "1", http.StatusNotFound},
+		{"arduino_001", "one", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("DELETE", "/devices/"+tc.device+"/calibration/"+tc.id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.device)
+		req.SetPathValue("calibration", tc.id)
+		rr := httptest.NewRecorder()
+
+		devices.DeleteCalibrationHandler(rr, req, log.Default(), &service.MockCalibrationService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s/%s: handler returned wrong status code: got %v want %v", tc.device, tc.id, rr.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package devices
+
+import (
+	"context"
+	"encoding/json"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"time"
+)
+
+// * Clock skew statistics of every device *
+// * curl -X GET http://127.0.0.1:8080/api/devices/clock -i -u admin:password
+func GetClocksHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, cs service.ClockService) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	clocks, err := cs.GetAllDeviceClocks(ctx)
+	if err != nil {
+		logger.Println("Error reading device clocks:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(clocks); err != nil {
+		logger.Println("Error encoding device clocks:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// * Clock skew statistics of one device *
+// * curl -X GET http://127.0.0.1:8080/api/devices/arduino_001/clock -i -u admin:password
+func GetClockHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, cs service.ClockService) {
+	deviceID := r.PathValue("id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	clock, err := cs.GetDeviceClock(deviceID, ctx)
+	if err != nil {
+		logger.Println("Error reading device clock:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if clock == nil {
+		// * The device never sent a reading with measure_time *
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(clock); err != nil {
+		logger.Println("Error encoding device clock:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,55 @@
+package devices_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/devices"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetClocksSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/devices/clock", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	devices.GetClocksHandler(rr, req, log.Default(), &service.MockClockService{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var clocks []models.DeviceClock
+	if err := json.Unmarshal(rr.Body.Bytes(), &clocks); err != nil {
+		t.Fatal(err)
+	}
+	if len(clocks) != 1 || clocks[0].DeviceID != "arduino_001" || clocks[0].LastSkewMs != -1500 {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetClockNotFound(t *testing.T) {
+	req, err := http.NewRequest("GET", "/devices/arduino_404/clock", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "arduino_404")
+	rr := httptest.NewRecorder()
+
+	devices.GetClockHandler(rr, req, log.Default(), &service.MockClockService{})
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	expected := `{"error": "Resource not found."}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
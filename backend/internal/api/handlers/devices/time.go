@@ -0,0 +1,29 @@
+package devices
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ServerTime is the response of the time endpoint
+type ServerTime struct {
+	Time   string `json:"time"`    // RFC 3339 in UTC, with milliseconds
+	UnixMs int64  `json:"unix_ms"` // Milliseconds since the Unix epoch, for devices without a date parser
+}
+
+// * Devices sync their clock with this before stamping readings with measure_time *
+// * curl -X GET http://127.0.0.1:8080/api/time -i -u admin:password
+func TimeHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger) {
+	now := time.Now().UTC()
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(ServerTime{
+		Time:   now.Format("2006-01-02T15:04:05.000Z07:00"),
+		UnixMs: now.UnixMilli(),
+	}); err != nil {
+		logger.Println("Error encoding time:", err)
+	}
+}
@@ -0,0 +1,38 @@
+package devices_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/devices"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeHandler(t *testing.T) {
+	req, err := http.NewRequest("GET", "/time", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	before := time.Now()
+	devices.TimeHandler(rr, req, log.Default())
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var got devices.ServerTime
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := time.Parse(time.RFC3339, got.Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.UnixMilli() != got.UnixMs || parsed.Before(before.Truncate(time.Millisecond)) || parsed.After(time.Now()) {
+		t.Errorf("handler returned unexpected time: %+v", got)
+	}
+}
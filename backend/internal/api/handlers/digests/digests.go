@@ -0,0 +1,56 @@
+package digests
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetDigestHandler returns the digest of the day (period=daily, default) or the week (period=weekly) before to
+// (default now) of a room, every location if room is empty: alerts, time over the threshold, loudest hours
+// and the change from the period before
+// Example: curl -X GET "http://localhost:8080/api/digests?period=weekly&room=Room1&to=2025-11-10T07:00:00Z" -u admin:password
+func GetDigestHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, dgs service.DigestService) {
+	query := r.URL.Query()
+	period := query.Get("period")
+	if period == "" {
+		period = models.DigestDaily
+	}
+	to := time.Now()
+	if raw := query.Get("to"); raw != "" {
+		var err error
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid to format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}`))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	digest, err := dgs.GetDigest(period, query.Get("room"), to, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error computing digest:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(digest); err != nil {
+		logger.Println("Error encoding digest:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,50 @@
+package digests_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/digests"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetDigest(t *testing.T) {
+	req, err := http.NewRequest("GET", "/digests?period=weekly&room=Office&to=2024-06-03T07:00:00Z", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	digests.GetDigestHandler(rr, req, log.Default(), &service.MockDigestService{})
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var digest models.Digest
+	if err := json.Unmarshal(rr.Body.Bytes(), &digest); err != nil {
+		t.Fatal(err)
+	}
+	if digest.Period != models.DigestWeekly || digest.From != "2024-05-27T07:00:00Z" || len(digest.Locations) != 1 || digest.Locations[0].RoomName != "Office" {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetDigestInvalid(t *testing.T) {
+	for _, query := range []string{"period=monthly", "to=yesterday"} {
+		req, err := http.NewRequest("GET", "/digests?"+query, strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		digests.GetDigestHandler(rr, req, log.Default(), &service.MockDigestService{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -11,13 +11,21 @@ import (
 	"time"
 )
 
+// * With ?dose=true the locations include today's noise dose *
 func GetLocationsHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, svc service.LocationService) {
+	dose, ok := doseParam(w, r)
+	if !ok {
+		return
+	}
 	locations, err := svc.GetAllLocations()
 	if err != nil {
 		logger.Println("Error getting locations:", err)
 		http.Error(w, `{"error": "Failed to get locations"}`, http.StatusInternalServerError)
 		return
 	}
+	if dose {
+		svc.FillDose(locations...)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -30,8 +38,13 @@ type LocationResponse struct {
 	Data    interface{} `json:"data"`
 }
 
+// * With ?dose=true the location includes today's noise dose *
 func GetChosenLocationHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, svc service.LocationService) {
 	w.Header().Set("Content-Type", "application/json")
+	dose, ok := doseParam(w, r)
+	if !ok {
+		return
+	}
 	location, err := svc.GetChosenLocation()
 	if err != nil {
 		logger.Println("Error getting chosen location:", err)
@@ -44,6 +57,9 @@ func GetChosenLocationHandler(w http.ResponseWriter, r *http.Request, logger *lo
 		w.Write([]byte(`{"error": "Resource not found."}`))
 		return
 	}
+	if dose {
+		svc.FillDose(location)
+	}
 
 	resp := LocationResponse{
 		Message: "Location retrieved",
@@ -159,3 +175,19 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, s
 	// * This is a Success, response in JSON and with a 204 status code when location was successfully deleted
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// doseParam reads the optional dose query parameter, writing the error if it is invalid
+func doseParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := r.URL.Query().Get("dose")
+	if raw == "" {
+		return false, true
+	}
+	dose, err := strconv.ParseBool(raw)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "dose must be true or false."}`))
+		return false, false
+	}
+	return dose, true
+}
@@ -0,0 +1,204 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetScheduleHandler returns the weekly threshold schedule of the location with the ID
+// Example: curl -X GET "http://localhost:8080/api/locations/1/schedule" -u admin:password
+func GetScheduleHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ss service.ScheduleService) {
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	schedule, err := ss.GetSchedule(id, ctx)
+	if err != nil {
+		logger.Println("Error reading schedule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if schedule == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		logger.Println("Error encoding schedule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PutScheduleHandler replaces the schedule of the location with the ID. The entries of a weekday must not overlap,
+// a range over midnight is two entries. Outside the entries the location's own threshold applies.
+// Example: curl -X PUT "http://localhost:8080/api/locations/1/schedule" -u admin:password -H "Content-Type: application/json" -d '{"timezone": "Europe/Helsinki", "entries": [{"label": "Nap time", "weekdays": ["monday", "tuesday", "wednesday", "thursday", "friday"], "start": "12:00", "end": "14:00", "threshold": 45}], "exceptions": [{"label": "Christmas", "from": "2024-12-23", "to": "2025-01-06"}]}'
+func PutScheduleHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ss service.ScheduleService) {
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+	var schedule models.ThresholdSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+	schedule.LocationID = id
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	updated, err := ss.SetSchedule(&schedule, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error storing schedule:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !updated {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	logger.Printf("Threshold schedule of location %d stored", id)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		logger.Println("Error encoding schedule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteScheduleHandler removes the schedule of the location with the ID, its own threshold applies again
+// Example: curl -X DELETE "http://localhost:8080/api/locations/1/schedule" -u admin:password
+func DeleteScheduleHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ss service.ScheduleService) {
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	deleted, err := ss.DeleteSchedule(id, ctx)
+	if err != nil {
+		logger.Println("Error deleting schedule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostScheduleExceptionHandler adds a date range to the schedule of the location with the ID, e.g. a holiday.
+// On those dates the exception's threshold applies all day, the location's own threshold if it is 0.
+// Example: curl -X POST "http://localhost:8080/api/locations/1/schedule/exceptions" -u admin:password -H "Content-Type: application/json" -d '{"label": "Spring party", "from": "2024-05-17", "threshold": 85}'
+func PostScheduleExceptionHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ss service.ScheduleService) {
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+	var exception models.ScheduleException
+	if err := json.NewDecoder(r.Body).Decode(&exception); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	added, err := ss.AddException(id, &exception, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error storing schedule exception:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !added {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(exception); err != nil {
+		logger.Println("Error encoding schedule exception:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteScheduleExceptionHandler removes an exception from the schedule of the location with the ID
+// Example: curl -X DELETE "http://localhost:8080/api/locations/1/schedule/exceptions/1" -u admin:password
+func DeleteScheduleExceptionHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ss service.ScheduleService) {
+	id, ok := locationID(w, r)
+	if !ok {
+		return
+	}
+	exceptionID, err := strconv.Atoi(r.PathValue("exception"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid exception ID."}`))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	deleted, err := ss.DeleteException(id, exceptionID, ctx)
+	if err != nil {
+		logger.Println("Error deleting schedule exception:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// locationID reads the location ID path parameter, writing the error if it is invalid
+func locationID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid location ID."}`))
+		return 0, false
+	}
+	return id, true
+}
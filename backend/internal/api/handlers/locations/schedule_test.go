@@ -0,0 +1,111 @@
+package locations_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/locations"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusNotFound},
+		{"office", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", "/locations/"+tc.id+"/schedule", strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		locations.GetScheduleHandler(rr, req, log.Default(), &service.MockScheduleService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.id, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestPutSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		body string
+		want int
+	}{
+		{"1", `{"timezone": "Europe/Helsinki", "entries": [{"weekdays": ["Monday"], "start": "12:00", "end": "14:00", "threshold": 45}]}`, http.StatusOK},
+		{"2", `{"entries": []}`, http.StatusNotFound},
+		{"1", `{"timezone": "Nowhere"}`, http.StatusBadRequest},
+		{"1", `{"entries": [{"weekdays": ["monday"], "start": "22:00", "end": "06:00", "threshold": 45}]}`, http.StatusBadRequest},
+		{"1", `not json`, http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("PUT", "/locations/"+tc.id+"/schedule", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		locations.PutScheduleHandler(rr, req, log.Default(), &service.MockScheduleService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.body, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestPostScheduleException(t *testing.T) {
+	req, err := http.NewRequest("POST", "/locations/1/schedule/exceptions", strings.NewReader(`{"label": "Spring party", "from": "2024-05-17", "threshold": 85}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+
+	locations.PostScheduleExceptionHandler(rr, req, log.Default(), &service.MockScheduleService{})
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	var exception models.ScheduleException
+	if err := json.Unmarshal(rr.Body.Bytes(), &exception); err != nil {
+		t.Fatal(err)
+	}
+	if exception.ID != 1 || exception.To != "2024-05-17" {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestDeleteScheduleException(t *testing.T) {
+	for _, tc := range []struct {
+		exception string
+		want      int
+	}{
+		{"1", http.StatusNoContent},
+		{"2", http.StatusNotFound},
+		{"x", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("DELETE", "/locations/1/schedule/exceptions/"+tc.exception, strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", "1")
+		req.SetPathValue("exception", tc.exception)
+		rr := httptest.NewRecorder()
+
+		locations.DeleteScheduleExceptionHandler(rr, req, log.Default(), &service.MockScheduleService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.exception, rr.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetFiringsHandler returns when alert rules fired for a device, between from and to (default the last 7 days),
+// of one rule or room if rule or room is set. A firing without ended_at is still active.
+// Example: curl -X GET "http://localhost:8080/api/rules/firings?room=Room1&from=2025-11-01T00:00:00Z" -u admin:password
+func GetFiringsHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, rs service.RuleService) {
+	query := r.URL.Query()
+	ruleID := 0
+	if raw := query.Get("rule"); raw != "" {
+		var err error
+		if ruleID, err = strconv.Atoi(raw); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid rule ID."}`))
+			return
+		}
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	for _, p := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"from", &from},
+		{"to", &to},
+	} {
+		raw := query.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid ` + p.name + ` format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}`))
+			return
+		}
+		*p.value = t
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	firings, err := rs.GetFirings(ruleID, query.Get("room"), from, to, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error reading rule firings:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(firings); err != nil {
+		logger.Println("Error encoding rule firings:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,182 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetRulesHandler returns the alert rules, with room those that apply to the room including the rules of every room
+// Example: curl -X GET "http://localhost:8080/api/rules?room=Room1" -u admin:password
+func GetRulesHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, rs service.RuleService) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	rules, err := rs.GetRules(r.URL.Query().Get("room"), ctx)
+	if err != nil {
+		logger.Println("Error reading alert rules:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		logger.Println("Error encoding alert rules:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetRuleHandler returns the alert rule with the ID
+// Example: curl -X GET "http://localhost:8080/api/rules/1" -u admin:password
+func GetRuleHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, rs service.RuleService) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	rule, err := rs.GetRule(id, ctx)
+	if err != nil {
+		logger.Println("Error reading alert rule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if rule == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		logger.Println("Error encoding alert rule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PostRuleHandler stores an alert rule, kind is level, leq (Leq over window_seconds, default 300)
+// or peaks (count readings, default 3, over window_seconds, default 600), all at or above level
+// Example: curl -X POST "http://localhost:8080/api/rules" -u admin:password -H "Content-Type: application/json" -d '{"name": "Loud for 5 minutes", "room_name": "Room1", "kind": "leq", "level": 75, "clear_level": 72, "window_seconds": 300, "severity": "warning"}'
+func PostRuleHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, rs service.RuleService) {
+	var rule models.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := rs.CreateRule(&rule, ctx); err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error storing alert rule:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logger.Printf("Alert rule %d (%s) stored", rule.ID, rule.Name)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		logger.Println("Error encoding alert rule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PutRuleHandler replaces the alert rule with the ID, its active firings end
+// Example: curl -X PUT "http://localhost:8080/api/rules/1" -u admin:password -H "Content-Type: application/json" -d '{"name": "Peaks", "kind": "peaks", "level": 85, "count": 5, "window_seconds": 600, "severity": "critical"}'
+func PutRuleHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, rs service.RuleService) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+	var rule models.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+	rule.ID = id
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	updated, err := rs.UpdateRule(&rule, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error updating alert rule:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !updated {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		logger.Println("Error encoding alert rule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteRuleHandler removes the alert rule with the ID, its active firings end
+// Example: curl -X DELETE "http://localhost:8080/api/rules/1" -u admin:password
+func DeleteRuleHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, rs service.RuleService) {
+	id, ok := ruleID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	deleted, err := rs.DeleteRule(id, ctx)
+	if err != nil {
+		logger.Println("Error deleting alert rule:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ruleID reads the rule ID path parameter, writing the error if it is invalid
+func ruleID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid rule ID."}`))
+		return 0, false
+	}
+	return id, true
+}
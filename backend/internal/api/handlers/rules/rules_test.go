@@ -0,0 +1,174 @@
+package rules_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/rules"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetRulesSuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/rules?room=Office", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.GetRulesHandler(rr, req, log.Default(), &service.MockRuleService{})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var got []models.AlertRule
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Kind != models.RuleLeq {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestGetRule(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusNotFound},
+		{"one", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", "/rules/"+tc.id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		rules.GetRuleHandler(rr, req, log.Default(), &service.MockRuleService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.id, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestPostRuleSuccessful(t *testing.T) {
+	body := `{"name": "Door slams", "room_name": "Office", "kind": "peaks", "level": 85}`
+	req, err := http.NewRequest("POST", "/rules", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.PostRuleHandler(rr, req, log.Default(), &service.MockRuleService{})
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+
+	var rule models.AlertRule
+	if err := json.Unmarshal(rr.Body.Bytes(), &rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule.ID != 2 || rule.Count != 3 || rule.WindowSeconds != 600 || rule.Severity != models.SeverityWarning {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestPostRuleInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"kind": "leq"}`,
+		`{"kind": "loudness", "level": 80}`,
+		`{"kind": "level", "level": 80, "clear_level": 90}`,
+		`not json`,
+	} {
+		req, err := http.NewRequest("POST", "/rules", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		rules.PostRuleHandler(rr, req, log.Default(), &service.MockRuleService{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestPutRule(t *testing.T) {
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{"1", `{"kind": "level", "level": 90}`, http.StatusOK},
+		{"2", `{"kind": "level", "level": 90}`, http.StatusNotFound},
+		{"1", `{"kind": "level"}`, http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("PUT", "/rules/"+tc.id, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		rules.PutRuleHandler(rr, req, log.Default(), &service.MockRuleService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tc.id, tc.body, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestDeleteRule(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusNoContent},
+		{"2", http.StatusNotFound},
+	} {
+		req, err := http.NewRequest("DELETE", "/rules/"+tc.id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		rules.DeleteRuleHandler(rr, req, log.Default(), &service.MockRuleService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.id, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestGetFirings(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"?room=Office&from=2024-06-01T00:00:00Z&to=2024-06-02T00:00:00Z", http.StatusOK},
+		{"?rule=one", http.StatusBadRequest},
+		{"?from=June", http.StatusBadRequest},
+		{"?from=2024-06-02T00:00:00Z&to=2024-06-01T00:00:00Z", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", "/rules/firings"+tc.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		rules.GetFiringsHandler(rr, req, log.Default(), &service.MockRuleService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.query, rr.Code, tc.want)
+		}
+	}
+}
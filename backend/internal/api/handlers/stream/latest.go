@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goapi/internal/api/pubsub"
+	"goapi/internal/api/repository/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HeartbeatInterval is how often a comment is sent on an idle stream,
+// so proxies don't close the connection and dead clients are noticed
+var HeartbeatInterval = 15 * time.Second
+
+// LatestHandler streams the latest readings as Server-Sent Events.
+// The stream ends when the client disconnects or rootCtx (the server's root context) is cancelled.
+// Example: curl -N "http://localhost:8080/api/stream/latest?room=PlayRoom_A&device=arduino_001" -u admin:password
+func LatestHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, hub *pubsub.Hub, rootCtx context.Context) {
+	room := r.URL.Query().Get("room")
+	device := r.URL.Query().Get("device")
+
+	// * Browsers send the ID of the last event they saw when they reconnect *
+	var lastEventID uint64
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid Last-Event-ID."}`))
+			return
+		}
+		lastEventID = id
+	}
+
+	rc := http.NewResponseController(w)
+
+	sub, missed := hub.Subscribe(lastEventID, func(data *models.Data) bool {
+		return !data.IsPeriodic &&
+			(room == "" || data.RoomName == room) &&
+			(device == "" || data.DeviceID == device)
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// * Tell the browser how long to wait before reconnecting *
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	for _, event := range missed {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		logger.Println("Streaming is not supported:", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				// * Hub closed or we fell behind, the client reconnects with Last-Event-ID *
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-rootCtx.Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event pubsub.Event) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: reading\ndata: %s\n\n", event.ID, payload)
+	return err
+}
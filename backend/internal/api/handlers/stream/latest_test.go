@@ -0,0 +1,109 @@
+package stream_test
+
+import (
+	"bufio"
+	"context"
+	"goapi/internal/api/handlers/stream"
+	"goapi/internal/api/pubsub"
+	"goapi/internal/api/repository/models"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// * readEvent reads lines until the end of the next event that has an id *
+func readEvent(t *testing.T, reader *bufio.Reader) []string {
+	t.Helper()
+
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if len(lines) > 0 && strings.HasPrefix(lines[0], "id: ") {
+				return lines
+			}
+			lines = nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLatestStreamsFilteredReadings(t *testing.T) {
+	hub := pubsub.NewHub(10)
+	rootCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream.LatestHandler(w, r, log.Default(), hub, rootCtx)
+	}))
+	defer srv.Close()
+
+	// * Both readings are published before connecting, only the one after Last-Event-ID is replayed *
+	hub.Publish(&models.Data{DeviceID: "arduino_001", RoomName: "Room_A", SoundLevel: 50})
+	hub.Publish(&models.Data{DeviceID: "arduino_001", RoomName: "Room_A", SoundLevel: 51})
+
+	req, _ := http.NewRequest("GET", srv.URL+"?room=Room_A", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	event := readEvent(t, reader)
+	if event[0] != "id: 2" || !strings.Contains(event[2], `"sound_level":51`) {
+		t.Errorf("unexpected replayed event: %v", event)
+	}
+
+	// * Other rooms and periodic readings are not streamed *
+	hub.Publish(&models.Data{DeviceID: "arduino_002", RoomName: "Room_B", SoundLevel: 60})
+	hub.Publish(&models.Data{DeviceID: "arduino_001", RoomName: "Room_A", SoundLevel: 62, IsPeriodic: true})
+	hub.Publish(&models.Data{DeviceID: "arduino_001", RoomName: "Room_A", SoundLevel: 63})
+
+	event = readEvent(t, reader)
+	if event[0] != "id: 5" || event[1] != "event: reading" {
+		t.Errorf("unexpected event: %v", event)
+	}
+
+	// * Cancelling the root context ends the stream *
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed after root context was cancelled")
+	}
+}
+
+func TestLatestInvalidLastEventID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/stream/latest", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rr := httptest.NewRecorder()
+
+	stream.LatestHandler(rr, req, log.Default(), pubsub.NewHub(10), context.Background())
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultLimit is how many deliveries are returned without a limit
+const DefaultLimit = 50
+
+// GetDeliveriesHandler returns the newest deliveries of the webhook with the ID (limit, default 50),
+// in one status (pending, delivered or dead) if set. Dead deliveries are not tried again.
+// Example: curl -X GET "http://localhost:8080/api/webhooks/1/deliveries?status=dead" -u admin:password
+func GetDeliveriesHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, whs service.WebhookService) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	limit := DefaultLimit
+	if raw := query.Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid limit"}`))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	deliveries, err := whs.GetDeliveries(id, query.Get("status"), limit, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error reading webhook deliveries:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	if deliveries == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+		logger.Println("Error encoding webhook deliveries:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,182 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetWebhooksHandler returns the webhooks, without their secrets
+// Example: curl -X GET "http://localhost:8080/api/webhooks" -u admin:password
+func GetWebhooksHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, whs service.WebhookService) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	webhooks, err := whs.GetWebhooks(ctx)
+	if err != nil {
+		logger.Println("Error reading webhooks:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(webhooks); err != nil {
+		logger.Println("Error encoding webhooks:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetWebhookHandler returns the webhook with the ID, without its secret
+// Example: curl -X GET "http://localhost:8080/api/webhooks/1" -u admin:password
+func GetWebhookHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, whs service.WebhookService) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	webhook, err := whs.GetWebhook(id, ctx)
+	if err != nil {
+		logger.Println("Error reading webhook:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if webhook == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(webhook); err != nil {
+		logger.Println("Error encoding webhook:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PostWebhookHandler stores a webhook, the events of room_name (every room if empty) at or above min_severity
+// are sent to it, only the listed events if set. Without a secret one is generated, it is only returned here.
+// Example: curl -X POST "http://localhost:8080/api/webhooks" -u admin:password -H "Content-Type: application/json" -d '{"url": "https://example.com/hooks/noise", "room_name": "Room1", "min_severity": "warning", "events": ["incident.opened", "incident.resolved"]}'
+func PostWebhookHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, whs service.WebhookService) {
+	var webhook models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := whs.CreateWebhook(&webhook, ctx); err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error storing webhook:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logger.Printf("Webhook %d (%s) stored", webhook.ID, webhook.URL)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(webhook); err != nil {
+		logger.Println("Error encoding webhook:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PutWebhookHandler replaces the webhook with the ID, the secret is kept unless a new one is set
+// Example: curl -X PUT "http://localhost:8080/api/webhooks/1" -u admin:password -H "Content-Type: application/json" -d '{"url": "https://example.com/hooks/noise", "min_severity": "critical", "disabled": false}'
+func PutWebhookHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, whs service.WebhookService) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	var webhook models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		return
+	}
+	webhook.ID = id
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	updated, err := whs.UpdateWebhook(&webhook, ctx)
+	if err != nil {
+		switch err.(type) {
+		case service.DataError:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		default:
+			logger.Println("Error updating webhook:", err)
+			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !updated {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(webhook); err != nil {
+		logger.Println("Error encoding webhook:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteWebhookHandler removes the webhook with the ID and its deliveries
+// Example: curl -X DELETE "http://localhost:8080/api/webhooks/1" -u admin:password
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, whs service.WebhookService) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	deleted, err := whs.DeleteWebhook(id, ctx)
+	if err != nil {
+		logger.Println("Error deleting webhook:", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found."}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhookID reads the webhook ID path parameter, writing the error if it is invalid
+func webhookID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid webhook ID."}`))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,146 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/webhooks"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostWebhookReturnsSecret(t *testing.T) {
+	body := `{"url": "https://example.com/hooks/noise", "room_name": "Office", "events": ["incident.opened"]}`
+	req, err := http.NewRequest("POST", "/webhooks", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	webhooks.PostWebhookHandler(rr, req, log.Default(), &service.MockWebhookService{})
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	var webhook models.Webhook
+	if err := json.Unmarshal(rr.Body.Bytes(), &webhook); err != nil {
+		t.Fatal(err)
+	}
+	if webhook.ID != 2 || webhook.Secret == "" {
+		t.Errorf("handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestPostWebhookInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"url": "ftp://example.com/hooks"}`,
+		`{"url": "https://example.com/hooks", "secret": "short"}`,
+		`{"url": "https://example.com/hooks", "min_severity": "loud"}`,
+		`{"url": "https://example.com/hooks", "events": ["reading"]}`,
+		`not json`,
+	} {
+		req, err := http.NewRequest("POST", "/webhooks", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		webhooks.PostWebhookHandler(rr, req, log.Default(), &service.MockWebhookService{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestPutWebhook(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusNotFound},
+		{"one", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("PUT", "/webhooks/"+tc.id, strings.NewReader(`{"url": "https://example.com/hooks/noise", "disabled": true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		webhooks.PutWebhookHandler(rr, req, log.Default(), &service.MockWebhookService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.id, rr.Code, tc.want)
+			continue
+		}
+		if tc.want == http.StatusOK && strings.Contains(rr.Body.String(), "secret") {
+			t.Errorf("handler returned the secret: %v", rr.Body.String())
+		}
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusNoContent},
+		{"2", http.StatusNotFound},
+	} {
+		req, err := http.NewRequest("DELETE", "/webhooks/"+tc.id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		webhooks.DeleteWebhookHandler(rr, req, log.Default(), &service.MockWebhookService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.id, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestGetDeliveries(t *testing.T) {
+	for _, tc := range []struct {
+		id, query string
+		want      int
+		count     int
+	}{
+		{"1", "", http.StatusOK, 1},
+		{"1", "?status=dead", http.StatusOK, 0},
+		{"1", "?status=lost", http.StatusBadRequest, 0},
+		{"1", "?limit=0", http.StatusBadRequest, 0},
+		{"2", "", http.StatusNotFound, 0},
+	} {
+		req, err := http.NewRequest("GET", "/webhooks/"+tc.id+"/deliveries"+tc.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		webhooks.GetDeliveriesHandler(rr, req, log.Default(), &service.MockWebhookService{})
+
+		if rr.Code != tc.want {
+			t.Errorf("%s%s: handler returned wrong status code: got %v want %v", tc.id, tc.query, rr.Code, tc.want)
+			continue
+		}
+		if tc.want != http.StatusOK {
+			continue
+		}
+		var deliveries []models.WebhookDelivery
+		if err := json.Unmarshal(rr.Body.Bytes(), &deliveries); err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != tc.count {
+			t.Errorf("%s%s: handler returned unexpected body: %v", tc.id, tc.query, rr.Body.String())
+		}
+	}
+}
@@ -31,9 +31,10 @@ func CommonMiddleware(next http.Handler) http.Handler {
 
 		// Only check Content-Type for requests with body (POST, PUT, PATCH)
 		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
-			if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			allowed := allowedContentTypes(r.URL.Path)
+			if !hasContentType(r.Header.Get("Content-Type"), allowed) {
 				w.WriteHeader(http.StatusUnsupportedMediaType)
-				w.Write([]byte(`{"error": "Content-Type header should be set to: application/json."}`))
+				w.Write([]byte(`{"error": "Content-Type header should be set to: ` + strings.Join(allowed, " or ") + `."}`))
 				return
 			}
 		}
@@ -42,3 +43,25 @@ func CommonMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// allowedContentTypes returns the request body types a route accepts.
+// NDJSON is only used by devices uploading buffered readings in a batch, WAV only by recording uploads.
+func allowedContentTypes(path string) []string {
+	switch path {
+	case "/data/batch":
+		return []string{"application/json", "application/x-ndjson"}
+	case "/audio":
+		return []string{"audio/wav", "audio/x-wav", "audio/wave"}
+	default:
+		return []string{"application/json"}
+	}
+}
+
+func hasContentType(contentType string, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.HasPrefix(contentType, a) {
+			return true
+		}
+	}
+	return false
+}
@@ -52,3 +52,43 @@ func TestCommonCorrectContentType(t *testing.T) {
 		t.Fatalf("Expected Access-Control-Allow-Origin: *, got: %s", rr.Header().Get("Access-Control-Allow-Origin"))
 	}
 }
+
+func TestCommonContentTypePerRoute(t *testing.T) {
+	for _, tc := range []struct {
+		method, path, contentType string
+		want                      int
+	}{
+		{"POST", "/data", "application/json", http.StatusOK},
+		{"POST", "/data", "application/x-ndjson", http.StatusUnsupportedMediaType},
+		{"PUT", "/rules/1", "audio/wav", http.StatusUnsupportedMediaType},
+		{"POST", "/data/batch", "application/x-ndjson", http.StatusOK},
+		{"POST", "/data/batch", "application/json; charset=utf-8", http.StatusOK},
+		{"POST", "/data/batch", "audio/wav", http.StatusUnsupportedMediaType},
+		{"POST", "/audio", "audio/wav", http.StatusOK},
+		{"POST", "/audio", "application/json", http.StatusUnsupportedMediaType},
+	} {
+		req, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set("Content-Type", tc.contentType)
+		rr := httptest.NewRecorder()
+
+		handler := CommonMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tc.want {
+			t.Errorf("%s %s with %s: expected status code %d, got: %d", tc.method, tc.path, tc.contentType, tc.want, rr.Code)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/data/batch", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	rr := httptest.NewRecorder()
+	CommonMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+	expected := `{"error": "Content-Type header should be set to: application/json or application/x-ndjson."}`
+	if rr.Body.String() != expected {
+		t.Fatalf("Expected response body: %s, got: %s", expected, rr.Body.String())
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"goapi/internal/api/relay"
+	service "goapi/internal/api/service/data"
+	"io"
+	"net/http"
+)
+
+// maxRelayedBody is the largest request body a relay signature is checked on
+const maxRelayedBody = 1 << 20
+
+// RelayAuthenticationMiddleware marks the requests signed with key by a relay (the Relay-Signature header),
+// whose readings keep the received_at the relay stamped. A request with an invalid signature is refused.
+// Without a key no request is trusted.
+func RelayAuthenticationMiddleware(key []byte) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signature := r.Header.Get(relay.SignatureHeader)
+			if signature == "" || len(key) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRelayedBody))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "Could not read the request body."}`))
+				return
+			}
+			if !hmac.Equal([]byte(signature), []byte(relay.Sign(body, key))) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "Unauthorized: Invalid Relay-Signature."}`))
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r.WithContext(service.WithRelayed(r.Context())))
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"goapi/internal/api/relay"
+	service "goapi/internal/api/service/data"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRelayAuthentication(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	body := `{"device_id": "arduino_001", "sound_level": 60, "received_at": "2024-06-03T09:00:05Z"}`
+
+	for _, tc := range []struct {
+		name      string
+		signature string
+		key       []byte
+		want      int
+		relayed   bool
+	}{
+		{"signed", relay.Sign([]byte(body), key), key, http.StatusOK, true},
+		{"unsigned", "", key, http.StatusOK, false},
+		{"wrong signature", relay.Sign([]byte(body), []byte("another key 1234")), key, http.StatusUnauthorized, false},
+		{"no key configured", relay.Sign([]byte(body), key), nil, http.StatusOK, false},
+	} {
+		req, err := http.NewRequest("POST", "/data", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.signature != "" {
+			req.Header.Set(relay.SignatureHeader, tc.signature)
+		}
+		rr := httptest.NewRecorder()
+
+		var relayed bool
+		var got string
+		handler := RelayAuthenticationMiddleware(tc.key)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			relayed = service.IsRelayed(r.Context())
+			b, _ := io.ReadAll(r.Body)
+			got = string(b)
+		}))
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tc.want {
+			t.Errorf("%s: expected status code %d, got: %d", tc.name, tc.want, rr.Code)
+		}
+		if relayed != tc.relayed {
+			t.Errorf("%s: expected relayed %v, got %v", tc.name, tc.relayed, relayed)
+		}
+		if tc.want == http.StatusOK && got != body {
+			t.Errorf("%s: the handler got body %q", tc.name, got)
+		}
+	}
+}
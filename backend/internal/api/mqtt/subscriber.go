@@ -0,0 +1,193 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// DeviceIDPlaceholder marks the topic level that carries the device ID
+const DeviceIDPlaceholder = "{device_id}"
+
+// Config holds the MQTT subscriber settings
+type Config struct {
+	BrokerURL string // e.g. tcp://localhost:1883 or ssl://broker:8883
+	Topic     string // Topic pattern, e.g. soundbridge/{device_id}/level
+	ClientID  string // Fixed client ID, so the broker keeps our session while we are offline
+	Username  string
+	Password  string
+	QoS       byte
+}
+
+// ConfigFromEnv reads the subscriber settings from the environment.
+// ok is false when MQTT_BROKER_URL is not set, in which case MQTT ingestion stays disabled.
+func ConfigFromEnv() (cfg Config, ok bool) {
+	cfg = Config{
+		BrokerURL: os.Getenv("MQTT_BROKER_URL"),
+		Topic:     os.Getenv("MQTT_TOPIC"),
+		ClientID:  os.Getenv("MQTT_CLIENT_ID"),
+		Username:  os.Getenv("MQTT_USERNAME"),
+		Password:  os.Getenv("MQTT_PASSWORD"),
+		QoS:       1,
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = "soundbridge/" + DeviceIDPlaceholder + "/level"
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "sound-bridge"
+	}
+	return cfg, cfg.BrokerURL != ""
+}
+
+// Subscriber receives readings from an MQTT broker and stores them through the DataService
+type Subscriber struct {
+	cfg         Config
+	client      paho.Client
+	ds          service.DataService
+	logger      *log.Logger
+	filter      string // Subscription filter, the device ID level replaced by '+'
+	deviceLevel int    // Index of the device ID level in the topic, -1 if the pattern has none
+}
+
+// NewSubscriber creates a new subscriber, Start has to be called to connect to the broker
+func NewSubscriber(cfg Config, ds service.DataService, logger *log.Logger) (*Subscriber, error) {
+	if cfg.BrokerURL == "" {
+		return nil, fmt.Errorf("mqtt: broker URL is required")
+	}
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("mqtt: invalid QoS %d", cfg.QoS)
+	}
+
+	filter, deviceLevel, err := parseTopicPattern(cfg.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscriber{
+		cfg:         cfg,
+		ds:          ds,
+		logger:      logger,
+		filter:      filter,
+		deviceLevel: deviceLevel,
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		// Keep the session so QoS 1 messages published while we are offline are delivered on reconnect
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		SetOrderMatters(false).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.Println("MQTT connection lost, reconnecting:", err)
+		})
+	s.client = paho.NewClient(opts)
+
+	return s, nil
+}
+
+// Start connects to the broker in the background and disconnects when ctx is cancelled
+func (s *Subscriber) Start(ctx context.Context) {
+	s.logger.Println("Connecting to MQTT broker " + s.cfg.BrokerURL + ", topic " + s.filter)
+	s.client.Connect()
+
+	go func() {
+		<-ctx.Done()
+		s.client.Disconnect(250)
+		s.logger.Println("MQTT subscriber stopped")
+	}()
+}
+
+// onConnect (re)subscribes every time a connection is established
+func (s *Subscriber) onConnect(client paho.Client) {
+	s.logger.Println("Connected to MQTT broker, subscribing to", s.filter)
+	token := client.Subscribe(s.filter, s.cfg.QoS, s.handleMessage)
+	go func() {
+		if token.Wait(); token.Error() != nil {
+			s.logger.Println("MQTT subscribe error:", token.Error())
+		}
+	}()
+}
+
+func (s *Subscriber) handleMessage(_ paho.Client, msg paho.Message) {
+	data, err := s.decode(msg.Topic(), msg.Payload())
+	if err != nil {
+		s.logger.Printf("Discarding MQTT message on %s: %v", msg.Topic(), err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := service.Ingest(s.ds, data, "", ctx); err != nil {
+		s.logger.Printf("Error storing MQTT reading from %s: %v", msg.Topic(), err)
+	}
+}
+
+// decode turns a message into a reading.
+// The payload is either a JSON models.Data object or a bare sound level such as "67.45".
+// If the payload doesn't carry a device ID it is taken from the topic.
+func (s *Subscriber) decode(topic string, payload []byte) (*models.Data, error) {
+	var data models.Data
+
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &data); err != nil {
+			return nil, fmt.Errorf("invalid JSON payload: %w", err)
+		}
+	} else {
+		level, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("payload is neither JSON nor a number")
+		}
+		data.SoundLevel = level
+	}
+
+	if data.DeviceID == "" && s.deviceLevel >= 0 {
+		levels := strings.Split(topic, "/")
+		if s.deviceLevel < len(levels) {
+			data.DeviceID = levels[s.deviceLevel]
+		}
+	}
+
+	return &data, nil
+}
+
+// parseTopicPattern converts a topic pattern to a subscription filter
+// and returns the level index of the device ID placeholder.
+func parseTopicPattern(pattern string) (string, int, error) {
+	if pattern == "" {
+		return "", -1, fmt.Errorf("mqtt: topic pattern is required")
+	}
+
+	deviceLevel := -1
+	levels := strings.Split(pattern, "/")
+	for i, level := range levels {
+		if level == DeviceIDPlaceholder {
+			if deviceLevel >= 0 {
+				return "", -1, fmt.Errorf("mqtt: topic pattern %q has more than one %s", pattern, DeviceIDPlaceholder)
+			}
+			deviceLevel = i
+			levels[i] = "+"
+		} else if strings.ContainsAny(level, "{}") {
+			return "", -1, fmt.Errorf("mqtt: unknown placeholder in topic pattern %q", pattern)
+		}
+	}
+
+	return strings.Join(levels, "/"), deviceLevel, nil
+}
@@ -0,0 +1,124 @@
+package mqtt
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	broker "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// * startBroker starts an embedded broker on a free local port and returns its URL *
+func startBroker(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	server := broker.New(nil)
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: addr})); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	return "tcp://" + addr
+}
+
+func publish(t *testing.T, brokerURL, topic, payload string) {
+	t.Helper()
+
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(brokerURL).SetClientID("test-publisher"))
+	if token := client.Connect(); token.WaitTimeout(5*time.Second) && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer client.Disconnect(100)
+
+	if token := client.Publish(topic, 1, false, payload); token.WaitTimeout(5*time.Second) && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+}
+
+func waitForReading(t *testing.T, stored chan models.Data) models.Data {
+	t.Helper()
+
+	select {
+	case d := <-stored:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading was not stored")
+	}
+	return models.Data{}
+}
+
+func TestSubscriberStoresReadings(t *testing.T) {
+	brokerURL := startBroker(t)
+	ds := service.NewMockDataServiceRecording()
+
+	sub, err := NewSubscriber(Config{
+		BrokerURL: brokerURL,
+		Topic:     "soundbridge/{device_id}/level",
+		ClientID:  "test-subscriber",
+		QoS:       1,
+	}, ds, log.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub.Start(ctx)
+
+	// * Wait until the subscription is active before publishing *
+	deadline := time.Now().Add(5 * time.Second)
+	for !sub.client.IsConnectionOpen() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// * A bare number is a latest reading for the device in the topic *
+	publish(t, brokerURL, "soundbridge/arduino_007/level", "67.5")
+	got := waitForReading(t, ds.Stored)
+	if got.DeviceID != "arduino_007" || got.SoundLevel != 67.5 || got.IsPeriodic {
+		t.Errorf("unexpected reading: %+v", got)
+	}
+	// * The threshold is left to the service, which resolves it from the room *
+	if got.Threshold != 0 || got.MeasureTime == "" {
+		t.Errorf("defaults were not filled: %+v", got)
+	}
+
+	// * A JSON payload keeps its own fields *
+	publish(t, brokerURL, "soundbridge/arduino_008/level", `{"device_id": "arduino_009", "sound_level": 80, "is_periodic": true}`)
+	got = waitForReading(t, ds.Stored)
+	if got.DeviceID != "arduino_009" || got.SoundLevel != 80 || !got.IsPeriodic {
+		t.Errorf("unexpected reading: %+v", got)
+	}
+}
+
+func TestParseTopicPattern(t *testing.T) {
+	filter, level, err := parseTopicPattern("soundbridge/{device_id}/level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter != "soundbridge/+/level" || level != 1 {
+		t.Errorf("got filter %q level %d", filter, level)
+	}
+
+	if _, _, err := parseTopicPattern("soundbridge/{room}/level"); err == nil {
+		t.Error("expected an error for an unknown placeholder")
+	}
+}
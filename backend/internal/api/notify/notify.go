@@ -0,0 +1,325 @@
+// Package notify emails the recipients of a location when a noise alert incident opens and when it is resolved,
+// and the scheduled digests of their locations. Alert emails are rate limited per location,
+// so a noisy hour doesn't fill the inboxes.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"log"
+	"net/mail"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AllRooms is the recipient list key of the recipients of every location
+const AllRooms = "*"
+
+const (
+	DefaultMinInterval = 15 * time.Minute
+	queueSize          = 100
+)
+
+type Config struct {
+	Host        string
+	Port        int
+	StartTLS    bool // Upgrade the connection before authenticating, required unless the server is local
+	Username    string
+	Password    string
+	From        string
+	Recipients  map[string][]string // By location name, AllRooms for every location
+	MinInterval time.Duration       // Between the alert emails of a location
+	TemplateDir string              // Templates replacing the default ones, optional
+}
+
+// ConfigFromEnv reads SMTP_HOST, SMTP_PORT (default 587), SMTP_STARTTLS (default true), SMTP_USERNAME, SMTP_PASSWORD,
+// SMTP_FROM (default the username), NOTIFY_RECIPIENTS, NOTIFY_MIN_INTERVAL (default 15m) and NOTIFY_TEMPLATE_DIR.
+// NOTIFY_RECIPIENTS lists the recipients per location, e.g. "PlayRoom_A=a@example.com,b@example.com;*=principal@example.com".
+// ok is false when SMTP_HOST is not set, in which case no emails are sent.
+func ConfigFromEnv() (cfg Config, ok bool, err error) {
+	cfg = Config{
+		Host:        os.Getenv("SMTP_HOST"),
+		Port:        587,
+		StartTLS:    true,
+		Username:    os.Getenv("SMTP_USERNAME"),
+		Password:    os.Getenv("SMTP_PASSWORD"),
+		From:        os.Getenv("SMTP_FROM"),
+		MinInterval: DefaultMinInterval,
+		TemplateDir: os.Getenv("NOTIFY_TEMPLATE_DIR"),
+	}
+	if cfg.Host == "" {
+		return cfg, false, nil
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		if cfg.Port, err = strconv.Atoi(v); err != nil || cfg.Port < 1 || cfg.Port > 65535 {
+			return cfg, false, fmt.Errorf("invalid SMTP_PORT %q, expected a port number", v)
+		}
+	}
+	if v := os.Getenv("SMTP_STARTTLS"); v != "" {
+		if cfg.StartTLS, err = strconv.ParseBool(v); err != nil {
+			return cfg, false, fmt.Errorf("invalid SMTP_STARTTLS %q, expected true or false", v)
+		}
+	}
+	if v := os.Getenv("NOTIFY_MIN_INTERVAL"); v != "" {
+		if cfg.MinInterval, err = time.ParseDuration(v); err != nil || cfg.MinInterval < 0 {
+			return cfg, false, fmt.Errorf("invalid NOTIFY_MIN_INTERVAL %q, expected a duration such as 30m", v)
+		}
+	}
+	if cfg.Recipients, err = ParseRecipients(os.Getenv("NOTIFY_RECIPIENTS")); err != nil {
+		return cfg, false, err
+	}
+	return cfg, true, nil
+}
+
+// ParseRecipients reads the recipient lists of locations, "location=address,address;location=address"
+func ParseRecipients(v string) (map[string][]string, error) {
+	recipients := make(map[string][]string)
+	for _, list := range strings.Split(v, ";") {
+		if strings.TrimSpace(list) == "" {
+			continue
+		}
+		room, addresses, found := strings.Cut(list, "=")
+		room = strings.TrimSpace(room)
+		if !found || room == "" {
+			return nil, fmt.Errorf("invalid NOTIFY_RECIPIENTS list %q, expected location=address,address", list)
+		}
+		for _, address := range strings.Split(addresses, ",") {
+			a, err := mail.ParseAddress(strings.TrimSpace(address))
+			if err != nil {
+				return nil, fmt.Errorf("invalid NOTIFY_RECIPIENTS address %q of %s: %v", address, room, err)
+			}
+			recipients[room] = append(recipients[room], a.Address)
+		}
+	}
+	return recipients, nil
+}
+
+// Notifier emails alert and recovery messages to the recipients of the location of an incident.
+// It is an IncidentListener: an opened incident is an alert email, unless the location had one
+// less than MinInterval ago; then the latest such incident is emailed once the interval has passed,
+// if it is still unresolved. A resolved incident whose alert was emailed is a recovery email.
+type Notifier struct {
+	cfg       Config
+	templates *Templates
+	logger    *log.Logger
+	queue     chan *Message
+
+	mu    sync.Mutex
+	rooms map[string]*roomState
+}
+
+// roomState is what was emailed about a location
+type roomState struct {
+	lastAlert  time.Time        // When the last alert email was queued
+	suppressed int              // Opened incidents not emailed since
+	pending    *models.Incident // The latest of them, while unresolved
+	emailed    map[int]bool     // Unresolved incidents whose alert was emailed, by ID
+}
+
+func New(cfg Config, logger *log.Logger) (*Notifier, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, fmt.Errorf("notify: the SMTP host and the sender are required")
+	}
+	templates, err := LoadTemplates(cfg.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		cfg:       cfg,
+		templates: templates,
+		logger:    logger,
+		queue:     make(chan *Message, queueSize),
+		rooms:     make(map[string]*roomState),
+	}, nil
+}
+
+// Recipients returns the addresses emailed about a location, including those of every location
+func (n *Notifier) Recipients(roomName string) []string {
+	var to []string
+	seen := make(map[string]bool)
+	for _, address := range append(n.cfg.Recipients[roomName], n.cfg.Recipients[AllRooms]...) {
+		if !seen[address] {
+			seen[address] = true
+			to = append(to, address)
+		}
+	}
+	return to
+}
+
+// IncidentChanged queues the alert email of an opened incident and the recovery email of a resolved one
+func (n *Notifier) IncidentChanged(incident *models.Incident) {
+	if len(n.Recipients(incident.RoomName)) == 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	room := n.room(incident.RoomName)
+	switch incident.State {
+	case models.IncidentOpen:
+		if time.Since(room.lastAlert) < n.cfg.MinInterval {
+			room.suppressed++
+			i := *incident
+			room.pending = &i
+			return
+		}
+		n.alert(room, incident, time.Now())
+	case models.IncidentResolved:
+		if room.pending != nil && room.pending.ID == incident.ID {
+			room.pending = nil
+		}
+		if room.emailed[incident.ID] {
+			delete(room.emailed, incident.ID)
+			n.enqueue(KindRecovery, incident, 0)
+		}
+	}
+}
+
+func (n *Notifier) room(roomName string) *roomState {
+	room, ok := n.rooms[roomName]
+	if !ok {
+		room = &roomState{emailed: make(map[int]bool)}
+		n.rooms[roomName] = room
+	}
+	return room
+}
+
+// alert queues the alert email of an incident, with how many were not emailed before it
+func (n *Notifier) alert(room *roomState, incident *models.Incident, now time.Time) {
+	n.enqueue(KindAlert, incident, room.suppressed)
+	room.lastAlert = now
+	room.suppressed = 0
+	room.pending = nil
+	room.emailed[incident.ID] = true
+}
+
+// flush queues the alert emails of the unresolved incidents held back until the interval passed
+func (n *Notifier) flush(now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, room := range n.rooms {
+		if room.pending != nil && now.Sub(room.lastAlert) >= n.cfg.MinInterval {
+			// * The suppressed count includes the pending incident itself *
+			room.suppressed--
+			n.alert(room, room.pending, now)
+		}
+	}
+}
+
+// TemplateData is what the templates are executed with, the incident of alert and recovery emails
+// or the digest of digest emails
+type TemplateData struct {
+	Incident   *models.Incident
+	Suppressed int // Incidents of the location not emailed because of the rate limit
+	Digest     *models.Digest
+}
+
+func (n *Notifier) enqueue(kind string, incident *models.Incident, suppressed int) {
+	n.queueEmail(kind, incident.RoomName, n.Recipients(incident.RoomName), &TemplateData{Incident: incident, Suppressed: suppressed})
+}
+
+// DigestReady queues the digest emails, each recipient is emailed the part of the digest
+// about the locations they are recipients of. Digests are not rate limited.
+func (n *Notifier) DigestReady(digest *models.Digest) {
+	// * Recipients of the same locations share an email *
+	var order []string
+	groups := make(map[string]*digestGroup)
+	for _, address := range n.addresses() {
+		var rooms []string
+		locations := []models.LocationDigest{}
+		for _, location := range digest.Locations {
+			if slices.Contains(n.Recipients(location.RoomName), address) {
+				rooms = append(rooms, location.RoomName)
+				locations = append(locations, location)
+			}
+		}
+		if len(locations) == 0 {
+			continue
+		}
+		key := strings.Join(rooms, "\x00")
+		group, ok := groups[key]
+		if !ok {
+			d := *digest
+			d.Locations = locations
+			group = &digestGroup{digest: &d}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group.to = append(group.to, address)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		n.queueEmail(KindDigest, group.digest.Period+" digest", group.to, &TemplateData{Digest: group.digest})
+	}
+}
+
+type digestGroup struct {
+	to     []string
+	digest *models.Digest
+}
+
+// addresses returns every recipient once, by location name
+func (n *Notifier) addresses() []string {
+	rooms := make([]string, 0, len(n.cfg.Recipients))
+	for room := range n.cfg.Recipients {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, room := range rooms {
+		for _, address := range n.cfg.Recipients[room] {
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// queueEmail renders an email and queues it, what names it in the logs
+func (n *Notifier) queueEmail(kind, what string, to []string, data *TemplateData) {
+	subject, text, html, err := n.templates.Render(kind, data)
+	if err != nil {
+		n.logger.Printf("Error rendering %s email of %s: %v", kind, what, err)
+		return
+	}
+	msg := &Message{To: to, Subject: subject, Text: text, HTML: html}
+	select {
+	case n.queue <- msg:
+	default:
+		n.logger.Printf("Email queue full, dropping %s email of %s", kind, what)
+	}
+}
+
+// Run sends the queued emails and the held back alerts until ctx is cancelled
+func (n *Notifier) Run(ctx context.Context) {
+	n.logger.Printf("Emailing alerts through %s:%d", n.cfg.Host, n.cfg.Port)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-n.queue:
+			if err := n.send(msg); err != nil {
+				n.logger.Printf("Error emailing %q to %s: %v", msg.Subject, strings.Join(msg.To, ", "), err)
+			}
+		case now := <-ticker.C:
+			n.flush(now)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// * fakeSMTP is a local SMTP server keeping the emails it receives *
+type fakeSMTP struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	auth   string // Decoded AUTH PLAIN credentials
+	emails []received
+}
+
+type received struct {
+	from string
+	to   []string
+	msg  *mail.Message
+	body string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var email received
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			creds, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			s.mu.Lock()
+			s.auth = string(creds)
+			s.mu.Unlock()
+			reply("235 Authenticated")
+		case "MAIL":
+			email.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			email.to = append(email.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			email.msg, _ = mail.ReadMessage(strings.NewReader(data.String()))
+			b, _ := io.ReadAll(email.msg.Body)
+			email.body = string(b)
+			s.mu.Lock()
+			s.emails = append(s.emails, email)
+			s.mu.Unlock()
+			email = received{}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// wait returns the emails once n were received
+func (s *fakeSMTP) wait(t *testing.T, n int) []received {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		emails := append([]received(nil), s.emails...)
+		s.mu.Unlock()
+		if len(emails) >= n {
+			return emails
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("got %d emails, want %d", len(s.emails), n)
+	return nil
+}
+
+func newTestNotifier(t *testing.T, s *fakeSMTP, templateDir string) *Notifier {
+	t.Helper()
+	recipients, err := ParseRecipients("Office=teacher@example.com, office@example.com;*=principal@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := New(Config{
+		Host:        "127.0.0.1",
+		Port:        s.ln.Addr().(*net.TCPAddr).Port,
+		Username:    "noisemeter",
+		Password:    "secret",
+		From:        "noisemeter@example.com",
+		Recipients:  recipients,
+		MinInterval: time.Hour,
+		TemplateDir: templateDir,
+	}, log.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go n.Run(ctx)
+	return n
+}
+
+// textPart returns the plain text body of a multipart/alternative email
+func textPart(t *testing.T, email received) string {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(email.msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := multipart.NewReader(strings.NewReader(email.body), params["boundary"]).NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(part)
+	return string(b)
+}
+
+func incident(id int, state string) *models.Incident {
+	return &models.Incident{ID: id, RoomName: "Office", State: state, OpenedAt: "2024-06-01T10:00:00Z", Alerts: 3, PeakLevel: 88.4,
+		ResolvedBy: "Ms. Smith", ResolvedAt: "2024-06-01T10:20:00Z"}
+}
+
+func TestAlertAndRecoveryEmails(t *testing.T) {
+	s := newFakeSMTP(t)
+	n := newTestNotifier(t, s, "")
+
+	n.IncidentChanged(incident(1, models.IncidentOpen))
+	n.IncidentChanged(incident(1, models.IncidentAcknowledged))
+	n.IncidentChanged(incident(1, models.IncidentResolved))
+	// * A room without recipients is not emailed about *
+	n.IncidentChanged(&models.Incident{ID: 2, RoomName: "Hall", State: models.IncidentOpen})
+
+	emails := s.wait(t, 2)
+	alert, recovery := emails[0], emails[1]
+	if alert.from != "noisemeter@example.com" || len(alert.to) != 3 || alert.to[2] != "principal@example.com" {
+		t.Errorf("unexpected envelope: %s to %v", alert.from, alert.to)
+	}
+	s.mu.Lock()
+	if s.auth != "\x00noisemeter\x00secret" {
+		t.Errorf("unexpected credentials: %q", s.auth)
+	}
+	s.mu.Unlock()
+	if subject := alert.msg.Header.Get("Subject"); subject != "Noise alert in Office" {
+		t.Errorf("unexpected alert subject: %q", subject)
+	}
+	if text := textPart(t, alert); !strings.Contains(text, "88.4 dB") {
+		t.Errorf("unexpected alert text: %q", text)
+	}
+	if subject := recovery.msg.Header.Get("Subject"); subject != "Office is calm again" {
+		t.Errorf("unexpected recovery subject: %q", subject)
+	}
+}
+
+func TestAlertEmailsAreRateLimited(t *testing.T) {
+	s := newFakeSMTP(t)
+	n := newTestNotifier(t, s, "")
+
+	n.IncidentChanged(incident(1, models.IncidentOpen))
+	n.IncidentChanged(incident(1, models.IncidentResolved))
+	for id := 2; id <= 4; id++ {
+		n.IncidentChanged(incident(id, models.IncidentOpen))
+	}
+	// * Not emailed, so no recovery either *
+	n.IncidentChanged(incident(2, models.IncidentResolved))
+	s.wait(t, 2)
+
+	// * Once the interval has passed, the latest unresolved incident is emailed *
+	n.flush(time.Now().Add(time.Hour))
+	emails := s.wait(t, 3)
+	if len(emails) != 3 {
+		t.Fatalf("got %d emails, want 3", len(emails))
+	}
+	if text := textPart(t, emails[2]); !strings.Contains(text, "incident 4") || !strings.Contains(text, "2 more incidents") {
+		t.Errorf("unexpected held back alert: %q", text)
+	}
+
+	n.flush(time.Now().Add(3 * time.Hour))
+	n.IncidentChanged(incident(4, models.IncidentResolved))
+	if emails := s.wait(t, 4); len(emails) != 4 || emails[3].msg.Header.Get("Subject") != "Office is calm again" {
+		t.Errorf("the held back incident should get its recovery email only")
+	}
+}
+
+func TestTemplatesCanBeOverridden(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "alert.txt.tmpl"), []byte(`{{define "subject"}}[Noise] {{.Incident.RoomName}}{{end}}Too loud!`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newFakeSMTP(t)
+	n := newTestNotifier(t, s, dir)
+
+	n.IncidentChanged(incident(1, models.IncidentOpen))
+	email := s.wait(t, 1)[0]
+	if subject := email.msg.Header.Get("Subject"); subject != "[Noise] Office" {
+		t.Errorf("unexpected subject: %q", subject)
+	}
+	if text := textPart(t, email); text != "Too loud!" {
+		t.Errorf("unexpected text: %q", text)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "recovery.txt.tmpl"), []byte(`No subject`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplates(dir); err == nil {
+		t.Error("a text template without a subject block should be rejected")
+	}
+}
+
+func TestDigestEmails(t *testing.T) {
+	s := newFakeSMTP(t)
+	n := newTestNotifier(t, s, "")
+
+	n.DigestReady(&models.Digest{Period: models.DigestDaily, From: "2024-06-02T07:00:00Z", To: "2024-06-03T07:00:00Z", Timezone: "UTC",
+		Locations: []models.LocationDigest{
+			{RoomName: "Office", Current: models.DigestStats{Samples: 10, LAeq: 62.5, Alerts: 4, MinutesAboveThreshold: 35},
+				Change: models.DigestStats{Alerts: 2, LAeq: 1.5}, Trend: models.TrendLouder,
+				LoudestPeriods: []models.LoudPeriod{{Start: "2024-06-02T10:00:00Z", LAeq: 71.2, Alerts: 3}}},
+			{RoomName: "Hall"},
+		}})
+
+	// * The principal gets every location, the Office recipients share an email about theirs *
+	emails := s.wait(t, 2)
+	principal, office := emails[0], emails[1]
+	if len(principal.to) != 1 || principal.to[0] != "principal@example.com" || len(office.to) != 2 {
+		t.Fatalf("unexpected recipients: %v and %v", principal.to, office.to)
+	}
+	if subject := office.msg.Header.Get("Subject"); subject != "Daily noise digest of Office" {
+		t.Errorf("unexpected subject: %q", subject)
+	}
+	if text := textPart(t, principal); !strings.Contains(text, "No readings.") || !strings.Contains(text, "Trend: louder by 1.5 dB") {
+		t.Errorf("unexpected digest text: %q", text)
+	}
+	if text := textPart(t, office); strings.Contains(text, "Hall") || !strings.Contains(text, "2024-06-02T10:00:00Z: 71.2 dB, 3 alerts") {
+		t.Errorf("unexpected digest text: %q", text)
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	for _, v := range []string{"Office", "=a@example.com", "Office=not an address"} {
+		if _, err := ParseRecipients(v); err == nil {
+			t.Errorf("%q should be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Kinds of emails, each has a <kind>.txt.tmpl and a <kind>.html.tmpl template
+const (
+	KindAlert    = "alert"    // An incident was opened
+	KindRecovery = "recovery" // An emailed incident was resolved
+	KindDigest   = "digest"   // The scheduled daily or weekly digest
+)
+
+var kinds = []string{KindAlert, KindRecovery, KindDigest}
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Templates render the emails. The text template defines the subject in a "subject" block
+// and is the plain text body, the HTML template is the HTML body.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the templates of every kind, a file of the same name in dir
+// replaces the default one. dir may be empty to use the defaults.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	for _, kind := range kinds {
+		src, err := readTemplate(dir, kind+".txt.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		text, err := texttemplate.New(kind).Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		if text.Lookup("subject") == nil {
+			return nil, errors.New("notify: the " + kind + ".txt.tmpl template has no subject block")
+		}
+		t.text[kind] = text
+
+		if src, err = readTemplate(dir, kind+".html.tmpl"); err != nil {
+			return nil, err
+		}
+		if t.html[kind], err = htmltemplate.New(kind).Parse(src); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// readTemplate reads a template from dir, or the default one if dir has none
+func readTemplate(dir, name string) (string, error) {
+	if dir != "" {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(b), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	b, err := defaultTemplates.ReadFile("templates/" + name)
+	return string(b), err
+}
+
+// Render executes the templates of a kind with data
+func (t *Templates) Render(kind string, data any) (subject, text, html string, err error) {
+	var buf bytes.Buffer
+	if err := t.text[kind].ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	// * A subject is a single line *
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	if err := t.text[kind].Execute(&buf, data); err != nil {
+		return "", "", "", err
+	}
+	text = buf.String()
+
+	buf.Reset()
+	if err := t.html[kind].Execute(&buf, data); err != nil {
+		return "", "", "", err
+	}
+	return subject, text, buf.String(), nil
+}
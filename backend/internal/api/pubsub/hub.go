@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"goapi/internal/api/repository/models"
+	"sync"
+)
+
+// Event is a stored reading with a sequence number, used as the SSE event ID
+type Event struct {
+	ID   uint64
+	Data models.Data
+}
+
+// Filter decides whether a subscriber is interested in a reading
+type Filter func(data *models.Data) bool
+
+// Hub is an in-process publish/subscribe hub for stored readings.
+// It keeps the last events in memory, so a subscriber that reconnects
+// with the ID of the last event it saw doesn't miss anything in between.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event // Ring buffer of the most recent events
+	next        int     // Position of the next event in history
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// Subscription receives the published events that pass its filter on C.
+// C is closed when the subscription or the hub is closed,
+// or when the subscriber falls too far behind.
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+	hub    *Hub
+}
+
+const subscriberBuffer = 64
+
+// NewHub creates a hub that remembers the last historySize events
+func NewHub(historySize int) *Hub {
+	if historySize < 1 {
+		historySize = 1
+	}
+	return &Hub{
+		history:     make([]Event, 0, historySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends a copy of data to every interested subscriber
+func (h *Hub) Publish(data *models.Data) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.lastID++
+	event := Event{ID: h.lastID, Data: *data}
+
+	if len(h.history) < cap(h.history) {
+		h.history = append(h.history, event)
+	} else {
+		h.history[h.next] = event
+	}
+	h.next = (h.next + 1) % cap(h.history)
+
+	for sub := range h.subscribers {
+		if sub.filter != nil && !sub.filter(&event.Data) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			// * The subscriber is too slow, drop it so it can reconnect and resume from history *
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber.
+// If lastEventID is not 0, the events after it that are still in history are returned,
+// so they can be replayed before reading from the subscription.
+func (h *Hub) Subscribe(lastEventID uint64, filter Filter) (*Subscription, []Event) {
+	c := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, filter: filter, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(c)
+		return sub, nil
+	}
+	h.subscribers[sub] = struct{}{}
+
+	// * An ID from before a restart can be higher than ours, nothing can be replayed then *
+	if lastEventID == 0 || lastEventID >= h.lastID {
+		return sub, nil
+	}
+
+	var missed []Event
+	for i := 0; i < len(h.history); i++ {
+		// * Walk the ring buffer from the oldest event *
+		event := h.history[(h.next+i)%len(h.history)]
+		if event.ID > lastEventID && (filter == nil || filter(&event.Data)) {
+			missed = append(missed, event)
+		}
+	}
+	return sub, missed
+}
+
+// Close unsubscribes and closes C
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Close closes every subscription, publishing after this is a no-op
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		h.remove(sub)
+	}
+}
+
+// remove must be called with h.mu held
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"goapi/internal/api/repository/models"
+	"testing"
+)
+
+func TestHubPublishFiltersSubscribers(t *testing.T) {
+	hub := NewHub(10)
+	defer hub.Close()
+
+	roomA, _ := hub.Subscribe(0, func(d *models.Data) bool { return d.RoomName == "Room_A" })
+	all, _ := hub.Subscribe(0, nil)
+
+	hub.Publish(&models.Data{DeviceID: "arduino_001", RoomName: "Room_A", SoundLevel: 60})
+	hub.Publish(&models.Data{DeviceID: "arduino_002", RoomName: "Room_B", SoundLevel: 70})
+
+	if event := <-roomA.C; event.ID != 1 || event.Data.RoomName != "Room_A" {
+		t.Errorf("unexpected event for Room_A: %+v", event)
+	}
+	if len(roomA.C) != 0 {
+		t.Errorf("Room_A subscriber got %d unexpected events", len(roomA.C))
+	}
+	if len(all.C) != 2 {
+		t.Errorf("expected 2 events for unfiltered subscriber, got %d", len(all.C))
+	}
+}
+
+func TestHubReplaysMissedEvents(t *testing.T) {
+	hub := NewHub(3)
+	defer hub.Close()
+
+	for i := 0; i < 5; i++ {
+		hub.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: float64(i)})
+	}
+
+	// * Only the last 3 events are kept, so resuming after event 1 replays 3, 4 and 5 *
+	sub, missed := hub.Subscribe(1, nil)
+	defer sub.Close()
+
+	if len(missed) != 3 {
+		t.Fatalf("expected 3 missed events, got %d", len(missed))
+	}
+	for i, event := range missed {
+		if event.ID != uint64(i+3) {
+			t.Errorf("missed[%d]: expected ID %d, got %d", i, i+3, event.ID)
+		}
+	}
+
+	// * An ID from before a restart replays nothing *
+	_, missed = hub.Subscribe(100, nil)
+	if len(missed) != 0 {
+		t.Errorf("expected no missed events, got %d", len(missed))
+	}
+}
+
+func TestHubCloseClosesSubscriptions(t *testing.T) {
+	hub := NewHub(10)
+	sub, _ := hub.Subscribe(0, nil)
+
+	hub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("expected subscription channel to be closed")
+	}
+
+	// * Publishing and closing the subscription after the hub is closed must not panic *
+	hub.Publish(&models.Data{})
+	sub.Close()
+}
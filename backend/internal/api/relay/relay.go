@@ -0,0 +1,344 @@
+// Package relay forwards the readings stored by a local instance (e.g. on a Raspberry Pi in a school)
+// to a central instance through its /api/data endpoint.
+// Readings are queued in the local database first, so they survive uplink outages and restarts.
+// Every request is signed with the key shared with the upstream (see Sign), so the upstream
+// keeps the received_at the relay stamped on arrival.
+package relay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// timeFormat has a fixed width, so timestamps in the outbox compare as strings
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+const (
+	batchSize       = 50
+	pollInterval    = 5 * time.Second
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// SignatureHeader carries the signature of a relayed request body
+const SignatureHeader = "Relay-Signature"
+
+type Config struct {
+	UpstreamURL       string // Base URL of the upstream API, e.g. https://central.example.com/api
+	Username          string
+	Password          string
+	Key               []byte // Shared with the upstream, which trusts the requests signed with it
+	MinBackoff        time.Duration
+	MaxBackoff        time.Duration
+	ReconcileInterval time.Duration // How often acked readings are checked on the upstream
+}
+
+// ConfigFromEnv reads RELAY_UPSTREAM_URL, RELAY_USERNAME, RELAY_PASSWORD and RELAY_KEY.
+// ok is false when RELAY_UPSTREAM_URL is not set, in which case the instance doesn't relay.
+// RELAY_KEY is also read then, a central instance trusts the relays signing with it.
+func ConfigFromEnv() (Config, bool) {
+	cfg := Config{
+		UpstreamURL:       strings.TrimSuffix(os.Getenv("RELAY_UPSTREAM_URL"), "/"),
+		Username:          os.Getenv("RELAY_USERNAME"),
+		Password:          os.Getenv("RELAY_PASSWORD"),
+		Key:               []byte(os.Getenv("RELAY_KEY")),
+		MinBackoff:        time.Second,
+		MaxBackoff:        10 * time.Minute,
+		ReconcileInterval: time.Hour,
+	}
+	return cfg, cfg.UpstreamURL != ""
+}
+
+// Relay queues stored readings and forwards them upstream
+type Relay struct {
+	cfg    Config
+	outbox models.OutboxRepository
+	client *http.Client
+	logger *log.Logger
+	wake   chan struct{}
+}
+
+func New(cfg Config, outbox models.OutboxRepository, logger *log.Logger) (*Relay, error) {
+	if len(cfg.Key) < 16 {
+		return nil, fmt.Errorf("relay: RELAY_KEY must be at least 16 bytes and the same as the upstream's")
+	}
+	return &Relay{
+		cfg:    cfg,
+		outbox: outbox,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of a request body with the key shared by the relays and the upstream
+func Sign(body []byte, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publish queues a stored reading
+func (r *Relay) Publish(data *models.Data) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		r.logger.Println("Error encoding reading for upstream:", err)
+		return
+	}
+
+	now := time.Now().UTC().Format(timeFormat)
+	entry := &models.OutboxEntry{
+		IdempotencyKey: newKey(),
+		DeviceID:       data.DeviceID,
+		IsPeriodic:     data.IsPeriodic,
+		Payload:        string(payload),
+		Status:         models.OutboxPending,
+		NextAttempt:    now,
+		CreatedAt:      now,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := r.outbox.Enqueue(entry, ctx); err != nil {
+		r.logger.Printf("Error queueing reading of %s for upstream: %v", data.DeviceID, err)
+		return
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run forwards queued readings and reconciles acked ones until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	r.logger.Println("Relaying readings to " + r.cfg.UpstreamURL)
+
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	reconcile := time.NewTicker(r.cfg.ReconcileInterval)
+	defer reconcile.Stop()
+
+	for {
+		r.forward(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-poll.C:
+		case <-reconcile.C:
+			r.reconcile(ctx)
+		}
+	}
+}
+
+// forward sends the due readings, stopping at the first one the upstream can't take right now
+func (r *Relay) forward(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC()
+		entries, err := r.outbox.GetDue(now.Format(timeFormat), batchSize, ctx)
+		if err != nil {
+			r.logger.Println("Error reading relay outbox:", err)
+			return
+		}
+		if len(entries) == 0 {
+			return
+		}
+
+		for _, entry := range entries {
+			upstreamID, err := r.send(ctx, entry)
+			var rejected rejectedError
+			switch {
+			case err == nil:
+				entry.Status = models.OutboxAcked
+				entry.UpstreamID = upstreamID
+				entry.AckedAt = time.Now().UTC().Format(timeFormat)
+				entry.LastError = ""
+			case errors.As(err, &rejected):
+				r.logger.Printf("Upstream rejected reading of %s: %v", entry.DeviceID, err)
+				entry.Status = models.OutboxRejected
+				entry.LastError = err.Error()
+			default:
+				entry.Attempts++
+				entry.NextAttempt = now.Add(backoff(entry.Attempts, r.cfg.MinBackoff, r.cfg.MaxBackoff)).Format(timeFormat)
+				entry.LastError = err.Error()
+			}
+
+			// * An acked latest reading isn't reconciled, so there is nothing left to keep it for *
+			if entry.Status == models.OutboxAcked && !entry.IsPeriodic {
+				err = r.outbox.Delete(entry.ID, ctx)
+			} else {
+				err = r.outbox.Update(entry, ctx)
+			}
+			if err != nil {
+				r.logger.Println("Error updating relay outbox:", err)
+				return
+			}
+			if entry.Status == models.OutboxPending {
+				// * The upstream is unreachable or overloaded, the next readings would fail as well *
+				return
+			}
+		}
+	}
+}
+
+// rejectedError is a response that retrying won't change
+type rejectedError struct {
+	status int
+	body   string
+}
+
+func (e rejectedError) Error() string {
+	return fmt.Sprintf("upstream returned %d: %s", e.status, e.body)
+}
+
+// send posts a reading to the upstream /data endpoint and returns its ID there
+func (r *Relay) send(ctx context.Context, entry *models.OutboxEntry) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.UpstreamURL+"/data", strings.NewReader(entry.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", entry.IdempotencyKey)
+	req.Header.Set(SignatureHeader, Sign([]byte(entry.Payload), r.cfg.Key))
+	if r.cfg.Username != "" {
+		req.SetBasicAuth(r.cfg.Username, r.cfg.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		var stored models.Data
+		if err := json.Unmarshal(body, &stored); err != nil {
+			return 0, fmt.Errorf("invalid upstream response: %w", err)
+		}
+		return stored.ID, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests &&
+		resp.StatusCode != http.StatusUnauthorized:
+		// * The reading itself is refused, e.g. it fails validation upstream *
+		return 0, rejectedError{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	default:
+		return 0, fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+}
+
+// reconcile checks that the periodic readings the upstream acked are still there,
+// readings it lost (e.g. restored from a backup) are queued again
+func (r *Relay) reconcile(ctx context.Context) {
+	now := time.Now().UTC()
+
+	// * Give the upstream a moment, it may still be replicating *
+	entries, err := r.outbox.GetUnverified(now.Add(-time.Minute).Format(timeFormat), batchSize*10, ctx)
+	if err != nil {
+		r.logger.Println("Error reading relay outbox:", err)
+		return
+	}
+
+	var verified, requeued int
+	for _, entry := range entries {
+		found, err := r.exists(ctx, entry)
+		if err != nil {
+			r.logger.Println("Error reconciling with upstream:", err)
+			break
+		}
+		if found {
+			entry.Status = models.OutboxVerified
+			verified++
+		} else {
+			entry.Status = models.OutboxPending
+			entry.Attempts = 0
+			entry.NextAttempt = now.Format(timeFormat)
+			entry.AckedAt = ""
+			requeued++
+		}
+		if err := r.outbox.Update(entry, ctx); err != nil {
+			r.logger.Println("Error updating relay outbox:", err)
+			return
+		}
+	}
+
+	if _, err := r.outbox.Prune(now.Add(-outboxRetention).Format(timeFormat), ctx); err != nil {
+		r.logger.Println("Error pruning relay outbox:", err)
+	}
+
+	counts, err := r.outbox.CountByStatus(ctx)
+	if err != nil {
+		r.logger.Println("Error reading relay outbox:", err)
+		return
+	}
+	r.logger.Printf("Relay reconciled %d readings (%d queued again), outbox: %d pending, %d rejected",
+		verified, requeued, counts[models.OutboxPending], counts[models.OutboxRejected])
+}
+
+// exists reports whether the upstream still has the reading of an acked entry
+func (r *Relay) exists(ctx context.Context, entry *models.OutboxEntry) (bool, error) {
+	var sent models.Data
+	if err := json.Unmarshal([]byte(entry.Payload), &sent); err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/data/%d", r.cfg.UpstreamURL, entry.UpstreamID), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.cfg.Username != "" {
+		req.SetBasicAuth(r.cfg.Username, r.cfg.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var stored models.Data
+		if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+			return false, err
+		}
+		return stored.DeviceID == sent.DeviceID && stored.MeasureTime == sent.MeasureTime, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+}
+
+// backoff doubles the delay with every attempt, with jitter so relays don't retry in lockstep
+func backoff(attempts int, min, max time.Duration) time.Duration {
+	d := time.Duration(float64(min) * math.Pow(2, float64(attempts-1)))
+	if d > max || d <= 0 {
+		d = max
+	}
+	return d/2 + time.Duration(mrand.Int63n(int64(d/2)+1))
+}
+
+func newKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "relay-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,180 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+// * upstream is a fake central instance *
+type upstream struct {
+	mu       sync.Mutex
+	failures int // Requests to fail with 503 before accepting
+	keys     []string
+	stored   map[int]models.Data
+	lastID   int
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if r.Method == http.MethodGet {
+		var id int
+		json.Unmarshal([]byte(r.URL.Path[len("/api/data/"):]), &id)
+		data, ok := u.stored[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(data)
+		return
+	}
+
+	u.keys = append(u.keys, r.Header.Get("Idempotency-Key"))
+	if u.failures > 0 {
+		u.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get(SignatureHeader) != Sign(body, testKey) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var data models.Data
+	json.Unmarshal(body, &data)
+	if data.SoundLevel > 150 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid data"}`))
+		return
+	}
+	u.lastID++
+	data.ID = u.lastID
+	u.stored[data.ID] = data
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(data)
+}
+
+func newTestRelay(t *testing.T, u *upstream) (*Relay, models.OutboxRepository) {
+	t.Helper()
+
+	srv := httptest.NewServer(u)
+	t.Cleanup(srv.Close)
+
+	db, err := SQLite.NewSqlite(t.TempDir() + "/relay.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	outbox, err := SQLite.NewOutboxRepository(db, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{UpstreamURL: srv.URL + "/api", Key: testKey, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, ReconcileInterval: time.Hour}
+	r, err := New(cfg, outbox, log.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, outbox
+}
+
+func TestRelayRetriesWithSameKey(t *testing.T) {
+	u := &upstream{failures: 1, stored: make(map[int]models.Data)}
+	r, outbox := newTestRelay(t, u)
+	ctx := context.Background()
+
+	r.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 61, MeasureTime: "2024-10-27T09:10:00Z", IsPeriodic: true})
+	r.forward(ctx)
+	time.Sleep(5 * time.Millisecond)
+	r.forward(ctx)
+
+	if len(u.keys) != 2 || u.keys[0] == "" || u.keys[0] != u.keys[1] {
+		t.Errorf("expected 2 attempts with the same Idempotency-Key, got %q", u.keys)
+	}
+	if len(u.stored) != 1 {
+		t.Fatalf("expected 1 stored reading upstream, got %d", len(u.stored))
+	}
+
+	counts, _ := outbox.CountByStatus(ctx)
+	if counts[models.OutboxAcked] != 1 {
+		t.Errorf("expected the entry to be acked, got %v", counts)
+	}
+}
+
+func TestRelayCoalescesLatestAndRejects(t *testing.T) {
+	u := &upstream{stored: make(map[int]models.Data)}
+	r, outbox := newTestRelay(t, u)
+	ctx := context.Background()
+
+	// * Only the newest latest reading of a device is sent *
+	r.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 50, MeasureTime: "2024-10-27T09:10:01Z"})
+	r.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 52, MeasureTime: "2024-10-27T09:10:02Z"})
+	r.Publish(&models.Data{DeviceID: "arduino_002", SoundLevel: 200, MeasureTime: "2024-10-27T09:10:02Z", IsPeriodic: true})
+	r.forward(ctx)
+
+	if len(u.keys) != 2 {
+		t.Errorf("expected 2 requests, got %d", len(u.keys))
+	}
+	if len(u.stored) != 1 || u.stored[1].SoundLevel != 52 {
+		t.Errorf("expected only the newest latest reading upstream, got %v", u.stored)
+	}
+
+	// * The acked latest reading is deleted right away *
+	counts, _ := outbox.CountByStatus(ctx)
+	if counts[models.OutboxAcked] != 0 {
+		t.Errorf("expected no acked entries left, got %v", counts)
+	}
+
+	// * A rejected reading is not retried *
+	r.forward(ctx)
+	counts, _ = outbox.CountByStatus(ctx)
+	if counts[models.OutboxRejected] != 1 || counts[models.OutboxPending] != 0 || len(u.keys) != 2 {
+		t.Errorf("unexpected outbox %v after %d requests", counts, len(u.keys))
+	}
+}
+
+func TestRelayReconcileRequeuesLostReadings(t *testing.T) {
+	u := &upstream{stored: make(map[int]models.Data)}
+	r, outbox := newTestRelay(t, u)
+	ctx := context.Background()
+
+	r.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 61, MeasureTime: "2024-10-27T09:10:00Z", IsPeriodic: true})
+	r.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 62, MeasureTime: "2024-10-27T09:20:00Z", IsPeriodic: true})
+	r.forward(ctx)
+
+	// * The upstream loses the first reading, e.g. it was restored from a backup *
+	u.mu.Lock()
+	delete(u.stored, 1)
+	u.mu.Unlock()
+
+	// * Entries acked in the last minute are left alone, so pretend they are older *
+	entries, _ := outbox.GetUnverified("9999", 10, ctx)
+	for _, e := range entries {
+		e.AckedAt = "2024-10-27T09:30:00.000Z"
+		outbox.Update(e, ctx)
+	}
+	r.reconcile(ctx)
+
+	counts, _ := outbox.CountByStatus(ctx)
+	if counts[models.OutboxVerified] != 1 || counts[models.OutboxPending] != 1 {
+		t.Fatalf("unexpected outbox after reconciling: %v", counts)
+	}
+
+	r.forward(ctx)
+	if len(u.stored) != 2 || u.stored[3].SoundLevel != 61 {
+		t.Errorf("expected the lost reading to be sent again, upstream has %d", len(u.stored))
+	}
+}
@@ -0,0 +1,192 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// calibrationColumns are the columns of the calibrations table in the order scanCalibration expects them
+const calibrationColumns = `id, device_id, offset_db, gain, curve, valid_from, description, created_at`
+
+type CalibrationRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewCalibrationRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Context) (models.CalibrationRepository, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	repo := &CalibrationRepository{
+		sqlDB: db,
+		ctx:   ctx,
+	}
+
+	// Create calibrations table, the calibrations of each device by the time they are valid from.
+	// The curve is kept as JSON
+	_, err = repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS calibrations (
+			id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			device_id TEXT NOT NULL,
+			offset_db DOUBLE PRECISION NOT NULL DEFAULT 0.0,
+			gain DOUBLE PRECISION NOT NULL DEFAULT 1.0,
+			curve TEXT NOT NULL DEFAULT '[]',
+			valid_from TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS calibrations_device_valid_from ON calibrations(device_id, valid_from);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func scanCalibration(row interface{ Scan(dest ...any) error }, c *models.Calibration) error {
+	var curve string
+	if err := row.Scan(&c.ID, &c.DeviceID, &c.Offset, &c.Gain, &curve, &c.ValidFrom, &c.Description, &c.CreatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(curve), &c.Curve)
+}
+
+func (r *CalibrationRepository) Create(c *models.Calibration, ctx context.Context) error {
+	curve, err := json.Marshal(c.Curve)
+	if err != nil {
+		return err
+	}
+	return r.sqlDB.QueryRowContext(ctx, `
+		INSERT INTO calibrations (device_id, offset_db, gain, curve, valid_from, description, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		c.DeviceID, c.Offset, c.Gain, string(curve), c.ValidFrom, c.Description, c.CreatedAt).Scan(&c.ID)
+}
+
+func (r *CalibrationRepository) ReadOne(id int, ctx context.Context) (*models.Calibration, error) {
+	row := r.sqlDB.QueryRowContext(ctx, `SELECT `+calibrationColumns+` FROM calibrations WHERE id = $1`, id)
+
+	var c models.Calibration
+	if err := scanCalibration(row, &c); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CalibrationRepository) ReadByDevice(deviceID string, ctx context.Context) ([]*models.Calibration, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+calibrationColumns+`
+		FROM calibrations WHERE device_id = $1 ORDER BY valid_from, id`, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return calibrationRows(rows)
+}
+
+func (r *CalibrationRepository) ReadAll(ctx context.Context) ([]*models.Calibration, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+calibrationColumns+`
+		FROM calibrations ORDER BY device_id, valid_from, id`)
+	if err != nil {
+		return nil, err
+	}
+	return calibrationRows(rows)
+}
+
+func (r *CalibrationRepository) Delete(id int, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `DELETE FROM calibrations WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func calibrationRows(rows *sql.Rows) ([]*models.Calibration, error) {
+	defer rows.Close()
+
+	calibrations := []*models.Calibration{}
+	for rows.Next() {
+		var c models.Calibration
+		if err := scanCalibration(rows, &c); err != nil {
+			return nil, err
+		}
+		calibrations = append(calibrations, &c)
+	}
+	return calibrations, rows.Err()
+}
+
+// Recalibrate recomputes the levels of the device's readings that have a raw level with the calibrations
+// valid at their measure time, and returns how many changed. The rollups of the changed readings are refreshed.
+func (r *DataRepository) Recalibrate(deviceID string, calibrations []*models.Calibration, ctx context.Context) (int, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+dataColumns+`
+	FROM data
+	WHERE device_id = $1 AND raw_sound_level IS NOT NULL`, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	var readings []*models.Data
+	for rows.Next() {
+		var d models.Data
+		if err := scanData(rows, &d); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		readings = append(readings, &d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	refreshed := make(map[string]bool)
+	for _, d := range readings {
+		level, alert := d.SoundLevel, d.IsAlert
+		models.Recalibrate(d, models.CalibrationAt(calibrations, d.MeasureTime))
+		if d.SoundLevel == level && d.IsAlert == alert {
+			continue
+		}
+
+		args := append([]any{d.SoundLevel, d.IsAlert, d.MaxLevel, d.MinLevel}, bandArgs(d)...)
+		if _, err := tx.ExecContext(ctx, `UPDATE data SET
+		sound_level = $1, is_alert = $2, max_level = $3, min_level = $4,
+		band_63 = $5, band_125 = $6, band_250 = $7, band_500 = $8, band_1000 = $9, band_2000 = $10, band_4000 = $11, band_8000 = $12
+		WHERE id = $13`, append(args, d.ID)...); err != nil {
+			return 0, err
+		}
+		changed++
+
+		// * The rollups are refreshed once per room and hour *
+		t, err := time.Parse(time.RFC3339, d.MeasureTime)
+		if err != nil {
+			continue
+		}
+		key := d.RoomName + "|" + models.RollupBucket(models.RollupHour, t).Format(time.RFC3339)
+		if refreshed[key] {
+			continue
+		}
+		refreshed[key] = true
+		if err := refreshRollups(tx, d, ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	return changed, tx.Commit()
+}
@@ -11,6 +11,83 @@ import (
 	//"log"
 )
 
+// dataColumns are the columns of the data table in the order scanData expects them
+const dataColumns = `id, device_id, room_name, sound_level, threshold, measure_time, is_alert, description, received_at, max_level, min_level, anomaly_score,
+	band_63, band_125, band_250, band_500, band_1000, band_2000, band_4000, band_8000, raw_sound_level, alert_rule_id`
+
+// scanData scans a row of dataColumns into data
+func scanData(row interface{ Scan(dest ...any) error }, data *models.Data) error {
+	var bands [len(models.BandFrequencies)]sql.NullFloat64
+	var raw sql.NullFloat64
+	if err := row.Scan(
+		&data.ID,
+		&data.DeviceID,
+		&data.RoomName,
+		&data.SoundLevel,
+		&data.Threshold,
+		&data.MeasureTime,
+		&data.IsAlert,
+		&data.Description,
+		&data.ReceivedAt,
+		&data.MaxLevel,
+		&data.MinLevel,
+		&data.AnomalyScore,
+		&bands[0], &bands[1], &bands[2], &bands[3], &bands[4], &bands[5], &bands[6], &bands[7],
+		&raw,
+		&data.AlertRuleID); err != nil {
+		return err
+	}
+	data.RawSoundLevel = raw.Float64
+
+	// * The bands are all set or all NULL *
+	data.Bands = nil
+	if bands[0].Valid {
+		data.Bands = make([]float64, len(bands))
+		for i, band := range bands {
+			data.Bands[i] = band.Float64
+		}
+	}
+	return nil
+}
+
+// bandArgs returns the octave band levels of data for the band columns, NULL without bands
+func bandArgs(data *models.Data) []any {
+	args := make([]any, len(models.BandFrequencies))
+	if len(data.Bands) == len(models.BandFrequencies) {
+		for i, level := range data.Bands {
+			args[i] = level
+		}
+	}
+	return args
+}
+
+// rawArg returns the raw level of data for the raw_sound_level column, NULL if it has none
+func rawArg(data *models.Data) any {
+	if data.RawSoundLevel == 0 {
+		return nil
+	}
+	return data.RawSoundLevel
+}
+
+// addedDataColumns are the columns added to the data table after its first release,
+// they are added to existing tables on startup
+var addedDataColumns = []struct{ name, definition string }{
+	{"received_at", "TEXT NOT NULL DEFAULT ''"},
+	{"max_level", "DOUBLE PRECISION NOT NULL DEFAULT 0.0"}, // Of the samples a periodic row was computed from
+	{"min_level", "DOUBLE PRECISION NOT NULL DEFAULT 0.0"},
+	{"anomaly_score", "DOUBLE PRECISION NOT NULL DEFAULT 0.0"}, // Of the reading against its room's baseline when it was stored
+	{"band_63", "DOUBLE PRECISION"},                            // Octave band levels, NULL for readings without them
+	{"band_125", "DOUBLE PRECISION"},
+	{"band_250", "DOUBLE PRECISION"},
+	{"band_500", "DOUBLE PRECISION"},
+	{"band_1000", "DOUBLE PRECISION"},
+	{"band_2000", "DOUBLE PRECISION"},
+	{"band_4000", "DOUBLE PRECISION"},
+	{"band_8000", "DOUBLE PRECISION"},
+	{"raw_sound_level", "DOUBLE PRECISION"},        // Before the device's calibration, NULL for readings stored without calibration
+	{"alert_rule_id", "BIGINT NOT NULL DEFAULT 0"}, // Most severe active alert rule, 0 if the reading was not an alert of a rule
+}
+
 type DataRepository struct {
 	sqlDB *sql.DB
 	createStmt,
@@ -20,6 +97,9 @@ type DataRepository struct {
 	readManyStmt,
 	updateStmt,
 	deleteStmt *sql.Stmt
+	readDuplicateStmt,
+	readKeyStmt,
+	saveKeyStmt *sql.Stmt
 	ctx context.Context
 }
 
@@ -67,10 +147,76 @@ func NewDataRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Contex
 		return nil, err
 	}
 
+	// Add the columns added later to tables created before
+	for _, column := range addedDataColumns {
+		if _, err := repo.sqlDB.Exec(`ALTER TABLE data ADD COLUMN IF NOT EXISTS ` + column.name + ` ` + column.definition); err != nil {
+			repo.sqlDB.Close()
+			return nil, err
+		}
+	}
+	if _, err := repo.sqlDB.Exec(`ALTER TABLE latest_data ADD COLUMN IF NOT EXISTS received_at TEXT NOT NULL DEFAULT ''`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	if _, err := repo.sqlDB.Exec(`ALTER TABLE latest_data ADD COLUMN IF NOT EXISTS raw_sound_level DOUBLE PRECISION`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Readings are unique per device and measure time, so a retried reading is stored once
+	if err := migrateReadings(repo.sqlDB); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Create the idempotency_keys table if it doesn't exist
+	// for the Idempotency-Key of each stored reading, per device.
+	// Keys used to be global, as they are only kept for a day the old table is dropped rather than migrated
+	var hasDevice int
+	if err := repo.sqlDB.QueryRow(`SELECT COUNT(*) FROM information_schema.columns
+	WHERE table_name = 'idempotency_keys' AND column_name = 'device_id'`).Scan(&hasDevice); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	if hasDevice == 0 {
+		if _, err := repo.sqlDB.Exec(`DROP TABLE IF EXISTS idempotency_keys`); err != nil {
+			repo.sqlDB.Close()
+			return nil, err
+		}
+	}
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS idempotency_keys (
+		device_id TEXT NOT NULL,
+		idempotency_key TEXT NOT NULL,
+		request_hash TEXT NOT NULL,
+		data_id BIGINT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (device_id, idempotency_key)
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Create the data_rollups table if it doesn't exist
+	// for the hourly and daily rollups of the data table
+	if err := createRollupTable(repo.sqlDB); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Create the room_baselines table if it doesn't exist
+	// for the typical level of each room per hour of the week
+	if err := createBaselineTable(repo.sqlDB); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
 	// * Create needed Prepared SQL statements, this is more efficient than running each query individually
 	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO data (
-	device_id, room_name, sound_level, threshold, measure_time, is_alert, description)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)`)
+	device_id, room_name, sound_level, threshold, measure_time, is_alert, description, received_at, max_level, min_level, anomaly_score,
+	band_63, band_125, band_250, band_500, band_1000, band_2000, band_4000, band_8000, raw_sound_level, alert_rule_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	ON CONFLICT(device_id, measure_time) DO NOTHING
+	RETURNING id`)
 
 	if err != nil {
 		repo.sqlDB.Close() // Close the database connection if statement preparation fails
@@ -79,15 +225,17 @@ func NewDataRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Contex
 	repo.createStmt = createStmt
 
 	upsertLatestStmt, err := repo.sqlDB.Prepare(`INSERT INTO latest_data (
-	device_id, room_name, sound_level, threshold, measure_time, is_alert, description)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	device_id, room_name, sound_level, threshold, measure_time, is_alert, description, received_at, raw_sound_level)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	ON CONFLICT(device_id) DO UPDATE SET
 		room_name = excluded.room_name,
 		sound_level = excluded.sound_level,
 		threshold = excluded.threshold,
 		measure_time = excluded.measure_time,
 		is_alert = excluded.is_alert,
-		description = excluded.description`)
+		description = excluded.description,
+		received_at = excluded.received_at,
+		raw_sound_level = excluded.raw_sound_level`)
 	if err != nil {
 		repo.sqlDB.Close() // Close the database connection if statement preparation fails
 		return nil, err
@@ -96,7 +244,7 @@ func NewDataRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Contex
 
 	// Read single record
 	readStmt, err := repo.sqlDB.Prepare(`SELECT 
-	id, device_id, room_name, sound_level, threshold, measure_time, is_alert, description
+	` + dataColumns + `
 	FROM data WHERE id = $1`)
 	if err != nil {
 		repo.sqlDB.Close()
@@ -106,7 +254,7 @@ func NewDataRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Contex
 
 	// Read latest record
 	ReadLatestStmt, err := repo.sqlDB.Prepare(`SELECT 
-	device_id, room_name, sound_level, threshold, measure_time, is_alert, description
+	device_id, room_name, sound_level, threshold, measure_time, is_alert, description, received_at, raw_sound_level
 	FROM latest_data WHERE device_id = $1`)
 	if err != nil {
 		repo.sqlDB.Close()
@@ -116,7 +264,7 @@ func NewDataRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Contex
 
 	// Read multiple records with pagination
 	readManyStmt, err := repo.sqlDB.Prepare(`SELECT
-	id, device_id, room_name, sound_level, threshold, measure_time, is_alert, description
+	` + dataColumns + `
 	FROM data LIMIT $1 OFFSET $2`)
 	if err != nil {
 		repo.sqlDB.Close()
@@ -127,8 +275,10 @@ func NewDataRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Contex
 	// Update record
 	updateStmt, err := repo.sqlDB.Prepare(`UPDATE data SET 
 	device_id = $1, room_name = $2, sound_level = $3, threshold = $4, 
-	measure_time = $5, is_alert = $6, description = $7
-	WHERE id = $8`)
+	measure_time = $5, is_alert = $6, description = $7,
+	band_63 = COALESCE($8, band_63), band_125 = COALESCE($9, band_125), band_250 = COALESCE($10, band_250), band_500 = COALESCE($11, band_500),
+	band_1000 = COALESCE($12, band_1000), band_2000 = COALESCE($13, band_2000), band_4000 = COALESCE($14, band_4000), band_8000 = COALESCE($15, band_8000)
+	WHERE id = $16`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
@@ -143,6 +293,37 @@ func NewDataRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Contex
 	}
 	repo.deleteStmt = deleteStmt
 
+	// Read the already stored copy of a reading
+	readDuplicateStmt, err := repo.sqlDB.Prepare(`SELECT
+	` + dataColumns + `
+	FROM data WHERE device_id = $1 AND measure_time = $2`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readDuplicateStmt = readDuplicateStmt
+
+	// Read and save idempotency keys
+	readKeyStmt, err := repo.sqlDB.Prepare(`SELECT data_id, request_hash FROM idempotency_keys WHERE device_id = $1 AND idempotency_key = $2`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readKeyStmt = readKeyStmt
+
+	saveKeyStmt, err := repo.sqlDB.Prepare(`INSERT INTO idempotency_keys (
+	device_id, idempotency_key, request_hash, data_id, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT(device_id, idempotency_key) DO UPDATE SET
+		request_hash = excluded.request_hash,
+		data_id = excluded.data_id,
+		created_at = excluded.created_at`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.saveKeyStmt = saveKeyStmt
+
 	go Close(ctx, repo)
 
 	return repo, nil
@@ -159,41 +340,137 @@ func Close(ctx context.Context, r *DataRepository) {
 	r.updateStmt.Close()
 	r.deleteStmt.Close()
 	r.readManyStmt.Close()
+	r.readDuplicateStmt.Close()
+	r.readKeyStmt.Close()
+	r.saveKeyStmt.Close()
 	r.sqlDB.Close()
 }
 
-func (r *DataRepository) Create(data *models.Data, ctx context.Context) error {
-
-	// Set default values if not provided
+// setDefaults sets default values if not provided
+func setDefaults(data *models.Data) {
 	if data.Threshold == 0 {
 		data.Threshold = 70.0
 	}
-	if data.SoundLevel >= data.Threshold {
-		data.IsAlert = true
-	}
 	if data.MeasureTime == "" {
 		data.MeasureTime = time.Now().Format(time.RFC3339)
 		//Fill with current time if not provided
 	}
+}
+
+func (r *DataRepository) Create(data *models.Data, ctx context.Context) error {
+	_, err := r.CreateIdempotent(data, models.IdempotencyKey{}, ctx)
+	return err
+}
+
+// CreateIdempotent stores data unless it was stored before, either under the same
+// idempotency key of the device or with the same device and measure time.
+// In that case data is replaced by the stored reading and created is false.
+// A key the device used for a reading with another hash is models.ErrIdempotencyKeyReused.
+// An empty key only deduplicates by device and measure time.
+func (r *DataRepository) CreateIdempotent(data *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	zones, err := baselineZones(r.sqlDB, ctx)
+	if err != nil {
+		return false, err
+	}
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if key.Key != "" {
+		var id int
+		var hash string
+		err := tx.StmtContext(ctx, r.readKeyStmt).QueryRowContext(ctx, data.DeviceID, key.Key).Scan(&id, &hash)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		if err == nil {
+			if hash != key.Hash {
+				return false, models.ErrIdempotencyKeyReused
+			}
+			err = scanData(tx.StmtContext(ctx, r.readStmt).QueryRowContext(ctx, id), data)
+			if err == nil {
+				return false, nil
+			}
+			// * The reading of the key has been cleaned up, store it again *
+			if err != sql.ErrNoRows {
+				return false, err
+			}
+		}
+	}
+
+	created, err := r.insert(tx, data, zones, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if key.Key != "" {
+		if _, err := tx.StmtContext(ctx, r.saveKeyStmt).ExecContext(ctx, data.DeviceID, key.Key, key.Hash, data.ID, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return false, err
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+// CreateBatch stores all readings in one transaction and reports which ones were new
+func (r *DataRepository) CreateBatch(data []*models.Data, ctx context.Context) ([]bool, error) {
+	zones, err := baselineZones(r.sqlDB, ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created := make([]bool, len(data))
+	for i, d := range data {
+		if created[i], err = r.insert(tx, d, zones, ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+// insert stores data in tx, or loads the reading with the same device and measure time
+func (r *DataRepository) insert(tx *sql.Tx, data *models.Data, zones models.BaselineZones, ctx context.Context) (bool, error) {
+	setDefaults(data)
+
+	// * Score the reading against the baseline learned before it *
+	baseline, err := scoreReading(tx, data, zones, ctx)
+	if err != nil {
+		return false, err
+	}
 
 	// Execute INSERT with correct field order
-	res, err := r.createStmt.ExecContext(ctx,
+	args := append([]any{
 		data.DeviceID,
 		data.RoomName,
 		data.SoundLevel,
 		data.Threshold,
 		data.MeasureTime,
 		data.IsAlert,
-		data.Description)
-	if err != nil {
-		return err
+		data.Description,
+		data.ReceivedAt,
+		data.MaxLevel,
+		data.MinLevel,
+		data.AnomalyScore}, bandArgs(data)...)
+	err = tx.StmtContext(ctx, r.createStmt).QueryRowContext(ctx, append(args, rawArg(data), data.AlertRuleID)...).Scan(&data.ID)
+	if err == sql.ErrNoRows {
+		// * Already stored *
+		return false, scanData(tx.StmtContext(ctx, r.readDuplicateStmt).QueryRowContext(ctx, data.DeviceID, data.MeasureTime), data)
 	}
-	id, err := res.LastInsertId()
 	if err != nil {
-		return err
+		return false, err
 	}
-	data.ID = int(id)
-	return nil
+	if err := learnReading(tx, baseline, data, ctx); err != nil {
+		return false, err
+	}
+	return true, addToRollups(tx, data, ctx)
 }
 
 func (r *DataRepository) CreateLatest(data *models.Data, ctx context.Context) error {
@@ -205,7 +482,9 @@ func (r *DataRepository) CreateLatest(data *models.Data, ctx context.Context) er
 		data.Threshold,
 		data.MeasureTime,
 		data.IsAlert,
-		data.Description)
+		data.Description,
+		data.ReceivedAt,
+		rawArg(data))
 	if err != nil {
 		return err
 	}
@@ -222,15 +501,7 @@ func (r *DataRepository) ReadOne(id int, ctx context.Context) (*models.Data, err
 	var data models.Data
 
 	// Scan with correct field order matching new schema
-	err := row.Scan(
-		&data.ID,
-		&data.DeviceID,
-		&data.RoomName,
-		&data.SoundLevel,
-		&data.Threshold,
-		&data.MeasureTime,
-		&data.IsAlert,
-		&data.Description)
+	err := scanData(row, &data)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -243,6 +514,7 @@ func (r *DataRepository) ReadOne(id int, ctx context.Context) (*models.Data, err
 func (r *DataRepository) ReadLatest(id string, ctx context.Context) (*models.Data, error) {
 	row := r.ReadLatestStmt.QueryRowContext(ctx, id)
 	var data models.Data
+	var raw sql.NullFloat64
 
 	err := row.Scan(
 		&data.DeviceID,
@@ -251,13 +523,16 @@ func (r *DataRepository) ReadLatest(id string, ctx context.Context) (*models.Dat
 		&data.Threshold,
 		&data.MeasureTime,
 		&data.IsAlert,
-		&data.Description)
+		&data.Description,
+		&data.ReceivedAt,
+		&raw)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
+	data.RawSoundLevel = raw.Float64
 	return &data, nil
 }
 
@@ -276,15 +551,7 @@ func (r *DataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context
 	var data []*models.Data
 	for rows.Next() {
 		var d models.Data
-		err := rows.Scan(
-			&d.ID,
-			&d.DeviceID,
-			&d.RoomName,
-			&d.SoundLevel,
-			&d.Threshold,
-			&d.MeasureTime,
-			&d.IsAlert,
-			&d.Description)
+		err := scanData(rows, &d)
 		if err != nil {
 			return nil, err
 		}
@@ -295,7 +562,7 @@ func (r *DataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context
 
 func (r *DataRepository) ReadAll() ([]*models.Data, error) {
 	rows, err := r.sqlDB.QueryContext(context.Background(),
-		`SELECT id, device_id, room_name, sound_level, threshold, measure_time, is_alert, description 
+		`SELECT `+dataColumns+` 
 		FROM data`)
 	if err != nil {
 		return nil, err
@@ -305,15 +572,7 @@ func (r *DataRepository) ReadAll() ([]*models.Data, error) {
 	var data []*models.Data
 	for rows.Next() {
 		var d models.Data
-		err := rows.Scan(
-			&d.ID,
-			&d.DeviceID,
-			&d.RoomName,
-			&d.SoundLevel,
-			&d.Threshold,
-			&d.MeasureTime,
-			&d.IsAlert,
-			&d.Description)
+		err := scanData(rows, &d)
 		if err != nil {
 			return nil, err
 		}
@@ -328,15 +587,31 @@ func (r *DataRepository) Update(data *models.Data, ctx context.Context) (int64,
 		data.MeasureTime = time.Now().Format(time.RFC3339)
 	}
 
-	res, err := r.updateStmt.ExecContext(ctx,
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// * The rollups of the old and the new version of the reading change *
+	var old models.Data
+	if err := scanData(tx.StmtContext(ctx, r.readStmt).QueryRowContext(ctx, data.ID), &old); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// * Bands are only changed when given *
+	args := append([]any{
 		data.DeviceID,
 		data.RoomName,
 		data.SoundLevel,
 		data.Threshold,
 		data.MeasureTime,
 		data.IsAlert,
-		data.Description,
-		data.ID)
+		data.Description}, bandArgs(data)...)
+	res, err := tx.StmtContext(ctx, r.updateStmt).ExecContext(ctx, append(args, data.ID)...)
 	if err != nil {
 		return 0, err
 	}
@@ -345,11 +620,32 @@ func (r *DataRepository) Update(data *models.Data, ctx context.Context) (int64,
 	if err != nil {
 		return 0, err
 	}
-	return rowsAffected, nil
+	if err := refreshRollups(tx, &old, ctx); err != nil {
+		return 0, err
+	}
+	if err := refreshRollups(tx, data, ctx); err != nil {
+		return 0, err
+	}
+	return rowsAffected, tx.Commit()
 }
 
 func (r *DataRepository) Delete(data *models.Data, ctx context.Context) (int64, error) {
-	res, err := r.deleteStmt.ExecContext(ctx, data.ID)
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// * Read the reading first, the caller may only know its ID *
+	var old models.Data
+	if err := scanData(tx.StmtContext(ctx, r.readStmt).QueryRowContext(ctx, data.ID), &old); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	res, err := tx.StmtContext(ctx, r.deleteStmt).ExecContext(ctx, data.ID)
 	if err != nil {
 		return 0, err
 	}
@@ -358,7 +654,10 @@ func (r *DataRepository) Delete(data *models.Data, ctx context.Context) (int64,
 	if err != nil {
 		return 0, err
 	}
-	return rowsAffected, nil
+	if err := refreshRollups(tx, &old, ctx); err != nil {
+		return 0, err
+	}
+	return rowsAffected, tx.Commit()
 }
 
 func (r *DataRepository) GetByRoom(roomName string, ctx context.Context) ([]*models.Data, error) {
@@ -366,7 +665,7 @@ func (r *DataRepository) GetByRoom(roomName string, ctx context.Context) ([]*mod
 	startTime := time.Now().AddDate(0, 0, -35) // 35 days = 5 weeks
 
 	rows, err := r.sqlDB.QueryContext(ctx, `
-		SELECT id, device_id, room_name, sound_level, threshold, measure_time, is_alert, description 
+		SELECT `+dataColumns+` 
 		FROM data 
 		WHERE room_name = $1 AND measure_time >= $2`,
 		roomName, startTime.Format(time.RFC3339))
@@ -378,15 +677,7 @@ func (r *DataRepository) GetByRoom(roomName string, ctx context.Context) ([]*mod
 	var data []*models.Data
 	for rows.Next() {
 		var d models.Data
-		err := rows.Scan(
-			&d.ID,
-			&d.DeviceID,
-			&d.RoomName,
-			&d.SoundLevel,
-			&d.Threshold,
-			&d.MeasureTime,
-			&d.IsAlert,
-			&d.Description)
+		err := scanData(rows, &d)
 		if err != nil {
 			return nil, err
 		}
@@ -410,7 +701,7 @@ func (r *DataRepository) GetDailySummary(roomName string, date time.Time, ctx co
 
 	// Query to get data for the specified room and date range
 	query := `
-	SELECT id, device_id, room_name, sound_level, threshold, measure_time, is_alert, description 
+	SELECT ` + dataColumns + ` 
 	FROM data
 	WHERE room_name = $1
 		AND measure_time >= $2
@@ -427,15 +718,7 @@ func (r *DataRepository) GetDailySummary(roomName string, date time.Time, ctx co
 	var data []*models.Data
 	for rows.Next() {
 		var d models.Data
-		err := rows.Scan(
-			&d.ID,
-			&d.DeviceID,
-			&d.RoomName,
-			&d.SoundLevel,
-			&d.Threshold,
-			&d.MeasureTime,
-			&d.IsAlert,
-			&d.Description)
+		err := scanData(rows, &d)
 		if err != nil {
 			return nil, err
 		}
@@ -444,6 +727,31 @@ func (r *DataRepository) GetDailySummary(roomName string, date time.Time, ctx co
 	return data, nil
 }
 
+// GetByRange returns the readings of a room, or of all rooms if roomName is empty, with from <= measure_time < to
+func (r *DataRepository) GetByRange(roomName string, from, to time.Time, ctx context.Context) ([]*models.Data, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+dataColumns+`
+	FROM data
+	WHERE ($1 = '' OR room_name = $1)
+		AND measure_time >= $2
+		AND measure_time < $3
+	ORDER BY measure_time ASC`,
+		roomName, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []*models.Data
+	for rows.Next() {
+		var d models.Data
+		if err := scanData(rows, &d); err != nil {
+			return nil, err
+		}
+		data = append(data, &d)
+	}
+	return data, rows.Err()
+}
+
 // ExecContext executes an arbitrary SQL statement (used for cleanup, etc.)
 func (r *DataRepository) ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
 	res, err := r.sqlDB.ExecContext(ctx, query, args...)
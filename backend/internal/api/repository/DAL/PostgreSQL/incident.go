@@ -0,0 +1,141 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+// incidentColumns are the columns of the incidents table in the order scanIncident expects them
+const incidentColumns = `id, room_name, state, alert_rule_id, opened_at, last_alert_at, alerts, peak_level,
+	acknowledged_by, acknowledged_at, acknowledge_note, resolved_by, resolved_at, resolve_note`
+
+type IncidentRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewIncidentRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Context) (models.IncidentRepository, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	repo := &IncidentRepository{
+		sqlDB: db,
+		ctx:   ctx,
+	}
+
+	// Create incidents table, the alerts of each room grouped until they are resolved
+	_, err = repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS incidents (
+			id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			room_name TEXT NOT NULL,
+			state TEXT NOT NULL DEFAULT 'open',
+			alert_rule_id BIGINT NOT NULL DEFAULT 0,
+			opened_at TEXT NOT NULL,
+			last_alert_at TEXT NOT NULL,
+			alerts INTEGER NOT NULL DEFAULT 0,
+			peak_level DOUBLE PRECISION NOT NULL DEFAULT 0.0,
+			acknowledged_by TEXT NOT NULL DEFAULT '',
+			acknowledged_at TEXT NOT NULL DEFAULT '',
+			acknowledge_note TEXT NOT NULL DEFAULT '',
+			resolved_by TEXT NOT NULL DEFAULT '',
+			resolved_at TEXT NOT NULL DEFAULT '',
+			resolve_note TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS incidents_state_room ON incidents(state, room_name);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func scanIncident(row interface{ Scan(dest ...any) error }, i *models.Incident) error {
+	return row.Scan(&i.ID, &i.RoomName, &i.State, &i.AlertRuleID, &i.OpenedAt, &i.LastAlertAt, &i.Alerts, &i.PeakLevel,
+		&i.AcknowledgedBy, &i.AcknowledgedAt, &i.AcknowledgeNote, &i.ResolvedBy, &i.ResolvedAt, &i.ResolveNote)
+}
+
+func (r *IncidentRepository) Create(i *models.Incident, ctx context.Context) error {
+	return r.sqlDB.QueryRowContext(ctx, `
+		INSERT INTO incidents (room_name, state, alert_rule_id, opened_at, last_alert_at, alerts, peak_level)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		i.RoomName, i.State, i.AlertRuleID, i.OpenedAt, i.LastAlertAt, i.Alerts, i.PeakLevel).Scan(&i.ID)
+}
+
+func (r *IncidentRepository) ReadOne(id int, ctx context.Context) (*models.Incident, error) {
+	return r.readOne(r.sqlDB.QueryRowContext(ctx, `SELECT `+incidentColumns+` FROM incidents WHERE id = $1`, id))
+}
+
+func (r *IncidentRepository) ReadUnresolved(roomName string, ctx context.Context) (*models.Incident, error) {
+	return r.readOne(r.sqlDB.QueryRowContext(ctx, `SELECT `+incidentColumns+`
+		FROM incidents WHERE room_name = $1 AND state != $2
+		ORDER BY id DESC LIMIT 1`, roomName, models.IncidentResolved))
+}
+
+func (r *IncidentRepository) readOne(row *sql.Row) (*models.Incident, error) {
+	var i models.Incident
+	if err := scanIncident(row, &i); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *IncidentRepository) ReadMany(state, roomName string, limit int, ctx context.Context) ([]*models.Incident, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+incidentColumns+`
+		FROM incidents
+		WHERE ($1 = '' OR state = $1) AND ($2 = '' OR room_name = $2)
+		ORDER BY opened_at DESC, id DESC
+		LIMIT $3`, state, roomName, limit)
+	if err != nil {
+		return nil, err
+	}
+	return incidentRows(rows)
+}
+
+func (r *IncidentRepository) ReadQuiet(lastAlertBefore string, ctx context.Context) ([]*models.Incident, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+incidentColumns+`
+		FROM incidents WHERE state != $1 AND last_alert_at < $2
+		ORDER BY id`, models.IncidentResolved, lastAlertBefore)
+	if err != nil {
+		return nil, err
+	}
+	return incidentRows(rows)
+}
+
+func (r *IncidentRepository) Update(i *models.Incident, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `UPDATE incidents SET
+		state = $1, last_alert_at = $2, alerts = $3, peak_level = $4,
+		acknowledged_by = $5, acknowledged_at = $6, acknowledge_note = $7, resolved_by = $8, resolved_at = $9, resolve_note = $10
+		WHERE id = $11`,
+		i.State, i.LastAlertAt, i.Alerts, i.PeakLevel,
+		i.AcknowledgedBy, i.AcknowledgedAt, i.AcknowledgeNote, i.ResolvedBy, i.ResolvedAt, i.ResolveNote, i.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func incidentRows(rows *sql.Rows) ([]*models.Incident, error) {
+	defer rows.Close()
+
+	incidents := []*models.Incident{}
+	for rows.Next() {
+		var i models.Incident
+		if err := scanIncident(rows, &i); err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, &i)
+	}
+	return incidents, rows.Err()
+}
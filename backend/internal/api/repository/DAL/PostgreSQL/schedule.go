@@ -0,0 +1,219 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"strings"
+)
+
+type ScheduleRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewScheduleRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Context) (models.ScheduleRepository, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	repo := &ScheduleRepository{
+		sqlDB: db,
+		ctx:   ctx,
+	}
+
+	// Create threshold_schedules table, one per location,
+	// schedule_entries table, the weekly time ranges, and schedule_exceptions table, the date ranges
+	_, err = repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS threshold_schedules (
+			location_id BIGINT PRIMARY KEY,
+			timezone TEXT NOT NULL DEFAULT 'UTC'
+		);
+		CREATE TABLE IF NOT EXISTS schedule_entries (
+			id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			location_id BIGINT NOT NULL,
+			label TEXT NOT NULL DEFAULT '',
+			weekdays TEXT NOT NULL,
+			start_time TEXT NOT NULL,
+			end_time TEXT NOT NULL,
+			threshold DOUBLE PRECISION NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS schedule_exceptions (
+			id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			location_id BIGINT NOT NULL,
+			label TEXT NOT NULL DEFAULT '',
+			from_date TEXT NOT NULL,
+			to_date TEXT NOT NULL,
+			threshold DOUBLE PRECISION NOT NULL DEFAULT 0.0
+		);
+		CREATE INDEX IF NOT EXISTS schedule_entries_location ON schedule_entries(location_id);
+		CREATE INDEX IF NOT EXISTS schedule_exceptions_location ON schedule_exceptions(location_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *ScheduleRepository) Read(locationID int64, ctx context.Context) (*models.ThresholdSchedule, error) {
+	schedules, err := r.read(locationID, ctx)
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	return schedules[0], nil
+}
+
+func (r *ScheduleRepository) ReadAll(ctx context.Context) ([]*models.ThresholdSchedule, error) {
+	return r.read(0, ctx)
+}
+
+// read returns the schedule of a location, of every location if locationID is 0
+func (r *ScheduleRepository) read(locationID int64, ctx context.Context) ([]*models.ThresholdSchedule, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT location_id, timezone FROM threshold_schedules
+		WHERE ($1 = 0 OR location_id = $1) ORDER BY location_id`, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*models.ThresholdSchedule{}
+	byLocation := make(map[int64]*models.ThresholdSchedule)
+	for rows.Next() {
+		s := &models.ThresholdSchedule{Entries: []models.ScheduleEntry{}, Exceptions: []models.ScheduleException{}}
+		if err := rows.Scan(&s.LocationID, &s.Timezone); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+		byLocation[s.LocationID] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := r.sqlDB.QueryContext(ctx, `SELECT id, location_id, label, weekdays, start_time, end_time, threshold
+		FROM schedule_entries WHERE ($1 = 0 OR location_id = $1) ORDER BY start_time, id`, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer entries.Close()
+	for entries.Next() {
+		var e models.ScheduleEntry
+		var id int64
+		var weekdays string
+		if err := entries.Scan(&e.ID, &id, &e.Label, &weekdays, &e.Start, &e.End, &e.Threshold); err != nil {
+			return nil, err
+		}
+		e.Weekdays = strings.Split(weekdays, ",")
+		if s, ok := byLocation[id]; ok {
+			s.Entries = append(s.Entries, e)
+		}
+	}
+	if err := entries.Err(); err != nil {
+		return nil, err
+	}
+
+	exceptions, err := r.sqlDB.QueryContext(ctx, `SELECT id, location_id, label, from_date, to_date, threshold
+		FROM schedule_exceptions WHERE ($1 = 0 OR location_id = $1) ORDER BY from_date, id`, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer exceptions.Close()
+	for exceptions.Next() {
+		var e models.ScheduleException
+		var id int64
+		if err := exceptions.Scan(&e.ID, &id, &e.Label, &e.From, &e.To, &e.Threshold); err != nil {
+			return nil, err
+		}
+		if s, ok := byLocation[id]; ok {
+			s.Exceptions = append(s.Exceptions, e)
+		}
+	}
+	return schedules, exceptions.Err()
+}
+
+func (r *ScheduleRepository) Replace(schedule *models.ThresholdSchedule, ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO threshold_schedules (location_id, timezone) VALUES ($1, $2)
+		ON CONFLICT(location_id) DO UPDATE SET timezone = excluded.timezone`,
+		schedule.LocationID, schedule.Timezone)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schedule_entries WHERE location_id = $1`, schedule.LocationID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schedule_exceptions WHERE location_id = $1`, schedule.LocationID); err != nil {
+		return err
+	}
+
+	for i := range schedule.Entries {
+		e := &schedule.Entries[i]
+		err := tx.QueryRowContext(ctx, `INSERT INTO schedule_entries (location_id, label, weekdays, start_time, end_time, threshold)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			schedule.LocationID, e.Label, strings.Join(e.Weekdays, ","), e.Start, e.End, e.Threshold).Scan(&e.ID)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range schedule.Exceptions {
+		if err := createException(ctx, tx, schedule.LocationID, &schedule.Exceptions[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *ScheduleRepository) Delete(locationID int64, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schedule_entries WHERE location_id = $1`, locationID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schedule_exceptions WHERE location_id = $1`, locationID); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM threshold_schedules WHERE location_id = $1`, locationID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func (r *ScheduleRepository) CreateException(locationID int64, exception *models.ScheduleException, ctx context.Context) error {
+	return createException(ctx, r.sqlDB, locationID, exception)
+}
+
+func createException(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}, locationID int64, e *models.ScheduleException) error {
+	return db.QueryRowContext(ctx, `INSERT INTO schedule_exceptions (location_id, label, from_date, to_date, threshold)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		locationID, e.Label, e.From, e.To, e.Threshold).Scan(&e.ID)
+}
+
+func (r *ScheduleRepository) DeleteException(locationID int64, id int, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `DELETE FROM schedule_exceptions WHERE id = $1 AND location_id = $2`, id, locationID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,211 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"strings"
+)
+
+// webhookColumns are the columns of the webhooks table in the order scanWebhook expects them
+const webhookColumns = `id, url, secret, room_name, min_severity, events, disabled, created_at`
+
+// deliveryColumns are the columns of the webhook_deliveries table in the order queryDeliveries scans them
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt, last_error, response_status, created_at, delivered_at`
+
+type WebhookRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewWebhookRepository(connStr string, sqlDB DAL.SQLDatabase, ctx context.Context) (models.WebhookRepository, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	repo := &WebhookRepository{
+		sqlDB: db,
+		ctx:   ctx,
+	}
+
+	// Create webhooks table, the receivers of alert events,
+	// and webhook_deliveries table, the queue of events to send them
+	_, err = repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			room_name TEXT NOT NULL DEFAULT '',
+			min_severity TEXT NOT NULL DEFAULT '',
+			events TEXT NOT NULL DEFAULT '',
+			disabled BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			webhook_id BIGINT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt TEXT NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			response_status INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL,
+			delivered_at TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_status ON webhook_deliveries(status, next_attempt);
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// Events are stored comma separated
+func scanWebhook(row interface{ Scan(dest ...any) error }, w *models.Webhook) error {
+	var events string
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &w.RoomName, &w.MinSeverity, &events, &w.Disabled, &w.CreatedAt); err != nil {
+		return err
+	}
+	w.Events = []string{}
+	if events != "" {
+		w.Events = strings.Split(events, ",")
+	}
+	return nil
+}
+
+func (r *WebhookRepository) Create(webhook *models.Webhook, ctx context.Context) error {
+	return r.sqlDB.QueryRowContext(ctx, `
+		INSERT INTO webhooks (url, secret, room_name, min_severity, events, disabled, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		webhook.URL, webhook.Secret, webhook.RoomName, webhook.MinSeverity, strings.Join(webhook.Events, ","), webhook.Disabled, webhook.CreatedAt).Scan(&webhook.ID)
+}
+
+func (r *WebhookRepository) ReadOne(id int, ctx context.Context) (*models.Webhook, error) {
+	row := r.sqlDB.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id)
+
+	var webhook models.Webhook
+	if err := scanWebhook(row, &webhook); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) ReadAll(ctx context.Context) ([]*models.Webhook, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// Update replaces a webhook, keeping the time it was created
+func (r *WebhookRepository) Update(webhook *models.Webhook, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `UPDATE webhooks SET
+		url = $1, secret = $2, room_name = $3, min_severity = $4, events = $5, disabled = $6
+		WHERE id = $7`,
+		webhook.URL, webhook.Secret, webhook.RoomName, webhook.MinSeverity, strings.Join(webhook.Events, ","), webhook.Disabled, webhook.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *WebhookRepository) Delete(id int, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = $1`, id); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func (r *WebhookRepository) Enqueue(d *models.WebhookDelivery, ctx context.Context) error {
+	return r.sqlDB.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		d.WebhookID, d.Event, d.Payload, d.Status, d.Attempts, d.NextAttempt, d.CreatedAt).Scan(&d.ID)
+}
+
+func (r *WebhookRepository) GetDue(now string, limit int, ctx context.Context) ([]*models.WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE status = $1 AND next_attempt <= $2 ORDER BY id LIMIT $3`,
+		models.DeliveryPending, now, limit)
+}
+
+func (r *WebhookRepository) UpdateDelivery(d *models.WebhookDelivery, ctx context.Context) error {
+	_, err := r.sqlDB.ExecContext(ctx, `UPDATE webhook_deliveries SET
+		status = $1, attempts = $2, next_attempt = $3, last_error = $4, response_status = $5, delivered_at = $6
+		WHERE id = $7`,
+		d.Status, d.Attempts, d.NextAttempt, d.LastError, d.ResponseStatus, d.DeliveredAt, d.ID)
+	return err
+}
+
+func (r *WebhookRepository) GetDeliveries(webhookID int, status string, limit int, ctx context.Context) ([]*models.WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2) ORDER BY id DESC LIMIT $3`,
+		webhookID, status, limit)
+}
+
+func (r *WebhookRepository) PruneDeliveries(before string, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE created_at < $1 AND status IN ($2, $3)`,
+		before, models.DeliveryDelivered, models.DeliveryDead)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *WebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt,
+			&d.LastError, &d.ResponseStatus, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
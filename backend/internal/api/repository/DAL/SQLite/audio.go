@@ -0,0 +1,103 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+// audioColumns are the columns of the audio_analyses table in the order scanAudioAnalysis expects them
+const audioColumns = `data_id, file_name, sample_rate, channels, bits_per_sample, duration_ms, full_scale_db,
+	laeq, lceq, lzeq, lamax, lamin, peak, bands, created_at`
+
+type AudioRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewAudioRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.AudioRepository, error) {
+	repo := &AudioRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create audio_analyses table, the levels of the uploaded recordings by the data row they were stored as.
+	// The bands are kept as JSON, a recording has as many as its sample rate allows
+	_, err := repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS audio_analyses (
+			data_id INTEGER PRIMARY KEY,
+			file_name TEXT NOT NULL DEFAULT '',
+			sample_rate INTEGER NOT NULL,
+			channels INTEGER NOT NULL,
+			bits_per_sample INTEGER NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			full_scale_db REAL NOT NULL,
+			laeq REAL NOT NULL,
+			lceq REAL NOT NULL,
+			lzeq REAL NOT NULL,
+			lamax REAL NOT NULL,
+			lamin REAL NOT NULL,
+			peak REAL NOT NULL,
+			bands TEXT NOT NULL DEFAULT '[]',
+			created_at TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func scanAudioAnalysis(row interface{ Scan(dest ...any) error }, a *models.AudioAnalysis) error {
+	var bands string
+	if err := row.Scan(&a.DataID, &a.FileName, &a.SampleRate, &a.Channels, &a.BitsPerSample, &a.DurationMs, &a.FullScaleDB,
+		&a.LAeq, &a.LCeq, &a.LZeq, &a.LAMax, &a.LAMin, &a.Peak, &bands, &a.CreatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(bands), &a.Bands)
+}
+
+// Create stores an analysis, replacing the one of the same data row
+func (r *AudioRepository) Create(a *models.AudioAnalysis, ctx context.Context) error {
+	bands, err := json.Marshal(a.Bands)
+	if err != nil {
+		return err
+	}
+	_, err = r.sqlDB.ExecContext(ctx, `
+		INSERT INTO audio_analyses (`+audioColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(data_id) DO UPDATE SET
+			file_name = excluded.file_name,
+			sample_rate = excluded.sample_rate,
+			channels = excluded.channels,
+			bits_per_sample = excluded.bits_per_sample,
+			duration_ms = excluded.duration_ms,
+			full_scale_db = excluded.full_scale_db,
+			laeq = excluded.laeq,
+			lceq = excluded.lceq,
+			lzeq = excluded.lzeq,
+			lamax = excluded.lamax,
+			lamin = excluded.lamin,
+			peak = excluded.peak,
+			bands = excluded.bands,
+			created_at = excluded.created_at`,
+		a.DataID, a.FileName, a.SampleRate, a.Channels, a.BitsPerSample, a.DurationMs, a.FullScaleDB,
+		a.LAeq, a.LCeq, a.LZeq, a.LAMax, a.LAMin, a.Peak, string(bands), a.CreatedAt)
+	return err
+}
+
+func (r *AudioRepository) ReadByDataID(dataID int, ctx context.Context) (*models.AudioAnalysis, error) {
+	row := r.sqlDB.QueryRowContext(ctx, `SELECT `+audioColumns+` FROM audio_analyses WHERE data_id = ?`, dataID)
+
+	var a models.AudioAnalysis
+	if err := scanAudioAnalysis(row, &a); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
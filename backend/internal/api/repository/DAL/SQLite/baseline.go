@@ -0,0 +1,197 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// baselineColumns are the columns of the room_baselines table in the order scanBaseline expects them
+const baselineColumns = `room_name, hour_of_week, samples, mean, m2`
+
+// createBaselineTable creates the table of the typical level of each room per hour of the week.
+// Create learns every new reading, RebuildBaselines relearns them from the stored readings.
+func createBaselineTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS room_baselines (
+		room_name TEXT NOT NULL,
+		hour_of_week INTEGER NOT NULL,
+		samples INTEGER NOT NULL DEFAULT 0,
+		mean REAL NOT NULL DEFAULT 0.0,
+		m2 REAL NOT NULL DEFAULT 0.0,
+		PRIMARY KEY (room_name, hour_of_week)
+	);`)
+	return err
+}
+
+func scanBaseline(row interface{ Scan(dest ...any) error }, b *models.Baseline) error {
+	if err := row.Scan(&b.RoomName, &b.HourOfWeek, &b.Samples, &b.Mean, &b.M2); err != nil {
+		return err
+	}
+	b.StdDev = b.Deviation()
+	return nil
+}
+
+// scoreReading sets the anomaly score of a new reading from the baseline of its room and hour of the week,
+// and returns that baseline for learnReading. Readings without a valid measure time aren't scored.
+func scoreReading(tx *sql.Tx, data *models.Data, zones models.BaselineZones, ctx context.Context) (*models.Baseline, error) {
+	hour, err := models.BaselineHour(data, zones)
+	if err != nil {
+		return nil, nil
+	}
+
+	baseline := models.Baseline{RoomName: data.RoomName, HourOfWeek: hour}
+	err = scanBaseline(tx.QueryRowContext(ctx, `SELECT `+baselineColumns+`
+	FROM room_baselines WHERE room_name = ? AND hour_of_week = ?`, data.RoomName, hour), &baseline)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	data.AnomalyScore = baseline.Score(data.SoundLevel)
+	return &baseline, nil
+}
+
+// baselineZones reads the time zones of the rooms with a threshold schedule.
+// A timezone the schedule service would ignore is ignored here too.
+func baselineZones(q interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, ctx context.Context) (models.BaselineZones, error) {
+	rows, err := q.QueryContext(ctx, `SELECT l.name, s.timezone
+	FROM threshold_schedules s
+	JOIN locations l ON l.id = s.location_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := make(models.BaselineZones)
+	for rows.Next() {
+		var roomName, timezone string
+		if err := rows.Scan(&roomName, &timezone); err != nil {
+			return nil, err
+		}
+		if tz, err := time.LoadLocation(timezone); err == nil {
+			zones[roomName] = tz
+		}
+	}
+	return zones, rows.Err()
+}
+
+// learnReading adds a newly stored reading to the baseline scoreReading returned
+func learnReading(tx *sql.Tx, baseline *models.Baseline, data *models.Data, ctx context.Context) error {
+	if baseline == nil {
+		return nil
+	}
+	baseline.Add(data.SoundLevel)
+	return saveBaseline(tx, baseline, ctx)
+}
+
+// saveBaseline writes a baseline, replacing the stored one
+func saveBaseline(tx *sql.Tx, baseline *models.Baseline, ctx context.Context) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO room_baselines (`+baselineColumns+`)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(room_name, hour_of_week) DO UPDATE SET
+		samples = excluded.samples,
+		mean = excluded.mean,
+		m2 = excluded.m2`,
+		baseline.RoomName, baseline.HourOfWeek, baseline.Samples, baseline.Mean, baseline.M2)
+	return err
+}
+
+func (r *DataRepository) GetBaselines(roomName string, ctx context.Context) ([]*models.Baseline, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+baselineColumns+`
+	FROM room_baselines
+	WHERE room_name = ?
+	ORDER BY hour_of_week ASC`, roomName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var baselines []*models.Baseline
+	for rows.Next() {
+		var baseline models.Baseline
+		if err := scanBaseline(rows, &baseline); err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, &baseline)
+	}
+	return baselines, rows.Err()
+}
+
+func (r *DataRepository) GetAnomalies(roomName string, from, to time.Time, minScore float64, ctx context.Context) ([]*models.Data, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+dataColumns+`
+	FROM data
+	WHERE room_name = ? AND measure_time >= ? AND measure_time < ? AND ABS(anomaly_score) >= ?
+	ORDER BY measure_time ASC`,
+		roomName, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339), minScore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []*models.Data
+	for rows.Next() {
+		var d models.Data
+		if err := scanData(rows, &d); err != nil {
+			return nil, err
+		}
+		data = append(data, &d)
+	}
+	return data, rows.Err()
+}
+
+// RebuildBaselines relearns the baselines from the stored readings and returns how many there are. The anomaly scores of the stored readings are kept.
+func (r *DataRepository) RebuildBaselines(ctx context.Context) (int, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	zones, err := baselineZones(tx, ctx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT `+dataColumns+` FROM data`)
+	if err != nil {
+		return 0, err
+	}
+	baselines, err := baselineRows(rows, zones)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM room_baselines`); err != nil {
+		return 0, err
+	}
+	for _, baseline := range baselines {
+		if err := saveBaseline(tx, baseline, ctx); err != nil {
+			return 0, err
+		}
+	}
+	return len(baselines), tx.Commit()
+}
+
+// baselineRows learns the scanned readings per room and hour of the week
+func baselineRows(rows *sql.Rows, zones models.BaselineZones) (map[string]*models.Baseline, error) {
+	baselines := make(map[string]*models.Baseline)
+	for rows.Next() {
+		var d models.Data
+		if err := scanData(rows, &d); err != nil {
+			return nil, err
+		}
+		hour, err := models.BaselineHour(&d, zones)
+		if err != nil {
+			continue
+		}
+		baseline := &models.Baseline{RoomName: d.RoomName, HourOfWeek: hour}
+		if existing, ok := baselines[baseline.Key()]; ok {
+			baseline = existing
+		} else {
+			baselines[baseline.Key()] = baseline
+		}
+		baseline.Add(d.SoundLevel)
+	}
+	return baselines, rows.Err()
+}
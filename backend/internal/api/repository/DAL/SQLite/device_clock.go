@@ -0,0 +1,107 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+type DeviceClockRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewDeviceClockRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.DeviceClockRepository, error) {
+	repo := &DeviceClockRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create device_clocks table, one row of skew statistics per device
+	_, err := repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS device_clocks (
+			device_id TEXT PRIMARY KEY,
+			samples INTEGER NOT NULL DEFAULT 0,
+			last_skew_ms INTEGER NOT NULL DEFAULT 0,
+			mean_skew_ms REAL NOT NULL DEFAULT 0.0,
+			min_skew_ms INTEGER NOT NULL DEFAULT 0,
+			max_skew_ms INTEGER NOT NULL DEFAULT 0,
+			corrected INTEGER NOT NULL DEFAULT 0,
+			rejected INTEGER NOT NULL DEFAULT 0,
+			last_seen TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *DeviceClockRepository) RecordSkew(deviceID string, skewMs int64, outcome string, receivedAt string, ctx context.Context) error {
+	var corrected, rejected int
+	switch outcome {
+	case models.ClockCorrected:
+		corrected = 1
+	case models.ClockRejected:
+		rejected = 1
+	}
+
+	// The mean is updated incrementally so no samples need to be kept
+	_, err := r.sqlDB.ExecContext(ctx, `
+		INSERT INTO device_clocks (
+		device_id, samples, last_skew_ms, mean_skew_ms, min_skew_ms, max_skew_ms, corrected, rejected, last_seen)
+		VALUES (?, 1, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(device_id) DO UPDATE SET
+			samples = device_clocks.samples + 1,
+			last_skew_ms = excluded.last_skew_ms,
+			mean_skew_ms = device_clocks.mean_skew_ms + (excluded.mean_skew_ms - device_clocks.mean_skew_ms) / (device_clocks.samples + 1),
+			min_skew_ms = MIN(device_clocks.min_skew_ms, excluded.min_skew_ms),
+			max_skew_ms = MAX(device_clocks.max_skew_ms, excluded.max_skew_ms),
+			corrected = device_clocks.corrected + excluded.corrected,
+			rejected = device_clocks.rejected + excluded.rejected,
+			last_seen = excluded.last_seen`,
+		deviceID, skewMs, float64(skewMs), skewMs, skewMs, corrected, rejected, receivedAt)
+	return err
+}
+
+func (r *DeviceClockRepository) GetDeviceClock(deviceID string, ctx context.Context) (*models.DeviceClock, error) {
+	row := r.sqlDB.QueryRowContext(ctx, `
+		SELECT device_id, samples, last_skew_ms, mean_skew_ms, min_skew_ms, max_skew_ms, corrected, rejected, last_seen
+		FROM device_clocks WHERE device_id = ?`, deviceID)
+
+	var clock models.DeviceClock
+	if err := scanDeviceClock(row, &clock); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &clock, nil
+}
+
+func (r *DeviceClockRepository) GetAllDeviceClocks(ctx context.Context) ([]*models.DeviceClock, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `
+		SELECT device_id, samples, last_skew_ms, mean_skew_ms, min_skew_ms, max_skew_ms, corrected, rejected, last_seen
+		FROM device_clocks ORDER BY device_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clocks := []*models.DeviceClock{}
+	for rows.Next() {
+		var clock models.DeviceClock
+		if err := scanDeviceClock(rows, &clock); err != nil {
+			return nil, err
+		}
+		clocks = append(clocks, &clock)
+	}
+	return clocks, rows.Err()
+}
+
+func scanDeviceClock(row interface{ Scan(dest ...any) error }, clock *models.DeviceClock) error {
+	return row.Scan(&clock.DeviceID, &clock.Samples, &clock.LastSkewMs, &clock.MeanSkewMs,
+		&clock.MinSkewMs, &clock.MaxSkewMs, &clock.Corrected, &clock.Rejected, &clock.LastSeen)
+}
@@ -0,0 +1,113 @@
+package SQLite
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// duplicateReadings selects the readings with the device and measure time of an older one
+const duplicateReadings = `FROM data WHERE id NOT IN (
+	SELECT MIN(id) FROM data GROUP BY device_id, measure_time
+)`
+
+// migrateReadings makes readings unique per device and measure time, so a retried reading is stored once.
+// Readings stored before measure times were normalized get theirs in UTC, so the same instant is the same string,
+// then the readings with the device and measure time of an older one are deleted, the oldest is kept.
+// Both happen in one transaction with the unique index, every changed reading is logged.
+func migrateReadings(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hasIndex int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+	WHERE type = 'index' AND name = 'data_device_measure_time'`).Scan(&hasIndex); err != nil {
+		return err
+	}
+	normalized, err := utcMeasureTimes(tx)
+	if err != nil {
+		return err
+	}
+	if hasIndex > 0 && len(normalized) == 0 {
+		return nil
+	}
+
+	// * The index is created again once the measure times are unique *
+	if _, err := tx.Exec(`DROP INDEX IF EXISTS data_device_measure_time`); err != nil {
+		return err
+	}
+	for id, measureTime := range normalized {
+		if _, err := tx.Exec(`UPDATE data SET measure_time = ? WHERE id = ?`, measureTime, id); err != nil {
+			return err
+		}
+	}
+
+	// * Only the columns of the first version of the table, the database may be that old *
+	rows, err := tx.Query(`SELECT id, device_id, room_name, sound_level, measure_time ` + duplicateReadings + ` ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for rows.Next() {
+		var id int
+		var deviceID, roomName, measureTime string
+		var level float64
+		if err := rows.Scan(&id, &deviceID, &roomName, &level, &measureTime); err != nil {
+			rows.Close()
+			return err
+		}
+		removed = append(removed, fmt.Sprintf("reading %d of %s in %s at %s (%.1f dB)", id, deviceID, roomName, measureTime, level))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE ` + duplicateReadings); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`CREATE UNIQUE INDEX data_device_measure_time ON data(device_id, measure_time)`); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if len(normalized) > 0 {
+		log.Printf("Stored the measure time of %d readings in UTC", len(normalized))
+	}
+	for _, r := range removed {
+		log.Println("Removed duplicate", r)
+	}
+	if len(removed) > 0 {
+		log.Printf("Removed %d duplicate readings, run the backfill command to recompute the rollups and baselines", len(removed))
+	}
+	return nil
+}
+
+// utcMeasureTimes returns the measure times of the readings not stored in UTC, converted to UTC, by reading ID.
+// Measure times that don't parse are left as they are, ValidateData rejects them for new readings.
+func utcMeasureTimes(tx *sql.Tx) (map[int]string, error) {
+	rows, err := tx.Query(`SELECT id, measure_time FROM data WHERE measure_time NOT LIKE '%Z'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	normalized := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var measureTime string
+		if err := rows.Scan(&id, &measureTime); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, measureTime); err == nil {
+			normalized[id] = t.UTC().Format(time.RFC3339)
+		}
+	}
+	return normalized, rows.Err()
+}
@@ -0,0 +1,141 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+// incidentColumns are the columns of the incidents table in the order scanIncident expects them
+const incidentColumns = `id, room_name, state, alert_rule_id, opened_at, last_alert_at, alerts, peak_level,
+	acknowledged_by, acknowledged_at, acknowledge_note, resolved_by, resolved_at, resolve_note`
+
+type IncidentRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewIncidentRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.IncidentRepository, error) {
+	repo := &IncidentRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create incidents table, the alerts of each room grouped until they are resolved
+	_, err := repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS incidents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			room_name TEXT NOT NULL,
+			state TEXT NOT NULL DEFAULT 'open',
+			alert_rule_id INTEGER NOT NULL DEFAULT 0,
+			opened_at TEXT NOT NULL,
+			last_alert_at TEXT NOT NULL,
+			alerts INTEGER NOT NULL DEFAULT 0,
+			peak_level REAL NOT NULL DEFAULT 0.0,
+			acknowledged_by TEXT NOT NULL DEFAULT '',
+			acknowledged_at TEXT NOT NULL DEFAULT '',
+			acknowledge_note TEXT NOT NULL DEFAULT '',
+			resolved_by TEXT NOT NULL DEFAULT '',
+			resolved_at TEXT NOT NULL DEFAULT '',
+			resolve_note TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS incidents_state_room ON incidents(state, room_name);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func scanIncident(row interface{ Scan(dest ...any) error }, i *models.Incident) error {
+	return row.Scan(&i.ID, &i.RoomName, &i.State, &i.AlertRuleID, &i.OpenedAt, &i.LastAlertAt, &i.Alerts, &i.PeakLevel,
+		&i.AcknowledgedBy, &i.AcknowledgedAt, &i.AcknowledgeNote, &i.ResolvedBy, &i.ResolvedAt, &i.ResolveNote)
+}
+
+func (r *IncidentRepository) Create(i *models.Incident, ctx context.Context) error {
+	res, err := r.sqlDB.ExecContext(ctx, `
+		INSERT INTO incidents (room_name, state, alert_rule_id, opened_at, last_alert_at, alerts, peak_level)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		i.RoomName, i.State, i.AlertRuleID, i.OpenedAt, i.LastAlertAt, i.Alerts, i.PeakLevel)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	i.ID = int(id)
+	return nil
+}
+
+func (r *IncidentRepository) ReadOne(id int, ctx context.Context) (*models.Incident, error) {
+	return r.readOne(r.sqlDB.QueryRowContext(ctx, `SELECT `+incidentColumns+` FROM incidents WHERE id = ?`, id))
+}
+
+func (r *IncidentRepository) ReadUnresolved(roomName string, ctx context.Context) (*models.Incident, error) {
+	return r.readOne(r.sqlDB.QueryRowContext(ctx, `SELECT `+incidentColumns+`
+		FROM incidents WHERE room_name = ? AND state != ?
+		ORDER BY id DESC LIMIT 1`, roomName, models.IncidentResolved))
+}
+
+func (r *IncidentRepository) readOne(row *sql.Row) (*models.Incident, error) {
+	var i models.Incident
+	if err := scanIncident(row, &i); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *IncidentRepository) ReadMany(state, roomName string, limit int, ctx context.Context) ([]*models.Incident, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+incidentColumns+`
+		FROM incidents
+		WHERE (? = '' OR state = ?) AND (? = '' OR room_name = ?)
+		ORDER BY opened_at DESC, id DESC
+		LIMIT ?`, state, state, roomName, roomName, limit)
+	if err != nil {
+		return nil, err
+	}
+	return incidentRows(rows)
+}
+
+func (r *IncidentRepository) ReadQuiet(lastAlertBefore string, ctx context.Context) ([]*models.Incident, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+incidentColumns+`
+		FROM incidents WHERE state != ? AND last_alert_at < ?
+		ORDER BY id`, models.IncidentResolved, lastAlertBefore)
+	if err != nil {
+		return nil, err
+	}
+	return incidentRows(rows)
+}
+
+func (r *IncidentRepository) Update(i *models.Incident, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `UPDATE incidents SET
+		state = ?, last_alert_at = ?, alerts = ?, peak_level = ?,
+		acknowledged_by = ?, acknowledged_at = ?, acknowledge_note = ?, resolved_by = ?, resolved_at = ?, resolve_note = ?
+		WHERE id = ?`,
+		i.State, i.LastAlertAt, i.Alerts, i.PeakLevel,
+		i.AcknowledgedBy, i.AcknowledgedAt, i.AcknowledgeNote, i.ResolvedBy, i.ResolvedAt, i.ResolveNote, i.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func incidentRows(rows *sql.Rows) ([]*models.Incident, error) {
+	defer rows.Close()
+
+	incidents := []*models.Incident{}
+	for rows.Next() {
+		var i models.Incident
+		if err := scanIncident(rows, &i); err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, &i)
+	}
+	return incidents, rows.Err()
+}
@@ -0,0 +1,156 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+// OutboxRepository keeps the readings a relay forwards upstream.
+// It only exists for SQLite, as relays run on small local machines.
+type OutboxRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+const outboxColumns = `id, idempotency_key, device_id, is_periodic, payload, status, attempts, next_attempt, last_error, upstream_id, created_at, acked_at`
+
+func NewOutboxRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.OutboxRepository, error) {
+	repo := &OutboxRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create relay_outbox table
+	_, err := repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS relay_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			idempotency_key TEXT NOT NULL UNIQUE,
+			device_id TEXT NOT NULL,
+			is_periodic INTEGER NOT NULL DEFAULT 0,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt TEXT NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			upstream_id INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL,
+			acked_at TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS relay_outbox_status ON relay_outbox(status, next_attempt);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *OutboxRepository) Enqueue(entry *models.OutboxEntry, ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only the newest latest reading of a device is worth sending
+	if !entry.IsPeriodic {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM relay_outbox WHERE device_id = ? AND is_periodic = 0 AND status = ?",
+			entry.DeviceID, models.OutboxPending)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO relay_outbox (
+		idempotency_key, device_id, is_periodic, payload, status, attempts, next_attempt, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.IdempotencyKey, entry.DeviceID, entry.IsPeriodic, entry.Payload, entry.Status, entry.Attempts, entry.NextAttempt, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, _ := res.LastInsertId()
+	entry.ID = id
+
+	return tx.Commit()
+}
+
+// GetDue returns the pending entries to send at now, oldest first
+func (r *OutboxRepository) GetDue(now string, limit int, ctx context.Context) ([]*models.OutboxEntry, error) {
+	return r.query(ctx, `SELECT `+outboxColumns+` FROM relay_outbox
+		WHERE status = ? AND next_attempt <= ? ORDER BY id LIMIT ?`,
+		models.OutboxPending, now, limit)
+}
+
+// GetUnverified returns the periodic entries acked before ackedBefore that haven't been reconciled yet
+func (r *OutboxRepository) GetUnverified(ackedBefore string, limit int, ctx context.Context) ([]*models.OutboxEntry, error) {
+	return r.query(ctx, `SELECT `+outboxColumns+` FROM relay_outbox
+		WHERE status = ? AND is_periodic = 1 AND acked_at < ? ORDER BY id LIMIT ?`,
+		models.OutboxAcked, ackedBefore, limit)
+}
+
+func (r *OutboxRepository) Update(entry *models.OutboxEntry, ctx context.Context) error {
+	_, err := r.sqlDB.ExecContext(ctx, `UPDATE relay_outbox SET
+		status = ?, attempts = ?, next_attempt = ?, last_error = ?, upstream_id = ?, acked_at = ?
+		WHERE id = ?`,
+		entry.Status, entry.Attempts, entry.NextAttempt, entry.LastError, entry.UpstreamID, entry.AckedAt, entry.ID)
+	return err
+}
+
+func (r *OutboxRepository) Delete(id int64, ctx context.Context) error {
+	_, err := r.sqlDB.ExecContext(ctx, `DELETE FROM relay_outbox WHERE id = ?`, id)
+	return err
+}
+
+// Prune deletes the entries that are done with and were created before before.
+// Acked latest readings are deleted when acked, those still here are from before that
+func (r *OutboxRepository) Prune(before string, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `DELETE FROM relay_outbox
+		WHERE created_at < ? AND (status IN (?, ?) OR (status = ? AND is_periodic = 0))`,
+		before, models.OutboxVerified, models.OutboxRejected, models.OutboxAcked)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *OutboxRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, "SELECT status, COUNT(*) FROM relay_outbox GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *OutboxRepository) query(ctx context.Context, query string, args ...any) ([]*models.OutboxEntry, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.OutboxEntry
+	for rows.Next() {
+		var e models.OutboxEntry
+		if err := rows.Scan(&e.ID, &e.IdempotencyKey, &e.DeviceID, &e.IsPeriodic, &e.Payload, &e.Status,
+			&e.Attempts, &e.NextAttempt, &e.LastError, &e.UpstreamID, &e.CreatedAt, &e.AckedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
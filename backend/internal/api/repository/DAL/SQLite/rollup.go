@@ -0,0 +1,189 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// rollupColumns are the columns of the data_rollups table in the order scanRollup expects them
+const rollupColumns = `period, bucket, room_name, device_id, samples, energy_sum, min_level, max_level, alerts`
+
+// createRollupTable creates the table of the hourly and daily rollups of the data table.
+// They are kept up to date by Create, Update and Delete, RebuildRollups fills them for older readings.
+func createRollupTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS data_rollups (
+		period TEXT NOT NULL,
+		bucket TEXT NOT NULL,
+		room_name TEXT NOT NULL,
+		device_id TEXT NOT NULL,
+		samples INTEGER NOT NULL DEFAULT 0,
+		energy_sum REAL NOT NULL DEFAULT 0.0,
+		min_level REAL NOT NULL DEFAULT 0.0,
+		max_level REAL NOT NULL DEFAULT 0.0,
+		alerts INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (period, room_name, bucket, device_id)
+	);`)
+	return err
+}
+
+func scanRollup(row interface{ Scan(dest ...any) error }, r *models.Rollup) error {
+	return row.Scan(&r.Period, &r.Bucket, &r.RoomName, &r.DeviceID, &r.Samples, &r.EnergySum, &r.MinLevel, &r.MaxLevel, &r.Alerts)
+}
+
+// addToRollups adds a newly stored reading to its rollups
+func addToRollups(tx *sql.Tx, data *models.Data, ctx context.Context) error {
+	for _, period := range models.RollupPeriods {
+		rollup, err := models.NewRollup(period, data)
+		if err != nil {
+			// * Readings without a valid measure time can't be placed in a bucket *
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO data_rollups (`+rollupColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(period, room_name, bucket, device_id) DO UPDATE SET
+			samples = data_rollups.samples + excluded.samples,
+			energy_sum = data_rollups.energy_sum + excluded.energy_sum,
+			min_level = MIN(data_rollups.min_level, excluded.min_level),
+			max_level = MAX(data_rollups.max_level, excluded.max_level),
+			alerts = data_rollups.alerts + excluded.alerts`,
+			rollup.Period, rollup.Bucket, rollup.RoomName, rollup.DeviceID,
+			rollup.Samples, rollup.EnergySum, rollup.MinLevel, rollup.MaxLevel, rollup.Alerts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshRollups recomputes the rollups a reading belongs to from the stored readings,
+// after it was changed or deleted. The minimum and maximum can't be undone incrementally.
+func refreshRollups(tx *sql.Tx, data *models.Data, ctx context.Context) error {
+	t, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if err != nil {
+		return nil
+	}
+
+	for _, period := range models.RollupPeriods {
+		start := models.RollupBucket(period, t)
+		end := start.Add(time.Hour)
+		if period == models.RollupDay {
+			end = start.AddDate(0, 0, 1)
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+dataColumns+`
+		FROM data
+		WHERE room_name = ? AND device_id = ? AND measure_time >= ? AND measure_time < ?`,
+			data.RoomName, data.DeviceID, start.Format(time.RFC3339), end.Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+		rollups, err := rollupRows(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM data_rollups
+		WHERE period = ? AND room_name = ? AND bucket = ? AND device_id = ?`,
+			period, data.RoomName, start.Format(time.RFC3339), data.DeviceID); err != nil {
+			return err
+		}
+		for _, rollup := range rollups {
+			if rollup.Period != period {
+				continue
+			}
+			if err := saveRollup(tx, rollup, ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rollupRows computes the rollups of the scanned readings for every period
+func rollupRows(rows *sql.Rows) (map[string]*models.Rollup, error) {
+	rollups := make(map[string]*models.Rollup)
+	for rows.Next() {
+		var d models.Data
+		if err := scanData(rows, &d); err != nil {
+			return nil, err
+		}
+		for _, period := range models.RollupPeriods {
+			rollup, err := models.NewRollup(period, &d)
+			if err != nil {
+				continue
+			}
+			if existing, ok := rollups[rollup.Key()]; ok {
+				existing.Add(rollup)
+			} else {
+				rollups[rollup.Key()] = rollup
+			}
+		}
+	}
+	return rollups, rows.Err()
+}
+
+// saveRollup writes a rollup, replacing the stored one
+func saveRollup(tx *sql.Tx, rollup *models.Rollup, ctx context.Context) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO data_rollups (`+rollupColumns+`)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(period, room_name, bucket, device_id) DO UPDATE SET
+		samples = excluded.samples,
+		energy_sum = excluded.energy_sum,
+		min_level = excluded.min_level,
+		max_level = excluded.max_level,
+		alerts = excluded.alerts`,
+		rollup.Period, rollup.Bucket, rollup.RoomName, rollup.DeviceID,
+		rollup.Samples, rollup.EnergySum, rollup.MinLevel, rollup.MaxLevel, rollup.Alerts)
+	return err
+}
+
+func (r *DataRepository) GetRollups(period string, roomName string, from, to time.Time, ctx context.Context) ([]*models.Rollup, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+rollupColumns+`
+	FROM data_rollups
+	WHERE period = ? AND room_name = ? AND bucket >= ? AND bucket < ?
+	ORDER BY bucket ASC, device_id ASC`,
+		period, roomName, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []*models.Rollup
+	for rows.Next() {
+		var rollup models.Rollup
+		if err := scanRollup(rows, &rollup); err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, &rollup)
+	}
+	return rollups, rows.Err()
+}
+
+// RebuildRollups recomputes the rollups of every bucket that has stored readings and returns how many there are.
+// Rollups of buckets whose readings have been cleaned up are kept.
+func (r *DataRepository) RebuildRollups(ctx context.Context) (int, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+dataColumns+` FROM data`)
+	if err != nil {
+		return 0, err
+	}
+	rollups, err := rollupRows(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, rollup := range rollups {
+		if err := saveRollup(tx, rollup, ctx); err != nil {
+			return 0, err
+		}
+	}
+	return len(rollups), tx.Commit()
+}
@@ -0,0 +1,183 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// ruleColumns are the columns of the alert_rules table in the order scanRule expects them
+const ruleColumns = `id, name, room_name, kind, level, clear_level, window_seconds, peak_count, severity, disabled, created_at`
+
+// firingColumns are the columns of the alert_firings table in the order GetFirings scans them
+const firingColumns = `id, rule_id, room_name, device_id, started_at, ended_at, value`
+
+type RuleRepository struct {
+	sqlDB *sql.DB
+	ctx   context.Context
+}
+
+func NewRuleRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.RuleRepository, error) {
+	repo := &RuleRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create alert_rules table, the rules deciding which readings are alerts,
+	// and alert_firings table, the periods each rule was active for a device
+	_, err := repo.sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS alert_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL DEFAULT '',
+			room_name TEXT NOT NULL DEFAULT '',
+			kind TEXT NOT NULL,
+			level REAL NOT NULL,
+			clear_level REAL NOT NULL,
+			window_seconds INTEGER NOT NULL DEFAULT 0,
+			peak_count INTEGER NOT NULL DEFAULT 0,
+			severity TEXT NOT NULL DEFAULT 'warning',
+			disabled INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS alert_firings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_id INTEGER NOT NULL,
+			room_name TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			started_at TEXT NOT NULL,
+			ended_at TEXT NOT NULL DEFAULT '',
+			value REAL NOT NULL DEFAULT 0.0
+		);
+		CREATE INDEX IF NOT EXISTS alert_firings_started_at ON alert_firings(started_at);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func scanRule(row interface{ Scan(dest ...any) error }, r *models.AlertRule) error {
+	return row.Scan(&r.ID, &r.Name, &r.RoomName, &r.Kind, &r.Level, &r.ClearLevel, &r.WindowSeconds, &r.Count, &r.Severity, &r.Disabled, &r.CreatedAt)
+}
+
+func (r *RuleRepository) Create(rule *models.AlertRule, ctx context.Context) error {
+	res, err := r.sqlDB.ExecContext(ctx, `
+		INSERT INTO alert_rules (name, room_name, kind, level, clear_level, window_seconds, peak_count, severity, disabled, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.Name, rule.RoomName, rule.Kind, rule.Level, rule.ClearLevel, rule.WindowSeconds, rule.Count, rule.Severity, rule.Disabled, rule.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rule.ID = int(id)
+	return nil
+}
+
+func (r *RuleRepository) ReadOne(id int, ctx context.Context) (*models.AlertRule, error) {
+	row := r.sqlDB.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM alert_rules WHERE id = ?`, id)
+
+	var rule models.AlertRule
+	if err := scanRule(row, &rule); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *RuleRepository) ReadAll(ctx context.Context) ([]*models.AlertRule, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+ruleColumns+` FROM alert_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*models.AlertRule{}
+	for rows.Next() {
+		var rule models.AlertRule
+		if err := scanRule(rows, &rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, rows.Err()
+}
+
+// Update replaces a rule, keeping the time it was created
+func (r *RuleRepository) Update(rule *models.AlertRule, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `UPDATE alert_rules SET
+		name = ?, room_name = ?, kind = ?, level = ?, clear_level = ?, window_seconds = ?, peak_count = ?, severity = ?, disabled = ?
+		WHERE id = ?`,
+		rule.Name, rule.RoomName, rule.Kind, rule.Level, rule.ClearLevel, rule.WindowSeconds, rule.Count, rule.Severity, rule.Disabled, rule.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *RuleRepository) Delete(id int, ctx context.Context) (int64, error) {
+	res, err := r.sqlDB.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *RuleRepository) CreateFiring(f *models.RuleFiring, ctx context.Context) error {
+	res, err := r.sqlDB.ExecContext(ctx, `
+		INSERT INTO alert_firings (rule_id, room_name, device_id, started_at, ended_at, value)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		f.RuleID, f.RoomName, f.DeviceID, f.StartedAt, f.EndedAt, f.Value)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	f.ID = int(id)
+	return nil
+}
+
+func (r *RuleRepository) EndFiring(id int, endedAt string, ctx context.Context) error {
+	_, err := r.sqlDB.ExecContext(ctx, `UPDATE alert_firings SET ended_at = ? WHERE id = ?`, endedAt, id)
+	return err
+}
+
+func (r *RuleRepository) EndAllFirings(ruleID int, endedAt string, ctx context.Context) error {
+	_, err := r.sqlDB.ExecContext(ctx, `UPDATE alert_firings SET ended_at = ?
+		WHERE ended_at = '' AND (? = 0 OR rule_id = ?)`, endedAt, ruleID, ruleID)
+	return err
+}
+
+func (r *RuleRepository) GetFirings(ruleID int, roomName string, from, to time.Time, ctx context.Context) ([]*models.RuleFiring, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, `SELECT `+firingColumns+`
+	FROM alert_firings
+	WHERE (? = 0 OR rule_id = ?)
+		AND (? = '' OR room_name = ?)
+		AND started_at >= ?
+		AND started_at < ?
+	ORDER BY started_at, id`,
+		ruleID, ruleID, roomName, roomName, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	firings := []*models.RuleFiring{}
+	for rows.Next() {
+		var f models.RuleFiring
+		if err := rows.Scan(&f.ID, &f.RuleID, &f.RoomName, &f.DeviceID, &f.StartedAt, &f.EndedAt, &f.Value); err != nil {
+			return nil, err
+		}
+		firings = append(firings, &f)
+	}
+	return firings, rows.Err()
+}
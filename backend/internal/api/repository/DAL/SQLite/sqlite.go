@@ -40,3 +40,34 @@ func (s *SQLite) Connection() *sql.DB {
 func (s *SQLite) Close() error {
 	return s.sqlDB.Close()
 }
+
+// addColumn adds a column to an existing table unless it is already there,
+// SQLite has no ADD COLUMN IF NOT EXISTS
+func addColumn(db *sql.DB, table, column, definition string) error {
+	exists, err := hasColumn(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
+
+// hasColumn reports whether a table has a column, false if there is no such table
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,34 @@
+package models
+
+import "context"
+
+// Band is the level of a frequency band
+type Band struct {
+	Frequency float64 `json:"frequency"` // Nominal center frequency in Hz
+	Level     float64 `json:"level"`     // dB, unweighted
+}
+
+// AudioAnalysis holds the levels computed from an uploaded WAV recording,
+// the data row it was stored as has the LAeq as sound_level
+type AudioAnalysis struct {
+	DataID        int     `json:"data_id"`
+	FileName      string  `json:"file_name"`
+	SampleRate    int     `json:"sample_rate"`
+	Channels      int     `json:"channels"`
+	BitsPerSample int     `json:"bits_per_sample"`
+	DurationMs    int64   `json:"duration_ms"`
+	FullScaleDB   float64 `json:"full_scale_db"` // dB SPL of a full scale sine wave
+	LAeq          float64 `json:"laeq"`
+	LCeq          float64 `json:"lceq"`
+	LZeq          float64 `json:"lzeq"`
+	LAMax         float64 `json:"lamax"` // Of 125 ms blocks
+	LAMin         float64 `json:"lamin"`
+	Peak          float64 `json:"peak"` // Unweighted peak
+	Bands         []Band  `json:"bands"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+type AudioRepository interface {
+	Create(analysis *AudioAnalysis, ctx context.Context) error
+	ReadByDataID(dataID int, ctx context.Context) (*AudioAnalysis, error)
+}
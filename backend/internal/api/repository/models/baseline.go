@@ -0,0 +1,89 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Anomaly detection settings
+const (
+	MinBaselineSamples    = 30  // Readings an hour of the week needs before its readings are scored
+	MinBaselineStdDev     = 1.0 // dB, so a very steady room doesn't flag every small change
+	AnomalyScoreThreshold = 3.0 // Readings scoring at least this far from 0 are anomalies
+)
+
+// Baseline is the learned typical level of a room in one hour of the week,
+// its mean and variance are updated with each reading (Welford's algorithm)
+type Baseline struct {
+	RoomName   string  `json:"room_name"`
+	HourOfWeek int     `json:"hour_of_week"` // 0 is Sunday 00:00-01:00 in the room's time zone, see BaselineZones
+	Samples    int     `json:"samples"`
+	Mean       float64 `json:"mean"`   // dB
+	M2         float64 `json:"-"`      // Sum of the squared differences from the mean
+	StdDev     float64 `json:"stddev"` // Filled in when read
+}
+
+// HourOfWeek returns the hour of the week of t, in t's time zone
+func HourOfWeek(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// BaselineZones are the time zones of the rooms' hours of the week by room name,
+// the timezone of the room's threshold schedule. Measure times are stored in UTC,
+// so a room without a schedule learns its hours in UTC.
+type BaselineZones map[string]*time.Location
+
+// Zone returns the time zone of a room
+func (z BaselineZones) Zone(roomName string) *time.Location {
+	if tz, ok := z[roomName]; ok {
+		return tz
+	}
+	return time.UTC
+}
+
+// BaselineHour returns the hour of the week of a reading's measure time in its room's time zone
+func BaselineHour(data *Data, zones BaselineZones) (int, error) {
+	t, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if err != nil {
+		return 0, err
+	}
+	return HourOfWeek(t.In(zones.Zone(data.RoomName))), nil
+}
+
+// Key identifies the baseline's row
+func (b *Baseline) Key() string {
+	return b.RoomName + "|" + strconv.Itoa(b.HourOfWeek)
+}
+
+// Add learns a level
+func (b *Baseline) Add(level float64) {
+	b.Samples++
+	delta := level - b.Mean
+	b.Mean += delta / float64(b.Samples)
+	b.M2 += delta * (level - b.Mean)
+	b.StdDev = b.Deviation()
+}
+
+// Deviation returns the standard deviation of the learned levels
+func (b *Baseline) Deviation() float64 {
+	if b.Samples < 2 {
+		return 0
+	}
+	return math.Sqrt(b.M2 / float64(b.Samples-1))
+}
+
+// Score returns how many standard deviations level is above (or below, negative) the mean,
+// 0 while too few readings have been learned
+func (b *Baseline) Score(level float64) float64 {
+	if b.Samples < MinBaselineSamples {
+		return 0
+	}
+	score := (level - b.Mean) / math.Max(b.Deviation(), MinBaselineStdDev)
+	return math.Round(score*100) / 100
+}
+
+// IsAnomaly reports whether a score is far enough from the baseline
+func IsAnomaly(score float64) bool {
+	return math.Abs(score) >= AnomalyScoreThreshold
+}
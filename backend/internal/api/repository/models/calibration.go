@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// CalibrationPoint maps a level the device measures to the true level, in dB
+type CalibrationPoint struct {
+	Raw        float64 `json:"raw"`
+	Calibrated float64 `json:"calibrated"`
+}
+
+// Calibration corrects the levels a device measures from ValidFrom on, until the device's next calibration.
+// The curve, if any, maps the raw level to a corrected one by linear interpolation between its points,
+// then the level is multiplied by Gain and Offset is added.
+type Calibration struct {
+	ID          int                `json:"id,omitempty"`
+	DeviceID    string             `json:"device_id"`
+	Offset      float64            `json:"offset"` // dB
+	Gain        float64            `json:"gain"`   // 1 leaves the level as is
+	Curve       []CalibrationPoint `json:"curve,omitempty"`
+	ValidFrom   string             `json:"valid_from"` // RFC3339
+	Description string             `json:"description"`
+	CreatedAt   string             `json:"created_at"`
+}
+
+// Apply returns the calibrated level of a raw level
+func (c *Calibration) Apply(raw float64) float64 {
+	level := raw
+	switch {
+	case len(c.Curve) == 1:
+		level += c.Curve[0].Calibrated - c.Curve[0].Raw
+	case len(c.Curve) > 1:
+		// * Past the ends of the curve its first or last segment is extended *
+		i := 1
+		for i < len(c.Curve)-1 && raw > c.Curve[i].Raw {
+			i++
+		}
+		a, b := c.Curve[i-1], c.Curve[i]
+		level = a.Calibrated + (raw-a.Raw)*(b.Calibrated-a.Calibrated)/(b.Raw-a.Raw)
+	}
+	return math.Round((level*c.Gain+c.Offset)*100) / 100
+}
+
+// CalibrationAt returns the calibration valid at a measure time, nil if there is none.
+// The calibrations are those of one device ordered by ValidFrom.
+func CalibrationAt(calibrations []*Calibration, measureTime string) *Calibration {
+	t, err := time.Parse(time.RFC3339, measureTime)
+	if err != nil {
+		return nil
+	}
+	var valid *Calibration
+	for _, c := range calibrations {
+		from, err := time.Parse(time.RFC3339, c.ValidFrom)
+		if err != nil || from.After(t) {
+			break
+		}
+		valid = c
+	}
+	return valid
+}
+
+// Recalibrate sets the level of a reading from its raw level with the calibration, or to the raw level without one.
+// The loudest and quietest samples and the octave bands are shifted by the same amount. is_alert follows
+// the new level unless an alert rule decided it, as the rules see the readings around it as they were received.
+func Recalibrate(data *Data, calibration *Calibration) {
+	level := data.RawSoundLevel
+	if calibration != nil {
+		level = calibration.Apply(level)
+	}
+	shift := func(l float64) float64 { return math.Round((l+level-data.SoundLevel)*100) / 100 }
+
+	if data.MaxLevel != 0 {
+		data.MaxLevel = shift(data.MaxLevel)
+	}
+	if data.MinLevel != 0 {
+		data.MinLevel = shift(data.MinLevel)
+	}
+	for i := range data.Bands {
+		data.Bands[i] = shift(data.Bands[i])
+	}
+	data.SoundLevel = level
+	if data.Threshold > 0 && data.AlertRuleID == 0 {
+		data.IsAlert = level >= data.Threshold
+	}
+}
+
+type CalibrationRepository interface {
+	Create(calibration *Calibration, ctx context.Context) error
+	ReadOne(id int, ctx context.Context) (*Calibration, error)
+	ReadByDevice(deviceID string, ctx context.Context) ([]*Calibration, error) // Ordered by valid_from
+	ReadAll(ctx context.Context) ([]*Calibration, error)                       // Ordered by device and valid_from
+	Delete(id int, ctx context.Context) (int64, error)
+}
@@ -4,23 +4,46 @@ package models
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
 type Data struct {
-	ID          int     `json:"id,omitempty"`
-	DeviceID    string  `json:"device_id"`             // Arduino device ID
-	RoomName    string  `json:"room_name"`             // Name of the working room
-	SoundLevel  float64 `json:"sound_level"`           // Level of sound in dB
-	Threshold   float64 `json:"threshold"`             // Threshold level in dB
-	MeasureTime string  `json:"measure_time"`          // Time of measurement
-	IsAlert     bool    `json:"is_alert"`              // Whether the sound level exceeds the threshold
-	Description string  `json:"description"`           // Additional information
-	IsPeriodic  bool    `json:"is_periodic,omitempty"` // Is the data constantly/periodically measured
+	ID            int       `json:"id,omitempty"`
+	DeviceID      string    `json:"device_id"`                 // Arduino device ID
+	RoomName      string    `json:"room_name"`                 // Name of the working room
+	SoundLevel    float64   `json:"sound_level"`               // Level of sound in dB
+	Threshold     float64   `json:"threshold"`                 // Threshold level in dB
+	MeasureTime   string    `json:"measure_time"`              // Time of measurement
+	IsAlert       bool      `json:"is_alert"`                  // Whether an alert rule, or without rules the threshold, is exceeded
+	Description   string    `json:"description"`               // Additional information
+	IsPeriodic    bool      `json:"is_periodic,omitempty"`     // Is the data constantly/periodically measured
+	ReceivedAt    string    `json:"received_at,omitempty"`     // Time the server received the reading
+	MaxLevel      float64   `json:"max_level,omitempty"`       // Loudest sample of a periodic row computed by the server
+	MinLevel      float64   `json:"min_level,omitempty"`       // Quietest sample of a periodic row computed by the server
+	AnomalyScore  float64   `json:"anomaly_score,omitempty"`   // Standard deviations from the room's usual level at that hour of the week
+	Bands         []float64 `json:"bands,omitempty"`           // Octave band levels in dB, in the order of BandFrequencies
+	RawSoundLevel float64   `json:"raw_sound_level,omitempty"` // Level the device measured, before its calibration
+	AlertRuleID   int       `json:"alert_rule_id,omitempty"`   // Most severe active alert rule when the reading was stored
 }
 
+// IdempotencyKey is the Idempotency-Key of a request and a hash of the reading it carried.
+// Keys are scoped to the device of the reading.
+type IdempotencyKey struct {
+	Key  string
+	Hash string
+}
+
+// ErrIdempotencyKeyReused is returned when a device sends a different reading under a key it used before
+var ErrIdempotencyKeyReused = errors.New("the Idempotency-Key was already used for a different reading")
+
+// BandFrequencies are the center frequencies in Hz of the octave bands readings can carry
+var BandFrequencies = [...]float64{63, 125, 250, 500, 1000, 2000, 4000, 8000}
+
 type DataRepository interface {
 	Create(Data *Data, ctx context.Context) error
+	CreateIdempotent(data *Data, key IdempotencyKey, ctx context.Context) (bool, error) // False if the reading was already stored, data is then the stored row
+	CreateBatch(data []*Data, ctx context.Context) ([]bool, error)                      // Inserts all rows in a single transaction, reports which ones are new
 	CreateLatest(Data *Data, ctx context.Context) error
 	ReadOne(id int, ctx context.Context) (*Data, error)
 	ReadLatest(id string, ctx context.Context) (*Data, error)
@@ -29,5 +52,12 @@ type DataRepository interface {
 	Delete(data *Data, ctx context.Context) (int64, error)
 	GetDailySummary(roomName string, date time.Time, ctx context.Context) ([]*Data, error) // To retreive daily summary statistics
 	GetByRoom(roomName string, ctx context.Context) ([]*Data, error)                       // To retrieve data by room name
+	GetByRange(roomName string, from, to time.Time, ctx context.Context) ([]*Data, error)  // Readings of the room, all rooms if empty, with from <= measure_time < to
 	ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error)
+	GetRollups(period string, roomName string, from, to time.Time, ctx context.Context) ([]*Rollup, error)    // Rollups of the room with from <= bucket < to
+	RebuildRollups(ctx context.Context) (int, error)                                                          // Recomputes the rollups from the stored readings
+	GetBaselines(roomName string, ctx context.Context) ([]*Baseline, error)                                   // Learned levels of the room per hour of the week
+	GetAnomalies(roomName string, from, to time.Time, minScore float64, ctx context.Context) ([]*Data, error) // Readings of the room scoring at least minScore from 0
+	RebuildBaselines(ctx context.Context) (int, error)                                                        // Relearns the baselines from the stored readings
+	Recalibrate(deviceID string, calibrations []*Calibration, ctx context.Context) (int, error)               // Recomputes the levels of the device's readings from their raw levels
 }
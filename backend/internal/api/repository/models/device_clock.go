@@ -0,0 +1,31 @@
+package models
+
+import "context"
+
+// What happened to a reading whose clock skew was measured
+const (
+	ClockAccepted  = "accepted"
+	ClockCorrected = "corrected" // measure_time was replaced by received_at
+	ClockRejected  = "rejected"
+)
+
+// DeviceClock holds the clock skew statistics of a device.
+// Skew is received_at minus measure_time in milliseconds,
+// so a device whose clock runs ahead has a negative skew.
+type DeviceClock struct {
+	DeviceID   string  `json:"device_id"`
+	Samples    int64   `json:"samples"`
+	LastSkewMs int64   `json:"last_skew_ms"`
+	MeanSkewMs float64 `json:"mean_skew_ms"`
+	MinSkewMs  int64   `json:"min_skew_ms"`
+	MaxSkewMs  int64   `json:"max_skew_ms"`
+	Corrected  int64   `json:"corrected"`
+	Rejected   int64   `json:"rejected"`
+	LastSeen   string  `json:"last_seen"`
+}
+
+type DeviceClockRepository interface {
+	RecordSkew(deviceID string, skewMs int64, outcome string, receivedAt string, ctx context.Context) error
+	GetDeviceClock(deviceID string, ctx context.Context) (*DeviceClock, error)
+	GetAllDeviceClocks(ctx context.Context) ([]*DeviceClock, error)
+}
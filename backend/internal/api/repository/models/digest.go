@@ -0,0 +1,53 @@
+package models
+
+// Digest periods
+const (
+	DigestDaily  = "daily"  // The 24 hours before the digest
+	DigestWeekly = "weekly" // The 7 days before the digest
+)
+
+// Trends of a location against the previous period
+const (
+	TrendLouder    = "louder"
+	TrendQuieter   = "quieter"
+	TrendUnchanged = "unchanged"
+)
+
+// Digest summarises the noise of the locations over a period, from <= measure_time < to,
+// and compares it with the period before. It is computed, not stored.
+type Digest struct {
+	Period    string           `json:"period"`
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Timezone  string           `json:"timezone"` // Of the loudest periods
+	Locations []LocationDigest `json:"locations"`
+}
+
+// LocationDigest is the part of a digest about one location
+type LocationDigest struct {
+	RoomName       string       `json:"room_name"`
+	Current        DigestStats  `json:"current"`
+	Previous       DigestStats  `json:"previous"`
+	Trend          string       `json:"trend"` // Empty when either period has no readings
+	Change         DigestStats  `json:"change"`
+	LoudestPeriods []LoudPeriod `json:"loudest_periods"` // Loudest hours first
+}
+
+// DigestStats are the statistics of the periodic readings of a location over a period
+type DigestStats struct {
+	Samples               int     `json:"samples"`
+	LAeq                  float64 `json:"laeq"`
+	LMax                  float64 `json:"lmax"`
+	Alerts                int     `json:"alerts"`
+	MinutesAboveThreshold float64 `json:"minutes_above_threshold"`
+}
+
+// LoudPeriod is one of the loudest hours of a location
+type LoudPeriod struct {
+	Start                 string  `json:"start"`
+	End                   string  `json:"end"`
+	LAeq                  float64 `json:"laeq"`
+	LMax                  float64 `json:"lmax"`
+	Alerts                int     `json:"alerts"`
+	MinutesAboveThreshold float64 `json:"minutes_above_threshold"`
+}
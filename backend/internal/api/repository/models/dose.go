@@ -0,0 +1,17 @@
+package models
+
+// NoiseDose is the occupational noise dose of a room for a day.
+// A dose of 100% is the criterion level for the criterion duration (e.g. 85 dB for 8 hours),
+// every exchange rate dB more halves the time allowed.
+type NoiseDose struct {
+	RoomName             string  `json:"room_name"`
+	Date                 string  `json:"date"`
+	DeviceID             string  `json:"device_id"` // The room's device with the highest dose
+	CriterionLevel       float64 `json:"criterion_level"`
+	ExchangeRate         float64 `json:"exchange_rate"`
+	CriterionHours       float64 `json:"criterion_hours"`
+	ExposureMinutes      float64 `json:"exposure_minutes"`       // Time covered by the readings
+	DosePercent          float64 `json:"dose_percent"`           // Share of the daily dose used so far
+	TWA                  float64 `json:"twa"`                    // Time weighted average over the criterion duration
+	ProjectedDosePercent float64 `json:"projected_dose_percent"` // Dose if the rest of the criterion duration is as loud
+}
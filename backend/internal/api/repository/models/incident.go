@@ -0,0 +1,41 @@
+package models
+
+import "context"
+
+// States of alert incidents
+const (
+	IncidentOpen         = "open"         // Alerting, nobody responded yet
+	IncidentAcknowledged = "acknowledged" // Someone is on it, alerts are still added to it
+	IncidentResolved     = "resolved"     // Over, the next alert of the room opens a new incident
+)
+
+// Incident groups the alerts of a room from the first one until it is resolved,
+// by someone or automatically once the room has been quiet for a while
+type Incident struct {
+	ID              int     `json:"id,omitempty"`
+	RoomName        string  `json:"room_name"`
+	State           string  `json:"state"`
+	AlertRuleID     int     `json:"alert_rule_id,omitempty"` // Rule of the alert that opened the incident
+	OpenedAt        string  `json:"opened_at"`               // Measure time of the first alert
+	LastAlertAt     string  `json:"last_alert_at"`           // Measure time of the latest alert
+	Alerts          int     `json:"alerts"`                  // Alerting readings of the room
+	PeakLevel       float64 `json:"peak_level"`              // Loudest alerting reading, dB
+	AcknowledgedBy  string  `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt  string  `json:"acknowledged_at,omitempty"`
+	AcknowledgeNote string  `json:"acknowledge_note,omitempty"`
+	ResolvedBy      string  `json:"resolved_by,omitempty"` // IncidentSystem if resolved after the quiet period
+	ResolvedAt      string  `json:"resolved_at,omitempty"`
+	ResolveNote     string  `json:"resolve_note,omitempty"`
+}
+
+// IncidentSystem is who resolves incidents automatically
+const IncidentSystem = "system"
+
+type IncidentRepository interface {
+	Create(incident *Incident, ctx context.Context) error
+	ReadOne(id int, ctx context.Context) (*Incident, error)
+	ReadUnresolved(roomName string, ctx context.Context) (*Incident, error)               // The room's open or acknowledged incident, nil if none
+	ReadMany(state, roomName string, limit int, ctx context.Context) ([]*Incident, error) // Newest first, of every state or room if empty
+	ReadQuiet(lastAlertBefore string, ctx context.Context) ([]*Incident, error)           // Unresolved incidents without alerts since lastAlertBefore
+	Update(incident *Incident, ctx context.Context) (int64, error)
+}
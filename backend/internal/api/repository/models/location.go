@@ -7,6 +7,9 @@ type Location struct {
 	Name      string  `json:"name"`
 	Chosen    bool    `json:"chosen"`
 	Threshold float64 `json:"threshold"`
+
+	Dose               *NoiseDose `json:"dose,omitempty"`                // Today's noise dose, computed, not stored
+	EffectiveThreshold float64    `json:"effective_threshold,omitempty"` // The threshold now, from the schedule, computed, not stored
 }
 
 type LocationRepository interface {
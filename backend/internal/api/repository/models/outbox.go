@@ -0,0 +1,38 @@
+package models
+
+import "context"
+
+// Status of an outbox entry
+const (
+	OutboxPending  = "pending"  // Waiting to be sent upstream
+	OutboxAcked    = "acked"    // Stored by the upstream
+	OutboxVerified = "verified" // Found on the upstream when reconciling
+	OutboxRejected = "rejected" // Refused by the upstream, not retried
+)
+
+// OutboxEntry is a reading waiting to be forwarded to the upstream instance in relay mode
+type OutboxEntry struct {
+	ID             int64
+	IdempotencyKey string // Sent with every attempt, so the upstream stores the reading once
+	DeviceID       string
+	IsPeriodic     bool
+	Payload        string // The reading as JSON
+	Status         string
+	Attempts       int
+	NextAttempt    string
+	LastError      string
+	UpstreamID     int // ID of the reading on the upstream
+	CreatedAt      string
+	AckedAt        string
+}
+
+type OutboxRepository interface {
+	// Enqueue adds an entry, a latest reading replaces the pending latest reading of its device
+	Enqueue(entry *OutboxEntry, ctx context.Context) error
+	GetDue(now string, limit int, ctx context.Context) ([]*OutboxEntry, error)
+	GetUnverified(ackedBefore string, limit int, ctx context.Context) ([]*OutboxEntry, error)
+	Update(entry *OutboxEntry, ctx context.Context) error
+	Delete(id int64, ctx context.Context) error
+	Prune(before string, ctx context.Context) (int64, error)
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+}
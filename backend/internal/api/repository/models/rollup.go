@@ -0,0 +1,90 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Rollup periods
+const (
+	RollupHour = "hour"
+	RollupDay  = "day" // UTC day
+)
+
+// RollupPeriods are the periods rollups are kept for
+var RollupPeriods = []string{RollupHour, RollupDay}
+
+// Rollup aggregates the periodic readings of a device in a room over an hour or a day.
+// Levels are kept as a sum of their energy, so rollups can be added up and averaged correctly.
+type Rollup struct {
+	Period    string  `json:"period"`
+	Bucket    string  `json:"bucket"` // Start of the hour or day, RFC3339 UTC
+	RoomName  string  `json:"room_name"`
+	DeviceID  string  `json:"device_id"`
+	Samples   int     `json:"samples"`
+	EnergySum float64 `json:"energy_sum"` // Sum of 10^(L/10) of the readings
+	MinLevel  float64 `json:"min_level"`
+	MaxLevel  float64 `json:"max_level"`
+	Alerts    int     `json:"alerts"`
+}
+
+// RollupBucket returns the start of the period containing t, in UTC
+func RollupBucket(period string, t time.Time) time.Time {
+	t = t.UTC()
+	if period == RollupDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// NewRollup returns the rollup of a single reading for the period
+func NewRollup(period string, data *Data) (*Rollup, error) {
+	t, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Rollup{
+		Period:    period,
+		Bucket:    RollupBucket(period, t).Format(time.RFC3339),
+		RoomName:  data.RoomName,
+		DeviceID:  data.DeviceID,
+		Samples:   1,
+		EnergySum: math.Pow(10, data.SoundLevel/10),
+		MinLevel:  data.SoundLevel,
+		MaxLevel:  data.SoundLevel,
+	}
+	// * Rows computed by the server carry the loudest and quietest sample of their window *
+	if data.MinLevel > 0 {
+		r.MinLevel = data.MinLevel
+	}
+	if data.MaxLevel > 0 {
+		r.MaxLevel = data.MaxLevel
+	}
+	if data.IsAlert {
+		r.Alerts = 1
+	}
+	return r, nil
+}
+
+// Key identifies the rollup's row
+func (r *Rollup) Key() string {
+	return r.Period + "|" + r.Bucket + "|" + r.RoomName + "|" + r.DeviceID
+}
+
+// Add adds the readings of another rollup of the same row
+func (r *Rollup) Add(other *Rollup) {
+	r.Samples += other.Samples
+	r.EnergySum += other.EnergySum
+	r.MinLevel = math.Min(r.MinLevel, other.MinLevel)
+	r.MaxLevel = math.Max(r.MaxLevel, other.MaxLevel)
+	r.Alerts += other.Alerts
+}
+
+// Leq returns the energy averaged level of the readings
+func (r *Rollup) Leq() float64 {
+	if r.Samples == 0 {
+		return 0
+	}
+	return 10 * math.Log10(r.EnergySum/float64(r.Samples))
+}
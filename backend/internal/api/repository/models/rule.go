@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of alert rules
+const (
+	RuleLevel = "level" // The level of a reading is at or above Level
+	RuleLeq   = "leq"   // The Leq of the device's readings over the window is at or above Level
+	RulePeaks = "peaks" // At least Count of the device's readings over the window are at or above Level
+)
+
+// Severities of alert rules, from the least to the most severe
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// AlertRule decides when readings are alerts. A rule fires when its condition holds with Level
+// and, once fired, stays active until the condition no longer holds with ClearLevel (hysteresis).
+type AlertRule struct {
+	ID            int     `json:"id,omitempty"`
+	Name          string  `json:"name"`
+	RoomName      string  `json:"room_name"` // Empty applies the rule to every room
+	Kind          string  `json:"kind"`
+	Level         float64 `json:"level"`          // dB
+	ClearLevel    float64 `json:"clear_level"`    // dB, at most Level
+	WindowSeconds int     `json:"window_seconds"` // Of leq and peaks rules
+	Count         int     `json:"count"`          // Of peaks rules
+	Severity      string  `json:"severity"`
+	Disabled      bool    `json:"disabled"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+// Window returns how far back the rule looks from a reading
+func (r *AlertRule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// SeverityRank orders severities, higher is more severe
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+// RuleFiring is a period a rule was active for a device, EndedAt is empty while it is
+type RuleFiring struct {
+	ID        int     `json:"id,omitempty"`
+	RuleID    int     `json:"rule_id"`
+	RoomName  string  `json:"room_name"`
+	DeviceID  string  `json:"device_id"`
+	StartedAt string  `json:"started_at"` // Measure time of the reading that fired the rule
+	EndedAt   string  `json:"ended_at"`   // Measure time of the reading that cleared it
+	Value     float64 `json:"value"`      // The level, Leq or number of peaks that fired the rule
+}
+
+type RuleRepository interface {
+	Create(rule *AlertRule, ctx context.Context) error
+	ReadOne(id int, ctx context.Context) (*AlertRule, error)
+	ReadAll(ctx context.Context) ([]*AlertRule, error) // Ordered by ID
+	Update(rule *AlertRule, ctx context.Context) (int64, error)
+	Delete(id int, ctx context.Context) (int64, error)
+	CreateFiring(firing *RuleFiring, ctx context.Context) error
+	EndFiring(id int, endedAt string, ctx context.Context) error
+	EndAllFirings(ruleID int, endedAt string, ctx context.Context) error                                    // Ends the active firings of a rule, of every rule if ruleID is 0
+	GetFirings(ruleID int, roomName string, from, to time.Time, ctx context.Context) ([]*RuleFiring, error) // Started in [from, to), of every rule or room if 0 or empty
+}
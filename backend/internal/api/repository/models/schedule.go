@@ -0,0 +1,40 @@
+package models
+
+import "context"
+
+// ThresholdSchedule changes the threshold of a location over the week, e.g. lower during nap time.
+// The times and dates are local to Timezone. Outside its entries the location's own threshold applies.
+type ThresholdSchedule struct {
+	LocationID int64               `json:"location_id"`
+	Timezone   string              `json:"timezone"` // IANA name, e.g. Europe/Helsinki, default UTC
+	Entries    []ScheduleEntry     `json:"entries"`
+	Exceptions []ScheduleException `json:"exceptions"`
+}
+
+// ScheduleEntry is the threshold of a time range on some weekdays
+type ScheduleEntry struct {
+	ID        int      `json:"id,omitempty"`
+	Label     string   `json:"label"`    // e.g. Nap time
+	Weekdays  []string `json:"weekdays"` // monday to sunday
+	Start     string   `json:"start"`    // HH:MM
+	End       string   `json:"end"`      // HH:MM, excluded, 24:00 for the end of the day
+	Threshold float64  `json:"threshold"`
+}
+
+// ScheduleException replaces the entries on a range of dates, e.g. holidays
+type ScheduleException struct {
+	ID        int     `json:"id,omitempty"`
+	Label     string  `json:"label"`
+	From      string  `json:"from"`      // YYYY-MM-DD
+	To        string  `json:"to"`        // YYYY-MM-DD, included
+	Threshold float64 `json:"threshold"` // For the whole days, 0 for the location's own threshold
+}
+
+type ScheduleRepository interface {
+	Read(locationID int64, ctx context.Context) (*ThresholdSchedule, error) // nil if the location has no schedule
+	ReadAll(ctx context.Context) ([]*ThresholdSchedule, error)
+	Replace(schedule *ThresholdSchedule, ctx context.Context) error // Sets the IDs of the entries and exceptions
+	Delete(locationID int64, ctx context.Context) (int64, error)
+	CreateException(locationID int64, exception *ScheduleException, ctx context.Context) error
+	DeleteException(locationID int64, id int, ctx context.Context) (int64, error)
+}
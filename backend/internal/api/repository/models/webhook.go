@@ -0,0 +1,71 @@
+package models
+
+import "context"
+
+// Events sent to webhooks
+const (
+	EventAlert                = "alert"                 // An alerting reading was stored
+	EventIncidentOpened       = "incident.opened"       // The first alert of a room opened an incident
+	EventIncidentAcknowledged = "incident.acknowledged" // Someone responded to an incident
+	EventIncidentResolved     = "incident.resolved"     // Someone resolved an incident, or the room was quiet
+	EventDigest               = "digest"                // A scheduled digest, only sent to the webhooks listing it
+)
+
+// Status of a webhook delivery
+const (
+	DeliveryPending   = "pending"   // Waiting to be sent, again after a failed attempt
+	DeliveryDelivered = "delivered" // The receiver answered with a 2xx status
+	DeliveryDead      = "dead"      // Failed every attempt, not retried
+)
+
+// Webhook is a receiver of alert events. Payloads are signed with its secret,
+// only the events matching its filters are sent to it.
+type Webhook struct {
+	ID          int      `json:"id,omitempty"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"` // Only returned when the webhook is created
+	RoomName    string   `json:"room_name"`        // Empty sends the events of every room
+	MinSeverity string   `json:"min_severity"`     // Empty sends the events of every severity
+	Events      []string `json:"events"`           // Empty sends every event but the digests
+	Disabled    bool     `json:"disabled"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// WebhookEvent is the payload sent to webhooks, with the reading, the incident or the digest of the event
+type WebhookEvent struct {
+	Event     string    `json:"event"`
+	Severity  string    `json:"severity"`
+	RoomName  string    `json:"room_name"`
+	CreatedAt string    `json:"created_at"`
+	Reading   *Data     `json:"reading,omitempty"`
+	Incident  *Incident `json:"incident,omitempty"`
+	Digest    *Digest   `json:"digest,omitempty"`
+}
+
+// WebhookDelivery is an event queued for a webhook and what became of it
+type WebhookDelivery struct {
+	ID             int    `json:"id"`
+	WebhookID      int    `json:"webhook_id"`
+	Event          string `json:"event"`
+	Payload        string `json:"payload"` // The event as JSON, exactly as signed
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	NextAttempt    string `json:"next_attempt"`
+	LastError      string `json:"last_error,omitempty"`
+	ResponseStatus int    `json:"response_status,omitempty"` // Of the last attempt, 0 if there was no response
+	CreatedAt      string `json:"created_at"`
+	DeliveredAt    string `json:"delivered_at,omitempty"`
+}
+
+type WebhookRepository interface {
+	Create(webhook *Webhook, ctx context.Context) error
+	ReadOne(id int, ctx context.Context) (*Webhook, error)
+	ReadAll(ctx context.Context) ([]*Webhook, error) // Ordered by ID
+	Update(webhook *Webhook, ctx context.Context) (int64, error)
+	Delete(id int, ctx context.Context) (int64, error) // Deletes the webhook's deliveries as well
+	Enqueue(delivery *WebhookDelivery, ctx context.Context) error
+	GetDue(now string, limit int, ctx context.Context) ([]*WebhookDelivery, error) // Pending deliveries due at now, oldest first
+	UpdateDelivery(delivery *WebhookDelivery, ctx context.Context) error
+	GetDeliveries(webhookID int, status string, limit int, ctx context.Context) ([]*WebhookDelivery, error) // Newest first, of every status if empty
+	PruneDeliveries(before string, ctx context.Context) (int64, error)                                      // Deletes the delivered and dead deliveries created before before
+}
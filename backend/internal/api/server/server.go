@@ -2,11 +2,21 @@ package server
 
 import (
 	"context"
+	"goapi/internal/api/handlers/alerts"
+	"goapi/internal/api/handlers/audio"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/handlers/devices"
+	"goapi/internal/api/handlers/digests"
 	"goapi/internal/api/handlers/locations"
+	"goapi/internal/api/handlers/rules"
+	"goapi/internal/api/handlers/stream"
+	"goapi/internal/api/handlers/webhooks"
 	"goapi/internal/api/middleware"
+	"goapi/internal/api/pubsub"
+	"goapi/internal/api/relay"
 	"goapi/internal/api/service"
 	dataService "goapi/internal/api/service/data"
+	"goapi/internal/api/ws"
 	"log"
 	"net/http"
 	"os"
@@ -19,6 +29,7 @@ type Server struct {
 	ctx        context.Context
 	HTTPServer *http.Server
 	logger     *log.Logger
+	ds         dataService.DataService
 }
 
 // NewServer creates a new server instance
@@ -32,19 +43,100 @@ func NewServer(ctx context.Context, sf *service.ServiceFactory, logger *log.Logg
 		logger.Fatalf("Error creating data service: %v", err)
 	}
 
-	// Create LocationService
-	ls, err := sf.CreateLocationService(serviceType)
+	// Create the WebSocket server for devices, it stores readings through the DataService
+	wss := ws.NewServer(ds, ws.OriginsFromEnv(), logger)
+
+	// Create LocationService, threshold changes are pushed to the connected devices
+	ls, err := sf.CreateLocationService(serviceType, wss)
 	if err != nil {
 		logger.Fatalf("Error creating location service: %v", err)
 	}
 
+	// Create ScheduleService, shared with the DataService and the LocationService
+	ss, err := sf.CreateScheduleService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating schedule service: %v", err)
+	}
+
+	// Create ClockService, shared with the DataService
+	cs, err := sf.CreateClockService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating clock service: %v", err)
+	}
+
+	// Create CalibrationService, shared with the DataService
+	cal, err := sf.CreateCalibrationService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating calibration service: %v", err)
+	}
+
+	// Create RuleService, shared with the DataService
+	rs, err := sf.CreateRuleService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating rule service: %v", err)
+	}
+
+	// Create IncidentService, it is notified of the readings the DataService stores
+	is, err := sf.CreateIncidentService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating incident service: %v", err)
+	}
+
+	// Create WebhookService, it is notified of the alerts and incidents
+	whs, err := sf.CreateWebhookService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating webhook service: %v", err)
+	}
+
+	// Create DigestService, it sends the scheduled digests to the webhooks and the email recipients
+	dgs, err := sf.CreateDigestService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating digest service: %v", err)
+	}
+
+	// Create NoiseDoseService, shared with the LocationService
+	dose, err := sf.CreateDoseService(serviceType)
+	if err != nil {
+		logger.Fatalf("Error creating noise dose service: %v", err)
+	}
+
+	// Create AudioService, uploaded recordings are stored as readings through the DataService
+	as, err := sf.CreateAudioService(serviceType, ds)
+	if err != nil {
+		logger.Fatalf("Error creating audio service: %v", err)
+	}
+
 	// Setup handlers
-	if err := setupDataHandlers(apiMux, logger, ds); err != nil {
+	if err := setupDataHandlers(apiMux, logger, ds, dose); err != nil {
 		logger.Fatalf("Error setting up data handlers: %v", err)
 	}
-	if err := setupLocationHandlers(apiMux, logger, ls); err != nil {
+	if err := setupLocationHandlers(apiMux, logger, ls, ss); err != nil {
 		logger.Fatalf("Error setting up location handlers: %v", err)
 	}
+	if err := setupStreamHandlers(ctx, apiMux, logger, sf.Hub()); err != nil {
+		logger.Fatalf("Error setting up stream handlers: %v", err)
+	}
+	if err := setupDeviceHandlers(apiMux, logger, cs, cal, wss); err != nil {
+		logger.Fatalf("Error setting up device handlers: %v", err)
+	}
+	if err := setupRuleHandlers(apiMux, logger, rs); err != nil {
+		logger.Fatalf("Error setting up rule handlers: %v", err)
+	}
+	if err := setupAlertHandlers(apiMux, logger, is); err != nil {
+		logger.Fatalf("Error setting up alert handlers: %v", err)
+	}
+	if err := setupWebhookHandlers(apiMux, logger, whs); err != nil {
+		logger.Fatalf("Error setting up webhook handlers: %v", err)
+	}
+	if err := setupDigestHandlers(apiMux, logger, dgs); err != nil {
+		logger.Fatalf("Error setting up digest handlers: %v", err)
+	}
+	if err := setupWebSocketHandlers(ctx, apiMux, wss); err != nil {
+		logger.Fatalf("Error setting up WebSocket handlers: %v", err)
+	}
+	if err := setupAudioHandlers(apiMux, logger, as); err != nil {
+		logger.Fatalf("Error setting up audio handlers: %v", err)
+	}
 
 	// Schedule daily cleanup of old data (older than 6 months)
 	go func() {
@@ -75,7 +167,10 @@ func NewServer(ctx context.Context, sf *service.ServiceFactory, logger *log.Logg
 	//mux.Handle("/", http.FileServer(http.Dir(frontendDir)))
 
 	// Apply authentication & common middleware to API
+	// * Readings signed by a relay keep their received_at *
+	relayCfg, _ := relay.ConfigFromEnv()
 	middlewares := []middleware.Middleware{
+		middleware.RelayAuthenticationMiddleware(relayCfg.Key),
 		middleware.BasicAuthenticationMiddleware,
 		middleware.CommonMiddleware,
 	}
@@ -117,6 +212,7 @@ func NewServer(ctx context.Context, sf *service.ServiceFactory, logger *log.Logg
 	return &Server{
 		ctx:    ctx,
 		logger: logger,
+		ds:     ds,
 		HTTPServer: &http.Server{
 			Handler: mux,
 		},
@@ -129,6 +225,12 @@ func (api *Server) Shutdown() error {
 	return api.HTTPServer.Shutdown(api.ctx)
 }
 
+// DataService returns the DataService used by the API,
+// so other ingestion paths (MQTT, ...) store readings the same way
+func (api *Server) DataService() dataService.DataService {
+	return api.ds
+}
+
 // ListenAndServe starts the HTTP server
 func (api *Server) ListenAndServe(addr string) error {
 	api.HTTPServer.Addr = addr
@@ -136,7 +238,7 @@ func (api *Server) ListenAndServe(addr string) error {
 }
 
 // ==================== DATA HANDLERS ====================
-func setupDataHandlers(mux *http.ServeMux, logger *log.Logger, ds dataService.DataService) error {
+func setupDataHandlers(mux *http.ServeMux, logger *log.Logger, ds dataService.DataService, dose dataService.DoseService) error {
 	mux.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -152,6 +254,10 @@ func setupDataHandlers(mux *http.ServeMux, logger *log.Logger, ds dataService.Da
 		}
 	})
 
+	mux.HandleFunc("POST /data/batch", func(w http.ResponseWriter, r *http.Request) {
+		data.PostBatchHandler(w, r, logger, ds)
+	})
+
 	mux.HandleFunc("/data/{id}", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -169,6 +275,30 @@ func setupDataHandlers(mux *http.ServeMux, logger *log.Logger, ds dataService.Da
 		data.GetByRoomHandler(w, r, logger, ds)
 	})
 
+	mux.HandleFunc("GET /data/daily/{room}/summary", func(w http.ResponseWriter, r *http.Request) {
+		data.GetDailyStatsHandler(w, r, logger, ds)
+	})
+
+	mux.HandleFunc("GET /data/compare", func(w http.ResponseWriter, r *http.Request) {
+		data.CompareHandler(w, r, logger, ds)
+	})
+
+	mux.HandleFunc("GET /data/anomalies/{room}", func(w http.ResponseWriter, r *http.Request) {
+		data.GetAnomaliesHandler(w, r, logger, ds)
+	})
+
+	mux.HandleFunc("GET /data/baseline/{room}", func(w http.ResponseWriter, r *http.Request) {
+		data.GetBaselinesHandler(w, r, logger, ds)
+	})
+
+	mux.HandleFunc("GET /data/spectrum/{room}", func(w http.ResponseWriter, r *http.Request) {
+		data.GetSpectrumHandler(w, r, logger, ds)
+	})
+
+	mux.HandleFunc("GET /data/dose/{room}", func(w http.ResponseWriter, r *http.Request) {
+		data.GetDoseHandler(w, r, logger, dose)
+	})
+
 	mux.HandleFunc("/data/daily/{room}", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -184,7 +314,7 @@ func setupDataHandlers(mux *http.ServeMux, logger *log.Logger, ds dataService.Da
 }
 
 // ==================== LOCATION HANDLERS ====================
-func setupLocationHandlers(mux *http.ServeMux, logger *log.Logger, ls dataService.LocationService) error {
+func setupLocationHandlers(mux *http.ServeMux, logger *log.Logger, ls dataService.LocationService, ss dataService.ScheduleService) error {
 	mux.HandleFunc("/locations", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -215,5 +345,182 @@ func setupLocationHandlers(mux *http.ServeMux, logger *log.Logger, ls dataServic
 		}
 	})
 
+	mux.HandleFunc("GET /locations/{id}/schedule", func(w http.ResponseWriter, r *http.Request) {
+		locations.GetScheduleHandler(w, r, logger, ss)
+	})
+
+	mux.HandleFunc("PUT /locations/{id}/schedule", func(w http.ResponseWriter, r *http.Request) {
+		locations.PutScheduleHandler(w, r, logger, ss)
+	})
+
+	mux.HandleFunc("DELETE /locations/{id}/schedule", func(w http.ResponseWriter, r *http.Request) {
+		locations.DeleteScheduleHandler(w, r, logger, ss)
+	})
+
+	mux.HandleFunc("POST /locations/{id}/schedule/exceptions", func(w http.ResponseWriter, r *http.Request) {
+		locations.PostScheduleExceptionHandler(w, r, logger, ss)
+	})
+
+	mux.HandleFunc("DELETE /locations/{id}/schedule/exceptions/{exception}", func(w http.ResponseWriter, r *http.Request) {
+		locations.DeleteScheduleExceptionHandler(w, r, logger, ss)
+	})
+
+	return nil
+}
+
+// ==================== STREAM HANDLERS ====================
+func setupStreamHandlers(ctx context.Context, mux *http.ServeMux, logger *log.Logger, hub *pubsub.Hub) error {
+	mux.HandleFunc("GET /stream/latest", func(w http.ResponseWriter, r *http.Request) {
+		stream.LatestHandler(w, r, logger, hub, ctx)
+	})
+
+	return nil
+}
+
+// ==================== DEVICE HANDLERS ====================
+func setupDeviceHandlers(mux *http.ServeMux, logger *log.Logger, cs dataService.ClockService, cal dataService.CalibrationService, alerter devices.Alerter) error {
+	mux.HandleFunc("GET /time", func(w http.ResponseWriter, r *http.Request) {
+		devices.TimeHandler(w, r, logger)
+	})
+
+	mux.HandleFunc("GET /devices/clock", func(w http.ResponseWriter, r *http.Request) {
+		devices.GetClocksHandler(w, r, logger, cs)
+	})
+
+	mux.HandleFunc("GET /devices/{id}/clock", func(w http.ResponseWriter, r *http.Request) {
+		devices.GetClockHandler(w, r, logger, cs)
+	})
+
+	mux.HandleFunc("GET /devices/calibration", func(w http.ResponseWriter, r *http.Request) {
+		devices.GetAllCalibrationsHandler(w, r, logger, cal)
+	})
+
+	mux.HandleFunc("GET /devices/{id}/calibration", func(w http.ResponseWriter, r *http.Request) {
+		devices.GetCalibrationsHandler(w, r, logger, cal)
+	})
+
+	mux.HandleFunc("POST /devices/{id}/calibration", func(w http.ResponseWriter, r *http.Request) {
+		devices.PostCalibrationHandler(w, r, logger, cal)
+	})
+
+	mux.HandleFunc("DELETE /devices/{id}/calibration/{calibration}", func(w http.ResponseWriter, r *http.Request) {
+		devices.DeleteCalibrationHandler(w, r, logger, cal)
+	})
+
+	mux.HandleFunc("POST /devices/{id}/alert", func(w http.ResponseWriter, r *http.Request) {
+		devices.PlayAlertHandler(w, r, logger, alerter)
+	})
+
+	return nil
+}
+
+// ==================== RULE HANDLERS ====================
+func setupRuleHandlers(mux *http.ServeMux, logger *log.Logger, rs dataService.RuleService) error {
+	mux.HandleFunc("GET /rules", func(w http.ResponseWriter, r *http.Request) {
+		rules.GetRulesHandler(w, r, logger, rs)
+	})
+
+	mux.HandleFunc("POST /rules", func(w http.ResponseWriter, r *http.Request) {
+		rules.PostRuleHandler(w, r, logger, rs)
+	})
+
+	mux.HandleFunc("GET /rules/firings", func(w http.ResponseWriter, r *http.Request) {
+		rules.GetFiringsHandler(w, r, logger, rs)
+	})
+
+	mux.HandleFunc("GET /rules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		rules.GetRuleHandler(w, r, logger, rs)
+	})
+
+	mux.HandleFunc("PUT /rules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		rules.PutRuleHandler(w, r, logger, rs)
+	})
+
+	mux.HandleFunc("DELETE /rules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		rules.DeleteRuleHandler(w, r, logger, rs)
+	})
+
+	return nil
+}
+
+// ==================== ALERT HANDLERS ====================
+func setupAlertHandlers(mux *http.ServeMux, logger *log.Logger, is dataService.IncidentService) error {
+	mux.HandleFunc("GET /alerts", func(w http.ResponseWriter, r *http.Request) {
+		alerts.GetAlertsHandler(w, r, logger, is)
+	})
+
+	mux.HandleFunc("GET /alerts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		alerts.GetAlertHandler(w, r, logger, is)
+	})
+
+	mux.HandleFunc("POST /alerts/{id}/acknowledge", func(w http.ResponseWriter, r *http.Request) {
+		alerts.AcknowledgeAlertHandler(w, r, logger, is)
+	})
+
+	mux.HandleFunc("POST /alerts/{id}/resolve", func(w http.ResponseWriter, r *http.Request) {
+		alerts.ResolveAlertHandler(w, r, logger, is)
+	})
+
+	return nil
+}
+
+// ==================== WEBHOOK HANDLERS ====================
+func setupWebhookHandlers(mux *http.ServeMux, logger *log.Logger, whs dataService.WebhookService) error {
+	mux.HandleFunc("GET /webhooks", func(w http.ResponseWriter, r *http.Request) {
+		webhooks.GetWebhooksHandler(w, r, logger, whs)
+	})
+
+	mux.HandleFunc("POST /webhooks", func(w http.ResponseWriter, r *http.Request) {
+		webhooks.PostWebhookHandler(w, r, logger, whs)
+	})
+
+	mux.HandleFunc("GET /webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		webhooks.GetWebhookHandler(w, r, logger, whs)
+	})
+
+	mux.HandleFunc("PUT /webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		webhooks.PutWebhookHandler(w, r, logger, whs)
+	})
+
+	mux.HandleFunc("DELETE /webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		webhooks.DeleteWebhookHandler(w, r, logger, whs)
+	})
+
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		webhooks.GetDeliveriesHandler(w, r, logger, whs)
+	})
+
+	return nil
+}
+
+// ==================== DIGEST HANDLERS ====================
+func setupDigestHandlers(mux *http.ServeMux, logger *log.Logger, dgs dataService.DigestService) error {
+	mux.HandleFunc("GET /digests", func(w http.ResponseWriter, r *http.Request) {
+		digests.GetDigestHandler(w, r, logger, dgs)
+	})
+
+	return nil
+}
+
+// ==================== WEBSOCKET HANDLERS ====================
+func setupWebSocketHandlers(ctx context.Context, mux *http.ServeMux, wss *ws.Server) error {
+	// * Basic auth is checked on the upgrade request like on any other API request *
+	mux.HandleFunc("GET /ws/device", func(w http.ResponseWriter, r *http.Request) {
+		wss.Serve(w, r, ctx)
+	})
+
+	return nil
+}
+
+// ==================== AUDIO HANDLERS ====================
+func setupAudioHandlers(mux *http.ServeMux, logger *log.Logger, as dataService.AudioService) error {
+	mux.HandleFunc("POST /audio", func(w http.ResponseWriter, r *http.Request) {
+		audio.PostAudioHandler(w, r, logger, as)
+	})
+
+	mux.HandleFunc("GET /audio/{id}", func(w http.ResponseWriter, r *http.Request) {
+		audio.GetAudioAnalysisHandler(w, r, logger, as)
+	})
+
 	return nil
 }
@@ -10,62 +10,140 @@ import (
 type DataServicePostgreSQL struct {
 	repo         models.DataRepository
 	locationRepo models.LocationRepository
+	clock        ClockService       // Stamps received_at and checks device clocks, may be nil
+	calibration  CalibrationService // Corrects the levels devices measure, may be nil
+	rules        RuleService        // Decides which readings are alerts, may be nil
+	schedules    ScheduleService    // Sets the threshold of readings from their location's schedule, may be nil
+	publishers   []Publisher        // Notified after each stored reading
 }
 
-func NewDataServicePostgreSQL(repo models.DataRepository, locationRepo models.LocationRepository) *DataServicePostgreSQL {
+func NewDataServicePostgreSQL(repo models.DataRepository, locationRepo models.LocationRepository, clock ClockService, calibration CalibrationService, rules RuleService, schedules ScheduleService, publishers ...Publisher) *DataServicePostgreSQL {
 	return &DataServicePostgreSQL{
 		repo:         repo,
 		locationRepo: locationRepo,
+		clock:        clock,
+		calibration:  calibration,
+		rules:        rules,
+		schedules:    schedules,
+		publishers:   publishers,
 	}
 }
 
 func (ds *DataServicePostgreSQL) CleanOldData(ctx context.Context) error {
-	// SQL deletes rows where measure_time is older than 6 month
-	query := `DELETE FROM data WHERE measure_time < datetime('now', '-6 months');`
-	_, err := ds.repo.ExecContext(ctx, query)
+	// Readings are kept for DataRetentionMonths and their rollups longer, see retentionCutoffs
+	readingsBefore, rollupsBefore := retentionCutoffs(time.Now())
+	if _, err := ds.repo.ExecContext(ctx, `DELETE FROM data WHERE measure_time < $1;`, readingsBefore); err != nil {
+		return err
+	}
+	if _, err := ds.repo.ExecContext(ctx, `DELETE FROM data_rollups WHERE bucket < $1;`, rollupsBefore); err != nil {
+		return err
+	}
+
+	// Idempotency keys only need to outlive device retries
+	cutoff := time.Now().UTC().Add(-IdempotencyKeyTTL).Format(time.RFC3339)
+	_, err := ds.repo.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1;`, cutoff)
 	return err
 }
 
 func (ds *DataServicePostgreSQL) Create(data *models.Data, ctx context.Context) error {
+	_, err := ds.CreateIdempotent(data, models.IdempotencyKey{}, ctx)
+	return err
+}
+
+// CreateIdempotent stores a periodic reading once, see DataRepository.CreateIdempotent
+func (ds *DataServicePostgreSQL) CreateIdempotent(data *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
 	// If no room_name provided, set it as current chosen location
-	if data.RoomName == "" {
-		if ds.locationRepo != nil {
-			loc, err := ds.locationRepo.GetChosenLocation(ctx)
-			if err == nil && loc != nil && loc.Name != "" {
-				data.RoomName = loc.Name
-			} else {
-				data.RoomName = "Unknown" // fallback if no chosen location
-			}
-		} else {
-			data.RoomName = "Unknown"
-		}
-	}
+	fillRoomName(ds.locationRepo, data, ctx)
 
+	if err := ds.ResolveThreshold(data, ctx); err != nil {
+		return false, err
+	}
+	if err := ds.Calibrate(data, ctx); err != nil {
+		return false, err
+	}
 	if err := ds.ValidateData(data); err != nil {
-		return DataError{Message: "Invalid data: " + err.Error()}
+		return false, DataError{Message: "Invalid data: " + err.Error()}
+	}
+	decisions, err := ds.EvaluateAlerts([]*models.Data{data}, ctx)
+	if err != nil {
+		return false, err
+	}
+	created, err := ds.repo.CreateIdempotent(data, key, ctx)
+	if err == models.ErrIdempotencyKeyReused {
+		return false, ConflictError{Message: "The Idempotency-Key was already used for a different reading of the device."}
+	}
+	if err != nil {
+		return false, err
+	}
+	if created {
+		if err := ds.RecordAlerts(decisions[0], ctx); err != nil {
+			return false, err
+		}
+		publish(ds.publishers, data)
 	}
-	return ds.repo.Create(data, ctx)
+	return created, nil
 }
 
 func (ds *DataServicePostgreSQL) CreateLatest(data *models.Data, ctx context.Context) error {
 	// If no room_name provided, set it as current chosen location
-	if data.RoomName == "" {
-		if ds.locationRepo != nil {
-			loc, err := ds.locationRepo.GetChosenLocation(ctx)
-			if err == nil && loc != nil && loc.Name != "" {
-				data.RoomName = loc.Name
-			} else {
-				data.RoomName = "Unknown" // fallback if no chosen location
-			}
-		} else {
-			data.RoomName = "Unknown"
-		}
-	}
+	fillRoomName(ds.locationRepo, data, ctx)
 
+	if err := ds.ResolveThreshold(data, ctx); err != nil {
+		return err
+	}
+	if err := ds.Calibrate(data, ctx); err != nil {
+		return err
+	}
 	if err := ds.ValidateData(data); err != nil {
 		return DataError{Message: "Invalid data: " + err.Error()}
 	}
-	return ds.repo.CreateLatest(data, ctx)
+	decisions, err := ds.EvaluateAlerts([]*models.Data{data}, ctx)
+	if err != nil {
+		return err
+	}
+	if err := ds.repo.CreateLatest(data, ctx); err != nil {
+		return err
+	}
+	if err := ds.RecordAlerts(decisions[0], ctx); err != nil {
+		return err
+	}
+	publish(ds.publishers, data)
+	return nil
+}
+
+// CheckClock stamps a reading received from a device, see DeviceClockService.CheckClock
+func (ds *DataServicePostgreSQL) CheckClock(data *models.Data, ctx context.Context) error {
+	if ds.clock == nil {
+		return nil
+	}
+	return ds.clock.CheckClock(data, ctx)
+}
+
+// Calibrate corrects the level of a reading received from a device, see DeviceCalibrationService.Calibrate
+func (ds *DataServicePostgreSQL) Calibrate(data *models.Data, ctx context.Context) error {
+	if ds.calibration == nil {
+		return nil
+	}
+	return ds.calibration.Calibrate(data, ctx)
+}
+
+// ResolveThreshold sets the threshold of a reading whose device didn't send one, see ThresholdScheduler.ResolveThreshold
+func (ds *DataServicePostgreSQL) ResolveThreshold(data *models.Data, ctx context.Context) error {
+	return resolveThreshold(ds.schedules, ds.locationRepo, data, ctx)
+}
+
+// EvaluateAlerts decides which readings are alerts, see RuleEngine.EvaluateAlerts
+func (ds *DataServicePostgreSQL) EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	return evaluateAlerts(ds.rules, readings, ctx)
+}
+
+// RecordAlerts records the firings a stored reading starts and ends, see RuleEngine.RecordAlerts
+func (ds *DataServicePostgreSQL) RecordAlerts(decision *AlertDecision, ctx context.Context) error {
+	return recordAlerts(ds.rules, decision, ctx)
+}
+
+func (ds *DataServicePostgreSQL) CreateBatch(data []*models.Data, atomic bool, ctx context.Context) ([]BatchResult, error) {
+	return createBatch(ds, ds.repo, ds.locationRepo, ds.publishers, data, atomic, ctx)
 }
 
 func (ds *DataServicePostgreSQL) ReadOne(id int, ctx context.Context) (*models.Data, error) {
@@ -117,33 +195,80 @@ func (ds *DataServicePostgreSQL) GetDailySummary(roomName string, date time.Time
 	return ds.repo.GetDailySummary(roomName, date, ctx)
 }
 
-func (ds *DataServicePostgreSQL) GetByRoom(roomName string, ctx context.Context) ([]*models.Data, error) {
+// GetDailyStats summarizes the readings of a room for the day of date,
+// computed here so both databases give the same result
+func (ds *DataServicePostgreSQL) GetDailyStats(roomName string, date time.Time, ctx context.Context) (*DailyStats, error) {
 	if roomName == "" {
 		return nil, DataError{Message: "Room name is required"}
 	}
 
-	// Get data for the specified room
-	return ds.repo.GetByRoom(roomName, ctx)
+	rows, err := ds.repo.GetDailySummary(roomName, date, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return computeDailyStats(rows, roomName, date), nil
 }
 
-func (ds *DataServicePostgreSQL) ValidateData(data *models.Data) error {
-	var errMsg string
-	if data.DeviceID == "" || len(data.DeviceID) > 50 {
-		errMsg += "DeviceID is required and must be less than 50 characters. "
+// GetAnomalies returns the readings of a room measured from <= measure_time < to
+// that scored at least minScore standard deviations from the room's baseline
+func (ds *DataServicePostgreSQL) GetAnomalies(roomName string, from, to time.Time, minScore float64, ctx context.Context) ([]*models.Data, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
 	}
-	if data.RoomName == "" {
-		errMsg += "RoomName is required. "
+	if !from.Before(to) {
+		return nil, DataError{Message: "from must be before to"}
 	}
-	if data.SoundLevel < 0 || data.SoundLevel > 150 {
-		errMsg += "SoundLevel must be between 0 and 150 dB. "
+	if minScore <= 0 {
+		return nil, DataError{Message: "min_score must be greater than 0"}
 	}
-	if data.Threshold < 0 || data.Threshold > 150 {
-		errMsg += "Threshold must be between 0 and 150 dB. "
+	return ds.repo.GetAnomalies(roomName, from, to, minScore, ctx)
+}
+
+// GetBaselines returns the learned levels of a room per hour of the week
+func (ds *DataServicePostgreSQL) GetBaselines(roomName string, ctx context.Context) ([]*models.Baseline, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
 	}
+	return ds.repo.GetBaselines(roomName, ctx)
+}
+
+// ComparePeriods compares the readings of a room, or of all rooms if roomName is empty,
+// from <= measure_time < to with the ones compareFrom <= measure_time < compareTo
+func (ds *DataServicePostgreSQL) ComparePeriods(roomName string, from, to, compareFrom, compareTo time.Time, ctx context.Context) (*PeriodComparison, error) {
+	return comparePeriods(ds.repo, roomName, from, to, compareFrom, compareTo, ctx)
+}
+
+// GetSpectrum averages the octave band levels of a room from <= measure_time < to,
+// as a whole and per hour or day if bucket is set
+func (ds *DataServicePostgreSQL) GetSpectrum(roomName string, from, to time.Time, bucket string, ctx context.Context) (*RoomSpectrum, error) {
+	return getSpectrum(ds.repo, roomName, from, to, bucket, ctx)
+}
 
-	if errMsg != "" {
-		return DataError{Message: errMsg}
+func (ds *DataServicePostgreSQL) GetByRoom(roomName string, ctx context.Context) ([]*models.Data, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
 	}
 
-	return nil
+	// Get data for the specified room
+	return ds.repo.GetByRoom(roomName, ctx)
+}
+
+// GetByRoomRollups is GetByRoom read from the daily rollups, one row per device and day
+func (ds *DataServicePostgreSQL) GetByRoomRollups(roomName string, ctx context.Context) ([]*models.Data, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
+	}
+	return weeklyRollups(ds.repo, ds.locationRepo, roomName, ctx)
+}
+
+// GetDailySummaryRollups is GetDailySummary read from the hourly rollups, one row per device and hour
+func (ds *DataServicePostgreSQL) GetDailySummaryRollups(roomName string, date time.Time, ctx context.Context) ([]*models.Data, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
+	}
+	return dailyRollups(ds.repo, ds.locationRepo, roomName, date, ctx)
+}
+
+func (ds *DataServicePostgreSQL) ValidateData(data *models.Data) error {
+	return validateData(data)
 }
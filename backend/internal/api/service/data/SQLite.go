@@ -10,60 +10,138 @@ import (
 type DataServiceSQLite struct {
 	repo         models.DataRepository
 	locationRepo models.LocationRepository // Add locationRepo for accessing locations
+	clock        ClockService              // Stamps received_at and checks device clocks, may be nil
+	calibration  CalibrationService        // Corrects the levels devices measure, may be nil
+	rules        RuleService               // Decides which readings are alerts, may be nil
+	schedules    ScheduleService           // Sets the threshold of readings from their location's schedule, may be nil
+	publishers   []Publisher               // Notified after each stored reading
 }
 
-func NewDataServiceSQLite(repo models.DataRepository, locationRepo models.LocationRepository) *DataServiceSQLite {
+func NewDataServiceSQLite(repo models.DataRepository, locationRepo models.LocationRepository, clock ClockService, calibration CalibrationService, rules RuleService, schedules ScheduleService, publishers ...Publisher) *DataServiceSQLite {
 	return &DataServiceSQLite{
 		repo:         repo,
 		locationRepo: locationRepo,
+		clock:        clock,
+		calibration:  calibration,
+		rules:        rules,
+		schedules:    schedules,
+		publishers:   publishers,
 	}
 }
 func (ds *DataServiceSQLite) CleanOldData(ctx context.Context) error {
-	// SQL deletes rows where measure_time is older than 6 months
-	query := `DELETE FROM data WHERE measure_time < datetime('now', '-6 months');`
-	_, err := ds.repo.ExecContext(ctx, query)
+	// Readings are kept for DataRetentionMonths and their rollups longer, see retentionCutoffs
+	readingsBefore, rollupsBefore := retentionCutoffs(time.Now())
+	if _, err := ds.repo.ExecContext(ctx, `DELETE FROM data WHERE measure_time < ?;`, readingsBefore); err != nil {
+		return err
+	}
+	if _, err := ds.repo.ExecContext(ctx, `DELETE FROM data_rollups WHERE bucket < ?;`, rollupsBefore); err != nil {
+		return err
+	}
+
+	// Idempotency keys only need to outlive device retries
+	cutoff := time.Now().UTC().Add(-IdempotencyKeyTTL).Format(time.RFC3339)
+	_, err := ds.repo.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?;`, cutoff)
 	return err
 }
 func (ds *DataServiceSQLite) Create(data *models.Data, ctx context.Context) error {
+	_, err := ds.CreateIdempotent(data, models.IdempotencyKey{}, ctx)
+	return err
+}
+
+// CreateIdempotent stores a periodic reading once, see DataRepository.CreateIdempotent
+func (ds *DataServiceSQLite) CreateIdempotent(data *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
 	// If no room_name provided, set it as current chosen location
-	if data.RoomName == "" {
-		if ds.locationRepo != nil {
-			loc, err := ds.locationRepo.GetChosenLocation(ctx)
-			if err == nil && loc != nil && loc.Name != "" {
-				data.RoomName = loc.Name
-			} else {
-				data.RoomName = "Unknown" // fallback if no chosen location
-			}
-		} else {
-			data.RoomName = "Unknown"
-		}
-	}
+	fillRoomName(ds.locationRepo, data, ctx)
 
+	if err := ds.ResolveThreshold(data, ctx); err != nil {
+		return false, err
+	}
+	if err := ds.Calibrate(data, ctx); err != nil {
+		return false, err
+	}
 	if err := ds.ValidateData(data); err != nil {
-		return DataError{Message: "Invalid data: " + err.Error()}
+		return false, DataError{Message: "Invalid data: " + err.Error()}
+	}
+	decisions, err := ds.EvaluateAlerts([]*models.Data{data}, ctx)
+	if err != nil {
+		return false, err
+	}
+	created, err := ds.repo.CreateIdempotent(data, key, ctx)
+	if err == models.ErrIdempotencyKeyReused {
+		return false, ConflictError{Message: "The Idempotency-Key was already used for a different reading of the device."}
+	}
+	if err != nil {
+		return false, err
+	}
+	if created {
+		if err := ds.RecordAlerts(decisions[0], ctx); err != nil {
+			return false, err
+		}
+		publish(ds.publishers, data)
 	}
-	return ds.repo.Create(data, ctx)
+	return created, nil
 }
 
 func (ds *DataServiceSQLite) CreateLatest(data *models.Data, ctx context.Context) error {
 	// If no room_name provided, set it as current chosen location
-	if data.RoomName == "" {
-		if ds.locationRepo != nil {
-			loc, err := ds.locationRepo.GetChosenLocation(ctx)
-			if err == nil && loc != nil && loc.Name != "" {
-				data.RoomName = loc.Name
-			} else {
-				data.RoomName = "Unknown" // fallback if no chosen location
-			}
-		} else {
-			data.RoomName = "Unknown"
-		}
-	}
+	fillRoomName(ds.locationRepo, data, ctx)
 
+	if err := ds.ResolveThreshold(data, ctx); err != nil {
+		return err
+	}
+	if err := ds.Calibrate(data, ctx); err != nil {
+		return err
+	}
 	if err := ds.ValidateData(data); err != nil {
 		return DataError{Message: "Invalid data: " + err.Error()}
 	}
-	return ds.repo.CreateLatest(data, ctx)
+	decisions, err := ds.EvaluateAlerts([]*models.Data{data}, ctx)
+	if err != nil {
+		return err
+	}
+	if err := ds.repo.CreateLatest(data, ctx); err != nil {
+		return err
+	}
+	if err := ds.RecordAlerts(decisions[0], ctx); err != nil {
+		return err
+	}
+	publish(ds.publishers, data)
+	return nil
+}
+
+// CheckClock stamps a reading received from a device, see DeviceClockService.CheckClock
+func (ds *DataServiceSQLite) CheckClock(data *models.Data, ctx context.Context) error {
+	if ds.clock == nil {
+		return nil
+	}
+	return ds.clock.CheckClock(data, ctx)
+}
+
+// Calibrate corrects the level of a reading received from a device, see DeviceCalibrationService.Calibrate
+func (ds *DataServiceSQLite) Calibrate(data *models.Data, ctx context.Context) error {
+	if ds.calibration == nil {
+		return nil
+	}
+	return ds.calibration.Calibrate(data, ctx)
+}
+
+// ResolveThreshold sets the threshold of a reading whose device didn't send one, see ThresholdScheduler.ResolveThreshold
+func (ds *DataServiceSQLite) ResolveThreshold(data *models.Data, ctx context.Context) error {
+	return resolveThreshold(ds.schedules, ds.locationRepo, data, ctx)
+}
+
+// EvaluateAlerts decides which readings are alerts, see RuleEngine.EvaluateAlerts
+func (ds *DataServiceSQLite) EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	return evaluateAlerts(ds.rules, readings, ctx)
+}
+
+// RecordAlerts records the firings a stored reading starts and ends, see RuleEngine.RecordAlerts
+func (ds *DataServiceSQLite) RecordAlerts(decision *AlertDecision, ctx context.Context) error {
+	return recordAlerts(ds.rules, decision, ctx)
+}
+
+func (ds *DataServiceSQLite) CreateBatch(data []*models.Data, atomic bool, ctx context.Context) ([]BatchResult, error) {
+	return createBatch(ds, ds.repo, ds.locationRepo, ds.publishers, data, atomic, ctx)
 }
 
 func (ds *DataServiceSQLite) ReadOne(id int, ctx context.Context) (*models.Data, error) {
@@ -109,29 +187,24 @@ func (ds *DataServiceSQLite) Delete(data *models.Data, ctx context.Context) (int
 	return ds.repo.Delete(data, ctx)
 }
 
-func (ds *DataServiceSQLite) ValidateData(data *models.Data) error {
-	var errMsg string
-	if data.DeviceID == "" || len(data.DeviceID) > 50 {
-		errMsg += "DeviceID is required and must be less than 50 characters. "
-	}
-	if data.RoomName == "" {
-		errMsg += "RoomName is required. "
-	}
-	// Maybe we need to edit the system around RoomName so typos don't messup the data, for robustness and better usability.
-	// Maybe by making a predefined list of room names to choose from in the frontend.
-	if data.SoundLevel < 0 || data.SoundLevel > 150 {
-		errMsg += "SoundLevel must be between 0 and 150 dB. "
-	}
-	if data.Threshold < 0 || data.Threshold > 150 {
-		errMsg += "Threshold must be between 0 and 150 dB. "
+// GetByRoomRollups is GetByRoom read from the daily rollups, one row per device and day
+func (ds *DataServiceSQLite) GetByRoomRollups(roomName string, ctx context.Context) ([]*models.Data, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
 	}
+	return weeklyRollups(ds.repo, ds.locationRepo, roomName, ctx)
+}
 
-	if errMsg != "" {
-		return DataError{Message: errMsg}
+// GetDailySummaryRollups is GetDailySummary read from the hourly rollups, one row per device and hour
+func (ds *DataServiceSQLite) GetDailySummaryRollups(roomName string, date time.Time, ctx context.Context) ([]*models.Data, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
 	}
-	// Apparently there was bug here too, it didn't return error message when there was validation error.
+	return dailyRollups(ds.repo, ds.locationRepo, roomName, date, ctx)
+}
 
-	return nil
+func (ds *DataServiceSQLite) ValidateData(data *models.Data) error {
+	return validateData(data)
 }
 
 func (ds *DataServiceSQLite) GetDailySummary(roomName string, date time.Time, ctx context.Context) ([]*models.Data, error) {
@@ -143,6 +216,55 @@ func (ds *DataServiceSQLite) GetDailySummary(roomName string, date time.Time, ct
 	return ds.repo.GetDailySummary(roomName, date, ctx)
 }
 
+// GetDailyStats summarizes the readings of a room for the day of date,
+// computed here so both databases give the same result
+func (ds *DataServiceSQLite) GetDailyStats(roomName string, date time.Time, ctx context.Context) (*DailyStats, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
+	}
+
+	rows, err := ds.repo.GetDailySummary(roomName, date, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return computeDailyStats(rows, roomName, date), nil
+}
+
+// GetAnomalies returns the readings of a room measured from <= measure_time < to
+// that scored at least minScore standard deviations from the room's baseline
+func (ds *DataServiceSQLite) GetAnomalies(roomName string, from, to time.Time, minScore float64, ctx context.Context) ([]*models.Data, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
+	}
+	if !from.Before(to) {
+		return nil, DataError{Message: "from must be before to"}
+	}
+	if minScore <= 0 {
+		return nil, DataError{Message: "min_score must be greater than 0"}
+	}
+	return ds.repo.GetAnomalies(roomName, from, to, minScore, ctx)
+}
+
+// GetBaselines returns the learned levels of a room per hour of the week
+func (ds *DataServiceSQLite) GetBaselines(roomName string, ctx context.Context) ([]*models.Baseline, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
+	}
+	return ds.repo.GetBaselines(roomName, ctx)
+}
+
+// ComparePeriods compares the readings of a room, or of all rooms if roomName is empty,
+// from <= measure_time < to with the ones compareFrom <= measure_time < compareTo
+func (ds *DataServiceSQLite) ComparePeriods(roomName string, from, to, compareFrom, compareTo time.Time, ctx context.Context) (*PeriodComparison, error) {
+	return comparePeriods(ds.repo, roomName, from, to, compareFrom, compareTo, ctx)
+}
+
+// GetSpectrum averages the octave band levels of a room from <= measure_time < to,
+// as a whole and per hour or day if bucket is set
+func (ds *DataServiceSQLite) GetSpectrum(roomName string, from, to time.Time, bucket string, ctx context.Context) (*RoomSpectrum, error) {
+	return getSpectrum(ds.repo, roomName, from, to, bucket, ctx)
+}
+
 func (ds *DataServiceSQLite) GetByRoom(roomName string, ctx context.Context) ([]*models.Data, error) {
 	if roomName == "" {
 		return nil, DataError{Message: "Room name is required"}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"math"
+	"time"
+)
+
+// Sound levels are logarithmic, so they are averaged through their energy:
+// Leq = 10 * log10(mean(10^(L/10)))
+
+// toEnergy returns the relative energy of a sound level in dB
+func toEnergy(level float64) float64 {
+	return math.Pow(10, level/10)
+}
+
+// fromEnergy returns the sound level in dB of a relative energy
+func fromEnergy(energy float64) float64 {
+	return 10 * math.Log10(energy)
+}
+
+// roundLevel rounds a sound level to 0.01 dB
+func roundLevel(level float64) float64 {
+	return math.Round(level*100) / 100
+}
+
+// isLevel reports whether a level is a finite number from 0 to 150 dB, NaN fails every comparison so it is checked first
+func isLevel(level float64) bool {
+	return !math.IsNaN(level) && level >= 0 && level <= 150
+}
+
+// validateData checks the fields of a reading, the same for every database
+func validateData(data *models.Data) error {
+	var errMsg string
+	if data.DeviceID == "" || len(data.DeviceID) > 50 {
+		errMsg += "DeviceID is required and must be less than 50 characters. "
+	}
+	if data.RoomName == "" {
+		errMsg += "RoomName is required. "
+	}
+	// Maybe we need to edit the system around RoomName so typos don't messup the data, for robustness and better usability.
+	// Maybe by making a predefined list of room names to choose from in the frontend.
+	if !isLevel(data.SoundLevel) {
+		errMsg += "SoundLevel must be between 0 and 150 dB. "
+	}
+	if !isLevel(data.Threshold) {
+		errMsg += "Threshold must be between 0 and 150 dB. "
+	}
+	if _, err := time.Parse(time.RFC3339, data.MeasureTime); err != nil {
+		errMsg += "MeasureTime must be an RFC 3339 timestamp. "
+	}
+	if len(data.Bands) > 0 && len(data.Bands) != len(models.BandFrequencies) {
+		errMsg += "Bands must have 8 levels, from 63 Hz to 8 kHz. "
+	}
+	for _, level := range data.Bands {
+		if !isLevel(level) {
+			errMsg += "Band levels must be between 0 and 150 dB. "
+			break
+		}
+	}
+
+	if errMsg != "" {
+		return DataError{Message: errMsg}
+	}
+	// Apparently there was bug here too, it didn't return error message when there was validation error.
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// AggregateWindowFromEnv returns the window of server computed periodic rows from AGGREGATE_WINDOW
+// (a duration such as 10m). ok is false when it is not set, in which case devices send their own periodic rows.
+func AggregateWindowFromEnv() (window time.Duration, ok bool, err error) {
+	v := os.Getenv("AGGREGATE_WINDOW")
+	if v == "" {
+		return 0, false, nil
+	}
+	window, err = time.ParseDuration(v)
+	if err != nil || window < time.Minute {
+		return 0, false, fmt.Errorf("invalid AGGREGATE_WINDOW %q, expected a duration of at least 1m", v)
+	}
+	return window, true, nil
+}
+
+// Aggregator computes periodic rows from the latest readings of each device.
+// Windows are aligned to the clock (e.g. 10:00-10:10) and the row of a window is written,
+// with measure_time at the end of the window, once a sample of a later window arrives
+// or the window has been over for a while. The window in progress is lost on shutdown.
+type Aggregator struct {
+	repo       models.DataRepository
+	window     time.Duration
+	logger     *log.Logger
+	publishers []Publisher // Notified about the written rows
+
+	mu      sync.Mutex
+	windows map[string]*aggregateWindow // By device ID
+}
+
+// aggregateWindow accumulates the samples of one device
+type aggregateWindow struct {
+	start     time.Time
+	roomName  string
+	threshold float64
+	energy    float64 // Sum of the relative energy of the samples
+	samples   int
+	max, min  float64
+	bands     bandEnergy // Of the samples that carry octave bands
+	rawEnergy float64    // Of the samples' levels before calibration
+	raw       int        // Samples with a raw level
+	alert     bool       // Whether a sample was an alert
+	ruleID    int        // Most severe rule of the first alert, if it was one of a rule
+}
+
+func NewAggregator(repo models.DataRepository, window time.Duration, logger *log.Logger, publishers ...Publisher) *Aggregator {
+	return &Aggregator{
+		repo:       repo,
+		window:     window,
+		logger:     logger,
+		publishers: publishers,
+		windows:    make(map[string]*aggregateWindow),
+	}
+}
+
+// Publish adds a stored latest reading to the window of its device
+func (a *Aggregator) Publish(data *models.Data) {
+	if data.IsPeriodic {
+		return
+	}
+	measured, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if err != nil {
+		return
+	}
+	start := measured.Truncate(a.window)
+
+	a.mu.Lock()
+	var done *models.Data
+	w, ok := a.windows[data.DeviceID]
+	switch {
+	case ok && start.Before(w.start):
+		// * A late sample of a window that has been written already *
+		a.mu.Unlock()
+		return
+	case ok && start.After(w.start):
+		done = a.row(data.DeviceID, w)
+		ok = false
+	}
+	if !ok {
+		w = &aggregateWindow{start: start, min: math.Inf(1), max: math.Inf(-1)}
+		a.windows[data.DeviceID] = w
+	}
+	w.add(data)
+	a.mu.Unlock()
+
+	if done != nil {
+		a.write(done)
+	}
+}
+
+// Run writes the windows of devices that stopped sending until ctx is cancelled
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.window / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flush writes the windows that ended a tenth of a window before now,
+// the margin lets samples delayed by the network arrive
+func (a *Aggregator) flush(now time.Time) {
+	var done []*models.Data
+	a.mu.Lock()
+	for deviceID, w := range a.windows {
+		if now.After(w.start.Add(a.window + a.window/10)) {
+			done = append(done, a.row(deviceID, w))
+			delete(a.windows, deviceID)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, data := range done {
+		a.write(data)
+	}
+}
+
+func (w *aggregateWindow) add(data *models.Data) {
+	w.roomName = data.RoomName
+	w.threshold = data.Threshold
+	w.energy += toEnergy(data.SoundLevel)
+	w.samples++
+	w.max = math.Max(w.max, data.SoundLevel)
+	w.min = math.Min(w.min, data.SoundLevel)
+	if len(data.Bands) == len(models.BandFrequencies) {
+		w.bands.add(data.Bands)
+	}
+	if data.RawSoundLevel != 0 {
+		w.rawEnergy += toEnergy(data.RawSoundLevel)
+		w.raw++
+	}
+	if data.IsAlert && !w.alert {
+		w.alert = true
+		w.ruleID = data.AlertRuleID
+	}
+}
+
+// row returns the periodic row of a window, an alert if any of its samples was
+func (a *Aggregator) row(deviceID string, w *aggregateWindow) *models.Data {
+	leq := fromEnergy(w.energy / float64(w.samples))
+	var bands []float64
+	if w.bands.readings > 0 {
+		bands = w.bands.spectrum().Levels
+	}
+	// * The row can be recalibrated if all its samples can *
+	var raw float64
+	if w.raw == w.samples {
+		raw = roundLevel(fromEnergy(w.rawEnergy / float64(w.raw)))
+	}
+	return &models.Data{
+		DeviceID:      deviceID,
+		RoomName:      w.roomName,
+		SoundLevel:    roundLevel(leq),
+		Threshold:     w.threshold,
+		IsAlert:       w.alert,
+		AlertRuleID:   w.ruleID,
+		MeasureTime:   w.start.Add(a.window).UTC().Format(time.RFC3339),
+		Description:   fmt.Sprintf("Leq over %s of %d samples", a.window, w.samples),
+		IsPeriodic:    true,
+		ReceivedAt:    time.Now().UTC().Format(time.RFC3339),
+		MaxLevel:      w.max,
+		MinLevel:      w.min,
+		Bands:         bands,
+		RawSoundLevel: raw,
+	}
+}
+
+func (a *Aggregator) write(data *models.Data) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	created, err := a.repo.CreateIdempotent(data, models.IdempotencyKey{}, ctx)
+	if err != nil {
+		a.logger.Printf("Error storing periodic row of %s: %v", data.DeviceID, err)
+		return
+	}
+	if created {
+		publish(a.publishers, data)
+	}
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"log"
+	"testing"
+	"time"
+)
+
+// * recordingRepository keeps the rows written with CreateIdempotent *
+type recordingRepository struct {
+	models.DataRepository
+	created []*models.Data
+}
+
+func (r *recordingRepository) CreateIdempotent(data *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	r.created = append(r.created, data)
+	return true, nil
+}
+
+func sample(deviceID string, level float64, measureTime string) *models.Data {
+	return &models.Data{DeviceID: deviceID, RoomName: "PlayRoom_A", SoundLevel: level, Threshold: 70, MeasureTime: measureTime}
+}
+
+func TestAggregatorWritesEnergyAverage(t *testing.T) {
+	repo := &recordingRepository{}
+	a := NewAggregator(repo, 10*time.Minute, log.Default())
+
+	a.Publish(sample("arduino_001", 60, "2024-10-27T10:00:00Z"))
+	a.Publish(sample("arduino_001", 70, "2024-10-27T10:09:59Z"))
+	a.Publish(&models.Data{DeviceID: "arduino_001", SoundLevel: 99, MeasureTime: "2024-10-27T10:05:00Z", IsPeriodic: true})
+	a.Publish(sample("arduino_002", 50, "2024-10-27T10:01:00Z"))
+	if len(repo.created) != 0 {
+		t.Fatalf("expected no rows before the window ended, got %d", len(repo.created))
+	}
+
+	// * The first sample of the next window closes the previous one, late samples are dropped *
+	a.Publish(sample("arduino_001", 40, "2024-10-27T10:10:05Z"))
+	a.Publish(sample("arduino_001", 90, "2024-10-27T10:09:00Z"))
+	if len(repo.created) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(repo.created))
+	}
+
+	row := repo.created[0]
+	if row.SoundLevel != 67.4 || row.MaxLevel != 70 || row.MinLevel != 60 {
+		t.Errorf("unexpected levels: Leq %v, max %v, min %v", row.SoundLevel, row.MaxLevel, row.MinLevel)
+	}
+	if !row.IsPeriodic || row.DeviceID != "arduino_001" || row.RoomName != "PlayRoom_A" || row.MeasureTime != "2024-10-27T10:10:00Z" {
+		t.Errorf("unexpected row: %+v", row)
+	}
+
+	// * Windows of devices that stopped sending are written by flush *
+	a.flush(time.Date(2024, 10, 27, 10, 12, 0, 0, time.UTC))
+	if len(repo.created) != 2 || repo.created[1].DeviceID != "arduino_002" || repo.created[1].SoundLevel != 50 {
+		t.Errorf("expected the window of arduino_002 to be written, got %d rows", len(repo.created))
+	}
+}
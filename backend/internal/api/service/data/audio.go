@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/audio"
+	"goapi/internal/api/repository/models"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultFullScaleDB is the level a full scale sine wave represents in uploaded recordings,
+	// typical of MEMS microphones that read 94 dB at -26 dBFS
+	DefaultFullScaleDB = 120.0
+
+	// MaxAudioUploadBytes is the largest WAV file accepted, about three minutes of 48 kHz 16 bit stereo
+	MaxAudioUploadBytes = 32 << 20
+)
+
+// AudioUpload is a WAV recording to store as a reading of a device
+type AudioUpload struct {
+	DeviceID    string
+	RoomName    string // The chosen location if empty
+	MeasureTime string // Start of the recording, now if empty
+	FileName    string
+	FullScaleDB float64 // 0 uses the configured level
+	Body        io.Reader
+}
+
+// FullScaleFromEnv reads the level of a full scale sine wave from AUDIO_FULL_SCALE_DB (dB SPL, default 120)
+func FullScaleFromEnv() (float64, error) {
+	raw := os.Getenv("AUDIO_FULL_SCALE_DB")
+	if raw == "" {
+		return DefaultFullScaleDB, nil
+	}
+	fullScale, err := strconv.ParseFloat(raw, 64)
+	if err != nil || validateFullScale(fullScale) != nil {
+		return 0, fmt.Errorf("invalid AUDIO_FULL_SCALE_DB %q", raw)
+	}
+	return fullScale, nil
+}
+
+func validateFullScale(fullScale float64) error {
+	if fullScale < 60 || fullScale > 160 {
+		return DataError{Message: "The full scale level must be between 60 and 160 dB."}
+	}
+	return nil
+}
+
+// AudioAnalysisService analyzes uploaded recordings and stores them as periodic readings
+type AudioAnalysisService struct {
+	ds          DataService
+	repo        models.AudioRepository
+	fullScaleDB float64
+}
+
+func NewAudioAnalysisService(ds DataService, repo models.AudioRepository, fullScaleDB float64) *AudioAnalysisService {
+	return &AudioAnalysisService{
+		ds:          ds,
+		repo:        repo,
+		fullScaleDB: fullScaleDB,
+	}
+}
+
+// AnalyzeUpload decodes and analyzes a WAV recording, stores its LAeq as a reading of the device
+// (its LAMax and LAMin as max_level and min_level) and the whole analysis with the reading's ID.
+// The reading is deleted again if the analysis can't be stored.
+func (s *AudioAnalysisService) AnalyzeUpload(upload *AudioUpload, ctx context.Context) (*models.Data, *models.AudioAnalysis, error) {
+	fullScale := s.fullScaleDB
+	if upload.FullScaleDB != 0 {
+		if err := validateFullScale(upload.FullScaleDB); err != nil {
+			return nil, nil, err
+		}
+		fullScale = upload.FullScaleDB
+	}
+
+	clip, err := audio.Decode(upload.Body)
+	if err != nil {
+		return nil, nil, DataError{Message: "Invalid WAV file: " + err.Error()}
+	}
+	analysis, err := audio.Analyze(clip, fullScale)
+	if err != nil {
+		return nil, nil, DataError{Message: "Invalid WAV file: " + err.Error()}
+	}
+
+	data := &models.Data{
+		DeviceID:    upload.DeviceID,
+		RoomName:    upload.RoomName,
+		SoundLevel:  analysis.LAeq,
+		MeasureTime: upload.MeasureTime,
+		Description: fmt.Sprintf("WAV %s: %.1f s, LCeq %.1f dB, peak %.1f dB", upload.FileName, float64(analysis.DurationMs)/1000, analysis.LCeq, analysis.Peak),
+		IsPeriodic:  true,
+		ReceivedAt:  time.Now().UTC().Format(time.RFC3339),
+		MaxLevel:    analysis.LAMax,
+		MinLevel:    analysis.LAMin,
+		Bands:       readingBands(analysis.Bands),
+	}
+	// * The recording's time isn't a device clock, so it isn't checked like the readings' *
+	FillDefaults(data)
+	created, err := s.ds.CreateIdempotent(data, models.IdempotencyKey{}, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !created {
+		return nil, nil, DataError{Message: "A reading of the device at this measure_time is already stored."}
+	}
+
+	analysis.DataID = data.ID
+	analysis.FileName = upload.FileName
+	analysis.CreatedAt = data.ReceivedAt
+	if err := s.repo.Create(analysis, ctx); err != nil {
+		// * Don't keep a reading of a recording without its analysis, the upload can then be retried *
+		if _, deleteErr := s.ds.Delete(data, ctx); deleteErr != nil {
+			return nil, nil, fmt.Errorf("storing the analysis: %v, deleting its reading %d: %v", err, data.ID, deleteErr)
+		}
+		return nil, nil, err
+	}
+	return data, analysis, nil
+}
+
+// GetAnalysis returns the analysis of the recording stored as the data row, nil if there is none
+func (s *AudioAnalysisService) GetAnalysis(dataID int, ctx context.Context) (*models.AudioAnalysis, error) {
+	return s.repo.ReadByDataID(dataID, ctx)
+}
+
+// readingBands returns the levels of the analysis bands a reading carries,
+// nil if the recording's sample rate is too low to have them all
+func readingBands(bands []models.Band) []float64 {
+	var levels []float64
+	for _, frequency := range models.BandFrequencies {
+		for _, band := range bands {
+			if band.Frequency == frequency {
+				levels = append(levels, band.Level)
+			}
+		}
+	}
+	if len(levels) != len(models.BandFrequencies) {
+		return nil
+	}
+	return levels
+}
@@ -0,0 +1,43 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"os"
+	"testing"
+)
+
+// * failingAudioRepository can't store analyses *
+type failingAudioRepository struct {
+	models.AudioRepository
+}
+
+func (failingAudioRepository) Create(analysis *models.AudioAnalysis, ctx context.Context) error {
+	return errors.New("disk full")
+}
+
+func TestAnalyzeUploadDeletesReadingWithoutAnalysis(t *testing.T) {
+	f, err := os.Open("../../../../../build/alert.wav")
+	if err != nil {
+		t.Skip("build/alert.wav not found")
+	}
+	defer f.Close()
+
+	ds := NewMockDataServiceRecording()
+	s := NewAudioAnalysisService(ds, failingAudioRepository{}, DefaultFullScaleDB)
+	upload := &AudioUpload{DeviceID: "arduino_001", RoomName: "PlayRoom_A", FileName: "alert.wav", Body: f}
+	if _, _, err := s.AnalyzeUpload(upload, context.Background()); err == nil {
+		t.Fatal("expected the error of the analysis")
+	}
+
+	stored := <-ds.Stored
+	select {
+	case id := <-ds.Deleted:
+		if id != stored.ID {
+			t.Errorf("expected reading %d to be deleted, got %d", stored.ID, id)
+		}
+	default:
+		t.Error("expected the reading to be deleted")
+	}
+}
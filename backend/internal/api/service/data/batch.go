@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// BatchResult is the outcome of one reading of a batch
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     int    `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	BatchCreated  = "created"  // Stored as a periodic row, ID is set
+	BatchExisting = "existing" // Already stored with the same device and measure time, ID is the stored row
+	BatchLatest   = "latest"   // Accepted as the device's latest reading
+	BatchInvalid  = "invalid"  // Not stored, Error tells why
+	BatchFailed   = "failed"   // Not stored as it couldn't be processed, e.g. its calibration couldn't be read, Error tells why
+	BatchSkipped  = "skipped"  // Valid, but not stored because the all-or-nothing batch had invalid readings
+)
+
+// createBatch validates every reading and stores the valid ones.
+// All periodic rows are inserted in a single transaction. Of the non-periodic readings
+// only the last one per device is written, as only the device's latest reading is kept anyway.
+// A reading that is invalid or can't be processed is reported and the others go on.
+// If atomic is set, nothing is stored when any reading is invalid or failed.
+func createBatch(ds DataService, repo models.DataRepository, locationRepo models.LocationRepository, publishers []Publisher,
+	readings []*models.Data, atomic bool, ctx context.Context) ([]BatchResult, error) {
+
+	results := make([]BatchResult, len(readings))
+	var periodic []*models.Data
+	latest := make(map[string]*models.Data)
+	var latestOrder []string
+	hasInvalid := false
+
+	// * Batches are readings buffered while offline, so their age says nothing about the device's clock *
+	receivedAt := time.Now().UTC().Format(time.RFC3339)
+
+	for i, data := range readings {
+		results[i].Index = i
+		data.ReceivedAt = receivedAt
+		data.RawSoundLevel = 0 // Set from sound_level by Calibrate, batches don't come from relays
+
+		FillDefaults(data)
+		fillRoomName(locationRepo, data, ctx)
+		if err := ds.ResolveThreshold(data, ctx); err != nil {
+			results[i].Status = BatchFailed
+			results[i].Error = "Resolving the threshold: " + err.Error()
+			hasInvalid = true
+			continue
+		}
+		if err := ds.Calibrate(data, ctx); err != nil {
+			results[i].Status = BatchFailed
+			results[i].Error = "Calibrating: " + err.Error()
+			hasInvalid = true
+			continue
+		}
+		if err := ds.ValidateData(data); err != nil {
+			results[i].Status = BatchInvalid
+			results[i].Error = "Invalid data: " + err.Error()
+			hasInvalid = true
+			continue
+		}
+
+		if data.IsPeriodic {
+			periodic = append(periodic, data)
+			results[i].Status = BatchCreated
+		} else {
+			if _, ok := latest[data.DeviceID]; !ok {
+				latestOrder = append(latestOrder, data.DeviceID)
+			}
+			latest[data.DeviceID] = data
+			results[i].Status = BatchLatest
+		}
+	}
+
+	if hasInvalid && atomic {
+		for i := range results {
+			if results[i].Status != BatchInvalid && results[i].Status != BatchFailed {
+				results[i].Status = BatchSkipped
+			}
+		}
+		return results, nil
+	}
+
+	// * Alerts are decided for the readings to store in the order of the batch *
+	var stored []*models.Data
+	for i, data := range readings {
+		if results[i].Status != BatchInvalid && results[i].Status != BatchFailed {
+			stored = append(stored, data)
+		}
+	}
+	evaluated, err := ds.EvaluateAlerts(stored, ctx)
+	if err != nil {
+		return nil, err
+	}
+	decisions := make(map[*models.Data]*AlertDecision, len(stored))
+	for i, data := range stored {
+		decisions[data] = evaluated[i]
+	}
+
+	created := make(map[*models.Data]bool, len(periodic))
+	if len(periodic) > 0 {
+		createdRows, err := repo.CreateBatch(periodic, ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i, data := range periodic {
+			created[data] = createdRows[i]
+		}
+	}
+	for _, deviceID := range latestOrder {
+		if err := repo.CreateLatest(latest[deviceID], ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, data := range readings {
+		switch results[i].Status {
+		case BatchCreated:
+			results[i].ID = data.ID
+			if !created[data] {
+				results[i].Status = BatchExisting
+				continue
+			}
+			if err := ds.RecordAlerts(decisions[data], ctx); err != nil {
+				return nil, err
+			}
+			publish(publishers, data)
+		case BatchLatest:
+			if err := ds.RecordAlerts(decisions[data], ctx); err != nil {
+				return nil, err
+			}
+			if latest[data.DeviceID] == data {
+				publish(publishers, data)
+			}
+		}
+	}
+
+	return results, nil
+}
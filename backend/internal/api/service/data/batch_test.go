@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"testing"
+)
+
+// * batchRepository keeps the rows written with CreateBatch *
+type batchRepository struct {
+	models.DataRepository
+	created []*models.Data
+}
+
+func (r *batchRepository) CreateBatch(data []*models.Data, ctx context.Context) ([]bool, error) {
+	r.created = append(r.created, data...)
+	created := make([]bool, len(data))
+	for i := range created {
+		created[i] = true
+	}
+	return created, nil
+}
+
+// * failingCalibrations can't read the calibrations of arduino_002 *
+type failingCalibrations struct {
+	CalibrationService
+}
+
+func (failingCalibrations) Calibrate(data *models.Data, ctx context.Context) error {
+	if data.DeviceID == "arduino_002" {
+		return errors.New("database is locked")
+	}
+	return nil
+}
+
+func TestBatchReportsReadingsThatFail(t *testing.T) {
+	readings := func() []*models.Data {
+		return []*models.Data{
+			{DeviceID: "arduino_001", RoomName: "Office", SoundLevel: 60, MeasureTime: "2024-06-03T09:00:00Z", IsPeriodic: true},
+			{DeviceID: "arduino_002", RoomName: "Office", SoundLevel: 60, MeasureTime: "2024-06-03T09:00:00Z", IsPeriodic: true},
+			{DeviceID: "arduino_001", RoomName: "Office", SoundLevel: 65, MeasureTime: "2024-06-03T09:01:00Z", IsPeriodic: true},
+		}
+	}
+
+	repo := &batchRepository{}
+	ds := NewDataServiceSQLite(repo, nil, nil, failingCalibrations{}, nil, nil)
+	results, err := ds.CreateBatch(readings(), false, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != BatchCreated || results[1].Status != BatchFailed || results[1].Error == "" || results[2].Status != BatchCreated {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if len(repo.created) != 2 {
+		t.Errorf("expected the 2 other readings stored, got %d", len(repo.created))
+	}
+
+	// * An all-or-nothing batch stores nothing *
+	repo = &batchRepository{}
+	ds = NewDataServiceSQLite(repo, nil, nil, failingCalibrations{}, nil, nil)
+	results, err = ds.CreateBatch(readings(), true, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != BatchSkipped || results[1].Status != BatchFailed || len(repo.created) != 0 {
+		t.Errorf("unexpected results: %+v, stored %d", results, len(repo.created))
+	}
+}
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// MaxCalibrationPoints is the most points a calibration curve can have
+const MaxCalibrationPoints = 32
+
+// DeviceCalibrationService corrects the levels devices measure with their calibrations
+type DeviceCalibrationService struct {
+	repo models.CalibrationRepository
+	data models.DataRepository // Where readings are recalibrated
+}
+
+func NewDeviceCalibrationService(repo models.CalibrationRepository, data models.DataRepository) *DeviceCalibrationService {
+	return &DeviceCalibrationService{
+		repo: repo,
+		data: data,
+	}
+}
+
+// Calibrate keeps the level a device measured as raw_sound_level and replaces sound_level
+// with the level calibrated by the device's calibration valid at measure_time, if it has one.
+// Only a reading forwarded by a relay (see WithRelayed) keeps its raw level, it was calibrated where it was first received.
+func (s *DeviceCalibrationService) Calibrate(data *models.Data, ctx context.Context) error {
+	if IsRelayed(ctx) && data.RawSoundLevel != 0 {
+		return nil
+	}
+	data.RawSoundLevel = data.SoundLevel
+
+	deviceID := data.DeviceID
+	if deviceID == "" {
+		deviceID = DefaultDeviceID
+	}
+	calibrations, err := s.repo.ReadByDevice(deviceID, ctx)
+	if err != nil {
+		return err
+	}
+	if calibration := models.CalibrationAt(calibrations, data.MeasureTime); calibration != nil {
+		models.Recalibrate(data, calibration)
+	}
+	return nil
+}
+
+// CreateCalibration stores a calibration of a device. With recompute the levels of the device's
+// stored readings are recomputed from their raw levels, the number of changed readings is returned.
+func (s *DeviceCalibrationService) CreateCalibration(calibration *models.Calibration, recompute bool, ctx context.Context) (int, error) {
+	if err := validateCalibration(calibration); err != nil {
+		return 0, err
+	}
+	calibration.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := s.repo.Create(calibration, ctx); err != nil {
+		return 0, err
+	}
+	if !recompute {
+		return 0, nil
+	}
+	return s.recalibrate(calibration.DeviceID, ctx)
+}
+
+// GetCalibrations returns the calibrations of a device, of all devices if deviceID is empty
+func (s *DeviceCalibrationService) GetCalibrations(deviceID string, ctx context.Context) ([]*models.Calibration, error) {
+	if deviceID == "" {
+		return s.repo.ReadAll(ctx)
+	}
+	return s.repo.ReadByDevice(deviceID, ctx)
+}
+
+// DeleteCalibration removes a calibration of a device, false if the device has no such calibration.
+// With recompute the device's stored readings are recomputed like in CreateCalibration.
+func (s *DeviceCalibrationService) DeleteCalibration(deviceID string, id int, recompute bool, ctx context.Context) (bool, int, error) {
+	calibration, err := s.repo.ReadOne(id, ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	if calibration == nil || calibration.DeviceID != deviceID {
+		return false, 0, nil
+	}
+	if _, err := s.repo.Delete(id, ctx); err != nil {
+		return false, 0, err
+	}
+	if !recompute {
+		return true, 0, nil
+	}
+	changed, err := s.recalibrate(deviceID, ctx)
+	return true, changed, err
+}
+
+// recalibrate recomputes the levels of the device's readings with its current calibrations
+func (s *DeviceCalibrationService) recalibrate(deviceID string, ctx context.Context) (int, error) {
+	calibrations, err := s.repo.ReadByDevice(deviceID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	return s.data.Recalibrate(deviceID, calibrations, ctx)
+}
+
+// validateCalibration checks a calibration makes sense and fills in its defaults:
+// a gain of 1 and valid from now on
+func validateCalibration(c *models.Calibration) error {
+	if c.DeviceID == "" {
+		return DataError{Message: "Device ID is required"}
+	}
+	if c.Gain == 0 {
+		c.Gain = 1
+	}
+	if c.Gain < 0.5 || c.Gain > 2 {
+		return DataError{Message: "The gain must be between 0.5 and 2."}
+	}
+	if c.Offset < -30 || c.Offset > 30 {
+		return DataError{Message: "The offset must be between -30 and 30 dB."}
+	}
+
+	if len(c.Curve) > MaxCalibrationPoints {
+		return DataError{Message: "A calibration curve can have at most 32 points."}
+	}
+	for i, p := range c.Curve {
+		if p.Raw < 0 || p.Raw > 150 || p.Calibrated < 0 || p.Calibrated > 150 {
+			return DataError{Message: "The levels of the calibration curve must be between 0 and 150 dB."}
+		}
+		if i > 0 && p.Raw <= c.Curve[i-1].Raw {
+			return DataError{Message: "The points of the calibration curve must be ordered by increasing raw level."}
+		}
+	}
+
+	if c.ValidFrom == "" {
+		c.ValidFrom = time.Now().UTC().Format(time.RFC3339)
+		return nil
+	}
+	validFrom, err := time.Parse(time.RFC3339, c.ValidFrom)
+	if err != nil {
+		return DataError{Message: "Invalid valid_from format. Use RFC3339 format (e.g., 2025-11-07T00:00:00Z)"}
+	}
+	// * Stored in UTC like measure_time *
+	c.ValidFrom = validFrom.UTC().Format(time.RFC3339)
+	return nil
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"testing"
+)
+
+// * memoryCalibrations keeps the calibrations of one device in order *
+type memoryCalibrations struct {
+	models.CalibrationRepository
+	calibrations []*models.Calibration
+}
+
+func (m *memoryCalibrations) ReadByDevice(deviceID string, ctx context.Context) ([]*models.Calibration, error) {
+	var calibrations []*models.Calibration
+	for _, c := range m.calibrations {
+		if c.DeviceID == deviceID {
+			calibrations = append(calibrations, c)
+		}
+	}
+	return calibrations, nil
+}
+
+func TestCalibrationApply(t *testing.T) {
+	curve := []models.CalibrationPoint{{Raw: 40, Calibrated: 45}, {Raw: 60, Calibrated: 61}, {Raw: 90, Calibrated: 88}}
+	for _, tc := range []struct {
+		calibration models.Calibration
+		raw, want   float64
+	}{
+		{models.Calibration{Gain: 1, Offset: -2.5}, 60, 57.5},
+		{models.Calibration{Gain: 1.1, Offset: -6}, 60, 60},
+		{models.Calibration{Gain: 1, Curve: curve}, 50, 53},
+		{models.Calibration{Gain: 1, Curve: curve}, 30, 37},  // The first segment extended
+		{models.Calibration{Gain: 1, Curve: curve}, 100, 97}, // The last segment extended
+		{models.Calibration{Gain: 1, Offset: 1, Curve: curve[:1]}, 70, 76},
+	} {
+		if got := tc.calibration.Apply(tc.raw); got != tc.want {
+			t.Errorf("%+v of %v: got %v, want %v", tc.calibration, tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestCalibrateUsesCalibrationValidAtMeasureTime(t *testing.T) {
+	s := NewDeviceCalibrationService(&memoryCalibrations{calibrations: []*models.Calibration{
+		{DeviceID: "arduino_001", Gain: 1, Offset: -2, ValidFrom: "2024-06-01T00:00:00Z"},
+		{DeviceID: "arduino_001", Gain: 1, Offset: 3, ValidFrom: "2024-07-01T00:00:00Z"},
+	}}, nil)
+	ctx := context.Background()
+
+	before := &models.Data{DeviceID: "arduino_001", SoundLevel: 70, Threshold: 70, IsAlert: true, MeasureTime: "2024-05-31T23:59:59Z"}
+	june := &models.Data{DeviceID: "arduino_001", SoundLevel: 70, Threshold: 70, IsAlert: true, MeasureTime: "2024-06-15T10:00:00Z",
+		MaxLevel: 80, Bands: []float64{60, 60, 60, 60, 60, 60, 60, 60}}
+	july := &models.Data{DeviceID: "arduino_001", SoundLevel: 68, Threshold: 70, MeasureTime: "2024-07-01T00:00:00Z"}
+	for _, d := range []*models.Data{before, june, july} {
+		if err := s.Calibrate(d, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if before.SoundLevel != 70 || before.RawSoundLevel != 70 || !before.IsAlert {
+		t.Errorf("readings before the first calibration keep their level: %+v", before)
+	}
+	if june.SoundLevel != 68 || june.RawSoundLevel != 70 || june.IsAlert || june.MaxLevel != 78 || june.Bands[0] != 58 {
+		t.Errorf("unexpected June reading: %+v", june)
+	}
+	if july.SoundLevel != 71 || july.RawSoundLevel != 68 || !july.IsAlert {
+		t.Errorf("unexpected July reading: %+v", july)
+	}
+
+	// * A forwarded reading was calibrated already *
+	forwarded := &models.Data{DeviceID: "arduino_001", SoundLevel: 68, RawSoundLevel: 70, MeasureTime: "2024-06-15T10:00:00Z"}
+	if err := s.Calibrate(forwarded, WithRelayed(ctx)); err != nil || forwarded.SoundLevel != 68 || forwarded.RawSoundLevel != 70 {
+		t.Errorf("forwarded reading calibrated again: %+v", forwarded)
+	}
+
+	// * A device can't skip its calibration by sending a raw level *
+	claimed := &models.Data{DeviceID: "arduino_001", SoundLevel: 70, RawSoundLevel: 40, MeasureTime: "2024-06-15T10:00:00Z"}
+	if err := s.Calibrate(claimed, ctx); err != nil || claimed.SoundLevel != 68 || claimed.RawSoundLevel != 70 {
+		t.Errorf("unexpected reading with a raw level of its own: %+v", claimed)
+	}
+}
+
+func TestValidateCalibration(t *testing.T) {
+	c := &models.Calibration{DeviceID: "arduino_001", ValidFrom: "2024-06-01T02:00:00+02:00"}
+	if err := validateCalibration(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Gain != 1 || c.ValidFrom != "2024-06-01T00:00:00Z" {
+		t.Errorf("unexpected defaults: %+v", c)
+	}
+
+	for _, c := range []*models.Calibration{
+		{},
+		{DeviceID: "arduino_001", Gain: 3},
+		{DeviceID: "arduino_001", Offset: -40},
+		{DeviceID: "arduino_001", Curve: []models.CalibrationPoint{{Raw: 60, Calibrated: 60}, {Raw: 50, Calibrated: 52}}},
+		{DeviceID: "arduino_001", Curve: []models.CalibrationPoint{{Raw: 60, Calibrated: 160}}},
+		{DeviceID: "arduino_001", ValidFrom: "June"},
+	} {
+		if err := validateCalibration(c); err == nil {
+			t.Errorf("%+v should be rejected", c)
+		}
+	}
+}
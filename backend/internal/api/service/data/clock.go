@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"os"
+	"time"
+)
+
+// What to do with a reading whose measure_time is further than MaxSkew from the time it was received
+const (
+	ClockOff     = "off"     // Only record the skew
+	ClockCorrect = "correct" // Replace measure_time by received_at
+	ClockReject  = "reject"  // Refuse the reading, the device should sync with GET /api/time
+
+	DefaultMaxClockSkew = 5 * time.Minute
+)
+
+// ClockPolicy is how readings from devices with a skewed clock are handled
+type ClockPolicy struct {
+	Mode    string
+	MaxSkew time.Duration
+}
+
+// ClockPolicyFromEnv reads the policy from CLOCK_SKEW_POLICY (off, correct or reject, default off)
+// and the bound from CLOCK_SKEW_MAX (a duration such as 90s, default 5m)
+func ClockPolicyFromEnv() (ClockPolicy, error) {
+	policy := ClockPolicy{Mode: os.Getenv("CLOCK_SKEW_POLICY"), MaxSkew: DefaultMaxClockSkew}
+	switch policy.Mode {
+	case "":
+		policy.Mode = ClockOff
+	case ClockOff, ClockCorrect, ClockReject:
+	default:
+		return policy, fmt.Errorf("invalid CLOCK_SKEW_POLICY %q, expected off, correct or reject", policy.Mode)
+	}
+
+	if v := os.Getenv("CLOCK_SKEW_MAX"); v != "" {
+		maxSkew, err := time.ParseDuration(v)
+		if err != nil || maxSkew <= 0 {
+			return policy, fmt.Errorf("invalid CLOCK_SKEW_MAX %q", v)
+		}
+		policy.MaxSkew = maxSkew
+	}
+	return policy, nil
+}
+
+type DeviceClockService struct {
+	repo   models.DeviceClockRepository
+	policy ClockPolicy
+}
+
+func NewDeviceClockService(repo models.DeviceClockRepository, policy ClockPolicy) *DeviceClockService {
+	return &DeviceClockService{
+		repo:   repo,
+		policy: policy,
+	}
+}
+
+// CheckClock stamps a reading that just arrived with received_at.
+// When the device sent a measure_time, the skew between both is recorded and the policy applied.
+// A reading that already has received_at was forwarded by a relay, which checked it on arrival,
+// Ingest clears it on readings from anywhere else.
+func (s *DeviceClockService) CheckClock(data *models.Data, ctx context.Context) error {
+	if data.ReceivedAt != "" {
+		return nil
+	}
+	receivedAt := time.Now().UTC()
+	data.ReceivedAt = receivedAt.Format(time.RFC3339)
+
+	if data.MeasureTime == "" {
+		return nil
+	}
+	measured, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if err != nil {
+		// * ValidateData reports it *
+		return nil
+	}
+
+	// * The skew includes the network delay, which is negligible at the bounds that make sense *
+	skew := receivedAt.Sub(measured)
+	outcome := models.ClockAccepted
+	if s.policy.Mode != ClockOff && (skew > s.policy.MaxSkew || skew < -s.policy.MaxSkew) {
+		switch s.policy.Mode {
+		case ClockCorrect:
+			data.MeasureTime = data.ReceivedAt
+			outcome = models.ClockCorrected
+		case ClockReject:
+			outcome = models.ClockRejected
+		}
+	}
+
+	deviceID := data.DeviceID
+	if deviceID == "" {
+		deviceID = DefaultDeviceID
+	}
+	if err := s.repo.RecordSkew(deviceID, skew.Milliseconds(), outcome, data.ReceivedAt, ctx); err != nil {
+		return err
+	}
+
+	if outcome == models.ClockRejected {
+		return DataError{Message: fmt.Sprintf("measure_time is %s off the server time, sync the clock with GET /api/time.", skew.Round(time.Second))}
+	}
+	return nil
+}
+
+func (s *DeviceClockService) GetDeviceClock(deviceID string, ctx context.Context) (*models.DeviceClock, error) {
+	return s.repo.GetDeviceClock(deviceID, ctx)
+}
+
+func (s *DeviceClockService) GetAllDeviceClocks(ctx context.Context) ([]*models.DeviceClock, error) {
+	return s.repo.GetAllDeviceClocks(ctx)
+}
@@ -8,7 +8,14 @@ import (
 
 type DataService interface {
 	Create(data *models.Data, ctx context.Context) error
+	CreateIdempotent(data *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error)
 	CreateLatest(data *models.Data, ctx context.Context) error
+	CreateBatch(data []*models.Data, atomic bool, ctx context.Context) ([]BatchResult, error)
+	CheckClock(data *models.Data, ctx context.Context) error
+	Calibrate(data *models.Data, ctx context.Context) error
+	ResolveThreshold(data *models.Data, ctx context.Context) error
+	EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error)
+	RecordAlerts(decision *AlertDecision, ctx context.Context) error
 	ReadOne(id int, ctx context.Context) (*models.Data, error)
 	ReadLatest(id string, ctx context.Context) (*models.Data, error)
 	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error)
@@ -16,7 +23,14 @@ type DataService interface {
 	Delete(data *models.Data, ctx context.Context) (int64, error)
 	ValidateData(data *models.Data) error
 	GetDailySummary(roomName string, date time.Time, ctx context.Context) ([]*models.Data, error)
+	GetDailyStats(roomName string, date time.Time, ctx context.Context) (*DailyStats, error)
 	GetByRoom(roomName string, ctx context.Context) ([]*models.Data, error)
+	GetByRoomRollups(roomName string, ctx context.Context) ([]*models.Data, error)
+	GetDailySummaryRollups(roomName string, date time.Time, ctx context.Context) ([]*models.Data, error)
+	GetAnomalies(roomName string, from, to time.Time, minScore float64, ctx context.Context) ([]*models.Data, error)
+	GetBaselines(roomName string, ctx context.Context) ([]*models.Baseline, error)
+	ComparePeriods(roomName string, from, to, compareFrom, compareTo time.Time, ctx context.Context) (*PeriodComparison, error)
+	GetSpectrum(roomName string, from, to time.Time, bucket string, ctx context.Context) (*RoomSpectrum, error)
 	CleanOldData(ctx context.Context) error
 }
 
@@ -24,11 +38,106 @@ type LocationService interface {
 	CreateLocation(location *models.Location) error
 	GetAllLocations() ([]*models.Location, error)
 	GetChosenLocation() (*models.Location, error)
+	FillDose(locations ...*models.Location)
 	SetChosenLocation(id int) error
 	UpdateThreshold(id int, newThreshold float64) error
 	DeleteLocation(location *models.Location, ctx context.Context) (int64, error)
 }
 
+type ClockService interface {
+	CheckClock(data *models.Data, ctx context.Context) error
+	GetDeviceClock(deviceID string, ctx context.Context) (*models.DeviceClock, error)
+	GetAllDeviceClocks(ctx context.Context) ([]*models.DeviceClock, error)
+}
+
+type CalibrationService interface {
+	Calibrate(data *models.Data, ctx context.Context) error
+	// CreateCalibration and DeleteCalibration return how many stored readings changed when recompute is set
+	CreateCalibration(calibration *models.Calibration, recompute bool, ctx context.Context) (int, error)
+	GetCalibrations(deviceID string, ctx context.Context) ([]*models.Calibration, error)
+	DeleteCalibration(deviceID string, id int, recompute bool, ctx context.Context) (bool, int, error)
+}
+
+type RuleService interface {
+	EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error)
+	RecordAlerts(decision *AlertDecision, ctx context.Context) error
+	CreateRule(rule *models.AlertRule, ctx context.Context) error
+	GetRules(roomName string, ctx context.Context) ([]*models.AlertRule, error)
+	GetRule(id int, ctx context.Context) (*models.AlertRule, error)
+	UpdateRule(rule *models.AlertRule, ctx context.Context) (bool, error)
+	DeleteRule(id int, ctx context.Context) (bool, error)
+	GetFirings(ruleID int, roomName string, from, to time.Time, ctx context.Context) ([]*models.RuleFiring, error)
+}
+
+type ScheduleService interface {
+	ResolveThreshold(data *models.Data, ctx context.Context) error
+	EffectiveThreshold(location *models.Location, at time.Time, ctx context.Context) (float64, error)
+	// GetSchedule returns nil if the location has no schedule
+	GetSchedule(locationID int64, ctx context.Context) (*models.ThresholdSchedule, error)
+	// SetSchedule returns false if there is no such location
+	SetSchedule(schedule *models.ThresholdSchedule, ctx context.Context) (bool, error)
+	DeleteSchedule(locationID int64, ctx context.Context) (bool, error)
+	// AddException returns false if the location has no schedule
+	AddException(locationID int64, exception *models.ScheduleException, ctx context.Context) (bool, error)
+	DeleteException(locationID int64, id int, ctx context.Context) (bool, error)
+}
+
+type IncidentService interface {
+	GetIncidents(state, roomName string, limit int, ctx context.Context) ([]*models.Incident, error)
+	GetIncident(id int, ctx context.Context) (*models.Incident, error)
+	// Acknowledge and Resolve return nil if there is no such incident
+	Acknowledge(id int, by, note string, ctx context.Context) (*models.Incident, error)
+	Resolve(id int, by, note string, ctx context.Context) (*models.Incident, error)
+}
+
+type WebhookService interface {
+	// CreateWebhook generates the secret of a webhook without one, the secret is only returned here
+	CreateWebhook(webhook *models.Webhook, ctx context.Context) error
+	GetWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	GetWebhook(id int, ctx context.Context) (*models.Webhook, error)
+	// UpdateWebhook keeps the secret of the webhook if none is set
+	UpdateWebhook(webhook *models.Webhook, ctx context.Context) (bool, error)
+	DeleteWebhook(id int, ctx context.Context) (bool, error)
+	// GetDeliveries returns nil if there is no such webhook
+	GetDeliveries(webhookID int, status string, limit int, ctx context.Context) ([]*models.WebhookDelivery, error)
+}
+
+type DigestService interface {
+	// GetDigest summarises the daily or weekly period before to of a room, every location if roomName is empty
+	GetDigest(period, roomName string, to time.Time, ctx context.Context) (*models.Digest, error)
+}
+
+type DoseService interface {
+	// GetDose computes the noise dose of a room for the day of date,
+	// the fields of cfg that are 0 (or cfg nil) use the configured criterion
+	GetDose(roomName string, date time.Time, cfg *DoseConfig, ctx context.Context) (*models.NoiseDose, error)
+}
+
+type AudioService interface {
+	AnalyzeUpload(upload *AudioUpload, ctx context.Context) (*models.Data, *models.AudioAnalysis, error)
+	GetAnalysis(dataID int, ctx context.Context) (*models.AudioAnalysis, error)
+}
+
+// Publisher is notified after a reading has been stored, e.g. the live stream hub
+type Publisher interface {
+	Publish(data *models.Data)
+}
+
+// ThresholdListener is notified after a location's threshold has changed, e.g. the connected devices
+type ThresholdListener interface {
+	ThresholdChanged(location *models.Location)
+}
+
+// IncidentListener is notified after an incident was opened, acknowledged or resolved, e.g. the webhooks
+type IncidentListener interface {
+	IncidentChanged(incident *models.Incident)
+}
+
+// DigestListener is sent the scheduled digests, e.g. the email notifier
+type DigestListener interface {
+	DigestReady(digest *models.Digest)
+}
+
 type DataError struct {
 	Message string
 }
@@ -36,3 +145,12 @@ type DataError struct {
 func (de DataError) Error() string {
 	return de.Message
 }
+
+// ConflictError is returned when a request contradicts an earlier one, e.g. a reused Idempotency-Key
+type ConflictError struct {
+	Message string
+}
+
+func (ce ConflictError) Error() string {
+	return ce.Message
+}
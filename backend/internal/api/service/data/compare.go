@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"math"
+	"time"
+)
+
+// MaxComparePeriod is the longest period that can be compared
+const MaxComparePeriod = 31 * 24 * time.Hour
+
+// LevelDelta is how much a statistic changed from the previous period to the current one
+type LevelDelta struct {
+	LAeq                  float64 `json:"laeq"` // 0 when either period has no readings
+	Alerts                int     `json:"alerts"`
+	MinutesAboveThreshold float64 `json:"minutes_above_threshold"`
+}
+
+// ComparedStats are the statistics of the same part of both periods
+type ComparedStats struct {
+	Current  LevelStats `json:"current"`
+	Previous LevelStats `json:"previous"`
+	Delta    LevelDelta `json:"delta"`
+}
+
+// DayComparison compares the nth day of both periods
+type DayComparison struct {
+	Day          int    `json:"day"` // 0 is the day of the period's start
+	CurrentDate  string `json:"current_date"`
+	PreviousDate string `json:"previous_date"`
+	ComparedStats
+}
+
+// HourComparison compares an hour of the day over both periods
+type HourComparison struct {
+	Hour int `json:"hour"` // 0-23 in the time zone of the period's start
+	ComparedStats
+}
+
+// PeriodComparison compares two periods of a room, or of all rooms if RoomName is empty,
+// as a whole, day by day and per hour of the day
+type PeriodComparison struct {
+	RoomName    string `json:"room_name"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	CompareFrom string `json:"compare_from"`
+	CompareTo   string `json:"compare_to"`
+	ComparedStats
+	Days  []DayComparison  `json:"days"`
+	Hours []HourComparison `json:"hours"`
+}
+
+// validatePeriod checks a period can be compared
+func validatePeriod(from, to time.Time) error {
+	if !from.Before(to) {
+		return DataError{Message: "The start of a period must be before its end."}
+	}
+	if to.Sub(from) > MaxComparePeriod {
+		return DataError{Message: "Periods can be at most 31 days long."}
+	}
+	return nil
+}
+
+// comparePeriods reads the readings of both periods and compares them
+func comparePeriods(repo models.DataRepository, roomName string, from, to, compareFrom, compareTo time.Time, ctx context.Context) (*PeriodComparison, error) {
+	if err := validatePeriod(from, to); err != nil {
+		return nil, err
+	}
+	if err := validatePeriod(compareFrom, compareTo); err != nil {
+		return nil, err
+	}
+
+	current, err := repo.GetByRange(roomName, from, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := repo.GetByRange(roomName, compareFrom, compareTo, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return computeComparison(current, previous, roomName, from, to, compareFrom, compareTo), nil
+}
+
+// computeComparison compares the readings of the current period with the ones of the previous period.
+// Days are matched by their position in the period, hours by the hour of the day.
+func computeComparison(current, previous []*models.Data, roomName string, from, to, compareFrom, compareTo time.Time) *PeriodComparison {
+	currentSamples := toSamples(current)
+	previousSamples := toSamples(previous)
+
+	comparison := &PeriodComparison{
+		RoomName:      roomName,
+		From:          from.Format(time.RFC3339),
+		To:            to.Format(time.RFC3339),
+		CompareFrom:   compareFrom.Format(time.RFC3339),
+		CompareTo:     compareTo.Format(time.RFC3339),
+		ComparedStats: compareStats(currentSamples, previousSamples),
+		Days:          []DayComparison{},
+		Hours:         []HourComparison{},
+	}
+
+	currentStart, previousStart := startOfDay(from), startOfDay(compareFrom)
+	currentDays := splitSamples(currentSamples, func(t time.Time) int { return dayIndex(currentStart, t) })
+	previousDays := splitSamples(previousSamples, func(t time.Time) int { return dayIndex(previousStart, t) })
+	days := max(dayIndex(currentStart, to.Add(-time.Nanosecond)), dayIndex(previousStart, compareTo.Add(-time.Nanosecond))) + 1
+	for day := 0; day < days; day++ {
+		comparison.Days = append(comparison.Days, DayComparison{
+			Day:           day,
+			CurrentDate:   currentStart.AddDate(0, 0, day).Format(time.DateOnly),
+			PreviousDate:  previousStart.AddDate(0, 0, day).Format(time.DateOnly),
+			ComparedStats: compareStats(currentDays[day], previousDays[day]),
+		})
+	}
+
+	currentHours := splitSamples(currentSamples, func(t time.Time) int { return t.In(from.Location()).Hour() })
+	previousHours := splitSamples(previousSamples, func(t time.Time) int { return t.In(compareFrom.Location()).Hour() })
+	for hour := 0; hour < 24; hour++ {
+		comparison.Hours = append(comparison.Hours, HourComparison{
+			Hour:          hour,
+			ComparedStats: compareStats(currentHours[hour], previousHours[hour]),
+		})
+	}
+
+	return comparison
+}
+
+// compareStats computes the statistics of both sets of samples and their difference
+func compareStats(current, previous []levelSample) ComparedStats {
+	compared := ComparedStats{
+		Current:  levelStats(current),
+		Previous: levelStats(previous),
+	}
+	compared.Delta = LevelDelta{
+		Alerts:                compared.Current.Alerts - compared.Previous.Alerts,
+		MinutesAboveThreshold: math.Round((compared.Current.MinutesAboveThreshold-compared.Previous.MinutesAboveThreshold)*10) / 10,
+	}
+	if compared.Current.Samples > 0 && compared.Previous.Samples > 0 {
+		compared.Delta.LAeq = roundLevel(compared.Current.LAeq - compared.Previous.LAeq)
+	}
+	return compared
+}
+
+// splitSamples groups the samples by key, keeping how long each one counts for
+func splitSamples(samples []levelSample, key func(time.Time) int) map[int][]levelSample {
+	groups := make(map[int][]levelSample)
+	for _, s := range samples {
+		k := key(s.time)
+		groups[k] = append(groups[k], s)
+	}
+	return groups
+}
+
+// startOfDay returns midnight of t's day in t's time zone
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// dayIndex returns how many calendar days t is after start, in start's time zone
+func dayIndex(start, t time.Time) int {
+	day := startOfDay(t.In(start.Location()))
+	// * Round, days around a daylight saving change aren't 24 hours long *
+	return int(math.Round(day.Sub(start).Hours() / 24))
+}
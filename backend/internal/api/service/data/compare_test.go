@@ -0,0 +1,70 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"testing"
+	"time"
+)
+
+func TestComparePeriodsDeltas(t *testing.T) {
+	// * This Monday and Tuesday against last week's: an hour at 60 dB each day against 63 dB with an alert *
+	from := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	compareFrom := from.AddDate(0, 0, -7)
+	var current, previous []*models.Data
+	for day := 0; day < 2; day++ {
+		current = append(current, steadyLevel("arduino_001", from.AddDate(0, 0, day).Add(9*time.Hour), 60, 60)...)
+		previous = append(previous, steadyLevel("arduino_001", compareFrom.AddDate(0, 0, day).Add(9*time.Hour), 60, 63)...)
+	}
+	previous[0].IsAlert = true
+	previous[0].SoundLevel = 75
+
+	c := computeComparison(current, previous, "PlayRoom_A", from, from.AddDate(0, 0, 2), compareFrom, compareFrom.AddDate(0, 0, 2))
+
+	if c.Current.Samples != 120 || c.Previous.Samples != 120 || c.Delta.Alerts != -1 || c.Delta.MinutesAboveThreshold != -1 {
+		t.Errorf("unexpected totals: %+v", c.ComparedStats)
+	}
+	if c.Delta.LAeq >= -3 {
+		t.Errorf("LAeq delta: got %v, want below -3 with the loud reading", c.Delta.LAeq)
+	}
+
+	if len(c.Days) != 2 || c.Days[1].CurrentDate != "2024-06-11" || c.Days[1].PreviousDate != "2024-06-04" {
+		t.Fatalf("unexpected days: %+v", c.Days)
+	}
+	if c.Days[1].Delta.LAeq != -3 || c.Days[1].Delta.Alerts != 0 {
+		t.Errorf("unexpected second day: %+v", c.Days[1])
+	}
+
+	if len(c.Hours) != 24 || c.Hours[9].Current.Samples != 120 || c.Hours[10].Current.Samples != 0 {
+		t.Errorf("unexpected hours: %+v", c.Hours[9])
+	}
+	if c.Hours[10].Delta.LAeq != 0 {
+		t.Errorf("hours without readings should have no LAeq delta: %+v", c.Hours[10])
+	}
+}
+
+func TestComparePeriodsDifferentLengths(t *testing.T) {
+	// * A week against the previous three days: days past the shorter period compare with nothing *
+	from := time.Date(2024, 6, 10, 0, 0, 0, 0, time.FixedZone("", 2*3600))
+	rows := steadyLevel("arduino_001", from.Add(6*24*time.Hour+time.Hour), 10, 60)
+
+	c := computeComparison(rows, nil, "", from, from.AddDate(0, 0, 7), from.AddDate(0, 0, -3), from)
+	if len(c.Days) != 7 || c.Days[6].Current.Samples != 10 || c.Days[6].Previous.Samples != 0 {
+		t.Errorf("unexpected days: %+v", c.Days)
+	}
+	if c.Hours[1].Current.Samples != 10 {
+		t.Errorf("hours should be in the time zone of from: %+v", c.Hours[1])
+	}
+}
+
+func TestComparePeriodsValidation(t *testing.T) {
+	from := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	if err := validatePeriod(from, from); err == nil {
+		t.Error("expected an error for an empty period")
+	}
+	if err := validatePeriod(from, from.AddDate(0, 0, 32)); err == nil {
+		t.Error("expected an error for a period longer than 31 days")
+	}
+	if err := validatePeriod(from, from.AddDate(0, 0, 31)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a cron expression: minute, hour, day of month, month and day of week.
+// A field is *, a value, a range a-b, either with a step /n, or a comma separated list of them.
+// Days of week are 0-7, 0 and 7 are Sunday. As in cron, when both days are restricted
+// a time matches if either does.
+type CronSchedule struct {
+	expr                 string
+	minutes, hours, days []bool
+	months, weekdays     []bool
+	anyDay, anyWeekday   bool
+}
+
+// cronFields are the bounds of the fields of an expression
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron reads a cron expression, e.g. "0 7 * * 1-5" is 7:00 on weekdays
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q, expected 5 fields: minute hour day month weekday", expr)
+	}
+	sets := make([][]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q in cron expression %q", cronFields[i].name, field, expr)
+		}
+		sets[i] = set
+	}
+	// * 7 is also Sunday *
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &CronSchedule{
+		expr:       expr,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4][:7],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField returns which values from 0 to max a field matches
+func parseCronField(field string, min, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		first, last := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if first, err = strconv.Atoi(a); err != nil {
+				return nil, err
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(b); err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				// * a/n is from a to the last value *
+				last = max
+			}
+		}
+		if first < min || last > max || first > last {
+			return nil, fmt.Errorf("%d-%d is out of range %d-%d", first, last, min, max)
+		}
+		for v := first; v <= last; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+// Next returns the first time after t, to the minute, the schedule matches in t's time zone
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// * Every expression matches within a few years, February 29th included *
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.months[t.Month()] || !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[t.Weekday()]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
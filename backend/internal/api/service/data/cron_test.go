@@ -0,0 +1,45 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	for _, tc := range []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"0 7 * * *", "2024-06-03T06:59:30+03:00", "2024-06-03T07:00:00+03:00"},
+		{"0 7 * * *", "2024-06-03T07:00:00+03:00", "2024-06-04T07:00:00+03:00"},
+		{"0 7 * * 1", "2024-06-03T08:00:00+03:00", "2024-06-10T07:00:00+03:00"},
+		{"30 16 * * 1-5", "2024-06-07T17:00:00+03:00", "2024-06-10T16:30:00+03:00"},
+		{"*/15 8-9 * * *", "2024-06-03T09:50:00+03:00", "2024-06-04T08:00:00+03:00"},
+		{"0 0 1 * *", "2024-06-03T00:00:00+03:00", "2024-07-01T00:00:00+03:00"},
+		{"0 12 29 2 *", "2024-03-01T00:00:00+02:00", "2028-02-29T12:00:00+02:00"},
+		{"0 7 * * 7", "2024-06-03T08:00:00+03:00", "2024-06-09T07:00:00+03:00"},
+		// * Either day matches when both are restricted *
+		{"0 7 15 * 0", "2024-06-03T08:00:00+03:00", "2024-06-09T07:00:00+03:00"},
+		// * Clocks skip from 3:00 to 4:00 on the last Sunday of March, that 3:30 never happens *
+		{"30 3 * * *", "2024-03-30T04:00:00+02:00", "2024-04-01T03:30:00+03:00"},
+	} {
+		schedule, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, _ := time.Parse(time.RFC3339, tc.from)
+		if got := schedule.Next(from.In(helsinki)).Format(time.RFC3339); got != tc.want {
+			t.Errorf("%q after %s: got %s want %s", tc.expr, tc.from, got, tc.want)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "0 7 * *", "60 7 * * *", "0 24 * * *", "0 7 0 * *", "0 7 * 13 *", "0 7 * * 8", "0 7-5 * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q should be rejected", expr)
+		}
+	}
+}
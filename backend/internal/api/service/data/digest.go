@@ -0,0 +1,307 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDailyDigest  = "0 7 * * *" // Every day at 7:00
+	DefaultWeeklyDigest = "0 7 * * 1" // Mondays at 7:00
+	// LoudestPeriods is how many of the loudest hours of a location a digest lists
+	LoudestPeriods = 3
+	// trendLevel is how much the LAeq must change for a location to be louder or quieter
+	trendLevel = 1.0
+)
+
+// DigestConfig is when the digests are sent, a nil schedule is never
+type DigestConfig struct {
+	Daily    *CronSchedule
+	Weekly   *CronSchedule
+	Location *time.Location // Of the schedules and the loudest periods
+}
+
+// DigestConfigFromEnv reads DIGEST_DAILY (default "0 7 * * *"), DIGEST_WEEKLY (default "0 7 * * 1")
+// and DIGEST_TIMEZONE (default UTC). A schedule set to "off" is never sent.
+func DigestConfigFromEnv() (DigestConfig, error) {
+	cfg := DigestConfig{Location: time.UTC}
+	if v := os.Getenv("DIGEST_TIMEZONE"); v != "" {
+		tz, err := time.LoadLocation(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid DIGEST_TIMEZONE %q, expected an IANA name such as Europe/Helsinki", v)
+		}
+		cfg.Location = tz
+	}
+	for _, env := range []struct {
+		name     string
+		fallback string
+		schedule **CronSchedule
+	}{
+		{"DIGEST_DAILY", DefaultDailyDigest, &cfg.Daily},
+		{"DIGEST_WEEKLY", DefaultWeeklyDigest, &cfg.Weekly},
+	} {
+		v := os.Getenv(env.name)
+		if v == "" {
+			v = env.fallback
+		}
+		if v == "off" {
+			continue
+		}
+		schedule, err := ParseCron(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %v", env.name, err)
+		}
+		*env.schedule = schedule
+	}
+	return cfg, nil
+}
+
+// Digester summarises the alerts and levels of every location over a day or a week
+// and sends the digests to its listeners on the configured schedules, e.g. the email notifier
+type Digester struct {
+	repo      models.DataRepository
+	locations models.LocationRepository
+	cfg       DigestConfig
+	logger    *log.Logger
+	listeners []DigestListener
+}
+
+func NewDigester(repo models.DataRepository, locations models.LocationRepository, cfg DigestConfig, logger *log.Logger, listeners ...DigestListener) *Digester {
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &Digester{
+		repo:      repo,
+		locations: locations,
+		cfg:       cfg,
+		logger:    logger,
+		listeners: listeners,
+	}
+}
+
+// GetDigest summarises the period before to of a location, every location if roomName is empty
+func (d *Digester) GetDigest(period, roomName string, to time.Time, ctx context.Context) (*models.Digest, error) {
+	length, err := digestLength(period)
+	if err != nil {
+		return nil, err
+	}
+	from := to.Add(-length)
+
+	var rooms []string
+	if roomName != "" {
+		rooms = []string{roomName}
+	} else {
+		locations, err := d.locations.GetAllLocations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, location := range locations {
+			rooms = append(rooms, location.Name)
+		}
+	}
+
+	current, err := d.repo.GetByRange(roomName, from, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := d.repo.GetByRange(roomName, from.Add(-length), from, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return computeDigest(period, rooms, current, previous, from, to, d.cfg.Location), nil
+}
+
+// digestLength returns how long a digest period is
+func digestLength(period string) (time.Duration, error) {
+	switch period {
+	case models.DigestDaily:
+		return 24 * time.Hour, nil
+	case models.DigestWeekly:
+		return 7 * 24 * time.Hour, nil
+	default:
+		return 0, DataError{Message: "The period must be daily or weekly."}
+	}
+}
+
+// computeDigest summarises the readings of the rooms from <= measure_time < to against the previous ones,
+// the loudest periods are hours in tz
+func computeDigest(period string, rooms []string, current, previous []*models.Data, from, to time.Time, tz *time.Location) *models.Digest {
+	digest := &models.Digest{
+		Period:    period,
+		From:      from.UTC().Format(time.RFC3339),
+		To:        to.UTC().Format(time.RFC3339),
+		Timezone:  tz.String(),
+		Locations: []models.LocationDigest{},
+	}
+	currentRooms, previousRooms := byRoom(current), byRoom(previous)
+
+	for _, room := range rooms {
+		currentSamples := toSamples(currentRooms[room])
+		previousSamples := toSamples(previousRooms[room])
+		location := models.LocationDigest{
+			RoomName:       room,
+			Current:        digestStats(levelStats(currentSamples)),
+			Previous:       digestStats(levelStats(previousSamples)),
+			LoudestPeriods: loudestPeriods(currentSamples, tz),
+		}
+		location.Change = models.DigestStats{
+			Samples:               location.Current.Samples - location.Previous.Samples,
+			Alerts:                location.Current.Alerts - location.Previous.Alerts,
+			MinutesAboveThreshold: math.Round((location.Current.MinutesAboveThreshold-location.Previous.MinutesAboveThreshold)*10) / 10,
+		}
+		if location.Current.Samples > 0 && location.Previous.Samples > 0 {
+			location.Change.LAeq = roundLevel(location.Current.LAeq - location.Previous.LAeq)
+			location.Change.LMax = roundLevel(location.Current.LMax - location.Previous.LMax)
+			switch {
+			case location.Change.LAeq >= trendLevel:
+				location.Trend = models.TrendLouder
+			case location.Change.LAeq <= -trendLevel:
+				location.Trend = models.TrendQuieter
+			default:
+				location.Trend = models.TrendUnchanged
+			}
+		}
+		digest.Locations = append(digest.Locations, location)
+	}
+	return digest
+}
+
+// byRoom groups readings by their room
+func byRoom(rows []*models.Data) map[string][]*models.Data {
+	rooms := make(map[string][]*models.Data)
+	for _, row := range rows {
+		rooms[row.RoomName] = append(rooms[row.RoomName], row)
+	}
+	return rooms
+}
+
+func digestStats(stats LevelStats) models.DigestStats {
+	return models.DigestStats{
+		Samples:               stats.Samples,
+		LAeq:                  stats.LAeq,
+		LMax:                  stats.LMax,
+		Alerts:                stats.Alerts,
+		MinutesAboveThreshold: stats.MinutesAboveThreshold,
+	}
+}
+
+// loudestPeriods returns the hours of the samples with the highest LAeq, loudest first
+func loudestPeriods(samples []levelSample, tz *time.Location) []models.LoudPeriod {
+	hours := make(map[int64][]levelSample)
+	for _, s := range samples {
+		t := s.time.In(tz)
+		start := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, tz)
+		hours[start.Unix()] = append(hours[start.Unix()], s)
+	}
+
+	periods := []models.LoudPeriod{}
+	for start, hour := range hours {
+		stats := levelStats(hour)
+		t := time.Unix(start, 0).In(tz)
+		periods = append(periods, models.LoudPeriod{
+			Start:                 t.Format(time.RFC3339),
+			End:                   t.Add(time.Hour).Format(time.RFC3339),
+			LAeq:                  stats.LAeq,
+			LMax:                  stats.LMax,
+			Alerts:                stats.Alerts,
+			MinutesAboveThreshold: stats.MinutesAboveThreshold,
+		})
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		if periods[i].LAeq != periods[j].LAeq {
+			return periods[i].LAeq > periods[j].LAeq
+		}
+		return periods[i].Start < periods[j].Start
+	})
+	if len(periods) > LoudestPeriods {
+		periods = periods[:LoudestPeriods]
+	}
+	return periods
+}
+
+// Run sends the digests to the listeners on their schedules until ctx is cancelled.
+// A digest whose time passed while the server was down is not sent.
+func (d *Digester) Run(ctx context.Context) {
+	var schedules []string
+	for period, schedule := range map[string]*CronSchedule{models.DigestDaily: d.cfg.Daily, models.DigestWeekly: d.cfg.Weekly} {
+		if schedule != nil {
+			schedules = append(schedules, fmt.Sprintf("%s at %q", period, schedule))
+		}
+	}
+	if len(schedules) == 0 || len(d.listeners) == 0 {
+		return
+	}
+	sort.Strings(schedules)
+	d.logger.Printf("Sending %s digests (%s)", strings.Join(schedules, " and "), d.cfg.Location)
+
+	for {
+		now := time.Now().In(d.cfg.Location)
+		periods, next := d.next(now)
+		if next.IsZero() {
+			d.logger.Println("The digest schedules never match, no digests will be sent")
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		for _, period := range periods {
+			d.send(ctx, period, next)
+		}
+	}
+}
+
+// next returns when the next digests are sent after now and their periods, weekly first
+func (d *Digester) next(now time.Time) ([]string, time.Time) {
+	var periods []string
+	var next time.Time
+	for _, s := range []struct {
+		period   string
+		schedule *CronSchedule
+	}{
+		{models.DigestWeekly, d.cfg.Weekly},
+		{models.DigestDaily, d.cfg.Daily},
+	} {
+		if s.schedule == nil {
+			continue
+		}
+		t := s.schedule.Next(now)
+		switch {
+		case t.IsZero():
+		case next.IsZero() || t.Before(next):
+			periods, next = []string{s.period}, t
+		case t.Equal(next):
+			periods = append(periods, s.period)
+		}
+	}
+	return periods, next
+}
+
+// send computes the digest of the period before at and gives it to the listeners
+func (d *Digester) send(ctx context.Context, period string, at time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	digest, err := d.GetDigest(period, "", at, ctx)
+	if err != nil {
+		d.logger.Printf("Error computing the %s digest: %v", period, err)
+		return
+	}
+	if len(digest.Locations) == 0 {
+		return
+	}
+	for _, l := range d.listeners {
+		l.DigestReady(digest)
+	}
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"testing"
+	"time"
+)
+
+// hourOfReadings returns the readings of a device every 10 minutes of the hour starting at start
+func hourOfReadings(room, start string, level float64) []*models.Data {
+	t, _ := time.Parse(time.RFC3339, start)
+	var rows []*models.Data
+	for i := 0; i < 6; i++ {
+		rows = append(rows, &models.Data{DeviceID: "d1", RoomName: room, SoundLevel: level, Threshold: 70, IsAlert: level >= 70,
+			MeasureTime: t.Add(time.Duration(i) * 10 * time.Minute).Format(time.RFC3339), IsPeriodic: true})
+	}
+	return rows
+}
+
+func TestComputeDigest(t *testing.T) {
+	var current, previous []*models.Data
+	current = append(current, hourOfReadings("Office", "2024-06-02T10:00:00Z", 75)...)
+	current = append(current, hourOfReadings("Office", "2024-06-02T12:00:00Z", 55)...)
+	current = append(current, hourOfReadings("Office", "2024-06-02T14:00:00Z", 65)...)
+	current = append(current, hourOfReadings("Office", "2024-06-02T15:00:00Z", 60)...)
+	previous = append(previous, hourOfReadings("Office", "2024-06-01T10:00:00Z", 60)...)
+
+	helsinki, _ := time.LoadLocation("Europe/Helsinki")
+	from, _ := time.Parse(time.RFC3339, "2024-06-02T07:00:00Z")
+	digest := computeDigest(models.DigestDaily, []string{"Office", "Hall"}, current, previous, from, from.Add(24*time.Hour), helsinki)
+
+	if digest.From != "2024-06-02T07:00:00Z" || digest.To != "2024-06-03T07:00:00Z" || digest.Timezone != "Europe/Helsinki" || len(digest.Locations) != 2 {
+		t.Fatalf("unexpected digest: %+v", digest)
+	}
+	office, hall := digest.Locations[0], digest.Locations[1]
+	if office.Current.Alerts != 6 || office.Current.MinutesAboveThreshold != 60 || office.Current.LMax != 75 || office.Previous.Alerts != 0 {
+		t.Errorf("unexpected stats: %+v", office)
+	}
+	if office.Change.Alerts != 6 || office.Change.MinutesAboveThreshold != 60 || office.Change.LAeq <= 0 || office.Trend != models.TrendLouder {
+		t.Errorf("unexpected change: %+v %s", office.Change, office.Trend)
+	}
+
+	// * The loudest hours, in the digest's time zone *
+	var loudest []string
+	for _, p := range office.LoudestPeriods {
+		loudest = append(loudest, fmt.Sprintf("%s %.0f", p.Start, p.LAeq))
+	}
+	want := []string{"2024-06-02T13:00:00+03:00 75", "2024-06-02T17:00:00+03:00 65", "2024-06-02T18:00:00+03:00 60"}
+	if fmt.Sprint(loudest) != fmt.Sprint(want) {
+		t.Errorf("loudest periods: got %v want %v", loudest, want)
+	}
+
+	if hall.Current.Samples != 0 || hall.Trend != "" || len(hall.LoudestPeriods) != 0 {
+		t.Errorf("a location without readings should have an empty digest: %+v", hall)
+	}
+}
+
+func TestDigestSchedules(t *testing.T) {
+	daily, _ := ParseCron(DefaultDailyDigest)
+	weekly, _ := ParseCron(DefaultWeeklyDigest)
+	d := NewDigester(nil, nil, DigestConfig{Daily: daily, Weekly: weekly}, nil)
+
+	// * Sunday only has the daily digest, Monday both *
+	now, _ := time.Parse(time.RFC3339, "2024-06-01T08:00:00Z")
+	periods, next := d.next(now)
+	if fmt.Sprint(periods) != "[daily]" || next.Format(time.RFC3339) != "2024-06-02T07:00:00Z" {
+		t.Errorf("got %v at %s", periods, next)
+	}
+	periods, next = d.next(next)
+	if fmt.Sprint(periods) != "[weekly daily]" || next.Format(time.RFC3339) != "2024-06-03T07:00:00Z" {
+		t.Errorf("got %v at %s", periods, next)
+	}
+}
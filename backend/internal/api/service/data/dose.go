@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default dose criterion, the NIOSH recommendation
+const (
+	DefaultCriterionLevel = 85.0
+	DefaultExchangeRate   = 3.0
+	DefaultCriterionHours = 8.0
+)
+
+// DoseConfig is the criterion noise doses are computed against:
+// CriterionLevel dB for CriterionHours is 100%, and every ExchangeRate dB more halves the allowed time
+type DoseConfig struct {
+	CriterionLevel float64
+	ExchangeRate   float64
+	CriterionHours float64
+}
+
+// Validate reports whether the criterion makes sense
+func (c DoseConfig) Validate() error {
+	if c.CriterionLevel < 40 || c.CriterionLevel > 140 {
+		return DataError{Message: "The criterion level must be between 40 and 140 dB."}
+	}
+	if c.ExchangeRate < 1 || c.ExchangeRate > 10 {
+		return DataError{Message: "The exchange rate must be between 1 and 10 dB."}
+	}
+	if c.CriterionHours <= 0 || c.CriterionHours > 24 {
+		return DataError{Message: "The criterion duration must be between 0 and 24 hours."}
+	}
+	return nil
+}
+
+// withOverrides returns the criterion with the non zero fields of override replacing its own
+func (c DoseConfig) withOverrides(override *DoseConfig) (DoseConfig, error) {
+	if override == nil {
+		return c, nil
+	}
+	if override.CriterionLevel != 0 {
+		c.CriterionLevel = override.CriterionLevel
+	}
+	if override.ExchangeRate != 0 {
+		c.ExchangeRate = override.ExchangeRate
+	}
+	if override.CriterionHours != 0 {
+		c.CriterionHours = override.CriterionHours
+	}
+	return c, c.Validate()
+}
+
+// DoseConfigFromEnv reads the criterion from DOSE_CRITERION_LEVEL (dB, default 85),
+// DOSE_EXCHANGE_RATE (dB, default 3) and DOSE_CRITERION_HOURS (default 8)
+func DoseConfigFromEnv() (DoseConfig, error) {
+	cfg := DoseConfig{
+		CriterionLevel: DefaultCriterionLevel,
+		ExchangeRate:   DefaultExchangeRate,
+		CriterionHours: DefaultCriterionHours,
+	}
+	for _, v := range []struct {
+		name  string
+		value *float64
+	}{
+		{"DOSE_CRITERION_LEVEL", &cfg.CriterionLevel},
+		{"DOSE_EXCHANGE_RATE", &cfg.ExchangeRate},
+		{"DOSE_CRITERION_HOURS", &cfg.CriterionHours},
+	} {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s %q", v.name, raw)
+		}
+		*v.value = f
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// NoiseDoseService computes noise doses from the stored periodic readings
+type NoiseDoseService struct {
+	repo   models.DataRepository
+	config DoseConfig
+}
+
+func NewNoiseDoseService(repo models.DataRepository, config DoseConfig) *NoiseDoseService {
+	return &NoiseDoseService{
+		repo:   repo,
+		config: config,
+	}
+}
+
+func (s *NoiseDoseService) GetDose(roomName string, date time.Time, cfg *DoseConfig, ctx context.Context) (*models.NoiseDose, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
+	}
+	config, err := s.config.withOverrides(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.GetDailySummary(roomName, date, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return computeDose(rows, roomName, date, config), nil
+}
+
+// computeDose returns the dose of the room's device with the highest dose, the exposure of the
+// people in the room is at least that. Each reading counts for the time since the device's previous one.
+func computeDose(rows []*models.Data, roomName string, date time.Time, cfg DoseConfig) *models.NoiseDose {
+	dose := &models.NoiseDose{
+		RoomName:       roomName,
+		Date:           date.Format(time.DateOnly),
+		CriterionLevel: cfg.CriterionLevel,
+		ExchangeRate:   cfg.ExchangeRate,
+		CriterionHours: cfg.CriterionHours,
+	}
+
+	fractions := make(map[string]float64)
+	exposures := make(map[string]time.Duration)
+	for _, s := range toSamples(rows) {
+		// * Time allowed at this level: halved for every exchange rate above the criterion *
+		allowed := cfg.CriterionHours * math.Pow(2, (cfg.CriterionLevel-s.level)/cfg.ExchangeRate)
+		fractions[s.deviceID] += s.duration.Hours() / allowed
+		exposures[s.deviceID] += s.duration
+	}
+
+	var highest float64
+	for deviceID, fraction := range fractions {
+		if dose.DeviceID != "" && (fraction < highest || fraction == highest && deviceID > dose.DeviceID) {
+			continue
+		}
+		highest = fraction
+		dose.DeviceID = deviceID
+		dose.ExposureMinutes = math.Round(exposures[deviceID].Minutes()*10) / 10
+	}
+	if dose.DeviceID == "" {
+		return dose
+	}
+
+	dose.DosePercent = math.Round(highest*1000) / 10
+	if highest > 0 {
+		// * The level that gives the same dose when heard for the whole criterion duration *
+		dose.TWA = roundLevel(cfg.CriterionLevel + cfg.ExchangeRate*math.Log2(highest))
+	}
+
+	projected := highest
+	if hours := exposures[dose.DeviceID].Hours(); hours > 0 && hours < cfg.CriterionHours {
+		projected = highest * cfg.CriterionHours / hours
+	}
+	dose.ProjectedDosePercent = math.Round(projected*1000) / 10
+	return dose
+}
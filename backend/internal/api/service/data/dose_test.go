@@ -0,0 +1,77 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"testing"
+	"time"
+)
+
+// steadyLevel returns one reading a minute at level for the given minutes
+func steadyLevel(deviceID string, start time.Time, minutes int, level float64) []*models.Data {
+	var rows []*models.Data
+	for i := 0; i < minutes; i++ {
+		rows = append(rows, reading(deviceID, start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339), level, false))
+	}
+	return rows
+}
+
+var niosh = DoseConfig{CriterionLevel: 85, ExchangeRate: 3, CriterionHours: 8}
+
+func TestDoseAtCriterion(t *testing.T) {
+	// * Eight hours at the criterion level is exactly the daily dose *
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows := steadyLevel("arduino_001", date.Add(8*time.Hour), 480, 85)
+
+	dose := computeDose(rows, "PlayRoom_A", date, niosh)
+	if dose.DosePercent != 100 || dose.TWA != 85 || dose.ProjectedDosePercent != 100 {
+		t.Errorf("unexpected dose: %+v", dose)
+	}
+	if dose.ExposureMinutes != 480 || dose.Date != "2024-06-01" || dose.DeviceID != "arduino_001" {
+		t.Errorf("unexpected dose: %+v", dose)
+	}
+}
+
+func TestDoseExchangeRateAndProjection(t *testing.T) {
+	// * 3 dB louder halves the allowed time: four hours at 88 dB is the full dose, on pace for twice that *
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows := steadyLevel("arduino_001", date.Add(8*time.Hour), 240, 88)
+
+	dose := computeDose(rows, "PlayRoom_A", date, niosh)
+	if dose.DosePercent != 100 || dose.TWA != 85 || dose.ProjectedDosePercent != 200 {
+		t.Errorf("unexpected dose: %+v", dose)
+	}
+
+	// * With the OSHA 90 dB / 5 dB criterion the same exposure is much less *
+	dose = computeDose(rows, "PlayRoom_A", date, DoseConfig{CriterionLevel: 90, ExchangeRate: 5, CriterionHours: 8})
+	if dose.DosePercent != 37.9 || dose.ProjectedDosePercent != 75.8 {
+		t.Errorf("unexpected OSHA dose: %+v", dose)
+	}
+}
+
+func TestDoseLoudestDevice(t *testing.T) {
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows := append(steadyLevel("arduino_001", date.Add(8*time.Hour), 60, 70),
+		steadyLevel("arduino_002", date.Add(8*time.Hour), 60, 85)...)
+
+	dose := computeDose(rows, "PlayRoom_A", date, niosh)
+	if dose.DeviceID != "arduino_002" || dose.DosePercent != 12.5 || dose.ProjectedDosePercent != 100 {
+		t.Errorf("unexpected dose: %+v", dose)
+	}
+}
+
+func TestDoseNoReadings(t *testing.T) {
+	dose := computeDose(nil, "PlayRoom_A", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), niosh)
+	if dose.DeviceID != "" || dose.DosePercent != 0 || dose.TWA != 0 || dose.CriterionLevel != 85 {
+		t.Errorf("unexpected dose: %+v", dose)
+	}
+}
+
+func TestDoseConfigOverrides(t *testing.T) {
+	cfg, err := niosh.withOverrides(&DoseConfig{ExchangeRate: 5})
+	if err != nil || cfg.CriterionLevel != 85 || cfg.ExchangeRate != 5 || cfg.CriterionHours != 8 {
+		t.Errorf("unexpected config: %+v %v", cfg, err)
+	}
+	if _, err := niosh.withOverrides(&DoseConfig{CriterionLevel: 200}); err == nil {
+		t.Error("expected an error for a 200 dB criterion")
+	}
+}
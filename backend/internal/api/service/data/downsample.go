@@ -0,0 +1,167 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// MaxSeriesPoints is the largest points=N a chart can ask for
+const MaxSeriesPoints = 10000
+
+// Series is a compact chart series of one device, the points are in parallel arrays
+type Series struct {
+	DeviceID string    `json:"device_id"`
+	Total    int       `json:"total"`  // Points before downsampling
+	Times    []int64   `json:"t"`      // Unix seconds
+	Levels   []float64 `json:"v"`      // Sound levels in dB
+	Alerts   []int     `json:"alerts"` // Indexes of the points that are alerts
+}
+
+// seriesPoint is a reading placed on the chart
+type seriesPoint struct {
+	time  time.Time
+	level float64
+	alert bool
+}
+
+// DownsampleSeries turns readings into one series per device with at most points points each.
+// The shape is kept with Largest-Triangle-Three-Buckets and alerts are kept first,
+// so a device with more alerts than points gets only alerts, themselves downsampled.
+func DownsampleSeries(rows []*models.Data, points int) []Series {
+	byDevice := make(map[string][]seriesPoint)
+	var devices []string
+	for _, row := range rows {
+		t, err := time.Parse(time.RFC3339, row.MeasureTime)
+		if err != nil {
+			continue
+		}
+		if _, ok := byDevice[row.DeviceID]; !ok {
+			devices = append(devices, row.DeviceID)
+		}
+		byDevice[row.DeviceID] = append(byDevice[row.DeviceID], seriesPoint{time: t, level: row.SoundLevel, alert: row.IsAlert})
+	}
+	sort.Strings(devices)
+
+	series := make([]Series, 0, len(devices))
+	for _, device := range devices {
+		all := byDevice[device]
+		sort.SliceStable(all, func(i, j int) bool { return all[i].time.Before(all[j].time) })
+
+		s := Series{DeviceID: device, Total: len(all), Times: []int64{}, Levels: []float64{}, Alerts: []int{}}
+		for _, i := range downsample(all, points) {
+			if all[i].alert {
+				s.Alerts = append(s.Alerts, len(s.Times))
+			}
+			s.Times = append(s.Times, all[i].time.Unix())
+			s.Levels = append(s.Levels, all[i].level)
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// downsample returns the indexes of at most points points to keep, in order:
+// the alerts plus the points LTTB picks with what is left of the budget
+func downsample(all []seriesPoint, points int) []int {
+	if points >= len(all) {
+		keep := make([]int, len(all))
+		for i := range keep {
+			keep[i] = i
+		}
+		return keep
+	}
+
+	var alerts []int
+	var alertPoints []seriesPoint
+	for i, p := range all {
+		if p.alert {
+			alerts = append(alerts, i)
+			alertPoints = append(alertPoints, p)
+		}
+	}
+	if len(alerts) >= points {
+		// * The alerts alone are too many, they are thinned keeping their shape *
+		keep := make([]int, 0, points)
+		for _, i := range lttb(alertPoints, points) {
+			keep = append(keep, alerts[i])
+		}
+		return keep
+	}
+
+	keep := make(map[int]bool, points)
+	for _, i := range alerts {
+		keep[i] = true
+	}
+	budget := points - len(alerts)
+	var shape []int
+	switch {
+	case budget >= 3:
+		shape = lttb(all, budget)
+	case budget == 2:
+		shape = []int{0, len(all) - 1}
+	default:
+		shape = []int{0}
+	}
+	for _, i := range shape {
+		keep[i] = true
+	}
+
+	indexes := make([]int, 0, len(keep))
+	for i := range keep {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// lttb picks n points with Largest-Triangle-Three-Buckets: the first and last point are kept
+// and of each bucket in between the point forming the largest triangle with the point kept
+// before it and the average of the next bucket, which keeps peaks and dips visible
+func lttb(all []seriesPoint, n int) []int {
+	if n >= len(all) || n < 3 {
+		keep := make([]int, len(all))
+		for i := range keep {
+			keep[i] = i
+		}
+		return keep
+	}
+
+	x := func(i int) float64 { return float64(all[i].time.Unix()) }
+	y := func(i int) float64 { return all[i].level }
+
+	keep := make([]int, 0, n)
+	keep = append(keep, 0)
+	bucketSize := float64(len(all)-2) / float64(n-2)
+	a := 0
+	for b := 0; b < n-2; b++ {
+		start := int(float64(b)*bucketSize) + 1
+		end := int(float64(b+1)*bucketSize) + 1
+
+		// * Average of the next bucket, the last point for the last bucket *
+		nextStart := end
+		nextEnd := min(int(float64(b+2)*bucketSize)+1, len(all))
+		if b == n-3 {
+			nextStart, nextEnd = len(all)-1, len(all)
+		}
+		var avgX, avgY float64
+		for i := nextStart; i < nextEnd; i++ {
+			avgX += x(i)
+			avgY += y(i)
+		}
+		avgX /= float64(nextEnd - nextStart)
+		avgY /= float64(nextEnd - nextStart)
+
+		best, bestArea := start, -1.0
+		for i := start; i < end; i++ {
+			area := math.Abs((x(a)-avgX)*(y(i)-y(a)) - (x(a)-x(i))*(avgY-y(a)))
+			if area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+		keep = append(keep, best)
+		a = best
+	}
+	return append(keep, len(all)-1)
+}
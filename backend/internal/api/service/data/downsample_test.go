@@ -0,0 +1,105 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDownsampleKeepsAlertsAndShape(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var rows []*models.Data
+	for i := 0; i < 1000; i++ {
+		level := 50 + 5*math.Sin(float64(i)/50)
+		alert := false
+		switch i {
+		case 123, 124, 125, 777:
+			// * Alert peaks *
+			level, alert = 85, true
+		case 500:
+			// * A spike that isn't an alert *
+			level = 95
+		}
+		rows = append(rows, &models.Data{
+			DeviceID:    "arduino_001",
+			SoundLevel:  level,
+			MeasureTime: start.Add(time.Duration(i) * 10 * time.Minute).Format(time.RFC3339),
+			IsAlert:     alert,
+		})
+	}
+
+	series := DownsampleSeries(rows, 50)
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+	s := series[0]
+
+	if s.Total != 1000 || len(s.Times) > 50 || len(s.Times) != len(s.Levels) {
+		t.Fatalf("unexpected size: total %d, %d times, %d levels", s.Total, len(s.Times), len(s.Levels))
+	}
+	if s.Times[0] != start.Unix() || s.Times[len(s.Times)-1] != start.Add(999*10*time.Minute).Unix() {
+		t.Errorf("first and last point must be kept")
+	}
+	if len(s.Alerts) != 4 {
+		t.Fatalf("expected the 4 alerts to be kept, got %v", s.Alerts)
+	}
+	for _, i := range s.Alerts {
+		if s.Levels[i] != 85 {
+			t.Errorf("alert index %d points to level %v", i, s.Levels[i])
+		}
+	}
+	spike := false
+	for i := 1; i < len(s.Times); i++ {
+		if s.Times[i] <= s.Times[i-1] {
+			t.Fatalf("points out of order at %d", i)
+		}
+		if s.Levels[i] == 95 {
+			spike = true
+		}
+	}
+	if !spike {
+		t.Errorf("the spike should survive downsampling")
+	}
+}
+
+func TestDownsampleSmallSeriesUnchanged(t *testing.T) {
+	rows := []*models.Data{
+		{DeviceID: "b", SoundLevel: 60, MeasureTime: "2024-06-01T09:10:00Z"},
+		{DeviceID: "a", SoundLevel: 61, MeasureTime: "2024-06-01T09:00:00Z"},
+		{DeviceID: "b", SoundLevel: 62, MeasureTime: "2024-06-01T09:00:00Z", IsAlert: true},
+	}
+
+	series := DownsampleSeries(rows, 10)
+	if len(series) != 2 || series[0].DeviceID != "a" || series[1].DeviceID != "b" {
+		t.Fatalf("unexpected series: %+v", series)
+	}
+	if b := series[1]; len(b.Levels) != 2 || b.Levels[0] != 62 || len(b.Alerts) != 1 || b.Alerts[0] != 0 {
+		t.Errorf("unexpected series b: %+v", b)
+	}
+}
+
+func TestDownsampleNeverExceedsPoints(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, alertEvery := range []int{1, 2, 3, 50} {
+		var rows []*models.Data
+		for i := 0; i < 100; i++ {
+			rows = append(rows, &models.Data{
+				DeviceID:    "arduino_001",
+				SoundLevel:  float64(50 + i%7),
+				MeasureTime: start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339),
+				IsAlert:     i%alertEvery == 0,
+			})
+		}
+		for _, points := range []int{3, 4, 5, 10, 60} {
+			s := DownsampleSeries(rows, points)[0]
+			if len(s.Times) > points || len(s.Times) != len(s.Levels) {
+				t.Errorf("alert every %d, points=%d: got %d points", alertEvery, points, len(s.Times))
+			}
+			// * The alerts come first *
+			if want := min(points, (100+alertEvery-1)/alertEvery); len(s.Alerts) != want {
+				t.Errorf("alert every %d, points=%d: got %d alerts, want %d", alertEvery, points, len(s.Alerts), want)
+			}
+		}
+	}
+}
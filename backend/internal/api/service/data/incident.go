@@ -0,0 +1,260 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQuietPeriod is how long a room is without alerts before its incident resolves itself
+	DefaultQuietPeriod = 15 * time.Minute
+
+	// MaxIncidents is the most incidents returned at once
+	MaxIncidents = 500
+)
+
+// QuietPeriodFromEnv reads the quiet period from INCIDENT_QUIET_PERIOD (a duration such as 30m, default 15m)
+func QuietPeriodFromEnv() (time.Duration, error) {
+	v := os.Getenv("INCIDENT_QUIET_PERIOD")
+	if v == "" {
+		return DefaultQuietPeriod, nil
+	}
+	quiet, err := time.ParseDuration(v)
+	if err != nil || quiet < time.Minute {
+		return 0, fmt.Errorf("invalid INCIDENT_QUIET_PERIOD %q, expected a duration of at least 1m", v)
+	}
+	return quiet, nil
+}
+
+// AlertIncidentService groups the alerting readings of each room into incidents.
+// It is a Publisher: an alerting reading opens an incident for its room or is added to the room's
+// open or acknowledged one. Incidents resolve themselves after the quiet period without alerts.
+type AlertIncidentService struct {
+	repo      models.IncidentRepository
+	quiet     time.Duration
+	logger    *log.Logger
+	listeners []IncidentListener
+
+	mu sync.Mutex // Serializes the changes of incidents, a room has one unresolved incident at a time
+}
+
+// The listeners are notified of the incidents that are opened, acknowledged or resolved
+func NewAlertIncidentService(repo models.IncidentRepository, quiet time.Duration, logger *log.Logger, listeners ...IncidentListener) *AlertIncidentService {
+	return &AlertIncidentService{
+		repo:      repo,
+		quiet:     quiet,
+		logger:    logger,
+		listeners: listeners,
+	}
+}
+
+// Publish adds a stored alerting reading to its room's incident
+func (s *AlertIncidentService) Publish(data *models.Data) {
+	if !data.IsAlert {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.AddAlert(data, ctx); err != nil {
+		s.logger.Printf("Error adding alert of %s to its incident: %v", data.RoomName, err)
+	}
+}
+
+// AddAlert adds an alerting reading to the unresolved incident of its room, or opens one.
+// An incident that has been quiet for the quiet period before the reading is resolved first,
+// the reading then opens a new one.
+func (s *AlertIncidentService) AddAlert(data *models.Data, ctx context.Context) error {
+	measured, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident, err := s.repo.ReadUnresolved(data.RoomName, ctx)
+	if err != nil {
+		return err
+	}
+	if incident != nil {
+		lastAlert, err := time.Parse(time.RFC3339, incident.LastAlertAt)
+		if err == nil && measured.Sub(lastAlert) >= s.quiet {
+			if err := s.resolveQuiet(incident, lastAlert, ctx); err != nil {
+				return err
+			}
+			incident = nil
+		}
+	}
+
+	if incident == nil {
+		incident = &models.Incident{
+			RoomName:    data.RoomName,
+			State:       models.IncidentOpen,
+			AlertRuleID: data.AlertRuleID,
+			OpenedAt:    data.MeasureTime,
+			LastAlertAt: data.MeasureTime,
+			Alerts:      1,
+			PeakLevel:   data.SoundLevel,
+		}
+		if err := s.repo.Create(incident, ctx); err != nil {
+			return err
+		}
+		s.notify(incident)
+		return nil
+	}
+
+	incident.Alerts++
+	incident.PeakLevel = max(incident.PeakLevel, data.SoundLevel)
+	// * A late reading does not move the last alert back *
+	if data.MeasureTime > incident.LastAlertAt {
+		incident.LastAlertAt = data.MeasureTime
+	}
+	_, err = s.repo.Update(incident, ctx)
+	return err
+}
+
+// Run resolves the incidents that have been quiet for the quiet period, every minute until ctx is cancelled
+func (s *AlertIncidentService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.ResolveQuiet(time.Now(), ctx); err != nil {
+				s.logger.Println("Error resolving quiet incidents:", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ResolveQuiet resolves the incidents without alerts for the quiet period before now, and returns how many
+func (s *AlertIncidentService) ResolveQuiet(now time.Time, ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incidents, err := s.repo.ReadQuiet(now.Add(-s.quiet).UTC().Format(time.RFC3339), ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, incident := range incidents {
+		lastAlert, err := time.Parse(time.RFC3339, incident.LastAlertAt)
+		if err != nil {
+			lastAlert = now.Add(-s.quiet)
+		}
+		if err := s.resolveQuiet(incident, lastAlert, ctx); err != nil {
+			return 0, err
+		}
+	}
+	return len(incidents), nil
+}
+
+// resolveQuiet resolves an incident at the end of the quiet period after its last alert
+func (s *AlertIncidentService) resolveQuiet(incident *models.Incident, lastAlert time.Time, ctx context.Context) error {
+	incident.State = models.IncidentResolved
+	incident.ResolvedBy = models.IncidentSystem
+	incident.ResolvedAt = lastAlert.Add(s.quiet).UTC().Format(time.RFC3339)
+	incident.ResolveNote = fmt.Sprintf("No alerts for %s", s.quiet)
+	if _, err := s.repo.Update(incident, ctx); err != nil {
+		return err
+	}
+	s.notify(incident)
+	return nil
+}
+
+// notify tells the listeners about an incident that changed state
+func (s *AlertIncidentService) notify(incident *models.Incident) {
+	for _, l := range s.listeners {
+		l.IncidentChanged(incident)
+	}
+}
+
+// GetIncidents returns the newest incidents in a state and room, of every state or room if empty
+func (s *AlertIncidentService) GetIncidents(state, roomName string, limit int, ctx context.Context) ([]*models.Incident, error) {
+	switch state {
+	case "", models.IncidentOpen, models.IncidentAcknowledged, models.IncidentResolved:
+	default:
+		return nil, DataError{Message: "The state must be open, acknowledged or resolved."}
+	}
+	if limit <= 0 || limit > MaxIncidents {
+		return nil, DataError{Message: fmt.Sprintf("The limit must be between 1 and %d.", MaxIncidents)}
+	}
+	return s.repo.ReadMany(state, roomName, limit, ctx)
+}
+
+func (s *AlertIncidentService) GetIncident(id int, ctx context.Context) (*models.Incident, error) {
+	return s.repo.ReadOne(id, ctx)
+}
+
+// Acknowledge records who is responding to an open incident, nil if there is no such incident.
+// Acknowledging an acknowledged incident again replaces who and the note.
+func (s *AlertIncidentService) Acknowledge(id int, by, note string, ctx context.Context) (*models.Incident, error) {
+	if err := validateResponse(by, note); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident, err := s.repo.ReadOne(id, ctx)
+	if err != nil || incident == nil {
+		return nil, err
+	}
+	if incident.State == models.IncidentResolved {
+		return nil, DataError{Message: "The incident is resolved already."}
+	}
+	incident.State = models.IncidentAcknowledged
+	incident.AcknowledgedBy = by
+	incident.AcknowledgedAt = time.Now().UTC().Format(time.RFC3339)
+	incident.AcknowledgeNote = note
+	if _, err := s.repo.Update(incident, ctx); err != nil {
+		return nil, err
+	}
+	s.notify(incident)
+	return incident, nil
+}
+
+// Resolve closes an open or acknowledged incident, nil if there is no such incident.
+// The next alert of the room opens a new incident.
+func (s *AlertIncidentService) Resolve(id int, by, note string, ctx context.Context) (*models.Incident, error) {
+	if err := validateResponse(by, note); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident, err := s.repo.ReadOne(id, ctx)
+	if err != nil || incident == nil {
+		return nil, err
+	}
+	if incident.State == models.IncidentResolved {
+		return nil, DataError{Message: "The incident is resolved already."}
+	}
+	incident.State = models.IncidentResolved
+	incident.ResolvedBy = by
+	incident.ResolvedAt = time.Now().UTC().Format(time.RFC3339)
+	incident.ResolveNote = note
+	if _, err := s.repo.Update(incident, ctx); err != nil {
+		return nil, err
+	}
+	s.notify(incident)
+	return incident, nil
+}
+
+// validateResponse checks who responded to an incident and their note
+func validateResponse(by, note string) error {
+	if by == "" || len(by) > 100 {
+		return DataError{Message: "Who responded is required and must be less than 100 characters."}
+	}
+	if len(note) > 1000 {
+		return DataError{Message: "The note must be less than 1000 characters."}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"log"
+	"testing"
+	"time"
+)
+
+// * memoryIncidents keeps the incidents in memory *
+type memoryIncidents struct {
+	incidents []*models.Incident
+}
+
+func (m *memoryIncidents) Create(i *models.Incident, ctx context.Context) error {
+	m.incidents = append(m.incidents, i)
+	i.ID = len(m.incidents)
+	return nil
+}
+
+func (m *memoryIncidents) ReadOne(id int, ctx context.Context) (*models.Incident, error) {
+	if id < 1 || id > len(m.incidents) {
+		return nil, nil
+	}
+	i := *m.incidents[id-1]
+	return &i, nil
+}
+
+func (m *memoryIncidents) ReadUnresolved(roomName string, ctx context.Context) (*models.Incident, error) {
+	for id := len(m.incidents); id > 0; id-- {
+		if i := m.incidents[id-1]; i.RoomName == roomName && i.State != models.IncidentResolved {
+			return m.ReadOne(id, ctx)
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryIncidents) ReadMany(state, roomName string, limit int, ctx context.Context) ([]*models.Incident, error) {
+	return m.incidents, nil
+}
+
+func (m *memoryIncidents) ReadQuiet(lastAlertBefore string, ctx context.Context) ([]*models.Incident, error) {
+	var quiet []*models.Incident
+	for _, i := range m.incidents {
+		if i.State != models.IncidentResolved && i.LastAlertAt < lastAlertBefore {
+			c := *i
+			quiet = append(quiet, &c)
+		}
+	}
+	return quiet, nil
+}
+
+func (m *memoryIncidents) Update(i *models.Incident, ctx context.Context) (int64, error) {
+	c := *i
+	m.incidents[i.ID-1] = &c
+	return 1, nil
+}
+
+func alertAt(room, measureTime string, level float64) *models.Data {
+	return &models.Data{RoomName: room, SoundLevel: level, IsAlert: true, MeasureTime: measureTime}
+}
+
+func TestAlertsAreGroupedIntoIncidents(t *testing.T) {
+	repo := &memoryIncidents{}
+	s := NewAlertIncidentService(repo, 15*time.Minute, log.Default())
+
+	s.Publish(alertAt("Office", "2024-06-01T10:00:00Z", 82))
+	s.Publish(&models.Data{RoomName: "Office", SoundLevel: 60, MeasureTime: "2024-06-01T10:05:00Z"})
+	s.Publish(alertAt("Office", "2024-06-01T10:10:00Z", 88))
+	s.Publish(alertAt("Hall", "2024-06-01T10:10:00Z", 75))
+	// * Quiet for more than 15 minutes, the next alert opens a new incident *
+	s.Publish(alertAt("Office", "2024-06-01T10:30:00Z", 80))
+
+	if len(repo.incidents) != 3 {
+		t.Fatalf("got %d incidents, want 3", len(repo.incidents))
+	}
+	first := repo.incidents[0]
+	if first.Alerts != 2 || first.PeakLevel != 88 || first.LastAlertAt != "2024-06-01T10:10:00Z" {
+		t.Errorf("unexpected first incident: %+v", first)
+	}
+	if first.State != models.IncidentResolved || first.ResolvedBy != models.IncidentSystem || first.ResolvedAt != "2024-06-01T10:25:00Z" {
+		t.Errorf("first incident should be resolved after the quiet period: %+v", first)
+	}
+	if repo.incidents[1].RoomName != "Hall" || repo.incidents[2].State != models.IncidentOpen {
+		t.Errorf("unexpected incidents: %+v, %+v", repo.incidents[1], repo.incidents[2])
+	}
+}
+
+func TestIncidentLifecycle(t *testing.T) {
+	repo := &memoryIncidents{}
+	s := NewAlertIncidentService(repo, 15*time.Minute, log.Default())
+	ctx := context.Background()
+	s.Publish(alertAt("Office", "2024-06-01T10:00:00Z", 82))
+
+	incident, err := s.Acknowledge(1, "Ms. Smith", "On my way", ctx)
+	if err != nil || incident.State != models.IncidentAcknowledged || incident.AcknowledgedBy != "Ms. Smith" {
+		t.Fatalf("unexpected acknowledged incident: %+v, %v", incident, err)
+	}
+
+	// * An acknowledged incident still collects the alerts of its room *
+	s.Publish(alertAt("Office", "2024-06-01T10:01:00Z", 84))
+	if repo.incidents[0].Alerts != 2 || repo.incidents[0].State != models.IncidentAcknowledged {
+		t.Errorf("unexpected incident: %+v", repo.incidents[0])
+	}
+
+	if _, err := s.Resolve(1, "", "", ctx); err == nil {
+		t.Error("resolving without who should be rejected")
+	}
+	incident, err = s.Resolve(1, "Ms. Smith", "Window closed", ctx)
+	if err != nil || incident.State != models.IncidentResolved || incident.ResolveNote != "Window closed" {
+		t.Fatalf("unexpected resolved incident: %+v, %v", incident, err)
+	}
+	if _, err := s.Acknowledge(1, "Ms. Smith", "", ctx); err == nil {
+		t.Error("acknowledging a resolved incident should be rejected")
+	}
+	if incident, err := s.Resolve(2, "Ms. Smith", "", ctx); incident != nil || err != nil {
+		t.Errorf("unknown incident: %+v, %v", incident, err)
+	}
+}
+
+func TestResolveQuiet(t *testing.T) {
+	repo := &memoryIncidents{}
+	s := NewAlertIncidentService(repo, 15*time.Minute, log.Default())
+	s.Publish(alertAt("Office", "2024-06-01T10:00:00Z", 82))
+	s.Publish(alertAt("Hall", "2024-06-01T10:10:00Z", 75))
+
+	resolved, err := s.ResolveQuiet(time.Date(2024, 6, 1, 10, 20, 0, 0, time.UTC), context.Background())
+	if err != nil || resolved != 1 {
+		t.Fatalf("got %d resolved, %v", resolved, err)
+	}
+	if repo.incidents[0].State != models.IncidentResolved || repo.incidents[1].State != models.IncidentOpen {
+		t.Errorf("unexpected incidents: %+v, %+v", repo.incidents[0], repo.incidents[1])
+	}
+}
@@ -0,0 +1,149 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+const (
+	DefaultDeviceID  = "arduino_001"
+	DefaultThreshold = 70.0
+
+	// IdempotencyKeyTTL is how long an Idempotency-Key is remembered
+	IdempotencyKeyTTL = 24 * time.Hour
+)
+
+// relayedKey marks the context of a request authenticated as a relay
+type relayedKey struct{}
+
+// WithRelayed returns a context for a request authenticated as a relay, see Ingest
+func WithRelayed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, relayedKey{}, true)
+}
+
+// IsRelayed reports whether ctx is of a request authenticated as a relay
+func IsRelayed(ctx context.Context) bool {
+	relayed, _ := ctx.Value(relayedKey{}).(bool)
+	return relayed
+}
+
+// Ingest stores a reading received from a device, whatever the transport.
+// Missing fields are filled with defaults and the reading is routed by IsPeriodic:
+// periodic readings are stored in the data table (charts),
+// the rest replace the device's row in latest_data (current noise level card).
+// created is false when a periodic reading was already stored, under key
+// or with the same device and measure time; data then holds the stored row.
+// A key the device used before for a different reading is a ConflictError.
+// received_at and raw_sound_level are the server's to set, only a relay (see WithRelayed)
+// may send the time it received the reading and the level it measured before calibrating it.
+func Ingest(ds DataService, data *models.Data, key string, ctx context.Context) (created bool, err error) {
+	if !IsRelayed(ctx) {
+		data.ReceivedAt = ""
+		data.RawSoundLevel = 0
+	}
+
+	// The reading is hashed as it was sent, before the server fills anything in
+	idempotencyKey := models.IdempotencyKey{Key: key}
+	if key != "" {
+		idempotencyKey.Hash = readingHash(data)
+	}
+
+	// Stamp received_at and check the device's clock before measure_time is filled
+	if err := ds.CheckClock(data, ctx); err != nil {
+		return false, err
+	}
+	FillDefaults(data)
+
+	// Constantly sent data for current sound level card in UI
+	// Only has 1 row per device that gets updated
+	// So it doesn't need to be periodically cleared, and a retry just writes it again
+	if !data.IsPeriodic {
+		return true, ds.CreateLatest(data, ctx)
+	}
+
+	// Data sent periodically (every 10 minutes)
+	return ds.CreateIdempotent(data, idempotencyKey, ctx)
+}
+
+// readingHash returns the SHA-256 of a reading's JSON
+func readingHash(data *models.Data) string {
+	b, _ := json.Marshal(data)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolveThreshold sets the threshold of a reading whose device didn't send one from the schedules,
+// or without them from its location's threshold
+func resolveThreshold(schedules ScheduleService, locationRepo models.LocationRepository, data *models.Data, ctx context.Context) error {
+	if data.Threshold != 0 {
+		return nil
+	}
+	if schedules != nil {
+		return schedules.ResolveThreshold(data, ctx)
+	}
+	data.Threshold = roomThreshold(locationRepo, data.RoomName, ctx)
+	return nil
+}
+
+// evaluateAlerts decides which readings are alerts with the rules, or without them with their thresholds
+func evaluateAlerts(rules RuleService, readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	if rules == nil {
+		for _, data := range readings {
+			thresholdAlert(data)
+		}
+		return make([]*AlertDecision, len(readings)), nil
+	}
+	return rules.EvaluateAlerts(readings, ctx)
+}
+
+// recordAlerts records the firings of a stored reading, if there are rules
+func recordAlerts(rules RuleService, decision *AlertDecision, ctx context.Context) error {
+	if rules == nil {
+		return nil
+	}
+	return rules.RecordAlerts(decision, ctx)
+}
+
+// FillDefaults fills the fields a device may leave out, the threshold is filled by ResolveThreshold
+func FillDefaults(data *models.Data) {
+	// Fill timestamp if missing, and store all timestamps in UTC
+	// so the same instant is always the same string
+	if data.MeasureTime == "" && data.ReceivedAt != "" {
+		data.MeasureTime = data.ReceivedAt
+	} else if data.MeasureTime == "" {
+		data.MeasureTime = time.Now().UTC().Format(time.RFC3339)
+	} else if t, err := time.Parse(time.RFC3339, data.MeasureTime); err == nil {
+		data.MeasureTime = t.UTC().Format(time.RFC3339)
+	}
+
+	// Fill default device ID if missing
+	if data.DeviceID == "" {
+		data.DeviceID = DefaultDeviceID
+	}
+}
+
+// fillRoomName sets the room of a reading without one to the chosen location
+func fillRoomName(locationRepo models.LocationRepository, data *models.Data, ctx context.Context) {
+	if data.RoomName != "" {
+		return
+	}
+	if locationRepo != nil {
+		loc, err := locationRepo.GetChosenLocation(ctx)
+		if err == nil && loc != nil && loc.Name != "" {
+			data.RoomName = loc.Name
+			return
+		}
+	}
+	data.RoomName = "Unknown" // fallback if no chosen location
+}
+
+// publish notifies every publisher about a stored reading
+func publish(publishers []Publisher, data *models.Data) {
+	for _, p := range publishers {
+		p.Publish(data)
+	}
+}
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"testing"
+)
+
+func TestIngestTrustsReceivedAtOnlyFromRelays(t *testing.T) {
+	for _, tc := range []struct {
+		ctx  context.Context
+		want string
+	}{
+		{context.Background(), ""},
+		{WithRelayed(context.Background()), "2024-06-03T09:00:05Z"},
+	} {
+		ds := NewMockDataServiceRecording()
+		data := &models.Data{DeviceID: "arduino_001", SoundLevel: 60, MeasureTime: "2024-06-03T09:00:00Z", ReceivedAt: "2024-06-03T09:00:05Z", IsPeriodic: true}
+		if _, err := Ingest(ds, data, "", tc.ctx); err != nil {
+			t.Fatal(err)
+		}
+		if stored := <-ds.Stored; stored.ReceivedAt != tc.want {
+			t.Errorf("expected received_at %q, got %q", tc.want, stored.ReceivedAt)
+		}
+	}
+}
+
+func TestIngestTrustsRawSoundLevelOnlyFromRelays(t *testing.T) {
+	for _, tc := range []struct {
+		ctx  context.Context
+		want float64
+	}{
+		{context.Background(), 0},
+		{WithRelayed(context.Background()), 62},
+	} {
+		ds := NewMockDataServiceRecording()
+		data := &models.Data{DeviceID: "arduino_001", SoundLevel: 60, RawSoundLevel: 62, MeasureTime: "2024-06-03T09:00:00Z", IsPeriodic: true}
+		if _, err := Ingest(ds, data, "", tc.ctx); err != nil {
+			t.Fatal(err)
+		}
+		if stored := <-ds.Stored; stored.RawSoundLevel != tc.want {
+			t.Errorf("expected raw_sound_level %v, got %v", tc.want, stored.RawSoundLevel)
+		}
+	}
+}
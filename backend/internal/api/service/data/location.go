@@ -3,17 +3,24 @@ package data
 import (
 	"context"
 	"goapi/internal/api/repository/models"
+	"time"
 )
 
 type LocationServiceSQLite struct {
-	repo models.LocationRepository
-	ctx  context.Context
+	repo      models.LocationRepository
+	ctx       context.Context
+	dose      DoseService     // Fills in today's dose of the locations when asked, may be nil
+	schedules ScheduleService // Fills in the effective threshold of the locations, may be nil
+	listeners []ThresholdListener
 }
 
-func NewLocationServiceSQLite(repo models.LocationRepository) *LocationServiceSQLite {
+func NewLocationServiceSQLite(repo models.LocationRepository, dose DoseService, schedules ScheduleService, listeners ...ThresholdListener) *LocationServiceSQLite {
 	return &LocationServiceSQLite{
-		repo: repo,
-		ctx:  context.Background(),
+		repo:      repo,
+		ctx:       context.Background(),
+		dose:      dose,
+		schedules: schedules,
+		listeners: listeners,
 	}
 }
 
@@ -27,11 +34,26 @@ func (s *LocationServiceSQLite) CreateLocation(location *models.Location) error
 }
 
 func (s *LocationServiceSQLite) GetAllLocations() ([]*models.Location, error) {
-	return s.repo.GetAllLocations(s.ctx)
+	locations, err := s.repo.GetAllLocations(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	fillEffectiveThreshold(s.schedules, s.ctx, locations...)
+	return locations, nil
 }
 
 func (s *LocationServiceSQLite) GetChosenLocation() (*models.Location, error) {
-	return s.repo.GetChosenLocation(s.ctx)
+	location, err := s.repo.GetChosenLocation(s.ctx)
+	if err != nil || location == nil {
+		return location, err
+	}
+	fillEffectiveThreshold(s.schedules, s.ctx, location)
+	return location, nil
+}
+
+// FillDose sets today's noise dose of the locations, it reads the whole day of readings of each room
+func (s *LocationServiceSQLite) FillDose(locations ...*models.Location) {
+	fillDose(s.dose, s.ctx, locations...)
 }
 
 func (s *LocationServiceSQLite) SetChosenLocation(id int) error {
@@ -39,9 +61,67 @@ func (s *LocationServiceSQLite) SetChosenLocation(id int) error {
 }
 
 func (s *LocationServiceSQLite) UpdateThreshold(id int, newThreshold float64) error {
-	return s.repo.UpdateThreshold(int64(id), newThreshold, s.ctx)
+	if err := s.repo.UpdateThreshold(int64(id), newThreshold, s.ctx); err != nil {
+		return err
+	}
+	if len(s.listeners) == 0 {
+		return nil
+	}
+
+	// * Tell the listeners about the whole location, the repository only updated a row *
+	location, err := findLocation(s.repo, int64(id), s.ctx)
+	if err != nil || location == nil {
+		return err
+	}
+	for _, l := range s.listeners {
+		l.ThresholdChanged(location)
+	}
+	return nil
 }
 
 func (s *LocationServiceSQLite) DeleteLocation(location *models.Location, ctx context.Context) (int64, error) {
 	return s.repo.DeleteLocation(location, ctx)
 }
+
+// findLocation returns the location with the given ID or nil,
+// the repository has no lookup by ID
+func findLocation(repo models.LocationRepository, id int64, ctx context.Context) (*models.Location, error) {
+	locations, err := repo.GetAllLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		if location.ID == id {
+			return location, nil
+		}
+	}
+	return nil, nil
+}
+
+// fillDose sets today's noise dose of the locations, the location's name is the room.
+// A location whose dose can't be computed is returned without one.
+func fillDose(dose DoseService, ctx context.Context, locations ...*models.Location) {
+	if dose == nil {
+		return
+	}
+	today := time.Now()
+	for _, location := range locations {
+		if d, err := dose.GetDose(location.Name, today, nil, ctx); err == nil {
+			location.Dose = d
+		}
+	}
+}
+
+// fillEffectiveThreshold sets the threshold of the locations now, from their schedule.
+// A location whose threshold can't be resolved is returned without one.
+func fillEffectiveThreshold(schedules ScheduleService, ctx context.Context, locations ...*models.Location) {
+	if schedules == nil {
+		return
+	}
+	now := time.Now()
+	for _, location := range locations {
+		if t, err := schedules.EffectiveThreshold(location, now, ctx); err == nil {
+			location.EffectiveThreshold = t
+		}
+	}
+}
@@ -7,14 +7,20 @@ import (
 )
 
 type LocationServicePostgreSQL struct {
-	repo models.LocationRepository
-	ctx  context.Context
+	repo      models.LocationRepository
+	ctx       context.Context
+	dose      DoseService     // Fills in today's dose of the locations, may be nil
+	schedules ScheduleService // Fills in the effective threshold of the locations, may be nil
+	listeners []ThresholdListener
 }
 
-func NewLocationServicePostgreSQL(repo models.LocationRepository) *LocationServicePostgreSQL {
+func NewLocationServicePostgreSQL(repo models.LocationRepository, dose DoseService, schedules ScheduleService, listeners ...ThresholdListener) *LocationServicePostgreSQL {
 	return &LocationServicePostgreSQL{
-		repo: repo,
-		ctx:  context.Background(),
+		repo:      repo,
+		ctx:       context.Background(),
+		dose:      dose,
+		schedules: schedules,
+		listeners: listeners,
 	}
 }
 
@@ -28,11 +34,26 @@ func (s *LocationServicePostgreSQL) CreateLocation(location *models.Location) er
 }
 
 func (s *LocationServicePostgreSQL) GetAllLocations() ([]*models.Location, error) {
-	return s.repo.GetAllLocations(s.ctx)
+	locations, err := s.repo.GetAllLocations(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	fillEffectiveThreshold(s.schedules, s.ctx, locations...)
+	return locations, nil
 }
 
 func (s *LocationServicePostgreSQL) GetChosenLocation() (*models.Location, error) {
-	return s.repo.GetChosenLocation(s.ctx)
+	location, err := s.repo.GetChosenLocation(s.ctx)
+	if err != nil || location == nil {
+		return location, err
+	}
+	fillEffectiveThreshold(s.schedules, s.ctx, location)
+	return location, nil
+}
+
+// FillDose sets today's noise dose of the locations, it reads the whole day of readings of each room
+func (s *LocationServicePostgreSQL) FillDose(locations ...*models.Location) {
+	fillDose(s.dose, s.ctx, locations...)
 }
 
 func (s *LocationServicePostgreSQL) SetChosenLocation(id int) error {
@@ -40,7 +61,22 @@ func (s *LocationServicePostgreSQL) SetChosenLocation(id int) error {
 }
 
 func (s *LocationServicePostgreSQL) UpdateThreshold(id int, newThreshold float64) error {
-	return s.repo.UpdateThreshold(int64(id), newThreshold, s.ctx)
+	if err := s.repo.UpdateThreshold(int64(id), newThreshold, s.ctx); err != nil {
+		return err
+	}
+	if len(s.listeners) == 0 {
+		return nil
+	}
+
+	// * Tell the listeners about the whole location, the repository only updated a row *
+	location, err := findLocation(s.repo, int64(id), s.ctx)
+	if err != nil || location == nil {
+		return err
+	}
+	for _, l := range s.listeners {
+		l.ThresholdChanged(location)
+	}
+	return nil
 }
 
 func (s *LocationServicePostgreSQL) DeleteLocation(location *models.Location, ctx context.Context) (int64, error) {
@@ -13,9 +13,40 @@ func (m *MockDataServiceSuccessful) Create(d *models.Data, ctx context.Context)
 	return nil
 }
 
+func (m *MockDataServiceSuccessful) CreateIdempotent(d *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (m *MockDataServiceSuccessful) CheckClock(d *models.Data, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockDataServiceSuccessful) Calibrate(d *models.Data, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockDataServiceSuccessful) ResolveThreshold(d *models.Data, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockDataServiceSuccessful) EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	return evaluateAlerts(nil, readings, ctx)
+}
+
+func (m *MockDataServiceSuccessful) RecordAlerts(decision *AlertDecision, ctx context.Context) error {
+	return nil
+}
+
 func (m *MockDataServiceSuccessful) CreateLatest(d *models.Data, ctx context.Context) error {
 	return nil
 }
+func (m *MockDataServiceSuccessful) CreateBatch(d []*models.Data, atomic bool, ctx context.Context) ([]BatchResult, error) {
+	results := make([]BatchResult, len(d))
+	for i := range d {
+		results[i] = BatchResult{Index: i, Status: BatchCreated, ID: i + 1}
+	}
+	return results, nil
+}
 func (m *MockDataServiceSuccessful) ReadOne(id int, ctx context.Context) (*models.Data, error) {
 	return &models.Data{
 		ID:          id,
@@ -92,6 +123,15 @@ func (m *MockDataServiceSuccessful) GetDailySummary(room string, date time.Time,
 		},
 	}, nil
 }
+func (m *MockDataServiceSuccessful) GetDailyStats(room string, date time.Time, ctx context.Context) (*DailyStats, error) {
+	stats := LevelStats{Samples: 2, LAeq: 63.5, LMax: 72.0, LMin: 55.0, L10: 68.0, L50: 60.0, L90: 56.0, MinutesAboveThreshold: 5, Alerts: 1}
+	return &DailyStats{
+		RoomName:   room,
+		Date:       date.Format(time.DateOnly),
+		LevelStats: stats,
+		Hours:      []HourlyStats{{Hour: date.Format(time.RFC3339), LevelStats: stats}},
+	}, nil
+}
 func (m *MockDataServiceSuccessful) GetByRoom(room string, ctx context.Context) ([]*models.Data, error) {
 	return []*models.Data{
 		{
@@ -106,6 +146,79 @@ func (m *MockDataServiceSuccessful) GetByRoom(room string, ctx context.Context)
 		},
 	}, nil
 }
+func (m *MockDataServiceSuccessful) GetByRoomRollups(room string, ctx context.Context) ([]*models.Data, error) {
+	return []*models.Data{
+		{
+			DeviceID:    "arduino_mock",
+			RoomName:    room,
+			SoundLevel:  61.2,
+			Threshold:   70.0,
+			MeasureTime: time.Now().UTC().Truncate(24 * time.Hour).Format(time.RFC3339),
+			IsAlert:     true,
+			Description: "Leq of 96 readings, 2 alerts",
+			IsPeriodic:  true,
+			MaxLevel:    78.0,
+			MinLevel:    45.0,
+		},
+	}, nil
+}
+func (m *MockDataServiceSuccessful) GetDailySummaryRollups(room string, date time.Time, ctx context.Context) ([]*models.Data, error) {
+	return []*models.Data{
+		{
+			DeviceID:    "arduino_mock",
+			RoomName:    room,
+			SoundLevel:  61.2,
+			Threshold:   70.0,
+			MeasureTime: date.UTC().Format(time.RFC3339),
+			IsAlert:     false,
+			Description: "Leq of 4 readings, 0 alerts",
+			IsPeriodic:  true,
+			MaxLevel:    66.0,
+			MinLevel:    55.0,
+		},
+	}, nil
+}
+func (m *MockDataServiceSuccessful) GetAnomalies(room string, from, to time.Time, minScore float64, ctx context.Context) ([]*models.Data, error) {
+	return []*models.Data{
+		{
+			ID:           7,
+			DeviceID:     "arduino_mock",
+			RoomName:     room,
+			SoundLevel:   78.5,
+			Threshold:    70.0,
+			MeasureTime:  from.UTC().Add(3 * time.Hour).Format(time.RFC3339),
+			IsAlert:      true,
+			Description:  "Unusual noise",
+			AnomalyScore: 4.2,
+		},
+	}, nil
+}
+func (m *MockDataServiceSuccessful) GetBaselines(room string, ctx context.Context) ([]*models.Baseline, error) {
+	return []*models.Baseline{
+		{RoomName: room, HourOfWeek: 33, Samples: 120, Mean: 52.4, StdDev: 3.1},
+	}, nil
+}
+func (m *MockDataServiceSuccessful) ComparePeriods(room string, from, to, compareFrom, compareTo time.Time, ctx context.Context) (*PeriodComparison, error) {
+	if err := validatePeriod(from, to); err != nil {
+		return nil, err
+	}
+	if err := validatePeriod(compareFrom, compareTo); err != nil {
+		return nil, err
+	}
+	current := []*models.Data{{DeviceID: "arduino_mock", RoomName: room, SoundLevel: 60, Threshold: 70, MeasureTime: from.Format(time.RFC3339)}}
+	previous := []*models.Data{{DeviceID: "arduino_mock", RoomName: room, SoundLevel: 65, Threshold: 70, MeasureTime: compareFrom.Format(time.RFC3339)}}
+	return computeComparison(current, previous, room, from, to, compareFrom, compareTo), nil
+}
+func (m *MockDataServiceSuccessful) GetSpectrum(room string, from, to time.Time, bucket string, ctx context.Context) (*RoomSpectrum, error) {
+	if err := validatePeriod(from, to); err != nil {
+		return nil, err
+	}
+	if err := validateBucket(bucket); err != nil {
+		return nil, err
+	}
+	rows := []*models.Data{{DeviceID: "arduino_mock", RoomName: room, SoundLevel: 60, MeasureTime: from.Format(time.RFC3339), Bands: []float64{58, 55, 50, 52, 54, 51, 46, 40}}}
+	return computeSpectrum(rows, room, from, to, bucket), nil
+}
 func (m *MockDataServiceSuccessful) CleanOldData(ctx context.Context) error {
 	return nil
 }
@@ -116,9 +229,32 @@ type MockDataServiceError struct{}
 func (m *MockDataServiceError) Create(d *models.Data, ctx context.Context) error {
 	return &DataError{Message: "Error creating data."}
 }
+func (m *MockDataServiceError) CreateIdempotent(d *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	return false, &DataError{Message: "Error creating data."}
+}
+func (m *MockDataServiceError) CheckClock(d *models.Data, ctx context.Context) error {
+	return nil
+}
+func (m *MockDataServiceError) Calibrate(d *models.Data, ctx context.Context) error {
+	return nil
+}
+func (m *MockDataServiceError) ResolveThreshold(d *models.Data, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockDataServiceError) EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	return make([]*AlertDecision, len(readings)), nil
+}
+
+func (m *MockDataServiceError) RecordAlerts(decision *AlertDecision, ctx context.Context) error {
+	return nil
+}
 func (m *MockDataServiceError) CreateLatest(d *models.Data, ctx context.Context) error {
 	return &DataError{Message: "Error creating data."}
 }
+func (m *MockDataServiceError) CreateBatch(d []*models.Data, atomic bool, ctx context.Context) ([]BatchResult, error) {
+	return nil, &DataError{Message: "Error creating data."}
+}
 func (m *MockDataServiceError) ReadOne(id int, ctx context.Context) (*models.Data, error) {
 	return nil, &DataError{Message: "Error reading data."}
 }
@@ -140,9 +276,30 @@ func (m *MockDataServiceError) ValidateData(d *models.Data) error {
 func (m *MockDataServiceError) GetDailySummary(room string, date time.Time, ctx context.Context) ([]*models.Data, error) {
 	return nil, &DataError{Message: "Error fetching daily summary."}
 }
+func (m *MockDataServiceError) GetDailyStats(room string, date time.Time, ctx context.Context) (*DailyStats, error) {
+	return nil, &DataError{Message: "Error fetching daily summary."}
+}
 func (m *MockDataServiceError) GetByRoom(room string, ctx context.Context) ([]*models.Data, error) {
 	return nil, &DataError{Message: "Error fetching weekly summary."}
 }
+func (m *MockDataServiceError) GetByRoomRollups(room string, ctx context.Context) ([]*models.Data, error) {
+	return nil, &DataError{Message: "Error fetching weekly summary."}
+}
+func (m *MockDataServiceError) GetDailySummaryRollups(room string, date time.Time, ctx context.Context) ([]*models.Data, error) {
+	return nil, &DataError{Message: "Error fetching daily summary."}
+}
+func (m *MockDataServiceError) GetAnomalies(room string, from, to time.Time, minScore float64, ctx context.Context) ([]*models.Data, error) {
+	return nil, &DataError{Message: "Error fetching anomalies."}
+}
+func (m *MockDataServiceError) GetBaselines(room string, ctx context.Context) ([]*models.Baseline, error) {
+	return nil, &DataError{Message: "Error fetching baselines."}
+}
+func (m *MockDataServiceError) ComparePeriods(room string, from, to, compareFrom, compareTo time.Time, ctx context.Context) (*PeriodComparison, error) {
+	return nil, &DataError{Message: "Error comparing periods."}
+}
+func (m *MockDataServiceError) GetSpectrum(room string, from, to time.Time, bucket string, ctx context.Context) (*RoomSpectrum, error) {
+	return nil, &DataError{Message: "Error fetching spectrum."}
+}
 func (m *MockDataServiceError) CleanOldData(ctx context.Context) error {
 	return &DataError{Message: "Error cleaning old data."}
 }
@@ -153,9 +310,32 @@ type MockDataServiceNotFound struct{}
 func (m *MockDataServiceNotFound) Create(d *models.Data, ctx context.Context) error {
 	return nil
 }
+func (m *MockDataServiceNotFound) CreateIdempotent(d *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	return true, nil
+}
+func (m *MockDataServiceNotFound) CheckClock(d *models.Data, ctx context.Context) error {
+	return nil
+}
+func (m *MockDataServiceNotFound) Calibrate(d *models.Data, ctx context.Context) error {
+	return nil
+}
+func (m *MockDataServiceNotFound) ResolveThreshold(d *models.Data, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockDataServiceNotFound) EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	return make([]*AlertDecision, len(readings)), nil
+}
+
+func (m *MockDataServiceNotFound) RecordAlerts(decision *AlertDecision, ctx context.Context) error {
+	return nil
+}
 func (m *MockDataServiceNotFound) CreateLatest(d *models.Data, ctx context.Context) error {
 	return nil
 }
+func (m *MockDataServiceNotFound) CreateBatch(d []*models.Data, atomic bool, ctx context.Context) ([]BatchResult, error) {
+	return []BatchResult{}, nil
+}
 func (m *MockDataServiceNotFound) ReadOne(id int, ctx context.Context) (*models.Data, error) {
 	return nil, nil
 }
@@ -177,9 +357,60 @@ func (m *MockDataServiceNotFound) ValidateData(d *models.Data) error {
 func (m *MockDataServiceNotFound) GetDailySummary(room string, date time.Time, ctx context.Context) ([]*models.Data, error) {
 	return nil, nil
 }
+func (m *MockDataServiceNotFound) GetDailyStats(room string, date time.Time, ctx context.Context) (*DailyStats, error) {
+	return &DailyStats{RoomName: room, Date: date.Format(time.DateOnly), Hours: []HourlyStats{}}, nil
+}
 func (m *MockDataServiceNotFound) GetByRoom(room string, ctx context.Context) ([]*models.Data, error) {
 	return nil, nil
 }
+func (m *MockDataServiceNotFound) GetByRoomRollups(room string, ctx context.Context) ([]*models.Data, error) {
+	return nil, nil
+}
+func (m *MockDataServiceNotFound) GetDailySummaryRollups(room string, date time.Time, ctx context.Context) ([]*models.Data, error) {
+	return nil, nil
+}
+func (m *MockDataServiceNotFound) GetAnomalies(room string, from, to time.Time, minScore float64, ctx context.Context) ([]*models.Data, error) {
+	return nil, nil
+}
+func (m *MockDataServiceNotFound) GetBaselines(room string, ctx context.Context) ([]*models.Baseline, error) {
+	return nil, nil
+}
+func (m *MockDataServiceNotFound) ComparePeriods(room string, from, to, compareFrom, compareTo time.Time, ctx context.Context) (*PeriodComparison, error) {
+	return computeComparison(nil, nil, room, from, to, compareFrom, compareTo), nil
+}
+func (m *MockDataServiceNotFound) GetSpectrum(room string, from, to time.Time, bucket string, ctx context.Context) (*RoomSpectrum, error) {
+	return computeSpectrum(nil, room, from, to, bucket), nil
+}
 func (m *MockDataServiceNotFound) CleanOldData(ctx context.Context) error {
 	return &DataError{Message: "Resource not found."}
 }
+
+// ================= MOCK RECORDING =================
+// MockDataServiceRecording passes every stored reading to Stored and, when Keys is set, its idempotency key to Keys.
+// The IDs of deleted readings are passed to Deleted.
+type MockDataServiceRecording struct {
+	MockDataServiceSuccessful
+	Stored  chan models.Data
+	Keys    chan string
+	Deleted chan int
+}
+
+func NewMockDataServiceRecording() *MockDataServiceRecording {
+	return &MockDataServiceRecording{Stored: make(chan models.Data, 10), Deleted: make(chan int, 10)}
+}
+
+func (m *MockDataServiceRecording) CreateIdempotent(d *models.Data, key models.IdempotencyKey, ctx context.Context) (bool, error) {
+	m.Stored <- *d
+	if m.Keys != nil {
+		m.Keys <- key.Key
+	}
+	return true, nil
+}
+func (m *MockDataServiceRecording) CreateLatest(d *models.Data, ctx context.Context) error {
+	m.Stored <- *d
+	return nil
+}
+func (m *MockDataServiceRecording) Delete(d *models.Data, ctx context.Context) (int64, error) {
+	m.Deleted <- d.ID
+	return 1, nil
+}
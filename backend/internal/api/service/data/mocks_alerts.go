@@ -0,0 +1,161 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// ================= MOCK RULES =================
+// MockRuleService knows one rule, ID 1 of the Office
+type MockRuleService struct{}
+
+func (m *MockRuleService) EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	return evaluateAlerts(nil, readings, ctx)
+}
+
+func (m *MockRuleService) RecordAlerts(decision *AlertDecision, ctx context.Context) error {
+	return nil
+}
+func (m *MockRuleService) CreateRule(rule *models.AlertRule, ctx context.Context) error {
+	if err := validateRule(rule); err != nil {
+		return err
+	}
+	rule.ID = 2
+	rule.CreatedAt = "2024-06-01T12:00:00Z"
+	return nil
+}
+func (m *MockRuleService) GetRules(roomName string, ctx context.Context) ([]*models.AlertRule, error) {
+	if roomName != "" && roomName != "Office" {
+		return []*models.AlertRule{}, nil
+	}
+	rule, _ := m.GetRule(1, ctx)
+	return []*models.AlertRule{rule}, nil
+}
+func (m *MockRuleService) GetRule(id int, ctx context.Context) (*models.AlertRule, error) {
+	if id != 1 {
+		return nil, nil
+	}
+	return &models.AlertRule{ID: 1, Name: "Loud for 5 minutes", RoomName: "Office", Kind: models.RuleLeq, Level: 75, ClearLevel: 72,
+		WindowSeconds: 300, Severity: models.SeverityWarning, CreatedAt: "2024-06-01T12:00:00Z"}, nil
+}
+func (m *MockRuleService) UpdateRule(rule *models.AlertRule, ctx context.Context) (bool, error) {
+	if err := validateRule(rule); err != nil {
+		return false, err
+	}
+	return rule.ID == 1, nil
+}
+func (m *MockRuleService) DeleteRule(id int, ctx context.Context) (bool, error) {
+	return id == 1, nil
+}
+func (m *MockRuleService) GetFirings(ruleID int, roomName string, from, to time.Time, ctx context.Context) ([]*models.RuleFiring, error) {
+	if err := validatePeriod(from, to); err != nil {
+		return nil, err
+	}
+	return []*models.RuleFiring{
+		{ID: 1, RuleID: 1, RoomName: "Office", DeviceID: "arduino_001", StartedAt: "2024-06-01T10:05:00Z", EndedAt: "2024-06-01T10:20:00Z", Value: 76.2},
+	}, nil
+}
+
+// ================= MOCK INCIDENTS =================
+// MockIncidentService knows one open incident, ID 1 of the Office
+type MockIncidentService struct{}
+
+func (m *MockIncidentService) GetIncidents(state, roomName string, limit int, ctx context.Context) ([]*models.Incident, error) {
+	if state != "" && state != models.IncidentOpen && state != models.IncidentAcknowledged && state != models.IncidentResolved {
+		return nil, DataError{Message: "The state must be open, acknowledged or resolved."}
+	}
+	if (state != "" && state != models.IncidentOpen) || (roomName != "" && roomName != "Office") {
+		return []*models.Incident{}, nil
+	}
+	incident, _ := m.GetIncident(1, ctx)
+	return []*models.Incident{incident}, nil
+}
+func (m *MockIncidentService) GetIncident(id int, ctx context.Context) (*models.Incident, error) {
+	if id != 1 {
+		return nil, nil
+	}
+	return &models.Incident{ID: 1, RoomName: "Office", State: models.IncidentOpen, OpenedAt: "2024-06-01T10:05:00Z",
+		LastAlertAt: "2024-06-01T10:12:00Z", Alerts: 8, PeakLevel: 88.5}, nil
+}
+func (m *MockIncidentService) Acknowledge(id int, by, note string, ctx context.Context) (*models.Incident, error) {
+	if err := validateResponse(by, note); err != nil {
+		return nil, err
+	}
+	incident, _ := m.GetIncident(id, ctx)
+	if incident != nil {
+		incident.State = models.IncidentAcknowledged
+		incident.AcknowledgedBy = by
+		incident.AcknowledgedAt = "2024-06-01T10:15:00Z"
+		incident.AcknowledgeNote = note
+	}
+	return incident, nil
+}
+func (m *MockIncidentService) Resolve(id int, by, note string, ctx context.Context) (*models.Incident, error) {
+	if err := validateResponse(by, note); err != nil {
+		return nil, err
+	}
+	incident, _ := m.GetIncident(id, ctx)
+	if incident != nil {
+		incident.State = models.IncidentResolved
+		incident.ResolvedBy = by
+		incident.ResolvedAt = "2024-06-01T10:30:00Z"
+		incident.ResolveNote = note
+	}
+	return incident, nil
+}
+
+// ================= MOCK WEBHOOKS =================
+// MockWebhookService knows one webhook, ID 1 for the critical alerts of the Office, with one delivery
+type MockWebhookService struct{}
+
+func (m *MockWebhookService) CreateWebhook(webhook *models.Webhook, ctx context.Context) error {
+	if webhook.Secret == "" {
+		webhook.Secret = "0123456789abcdef0123456789abcdef"
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+	webhook.ID = 2
+	webhook.CreatedAt = "2024-06-01T12:00:00Z"
+	return nil
+}
+func (m *MockWebhookService) GetWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	webhook, _ := m.GetWebhook(1, ctx)
+	return []*models.Webhook{webhook}, nil
+}
+func (m *MockWebhookService) GetWebhook(id int, ctx context.Context) (*models.Webhook, error) {
+	if id != 1 {
+		return nil, nil
+	}
+	return &models.Webhook{ID: 1, URL: "https://example.com/hooks/noise", RoomName: "Office", MinSeverity: models.SeverityCritical,
+		Events: []string{}, CreatedAt: "2024-06-01T12:00:00Z"}, nil
+}
+func (m *MockWebhookService) UpdateWebhook(webhook *models.Webhook, ctx context.Context) (bool, error) {
+	if webhook.Secret == "" {
+		webhook.Secret = "0123456789abcdef0123456789abcdef"
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return false, err
+	}
+	webhook.Secret = ""
+	return webhook.ID == 1, nil
+}
+func (m *MockWebhookService) DeleteWebhook(id int, ctx context.Context) (bool, error) {
+	return id == 1, nil
+}
+func (m *MockWebhookService) GetDeliveries(webhookID int, status string, limit int, ctx context.Context) ([]*models.WebhookDelivery, error) {
+	if err := validateDeliveryQuery(status, limit); err != nil {
+		return nil, err
+	}
+	if webhookID != 1 {
+		return nil, nil
+	}
+	if status != "" && status != models.DeliveryDelivered {
+		return []*models.WebhookDelivery{}, nil
+	}
+	return []*models.WebhookDelivery{
+		{ID: 1, WebhookID: 1, Event: models.EventIncidentOpened, Payload: `{"event":"incident.opened"}`, Status: models.DeliveryDelivered,
+			Attempts: 1, NextAttempt: "2024-06-01T10:05:00.000Z", ResponseStatus: 200, CreatedAt: "2024-06-01T10:05:00.000Z", DeliveredAt: "2024-06-01T10:05:01.000Z"},
+	}, nil
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/audio"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// ================= MOCK CLOCK =================
+type MockClockService struct{}
+
+func (m *MockClockService) CheckClock(d *models.Data, ctx context.Context) error {
+	return nil
+}
+func (m *MockClockService) GetDeviceClock(deviceID string, ctx context.Context) (*models.DeviceClock, error) {
+	if deviceID != "arduino_001" {
+		return nil, nil
+	}
+	return &models.DeviceClock{
+		DeviceID:   deviceID,
+		Samples:    3,
+		LastSkewMs: -1500,
+		MeanSkewMs: -1200,
+		MinSkewMs:  -1500,
+		MaxSkewMs:  -900,
+		LastSeen:   "2024-06-01T12:00:00Z",
+	}, nil
+}
+func (m *MockClockService) GetAllDeviceClocks(ctx context.Context) ([]*models.DeviceClock, error) {
+	clock, _ := m.GetDeviceClock("arduino_001", ctx)
+	return []*models.DeviceClock{clock}, nil
+}
+
+// ================= MOCK CALIBRATION =================
+// MockCalibrationService knows one calibration, ID 1 of arduino_001
+type MockCalibrationService struct{}
+
+func (m *MockCalibrationService) Calibrate(d *models.Data, ctx context.Context) error {
+	return nil
+}
+func (m *MockCalibrationService) CreateCalibration(calibration *models.Calibration, recompute bool, ctx context.Context) (int, error) {
+	if err := validateCalibration(calibration); err != nil {
+		return 0, err
+	}
+	calibration.ID = 2
+	calibration.CreatedAt = "2024-06-01T12:00:00Z"
+	if recompute {
+		return 42, nil
+	}
+	return 0, nil
+}
+func (m *MockCalibrationService) GetCalibrations(deviceID string, ctx context.Context) ([]*models.Calibration, error) {
+	if deviceID != "" && deviceID != "arduino_001" {
+		return []*models.Calibration{}, nil
+	}
+	return []*models.Calibration{
+		{ID: 1, DeviceID: "arduino_001", Offset: -2.5, Gain: 1, ValidFrom: "2024-06-01T00:00:00Z", CreatedAt: "2024-06-01T12:00:00Z"},
+	}, nil
+}
+func (m *MockCalibrationService) DeleteCalibration(deviceID string, id int, recompute bool, ctx context.Context) (bool, int, error) {
+	if deviceID != "arduino_001" || id != 1 {
+		return false, 0, nil
+	}
+	if recompute {
+		return true, 42, nil
+	}
+	return true, 0, nil
+}
+
+// ================= MOCK AUDIO =================
+// MockAudioService analyzes the recording but doesn't store it
+type MockAudioService struct{}
+
+func (m *MockAudioService) AnalyzeUpload(upload *AudioUpload, ctx context.Context) (*models.Data, *models.AudioAnalysis, error) {
+	clip, err := audio.Decode(upload.Body)
+	if err != nil {
+		return nil, nil, DataError{Message: "Invalid WAV file: " + err.Error()}
+	}
+	analysis, err := audio.Analyze(clip, DefaultFullScaleDB)
+	if err != nil {
+		return nil, nil, DataError{Message: "Invalid WAV file: " + err.Error()}
+	}
+	analysis.DataID = 1
+	analysis.FileName = upload.FileName
+	data := &models.Data{ID: 1, DeviceID: upload.DeviceID, RoomName: upload.RoomName, SoundLevel: analysis.LAeq, IsPeriodic: true}
+	return data, analysis, nil
+}
+func (m *MockAudioService) GetAnalysis(dataID int, ctx context.Context) (*models.AudioAnalysis, error) {
+	if dataID != 1 {
+		return nil, nil
+	}
+	return &models.AudioAnalysis{DataID: 1, FileName: "alert.wav", SampleRate: 48000, Channels: 2, BitsPerSample: 16, LAeq: 84.7}, nil
+}
+
+// ================= MOCK SCHEDULES =================
+// MockScheduleService knows location 1, the Office, whose schedule lowers the threshold to 45 dB for nap time
+type MockScheduleService struct{}
+
+func (m *MockScheduleService) ResolveThreshold(d *models.Data, ctx context.Context) error {
+	return nil
+}
+func (m *MockScheduleService) EffectiveThreshold(location *models.Location, at time.Time, ctx context.Context) (float64, error) {
+	return baseThreshold(location.Threshold), nil
+}
+func (m *MockScheduleService) GetSchedule(locationID int64, ctx context.Context) (*models.ThresholdSchedule, error) {
+	if locationID != 1 {
+		return nil, nil
+	}
+	return &models.ThresholdSchedule{LocationID: 1, Timezone: "Europe/Helsinki",
+		Entries: []models.ScheduleEntry{
+			{ID: 1, Label: "Nap time", Weekdays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"}, Start: "12:00", End: "14:00", Threshold: 45},
+		},
+		Exceptions: []models.ScheduleException{},
+	}, nil
+}
+func (m *MockScheduleService) SetSchedule(schedule *models.ThresholdSchedule, ctx context.Context) (bool, error) {
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if err := validateSchedule(schedule); err != nil {
+		return false, err
+	}
+	return schedule.LocationID == 1, nil
+}
+func (m *MockScheduleService) DeleteSchedule(locationID int64, ctx context.Context) (bool, error) {
+	return locationID == 1, nil
+}
+func (m *MockScheduleService) AddException(locationID int64, exception *models.ScheduleException, ctx context.Context) (bool, error) {
+	if err := validateException(exception); err != nil {
+		return false, err
+	}
+	exception.ID = 1
+	return locationID == 1, nil
+}
+func (m *MockScheduleService) DeleteException(locationID int64, id int, ctx context.Context) (bool, error) {
+	return locationID == 1 && id == 1, nil
+}
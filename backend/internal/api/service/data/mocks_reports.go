@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// ================= MOCK DOSE =================
+type MockDoseService struct{}
+
+func (m *MockDoseService) GetDose(roomName string, date time.Time, cfg *DoseConfig, ctx context.Context) (*models.NoiseDose, error) {
+	config, err := DoseConfig{CriterionLevel: DefaultCriterionLevel, ExchangeRate: DefaultExchangeRate, CriterionHours: DefaultCriterionHours}.withOverrides(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &models.NoiseDose{
+		RoomName:             roomName,
+		Date:                 date.Format(time.DateOnly),
+		DeviceID:             "arduino_001",
+		CriterionLevel:       config.CriterionLevel,
+		ExchangeRate:         config.ExchangeRate,
+		CriterionHours:       config.CriterionHours,
+		ExposureMinutes:      240,
+		DosePercent:          50,
+		TWA:                  82,
+		ProjectedDosePercent: 100,
+	}, nil
+}
+
+// ================= MOCK DIGESTS =================
+// MockDigestService knows the Office, 4 alerts in the period, 2 more than in the one before
+type MockDigestService struct{}
+
+func (m *MockDigestService) GetDigest(period, roomName string, to time.Time, ctx context.Context) (*models.Digest, error) {
+	length, err := digestLength(period)
+	if err != nil {
+		return nil, err
+	}
+	digest := &models.Digest{Period: period, From: to.Add(-length).UTC().Format(time.RFC3339), To: to.UTC().Format(time.RFC3339),
+		Timezone: "UTC", Locations: []models.LocationDigest{}}
+	if roomName == "" || roomName == "Office" {
+		digest.Locations = append(digest.Locations, models.LocationDigest{
+			RoomName: "Office",
+			Current:  models.DigestStats{Samples: 10, LAeq: 62.5, LMax: 80, Alerts: 4, MinutesAboveThreshold: 35},
+			Previous: models.DigestStats{Samples: 10, LAeq: 61, LMax: 78, Alerts: 2, MinutesAboveThreshold: 20},
+			Change:   models.DigestStats{LAeq: 1.5, LMax: 2, Alerts: 2, MinutesAboveThreshold: 15},
+			Trend:    models.TrendLouder,
+			LoudestPeriods: []models.LoudPeriod{
+				{Start: "2024-06-02T10:00:00Z", End: "2024-06-02T11:00:00Z", LAeq: 71.2, LMax: 80, Alerts: 3, MinutesAboveThreshold: 25},
+			},
+		})
+	}
+	return digest, nil
+}
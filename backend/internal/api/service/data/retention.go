@@ -0,0 +1,20 @@
+package data
+
+import "time"
+
+// Retention of the stored history. The readings are the source of truth for the last DataRetentionMonths,
+// the rollups of their buckets can be recomputed from them with the backfill command. Older history is only
+// kept in the rollups, for RollupRetentionMonths. The baselines are learned from every reading and are kept
+// through the cleanup, the backfill command relearns them from the readings left.
+const (
+	DataRetentionMonths   = 6
+	RollupRetentionMonths = 24
+)
+
+// retentionCutoffs returns the times before which readings and rollups are removed at now, RFC3339 in UTC
+func retentionCutoffs(now time.Time) (readings, rollups string) {
+	now = now.UTC()
+	readings = now.AddDate(0, -DataRetentionMonths, 0).Format(time.RFC3339)
+	rollups = now.AddDate(0, -RollupRetentionMonths, 0).Format(time.RFC3339)
+	return readings, rollups
+}
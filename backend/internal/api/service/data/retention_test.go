@@ -0,0 +1,64 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	"testing"
+	"time"
+)
+
+func TestCleanOldDataKeepsRollupsLonger(t *testing.T) {
+	db, err := SQLite.NewSqlite(t.TempDir() + "/retention.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	locations, err := SQLite.NewLocationRepository(db, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// * The baselines read the time zones of the schedules *
+	if _, err := SQLite.NewScheduleRepository(db, ctx); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := SQLite.NewDataRepository(db, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := NewDataServiceSQLite(repo, locations, nil, nil, nil, nil)
+
+	// * A reading of today, of a year ago and of three years ago *
+	now := time.Now().UTC().Truncate(time.Hour)
+	ages := []time.Time{now, now.AddDate(-1, 0, 0), now.AddDate(-3, 0, 0)}
+	for _, at := range ages {
+		data := &models.Data{DeviceID: "arduino_001", RoomName: "Office", SoundLevel: 60, MeasureTime: at.Format(time.RFC3339), IsPeriodic: true}
+		if err := ds.Create(data, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ds.CleanOldData(ctx); err != nil {
+		t.Fatal(err)
+	}
+	readings, err := repo.GetByRange("Office", ages[2], now.Add(time.Hour), ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 1 || readings[0].MeasureTime != now.Format(time.RFC3339) {
+		t.Errorf("expected only today's reading, got %+v", readings)
+	}
+
+	for _, period := range models.RollupPeriods {
+		rollups, err := repo.GetRollups(period, "Office", ages[2].AddDate(0, 0, -1), now.Add(time.Hour), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// * The rollup of a year ago outlives its reading, the one of three years ago is removed *
+		want := []string{models.RollupBucket(period, ages[1]).Format(time.RFC3339), models.RollupBucket(period, now).Format(time.RFC3339)}
+		if len(rollups) != len(want) || rollups[0].Bucket != want[0] || rollups[1].Bucket != want[1] {
+			t.Errorf("unexpected %s rollups after the cleanup: %+v, want buckets %v", period, rollups, want)
+		}
+	}
+}
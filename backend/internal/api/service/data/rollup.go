@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// WeeklyDays is how far back the weekly chart goes
+const WeeklyDays = 35
+
+// weeklyRollups returns the daily rollups of the room for the last WeeklyDays, as data rows
+func weeklyRollups(repo models.DataRepository, locationRepo models.LocationRepository, roomName string, ctx context.Context) ([]*models.Data, error) {
+	now := time.Now()
+	from := models.RollupBucket(models.RollupDay, now.AddDate(0, 0, -WeeklyDays))
+	to := models.RollupBucket(models.RollupDay, now).AddDate(0, 0, 1)
+
+	rollups, err := repo.GetRollups(models.RollupDay, roomName, from, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rollupData(rollups, roomThreshold(locationRepo, roomName, ctx)), nil
+}
+
+// dailyRollups returns the hourly rollups of the room for the day of date, as data rows.
+// The day starts at midnight in date's time zone.
+func dailyRollups(repo models.DataRepository, locationRepo models.LocationRepository, roomName string, date time.Time, ctx context.Context) ([]*models.Data, error) {
+	year, month, day := date.Date()
+	from := time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+	to := from.AddDate(0, 0, 1)
+
+	rollups, err := repo.GetRollups(models.RollupHour, roomName, from, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rollupData(rollups, roomThreshold(locationRepo, roomName, ctx)), nil
+}
+
+// rollupData turns rollups into data rows, so the charts can read them like raw rows:
+// sound_level is the energy averaged level, measure_time the start of the hour or day
+// and is_alert is set when any reading of the rollup was an alert
+func rollupData(rollups []*models.Rollup, threshold float64) []*models.Data {
+	data := make([]*models.Data, 0, len(rollups))
+	for _, r := range rollups {
+		data = append(data, &models.Data{
+			DeviceID:    r.DeviceID,
+			RoomName:    r.RoomName,
+			SoundLevel:  roundLevel(r.Leq()),
+			Threshold:   threshold,
+			MeasureTime: r.Bucket,
+			IsAlert:     r.Alerts > 0,
+			Description: fmt.Sprintf("Leq of %d readings, %d alerts", r.Samples, r.Alerts),
+			IsPeriodic:  true,
+			MaxLevel:    r.MaxLevel,
+			MinLevel:    r.MinLevel,
+		})
+	}
+	return data
+}
+
+// roomThreshold returns the threshold of the location named like the room,
+// rollups don't keep the thresholds of their readings
+func roomThreshold(locationRepo models.LocationRepository, roomName string, ctx context.Context) float64 {
+	if locationRepo != nil {
+		locations, err := locationRepo.GetAllLocations(ctx)
+		if err == nil {
+			for _, location := range locations {
+				if location.Name == roomName && location.Threshold > 0 {
+					return location.Threshold
+				}
+			}
+		}
+	}
+	return DefaultThreshold
+}
@@ -0,0 +1,439 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limits of alert rules
+const (
+	MaxRuleWindow       = 24 * time.Hour
+	DefaultLeqWindow    = 5 * time.Minute
+	DefaultPeaksWindow  = 10 * time.Minute
+	DefaultPeaksCount   = 3
+	defaultRuleSeverity = models.SeverityWarning
+)
+
+// RuleEngine decides which readings are alerts with the alert rules of their room.
+// Rules are evaluated per device over the device's recent readings, which are kept in memory,
+// so leq and peaks rules only see the readings received since the server started.
+// Periodic rows are evaluated apart from the live readings of a device, as they are averages.
+// The firings are recorded after the readings are stored, see RecordAlerts.
+type RuleEngine struct {
+	repo models.RuleRepository
+
+	mu      sync.Mutex
+	rules   []*models.AlertRule // Cached, nil until loaded
+	started bool                // Whether the firings of a previous run were ended
+	streams map[string]*ruleStream
+}
+
+// ruleStream holds the recent readings of a device and the rules active for it
+type ruleStream struct {
+	samples []ruleSample               // Ordered by time
+	active  map[int]*models.RuleFiring // By rule ID
+}
+
+type ruleSample struct {
+	time  time.Time
+	level float64
+}
+
+func NewRuleEngine(repo models.RuleRepository) *RuleEngine {
+	return &RuleEngine{
+		repo:    repo,
+		streams: make(map[string]*ruleStream),
+	}
+}
+
+// AlertDecision is what EvaluateAlerts decided for a reading of a device, the firings it starts and ends.
+// It is recorded with RecordAlerts once the reading is stored.
+type AlertDecision struct {
+	key         string // Of the device's stream
+	sample      ruleSample
+	measureTime string
+	starts      []*models.RuleFiring
+	ends        []*models.RuleFiring
+}
+
+// EvaluateAlerts sets is_alert and alert_rule_id of readings from the rules of their room,
+// in order as if each were stored before the next. Nothing is written: the decisions, nil for a reading
+// that changes no firing, are recorded with RecordAlerts once the readings are stored.
+// A room without enabled rules keeps the single threshold: a reading is an alert if the device
+// flagged it or its level is at or above its threshold.
+// Firings start and end with the readings of a device in measure time order, a reading
+// older than the device's latest one is only checked against the levels that fire the rules.
+func (e *RuleEngine) EvaluateAlerts(readings []*models.Data, ctx context.Context) ([]*AlertDecision, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.load(ctx); err != nil {
+		return nil, err
+	}
+	decisions := make([]*AlertDecision, len(readings))
+	// * The streams as they will be once the readings are stored *
+	pending := make(map[string]*ruleStream)
+	for i, data := range readings {
+		decisions[i] = e.decide(data, pending)
+	}
+	return decisions, nil
+}
+
+// decide evaluates the rules of a reading's room over the pending streams
+func (e *RuleEngine) decide(data *models.Data, pending map[string]*ruleStream) *AlertDecision {
+	var rules []*models.AlertRule
+	for _, rule := range e.rules {
+		if !rule.Disabled && (rule.RoomName == "" || rule.RoomName == data.RoomName) {
+			rules = append(rules, rule)
+		}
+	}
+	measured, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if len(rules) == 0 || err != nil {
+		thresholdAlert(data)
+		return nil
+	}
+
+	key := data.DeviceID
+	if data.IsPeriodic {
+		key += "|periodic"
+	}
+	stream, ok := pending[key]
+	if !ok {
+		stream = e.streams[key].pending()
+		pending[key] = stream
+	}
+	sample := ruleSample{time: measured, level: data.SoundLevel}
+	latest := len(stream.samples) == 0 || measured.After(stream.samples[len(stream.samples)-1].time)
+	samples := stream.samples
+	var decision *AlertDecision
+	if latest {
+		stream.samples = append(stream.samples, sample)
+		samples = stream.samples
+		decision = &AlertDecision{key: key, sample: sample, measureTime: data.MeasureTime}
+	} else {
+		samples = append(sampledBefore(samples, measured), sample)
+	}
+
+	data.IsAlert = false
+	data.AlertRuleID = 0
+	var alert *models.AlertRule
+	for _, rule := range rules {
+		firing, active := stream.active[rule.ID]
+		if !latest {
+			active = false
+		}
+		level := rule.Level
+		if active {
+			level = rule.ClearLevel
+		}
+		value, fires := evaluateRule(rule, samples, measured, level)
+
+		if latest {
+			switch {
+			case fires && !active:
+				firing = &models.RuleFiring{
+					RuleID:    rule.ID,
+					RoomName:  data.RoomName,
+					DeviceID:  data.DeviceID,
+					StartedAt: data.MeasureTime,
+					Value:     value,
+				}
+				decision.starts = append(decision.starts, firing)
+				stream.active[rule.ID] = firing
+			case !fires && active:
+				decision.ends = append(decision.ends, firing)
+				delete(stream.active, rule.ID)
+			}
+		}
+
+		if fires && (alert == nil || models.SeverityRank(rule.Severity) > models.SeverityRank(alert.Severity)) {
+			alert = rule
+		}
+	}
+	if alert != nil {
+		data.IsAlert = true
+		data.AlertRuleID = alert.ID
+	}
+	return decision
+}
+
+// RecordAlerts stores the firings a stored reading starts and ends and adds the reading to its device's stream.
+// A decision that no longer holds, as a later reading of the device was stored first or the rule changed,
+// changes nothing.
+func (e *RuleEngine) RecordAlerts(decision *AlertDecision, ctx context.Context) error {
+	if decision == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.load(ctx); err != nil {
+		return err
+	}
+	stream, ok := e.streams[decision.key]
+	if !ok {
+		stream = &ruleStream{active: make(map[int]*models.RuleFiring)}
+		e.streams[decision.key] = stream
+	}
+	if n := len(stream.samples); n > 0 && !decision.sample.time.After(stream.samples[n-1].time) {
+		return nil
+	}
+	stream.samples = append(stream.samples, decision.sample)
+	stream.trim(decision.sample.time.Add(-e.maxWindow()))
+
+	for _, firing := range decision.ends {
+		if stream.active[firing.RuleID] != firing {
+			continue
+		}
+		if err := e.repo.EndFiring(firing.ID, decision.measureTime, ctx); err != nil {
+			return err
+		}
+		delete(stream.active, firing.RuleID)
+	}
+	for _, firing := range decision.starts {
+		if _, active := stream.active[firing.RuleID]; active || !e.enabled(firing.RuleID) {
+			continue
+		}
+		if err := e.repo.CreateFiring(firing, ctx); err != nil {
+			return err
+		}
+		stream.active[firing.RuleID] = firing
+	}
+	return nil
+}
+
+// enabled returns whether a rule is loaded and enabled
+func (e *RuleEngine) enabled(ruleID int) bool {
+	for _, rule := range e.rules {
+		if rule.ID == ruleID {
+			return !rule.Disabled
+		}
+	}
+	return false
+}
+
+// evaluateRule returns the value a rule compares with level at a reading, and whether the rule holds.
+// samples are the readings of the device up to and including the reading measured at t.
+func evaluateRule(rule *models.AlertRule, samples []ruleSample, t time.Time, level float64) (float64, bool) {
+	current := samples[len(samples)-1].level
+	from := t.Add(-rule.Window())
+	switch rule.Kind {
+	case models.RuleLeq:
+		energy, n := 0.0, 0
+		for _, s := range samples {
+			if s.time.After(from) {
+				energy += toEnergy(s.level)
+				n++
+			}
+		}
+		leq := roundLevel(fromEnergy(energy / float64(n)))
+		return leq, leq >= level
+	case models.RulePeaks:
+		peaks := 0
+		for _, s := range samples {
+			if s.time.After(from) && s.level >= level {
+				peaks++
+			}
+		}
+		return float64(peaks), peaks >= rule.Count
+	default:
+		return current, current >= level
+	}
+}
+
+// thresholdAlert is the alert of a reading without rules
+func thresholdAlert(data *models.Data) {
+	threshold := data.Threshold
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	data.IsAlert = data.IsAlert || data.SoundLevel >= threshold
+	data.AlertRuleID = 0
+}
+
+// sampledBefore returns the samples measured before t
+func sampledBefore(samples []ruleSample, t time.Time) []ruleSample {
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].time.Before(t) })
+	return samples[:i:i]
+}
+
+// pending returns a copy of the stream to evaluate readings with, nil is an empty stream.
+// Its samples aren't trimmed, the rules only look at the samples in their windows.
+func (s *ruleStream) pending() *ruleStream {
+	p := &ruleStream{active: make(map[int]*models.RuleFiring)}
+	if s != nil {
+		// * Appending copies the samples, so the stream's own are left as they are *
+		p.samples = s.samples[:len(s.samples):len(s.samples)]
+		maps.Copy(p.active, s.active)
+	}
+	return p
+}
+
+// trim drops the samples measured before from
+func (s *ruleStream) trim(from time.Time) {
+	i := sort.Search(len(s.samples), func(i int) bool { return !s.samples[i].time.Before(from) })
+	s.samples = append(s.samples[:0], s.samples[i:]...)
+}
+
+// maxWindow is the longest window of the rules, how long the readings of a device are kept
+func (e *RuleEngine) maxWindow() time.Duration {
+	var window time.Duration
+	for _, rule := range e.rules {
+		window = max(window, rule.Window())
+	}
+	return window
+}
+
+// load reads the rules if they are not cached. The first time, the firings left
+// active by a previous run are ended, as the state of the rules is not kept.
+func (e *RuleEngine) load(ctx context.Context) error {
+	if e.rules != nil {
+		return nil
+	}
+	rules, err := e.repo.ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+	if !e.started {
+		if err := e.repo.EndAllFirings(0, time.Now().UTC().Format(time.RFC3339), ctx); err != nil {
+			return err
+		}
+		e.started = true
+	}
+	e.rules = rules
+	return nil
+}
+
+// CreateRule stores an alert rule, which applies to the readings received from then on
+func (e *RuleEngine) CreateRule(rule *models.AlertRule, ctx context.Context) error {
+	if err := validateRule(rule); err != nil {
+		return err
+	}
+	rule.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.repo.Create(rule, ctx); err != nil {
+		return err
+	}
+	e.rules = nil
+	return nil
+}
+
+// GetRules returns the alert rules of a room, including those of every room, or all rules if roomName is empty
+func (e *RuleEngine) GetRules(roomName string, ctx context.Context) ([]*models.AlertRule, error) {
+	rules, err := e.repo.ReadAll(ctx)
+	if err != nil || roomName == "" {
+		return rules, err
+	}
+	roomRules := []*models.AlertRule{}
+	for _, rule := range rules {
+		if rule.RoomName == "" || rule.RoomName == roomName {
+			roomRules = append(roomRules, rule)
+		}
+	}
+	return roomRules, nil
+}
+
+func (e *RuleEngine) GetRule(id int, ctx context.Context) (*models.AlertRule, error) {
+	return e.repo.ReadOne(id, ctx)
+}
+
+// UpdateRule replaces an alert rule, false if there is no such rule.
+// The rule's active firings are ended, it starts over with the next readings.
+func (e *RuleEngine) UpdateRule(rule *models.AlertRule, ctx context.Context) (bool, error) {
+	if err := validateRule(rule); err != nil {
+		return false, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n, err := e.repo.Update(rule, ctx)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, e.reset(rule.ID, ctx)
+}
+
+// DeleteRule removes an alert rule, false if there is no such rule. Its active firings are ended.
+func (e *RuleEngine) DeleteRule(id int, ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n, err := e.repo.Delete(id, ctx)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, e.reset(id, ctx)
+}
+
+// reset ends the active firings of a changed rule and reloads the rules
+func (e *RuleEngine) reset(ruleID int, ctx context.Context) error {
+	e.rules = nil
+	for _, stream := range e.streams {
+		delete(stream.active, ruleID)
+	}
+	return e.repo.EndAllFirings(ruleID, time.Now().UTC().Format(time.RFC3339), ctx)
+}
+
+// GetFirings returns the firings started in [from, to), of one rule and room if set
+func (e *RuleEngine) GetFirings(ruleID int, roomName string, from, to time.Time, ctx context.Context) ([]*models.RuleFiring, error) {
+	if err := validatePeriod(from, to); err != nil {
+		return nil, err
+	}
+	return e.repo.GetFirings(ruleID, roomName, from, to, ctx)
+}
+
+// validateRule checks an alert rule makes sense and fills in its defaults: the clear level
+// is the level, leq rules look 5 minutes back, peaks rules 3 peaks in 10 minutes, severity warning
+func validateRule(r *models.AlertRule) error {
+	if len(r.Name) > 100 {
+		return DataError{Message: "The name of a rule must be less than 100 characters."}
+	}
+	if r.Level <= 0 || r.Level > 150 {
+		return DataError{Message: "The level must be between 0 and 150 dB."}
+	}
+	if r.ClearLevel == 0 {
+		r.ClearLevel = r.Level
+	}
+	if r.ClearLevel < 0 || r.ClearLevel > r.Level {
+		return DataError{Message: "The clear level must be between 0 dB and the level."}
+	}
+
+	switch r.Kind {
+	case models.RuleLevel:
+		r.WindowSeconds = 0
+		r.Count = 0
+	case models.RuleLeq:
+		if r.WindowSeconds == 0 {
+			r.WindowSeconds = int(DefaultLeqWindow.Seconds())
+		}
+		r.Count = 0
+	case models.RulePeaks:
+		if r.WindowSeconds == 0 {
+			r.WindowSeconds = int(DefaultPeaksWindow.Seconds())
+		}
+		if r.Count == 0 {
+			r.Count = DefaultPeaksCount
+		}
+		if r.Count < 1 {
+			return DataError{Message: "A peaks rule needs a count of at least 1."}
+		}
+	default:
+		return DataError{Message: "The kind of a rule must be level, leq or peaks."}
+	}
+	if r.WindowSeconds < 0 || r.Window() > MaxRuleWindow {
+		return DataError{Message: "The window must be between 1 second and 24 hours."}
+	}
+
+	if r.Severity == "" {
+		r.Severity = defaultRuleSeverity
+	}
+	if models.SeverityRank(r.Severity) == 0 {
+		return DataError{Message: "The severity must be info, warning or critical."}
+	}
+	return nil
+}
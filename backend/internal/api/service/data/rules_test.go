@@ -0,0 +1,214 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"testing"
+	"time"
+)
+
+// * memoryRules keeps the rules and firings in memory *
+type memoryRules struct {
+	models.RuleRepository
+	rules   []*models.AlertRule
+	firings []*models.RuleFiring
+}
+
+func (m *memoryRules) ReadAll(ctx context.Context) ([]*models.AlertRule, error) {
+	return append([]*models.AlertRule{}, m.rules...), nil
+}
+
+func (m *memoryRules) CreateFiring(f *models.RuleFiring, ctx context.Context) error {
+	m.firings = append(m.firings, f)
+	f.ID = len(m.firings)
+	return nil
+}
+
+func (m *memoryRules) EndFiring(id int, endedAt string, ctx context.Context) error {
+	m.firings[id-1].EndedAt = endedAt
+	return nil
+}
+
+func (m *memoryRules) EndAllFirings(ruleID int, endedAt string, ctx context.Context) error {
+	return nil
+}
+
+// readingsAt returns readings of arduino_001 in Office, one per level every minute from 10:00
+func readingsAt(levels ...float64) []*models.Data {
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	var readings []*models.Data
+	for i, level := range levels {
+		readings = append(readings, &models.Data{DeviceID: "arduino_001", RoomName: "Office", SoundLevel: level, Threshold: 70,
+			MeasureTime: start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339)})
+	}
+	return readings
+}
+
+// evaluate runs the engine over the readings of readingsAt, storing each before the next
+func evaluate(t *testing.T, e *RuleEngine, levels ...float64) []*models.Data {
+	t.Helper()
+	readings := readingsAt(levels...)
+	for _, d := range readings {
+		decisions, err := e.EvaluateAlerts([]*models.Data{d}, context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.RecordAlerts(decisions[0], context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return readings
+}
+
+func alerts(readings []*models.Data) []bool {
+	var alerts []bool
+	for _, d := range readings {
+		alerts = append(alerts, d.IsAlert)
+	}
+	return alerts
+}
+
+func TestRoomWithoutRulesUsesThreshold(t *testing.T) {
+	e := NewRuleEngine(&memoryRules{rules: []*models.AlertRule{{ID: 1, RoomName: "Hall", Kind: models.RuleLevel, Level: 50, ClearLevel: 50}}})
+	readings := evaluate(t, e, 65, 72)
+	if readings[0].IsAlert || !readings[1].IsAlert || readings[1].AlertRuleID != 0 {
+		t.Errorf("unexpected alerts: %+v", readings)
+	}
+}
+
+func TestLevelRuleHysteresis(t *testing.T) {
+	repo := &memoryRules{rules: []*models.AlertRule{{ID: 1, Kind: models.RuleLevel, Level: 80, ClearLevel: 75, Severity: models.SeverityWarning}}}
+	e := NewRuleEngine(repo)
+	readings := evaluate(t, e, 79, 82, 77, 76, 74, 78)
+
+	want := []bool{false, true, true, true, false, false}
+	for i, got := range alerts(readings) {
+		if got != want[i] {
+			t.Errorf("reading %d of %v: alert %v, want %v", i, readings[i].SoundLevel, got, want[i])
+		}
+	}
+	if readings[1].AlertRuleID != 1 || readings[4].AlertRuleID != 0 {
+		t.Errorf("unexpected alert rules: %d, %d", readings[1].AlertRuleID, readings[4].AlertRuleID)
+	}
+	if len(repo.firings) != 1 || repo.firings[0].StartedAt != "2024-06-01T10:01:00Z" || repo.firings[0].EndedAt != "2024-06-01T10:04:00Z" {
+		t.Errorf("unexpected firings: %+v", repo.firings)
+	}
+}
+
+func TestLeqRule(t *testing.T) {
+	repo := &memoryRules{rules: []*models.AlertRule{{ID: 1, Kind: models.RuleLeq, Level: 75, ClearLevel: 75, WindowSeconds: 180}}}
+	e := NewRuleEngine(repo)
+	// * The Leq of 80, 70 and 70 is 76, a single loud reading lifts it for the whole window *
+	readings := evaluate(t, e, 70, 70, 80, 70, 70, 70)
+
+	want := []bool{false, false, true, true, true, false}
+	for i, got := range alerts(readings) {
+		if got != want[i] {
+			t.Errorf("reading %d: alert %v, want %v", i, got, want[i])
+		}
+	}
+	if len(repo.firings) != 1 || repo.firings[0].Value != 76.02 {
+		t.Errorf("unexpected firings: %+v", repo.firings)
+	}
+}
+
+func TestPeaksRule(t *testing.T) {
+	e := NewRuleEngine(&memoryRules{rules: []*models.AlertRule{{ID: 1, Kind: models.RulePeaks, Level: 85, ClearLevel: 85, WindowSeconds: 600, Count: 3}}})
+	readings := evaluate(t, e, 90, 60, 88, 60, 86, 60)
+
+	want := []bool{false, false, false, false, true, true}
+	for i, got := range alerts(readings) {
+		if got != want[i] {
+			t.Errorf("reading %d: alert %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestMostSevereRuleIsRecorded(t *testing.T) {
+	e := NewRuleEngine(&memoryRules{rules: []*models.AlertRule{
+		{ID: 1, Kind: models.RuleLevel, Level: 70, ClearLevel: 70, Severity: models.SeverityInfo},
+		{ID: 2, RoomName: "Office", Kind: models.RuleLevel, Level: 85, ClearLevel: 85, Severity: models.SeverityCritical},
+		{ID: 3, Kind: models.RuleLevel, Level: 60, ClearLevel: 60, Severity: models.SeverityCritical, Disabled: true},
+	}})
+	readings := evaluate(t, e, 65, 75, 90)
+	if readings[0].IsAlert || readings[1].AlertRuleID != 1 || readings[2].AlertRuleID != 2 {
+		t.Errorf("unexpected alert rules: %+v", readings)
+	}
+}
+
+func TestLateReadingDoesNotChangeFirings(t *testing.T) {
+	repo := &memoryRules{rules: []*models.AlertRule{{ID: 1, Kind: models.RuleLevel, Level: 80, ClearLevel: 80}}}
+	e := NewRuleEngine(repo)
+	evaluate(t, e, 70, 70, 70)
+
+	late := &models.Data{DeviceID: "arduino_001", RoomName: "Office", SoundLevel: 85, MeasureTime: "2024-06-01T10:00:30Z"}
+	decisions, err := e.EvaluateAlerts([]*models.Data{late}, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RecordAlerts(decisions[0], context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !late.IsAlert || len(repo.firings) != 0 {
+		t.Errorf("late reading: %+v, firings %+v", late, repo.firings)
+	}
+}
+
+func TestFiringsAreRecordedOnlyForStoredReadings(t *testing.T) {
+	repo := &memoryRules{rules: []*models.AlertRule{{ID: 1, Kind: models.RuleLevel, Level: 80, ClearLevel: 75}}}
+	e := NewRuleEngine(repo)
+
+	// * A batch is decided in order, the second reading keeps the rule firing with the clear level *
+	readings := readingsAt(82, 77, 70)
+	decisions, err := e.EvaluateAlerts(readings, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := alerts(readings); !got[0] || !got[1] || got[2] {
+		t.Errorf("unexpected alerts: %v", got)
+	}
+	if len(repo.firings) != 0 || len(e.streams) != 0 {
+		t.Fatalf("evaluating changed the firings %+v or streams %+v", repo.firings, e.streams)
+	}
+
+	// * The readings weren't stored, the next one starts over *
+	again := readingsAt(78)
+	if _, err := e.EvaluateAlerts(again, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if again[0].IsAlert {
+		t.Errorf("a reading that wasn't stored kept the rule firing: %+v", again[0])
+	}
+
+	for _, decision := range decisions {
+		if err := e.RecordAlerts(decision, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.firings) != 1 || repo.firings[0].StartedAt != "2024-06-01T10:00:00Z" || repo.firings[0].EndedAt != "2024-06-01T10:02:00Z" {
+		t.Errorf("unexpected firings: %+v", repo.firings)
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	r := &models.AlertRule{Kind: models.RulePeaks, Level: 85}
+	if err := validateRule(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ClearLevel != 85 || r.WindowSeconds != 600 || r.Count != 3 || r.Severity != models.SeverityWarning {
+		t.Errorf("unexpected defaults: %+v", r)
+	}
+
+	for _, r := range []*models.AlertRule{
+		{Kind: models.RuleLevel},
+		{Kind: "loud", Level: 80},
+		{Kind: models.RuleLevel, Level: 80, ClearLevel: 85},
+		{Kind: models.RuleLeq, Level: 80, WindowSeconds: 2 * 86400},
+		{Kind: models.RulePeaks, Level: 80, Count: -1},
+		{Kind: models.RuleLevel, Level: 80, Severity: "urgent"},
+	} {
+		if err := validateRule(r); err == nil {
+			t.Errorf("%+v should be rejected", r)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// scheduleCacheTTL is how long the schedules and the locations they belong to are cached,
+	// a threshold changed through the locations endpoints applies after it at the latest
+	scheduleCacheTTL = time.Minute
+	// scheduleCheckInterval is how often the effective thresholds are checked for changes
+	scheduleCheckInterval = time.Minute
+	scheduleDateFormat    = "2006-01-02"
+)
+
+// weekdays are the days of ScheduleEntry.Weekdays
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// ThresholdScheduler sets the threshold of readings from the weekly schedule of their location.
+// The effective threshold at a time is the one of the exception covering the local date,
+// else of the entry covering the local weekday and time, else the location's own threshold.
+// A reading keeps the threshold its device sends.
+type ThresholdScheduler struct {
+	repo      models.ScheduleRepository
+	locations models.LocationRepository
+	logger    *log.Logger
+	wake      chan struct{}
+
+	mu       sync.Mutex
+	cache    map[string]*roomSchedule // By location name, nil until loaded
+	loadedAt time.Time
+}
+
+// roomSchedule is a location with its schedule and the timezone of the schedule,
+// schedule is nil if the location has none
+type roomSchedule struct {
+	location *models.Location
+	schedule *models.ThresholdSchedule
+	tz       *time.Location
+}
+
+func NewThresholdScheduler(repo models.ScheduleRepository, locations models.LocationRepository, logger *log.Logger) *ThresholdScheduler {
+	return &ThresholdScheduler{
+		repo:      repo,
+		locations: locations,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// ResolveThreshold sets the threshold of a reading whose device didn't send one: the effective threshold
+// of its room's schedule at its measure time, else the threshold of the location, else DefaultThreshold
+func (s *ThresholdScheduler) ResolveThreshold(data *models.Data, ctx context.Context) error {
+	if data.Threshold != 0 {
+		return nil
+	}
+	rooms, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	room, ok := rooms[data.RoomName]
+	if !ok {
+		data.Threshold = DefaultThreshold
+		return nil
+	}
+	measured, err := time.Parse(time.RFC3339, data.MeasureTime)
+	if err != nil || room.schedule == nil {
+		// * ValidateData rejects a reading without a valid measure time *
+		data.Threshold = baseThreshold(room.location.Threshold)
+		return nil
+	}
+	data.Threshold = effectiveThreshold(room.schedule, room.tz, room.location.Threshold, measured)
+	return nil
+}
+
+// EffectiveThreshold returns the threshold of a location at a time, its own threshold if it has no schedule
+func (s *ThresholdScheduler) EffectiveThreshold(location *models.Location, at time.Time, ctx context.Context) (float64, error) {
+	rooms, err := s.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	room, ok := rooms[location.Name]
+	if !ok || room.location.ID != location.ID || room.schedule == nil {
+		return baseThreshold(location.Threshold), nil
+	}
+	return effectiveThreshold(room.schedule, room.tz, location.Threshold, at), nil
+}
+
+// effectiveThreshold resolves a schedule at a time, base is the location's own threshold
+func effectiveThreshold(schedule *models.ThresholdSchedule, tz *time.Location, base float64, at time.Time) float64 {
+	local := at.In(tz)
+	date := local.Format(scheduleDateFormat)
+	for _, e := range schedule.Exceptions {
+		// * YYYY-MM-DD dates compare as strings *
+		if e.From <= date && date <= e.To {
+			if e.Threshold > 0 {
+				return e.Threshold
+			}
+			return baseThreshold(base)
+		}
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	for _, e := range schedule.Entries {
+		start, _ := parseScheduleTime(e.Start)
+		end, _ := parseScheduleTime(e.End)
+		if minute < start || minute >= end {
+			continue
+		}
+		for _, day := range e.Weekdays {
+			if weekdays[day] == local.Weekday() {
+				return e.Threshold
+			}
+		}
+	}
+	return baseThreshold(base)
+}
+
+// baseThreshold is the threshold of a location outside its schedule
+func baseThreshold(threshold float64) float64 {
+	if threshold == 0 {
+		return DefaultThreshold
+	}
+	return threshold
+}
+
+// load returns the cached locations and their schedules by location name, reading them again once they are too old
+func (s *ThresholdScheduler) load(ctx context.Context) (map[string]*roomSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && time.Since(s.loadedAt) < scheduleCacheTTL {
+		return s.cache, nil
+	}
+
+	locations, err := s.locations.GetAllLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := s.repo.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*models.ThresholdSchedule, len(schedules))
+	for _, schedule := range schedules {
+		byID[schedule.LocationID] = schedule
+	}
+
+	rooms := make(map[string]*roomSchedule)
+	for _, location := range locations {
+		room := &roomSchedule{location: location, tz: time.UTC}
+		rooms[location.Name] = room
+		schedule, ok := byID[location.ID]
+		if !ok {
+			continue
+		}
+		tz, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			s.logger.Printf("Ignoring the schedule of %s, unknown timezone %q", location.Name, schedule.Timezone)
+			continue
+		}
+		room.schedule, room.tz = schedule, tz
+	}
+	s.cache = rooms
+	s.loadedAt = time.Now()
+	return rooms, nil
+}
+
+// changed drops the cache after a schedule changed and has Run check the effective thresholds
+func (s *ThresholdScheduler) changed() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run tells the listeners about the locations whose effective threshold changed, e.g. when nap time starts,
+// until ctx is cancelled. The location they are given has the effective threshold as its threshold,
+// so the connected devices switch to it.
+func (s *ThresholdScheduler) Run(ctx context.Context, listeners ...ThresholdListener) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	sent := make(map[int64]float64)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+
+		locations, err := s.locations.GetAllLocations(ctx)
+		if err != nil {
+			s.logger.Println("Error reading locations for their schedules:", err)
+			continue
+		}
+		now := time.Now()
+		for _, location := range locations {
+			threshold, err := s.EffectiveThreshold(location, now, ctx)
+			if err != nil {
+				s.logger.Println("Error resolving threshold schedules:", err)
+				break
+			}
+			previous, ok := sent[location.ID]
+			if !ok && threshold == baseThreshold(location.Threshold) {
+				// * Nothing scheduled, the devices already have the location's own threshold *
+				continue
+			}
+			if ok && previous == threshold {
+				continue
+			}
+			sent[location.ID] = threshold
+			l := *location
+			l.Threshold = threshold
+			l.EffectiveThreshold = threshold
+			for _, listener := range listeners {
+				listener.ThresholdChanged(&l)
+			}
+		}
+	}
+}
+
+// GetSchedule returns the schedule of a location, nil if the location has none
+func (s *ThresholdScheduler) GetSchedule(locationID int64, ctx context.Context) (*models.ThresholdSchedule, error) {
+	return s.repo.Read(locationID, ctx)
+}
+
+// SetSchedule replaces the schedule of a location, false if there is no such location
+func (s *ThresholdScheduler) SetSchedule(schedule *models.ThresholdSchedule, ctx context.Context) (bool, error) {
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if schedule.Entries == nil {
+		schedule.Entries = []models.ScheduleEntry{}
+	}
+	if schedule.Exceptions == nil {
+		schedule.Exceptions = []models.ScheduleException{}
+	}
+	if err := validateSchedule(schedule); err != nil {
+		return false, err
+	}
+	location, err := findLocation(s.locations, schedule.LocationID, ctx)
+	if err != nil || location == nil {
+		return false, err
+	}
+	if err := s.repo.Replace(schedule, ctx); err != nil {
+		return false, err
+	}
+	s.changed()
+	return true, nil
+}
+
+// DeleteSchedule removes the schedule of a location, false if it had none
+func (s *ThresholdScheduler) DeleteSchedule(locationID int64, ctx context.Context) (bool, error) {
+	rows, err := s.repo.Delete(locationID, ctx)
+	if err != nil || rows == 0 {
+		return false, err
+	}
+	s.changed()
+	return true, nil
+}
+
+// AddException adds a date range to the schedule of a location, false if the location has no schedule
+func (s *ThresholdScheduler) AddException(locationID int64, exception *models.ScheduleException, ctx context.Context) (bool, error) {
+	if err := validateException(exception); err != nil {
+		return false, err
+	}
+	schedule, err := s.repo.Read(locationID, ctx)
+	if err != nil || schedule == nil {
+		return false, err
+	}
+	if err := s.repo.CreateException(locationID, exception, ctx); err != nil {
+		return false, err
+	}
+	s.changed()
+	return true, nil
+}
+
+// DeleteException removes a date range from the schedule of a location, false if there is no such exception
+func (s *ThresholdScheduler) DeleteException(locationID int64, id int, ctx context.Context) (bool, error) {
+	rows, err := s.repo.DeleteException(locationID, id, ctx)
+	if err != nil || rows == 0 {
+		return false, err
+	}
+	s.changed()
+	return true, nil
+}
+
+func validateSchedule(schedule *models.ThresholdSchedule) error {
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return DataError{Message: fmt.Sprintf("Unknown timezone %q, expected an IANA name such as Europe/Helsinki", schedule.Timezone)}
+	}
+
+	// * The entries of a weekday must not overlap, so a time has a single threshold *
+	ranges := make(map[string][][2]int)
+	for i, e := range schedule.Entries {
+		if len(e.Weekdays) == 0 {
+			return DataError{Message: fmt.Sprintf("Entry %d has no weekdays", i+1)}
+		}
+		start, err := parseScheduleTime(e.Start)
+		if err != nil || start == 24*60 {
+			return DataError{Message: fmt.Sprintf("Entry %d start must be a time from 00:00 to 23:59", i+1)}
+		}
+		end, err := parseScheduleTime(e.End)
+		if err != nil || end <= start {
+			return DataError{Message: fmt.Sprintf("Entry %d end must be a time after its start, up to 24:00", i+1)}
+		}
+		if e.Threshold <= 0 || e.Threshold > 150 {
+			return DataError{Message: fmt.Sprintf("Entry %d threshold must be between 0 and 150 dB", i+1)}
+		}
+		for j, day := range e.Weekdays {
+			day = strings.ToLower(day)
+			if _, ok := weekdays[day]; !ok {
+				return DataError{Message: fmt.Sprintf("Entry %d has an unknown weekday %q, expected monday to sunday", i+1, e.Weekdays[j])}
+			}
+			schedule.Entries[i].Weekdays[j] = day
+			for _, r := range ranges[day] {
+				if start < r[1] && r[0] < end {
+					return DataError{Message: fmt.Sprintf("Entry %d overlaps another entry on %s", i+1, day)}
+				}
+			}
+			ranges[day] = append(ranges[day], [2]int{start, end})
+		}
+	}
+
+	for i := range schedule.Exceptions {
+		if err := validateException(&schedule.Exceptions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateException(e *models.ScheduleException) error {
+	from, err := time.Parse(scheduleDateFormat, e.From)
+	if err != nil {
+		return DataError{Message: "Exception from must be a date, YYYY-MM-DD"}
+	}
+	if e.To == "" {
+		e.To = e.From
+	}
+	to, err := time.Parse(scheduleDateFormat, e.To)
+	if err != nil || to.Before(from) {
+		return DataError{Message: "Exception to must be a date, YYYY-MM-DD, not before from"}
+	}
+	if e.Threshold < 0 || e.Threshold > 150 {
+		return DataError{Message: "Exception threshold must be between 0 and 150 dB"}
+	}
+	return nil
+}
+
+// parseScheduleTime returns the minutes since midnight of HH:MM, 24:00 is the end of the day
+func parseScheduleTime(v string) (int, error) {
+	h, m, ok := strings.Cut(v, ":")
+	if !ok || len(h) != 2 || len(m) != 2 {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, err
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil {
+		return 0, err
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"log"
+	"testing"
+	"time"
+)
+
+func napSchedule() *models.ThresholdSchedule {
+	return &models.ThresholdSchedule{
+		LocationID: 1,
+		Timezone:   "Europe/Helsinki",
+		Entries: []models.ScheduleEntry{
+			{Label: "Nap time", Weekdays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"}, Start: "12:00", End: "14:00", Threshold: 45},
+			{Label: "Music", Weekdays: []string{"Friday"}, Start: "14:00", End: "15:30", Threshold: 85},
+		},
+		Exceptions: []models.ScheduleException{
+			{Label: "Midsummer", From: "2024-06-21", To: "2024-06-21"},
+			{Label: "Spring party", From: "2024-05-17", Threshold: 90},
+		},
+	}
+}
+
+func TestEffectiveThreshold(t *testing.T) {
+	schedule := napSchedule()
+	if err := validateSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	tz, _ := time.LoadLocation(schedule.Timezone)
+
+	for _, tc := range []struct {
+		at   string
+		base float64
+		want float64
+	}{
+		// * Helsinki is UTC+3 in summer *
+		{"2024-06-03T09:00:00Z", 60, 45}, // Monday 12:00
+		{"2024-06-03T10:59:00Z", 60, 45}, // Monday 13:59
+		{"2024-06-03T11:00:00Z", 60, 60}, // Monday 14:00, the end is excluded
+		{"2024-06-03T08:59:00Z", 0, 70},  // Monday 11:59, the default threshold
+		{"2024-06-07T11:30:00Z", 60, 85}, // Friday 14:30
+		{"2024-06-08T09:00:00Z", 60, 60}, // Saturday 12:00
+		{"2024-06-21T09:00:00Z", 60, 60}, // Midsummer, the location's own threshold all day
+		{"2024-05-17T20:59:00Z", 60, 90}, // Spring party, 23:59 local
+		{"2024-05-17T21:00:00Z", 60, 60}, // The next day locally, a Saturday
+		{"2024-01-08T10:00:00Z", 60, 45}, // Monday 12:00 in winter, UTC+2
+		{"2024-06-02T21:30:00Z", 60, 60}, // Monday 00:30 locally, Sunday in UTC
+	} {
+		at, _ := time.Parse(time.RFC3339, tc.at)
+		if got := effectiveThreshold(schedule, tz, tc.base, at); got != tc.want {
+			t.Errorf("%s: got %v want %v", tc.at, got, tc.want)
+		}
+	}
+}
+
+// * memorySchedules serves fixed schedules and locations *
+type memorySchedules struct {
+	models.ScheduleRepository
+	models.LocationRepository
+	schedules []*models.ThresholdSchedule
+	locations []*models.Location
+}
+
+func (m *memorySchedules) ReadAll(ctx context.Context) ([]*models.ThresholdSchedule, error) {
+	return m.schedules, nil
+}
+
+func (m *memorySchedules) GetAllLocations(ctx context.Context) ([]*models.Location, error) {
+	return m.locations, nil
+}
+
+func TestResolveThreshold(t *testing.T) {
+	repo := &memorySchedules{
+		schedules: []*models.ThresholdSchedule{napSchedule()},
+		locations: []*models.Location{
+			{ID: 1, Name: "Office", Threshold: 60},
+			{ID: 2, Name: "RoomA", Threshold: 55},
+			{ID: 3, Name: "RoomB"},
+		},
+	}
+	scheduler := NewThresholdScheduler(repo, repo, log.Default())
+
+	for _, tc := range []struct {
+		room        string
+		threshold   float64
+		want        float64
+		unscheduled float64 // Without the schedules
+	}{
+		{"Office", 80, 80, 80}, // The device's own threshold
+		{"Office", 0, 45, 60},  // Nap time in the schedule
+		{"RoomA", 0, 55, 55},   // The location's threshold
+		{"RoomB", 0, 70, 70},   // A location without a threshold
+		{"Kitchen", 0, 70, 70}, // No such location
+	} {
+		for _, schedules := range []ScheduleService{scheduler, nil} {
+			data := &models.Data{RoomName: tc.room, Threshold: tc.threshold, MeasureTime: "2024-06-03T09:00:00Z"}
+			if err := resolveThreshold(schedules, repo, data, context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want
+			if schedules == nil {
+				want = tc.unscheduled
+			}
+			if data.Threshold != want {
+				t.Errorf("%s with %v, schedules %v: got %v want %v", tc.room, tc.threshold, schedules != nil, data.Threshold, want)
+			}
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	for name, change := range map[string]func(s *models.ThresholdSchedule){
+		"unknown timezone":   func(s *models.ThresholdSchedule) { s.Timezone = "Mars/Olympus" },
+		"unknown weekday":    func(s *models.ThresholdSchedule) { s.Entries[0].Weekdays = []string{"funday"} },
+		"no weekdays":        func(s *models.ThresholdSchedule) { s.Entries[0].Weekdays = nil },
+		"end before start":   func(s *models.ThresholdSchedule) { s.Entries[0].End = "11:00" },
+		"invalid time":       func(s *models.ThresholdSchedule) { s.Entries[0].Start = "12:60" },
+		"start at 24:00":     func(s *models.ThresholdSchedule) { s.Entries[0].Start = "24:00" },
+		"threshold too high": func(s *models.ThresholdSchedule) { s.Entries[0].Threshold = 151 },
+		"no threshold":       func(s *models.ThresholdSchedule) { s.Entries[0].Threshold = 0 },
+		"overlap":            func(s *models.ThresholdSchedule) { s.Entries[1].Start = "13:30" },
+		"invalid date":       func(s *models.ThresholdSchedule) { s.Exceptions[0].From = "21.6.2024" },
+		"to before from":     func(s *models.ThresholdSchedule) { s.Exceptions[0].To = "2024-06-20" },
+		"negative exception": func(s *models.ThresholdSchedule) { s.Exceptions[0].Threshold = -1 },
+	} {
+		schedule := napSchedule()
+		change(schedule)
+		if err := validateSchedule(schedule); err == nil {
+			t.Errorf("%s: the schedule should be rejected", name)
+		}
+	}
+
+	// * Entries may end at midnight and touch each other *
+	schedule := napSchedule()
+	schedule.Entries[1].End = "24:00"
+	if err := validateSchedule(schedule); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if schedule.Entries[1].Weekdays[0] != "friday" || schedule.Exceptions[1].To != "2024-05-17" {
+		t.Errorf("weekdays should be lower case and a single day exception should end on its start: %+v", schedule)
+	}
+}
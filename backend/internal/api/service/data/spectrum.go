@@ -0,0 +1,132 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"sort"
+	"time"
+)
+
+// Spectrum is the energy average of the octave band levels of some readings
+type Spectrum struct {
+	Start    string    `json:"start,omitempty"` // Start of the bucket, empty for the whole period
+	Readings int       `json:"readings"`        // Readings that carried band levels
+	Levels   []float64 `json:"levels"`          // Band levels in dB in the order of Frequencies, empty without readings
+}
+
+// RoomSpectrum is the averaged spectrum of a room over a period, as a whole and per bucket
+type RoomSpectrum struct {
+	RoomName    string     `json:"room_name"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	Bucket      string     `json:"bucket,omitempty"` // hour, day or empty for no buckets
+	Frequencies []float64  `json:"frequencies"`      // Band center frequencies in Hz
+	Spectrum               // Of the whole period
+	Buckets     []Spectrum `json:"buckets,omitempty"`
+}
+
+// validateBucket checks the bucket a spectrum can be split in
+func validateBucket(bucket string) error {
+	switch bucket {
+	case "", "hour", "day":
+		return nil
+	}
+	return DataError{Message: "The bucket must be hour or day."}
+}
+
+// getSpectrum reads the readings of the room in the period and averages their band levels
+func getSpectrum(repo models.DataRepository, roomName string, from, to time.Time, bucket string, ctx context.Context) (*RoomSpectrum, error) {
+	if roomName == "" {
+		return nil, DataError{Message: "Room name is required"}
+	}
+	if err := validatePeriod(from, to); err != nil {
+		return nil, err
+	}
+	if err := validateBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.GetByRange(roomName, from, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return computeSpectrum(rows, roomName, from, to, bucket), nil
+}
+
+// computeSpectrum energy averages the band levels of the readings, readings without bands are skipped.
+// Buckets start at whole hours or midnight in the time zone of from, only buckets with readings are listed.
+func computeSpectrum(rows []*models.Data, roomName string, from, to time.Time, bucket string) *RoomSpectrum {
+	spectrum := &RoomSpectrum{
+		RoomName:    roomName,
+		From:        from.Format(time.RFC3339),
+		To:          to.Format(time.RFC3339),
+		Bucket:      bucket,
+		Frequencies: models.BandFrequencies[:],
+	}
+
+	var total bandEnergy
+	buckets := make(map[time.Time]*bandEnergy)
+	for _, row := range rows {
+		if len(row.Bands) != len(models.BandFrequencies) {
+			continue
+		}
+		total.add(row.Bands)
+		if bucket == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, row.MeasureTime)
+		if err != nil {
+			continue
+		}
+		start := bucketStart(t.In(from.Location()), bucket)
+		if buckets[start] == nil {
+			buckets[start] = &bandEnergy{}
+		}
+		buckets[start].add(row.Bands)
+	}
+	spectrum.Spectrum = total.spectrum()
+
+	starts := make([]time.Time, 0, len(buckets))
+	for start := range buckets {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for _, start := range starts {
+		s := buckets[start].spectrum()
+		s.Start = start.Format(time.RFC3339)
+		spectrum.Buckets = append(spectrum.Buckets, s)
+	}
+	return spectrum
+}
+
+// bucketStart returns the start of the hour or day of t, in t's time zone
+func bucketStart(t time.Time, bucket string) time.Time {
+	if bucket == "day" {
+		return startOfDay(t)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// bandEnergy sums the relative energy of band levels
+type bandEnergy struct {
+	energy   [len(models.BandFrequencies)]float64
+	readings int
+}
+
+func (b *bandEnergy) add(levels []float64) {
+	for i, level := range levels {
+		b.energy[i] += toEnergy(level)
+	}
+	b.readings++
+}
+
+func (b *bandEnergy) spectrum() Spectrum {
+	s := Spectrum{Readings: b.readings, Levels: []float64{}}
+	if b.readings == 0 {
+		return s
+	}
+	for _, energy := range b.energy {
+		s.Levels = append(s.Levels, roundLevel(fromEnergy(energy/float64(b.readings))))
+	}
+	return s
+}
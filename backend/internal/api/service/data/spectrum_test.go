@@ -0,0 +1,52 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"testing"
+	"time"
+)
+
+func TestComputeSpectrumEnergyAverage(t *testing.T) {
+	// * Hum at 63 Hz in the morning, voices at 1 kHz in the afternoon, a reading without bands *
+	from := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	hum := []float64{70, 60, 50, 40, 40, 40, 40, 40}
+	voices := []float64{40, 40, 50, 60, 70, 60, 50, 40}
+	rows := []*models.Data{
+		{DeviceID: "arduino_001", MeasureTime: from.Add(9 * time.Hour).Format(time.RFC3339), Bands: hum},
+		{DeviceID: "arduino_001", MeasureTime: from.Add(14 * time.Hour).Format(time.RFC3339), Bands: voices},
+		{DeviceID: "arduino_001", MeasureTime: from.Add(15 * time.Hour).Format(time.RFC3339), SoundLevel: 80},
+	}
+
+	s := computeSpectrum(rows, "PlayRoom_A", from, from.AddDate(0, 0, 1), "hour")
+	if s.Readings != 2 || len(s.Levels) != len(models.BandFrequencies) || len(s.Frequencies) != len(models.BandFrequencies) {
+		t.Fatalf("unexpected spectrum: %+v", s)
+	}
+	// * Equal energy of 70 and 40 dB averages to about 3 dB below 70 *
+	if s.Levels[0] != 66.99 || s.Levels[4] != 66.99 || s.Levels[2] != 50 {
+		t.Errorf("levels: got %v", s.Levels)
+	}
+	if len(s.Buckets) != 2 || s.Buckets[0].Start != "2024-06-10T09:00:00Z" || s.Buckets[1].Levels[4] != 70 {
+		t.Errorf("unexpected buckets: %+v", s.Buckets)
+	}
+}
+
+func TestComputeSpectrumWithoutBands(t *testing.T) {
+	from := time.Date(2024, 6, 10, 0, 0, 0, 0, time.FixedZone("", 2*3600))
+	rows := []*models.Data{{DeviceID: "arduino_001", MeasureTime: from.Format(time.RFC3339), SoundLevel: 60}}
+
+	s := computeSpectrum(rows, "PlayRoom_A", from, from.AddDate(0, 0, 1), "day")
+	if s.Readings != 0 || len(s.Levels) != 0 || s.Buckets != nil {
+		t.Errorf("unexpected spectrum: %+v", s)
+	}
+}
+
+func TestValidateBucket(t *testing.T) {
+	for _, bucket := range []string{"", "hour", "day"} {
+		if err := validateBucket(bucket); err != nil {
+			t.Errorf("%q: unexpected error %v", bucket, err)
+		}
+	}
+	if err := validateBucket("week"); err == nil {
+		t.Error("week should be rejected")
+	}
+}
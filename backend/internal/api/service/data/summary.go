@@ -0,0 +1,197 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultSampleInterval is how long a reading counts for when a device sent only one that day
+var DefaultSampleInterval = time.Minute
+
+// LevelStats are the statistics of the periodic readings of a period.
+// Every reading counts for the time since the device's previous one, so the levels are
+// averaged through their energy over time and the percentiles are time percentiles:
+// L10 is the level exceeded 10% of the time, L90 the background level exceeded 90% of the time.
+type LevelStats struct {
+	Samples               int     `json:"samples"`
+	LAeq                  float64 `json:"laeq"`
+	LMax                  float64 `json:"lmax"`
+	LMin                  float64 `json:"lmin"`
+	L10                   float64 `json:"l10"`
+	L50                   float64 `json:"l50"`
+	L90                   float64 `json:"l90"`
+	MinutesAboveThreshold float64 `json:"minutes_above_threshold"`
+	Alerts                int     `json:"alerts"`
+}
+
+// HourlyStats are the statistics of one hour of the day
+type HourlyStats struct {
+	Hour string `json:"hour"` // Start of the hour in the time zone of the requested date
+	LevelStats
+}
+
+// DailyStats are the statistics of a room for a whole day and per hour.
+// Hours without readings are left out.
+type DailyStats struct {
+	RoomName string `json:"room_name"`
+	Date     string `json:"date"`
+	LevelStats
+	Hours []HourlyStats `json:"hours"`
+}
+
+// levelSample is a reading prepared for the statistics
+type levelSample struct {
+	deviceID  string
+	time      time.Time
+	level     float64
+	max       float64
+	min       float64
+	threshold float64
+	alert     bool
+	duration  time.Duration
+}
+
+// computeDailyStats summarizes the readings of a room for the day of date,
+// the hours are taken in date's time zone
+func computeDailyStats(rows []*models.Data, roomName string, date time.Time) *DailyStats {
+	samples := toSamples(rows)
+
+	stats := &DailyStats{
+		RoomName:   roomName,
+		Date:       date.Format(time.DateOnly),
+		LevelStats: levelStats(samples),
+		Hours:      []HourlyStats{},
+	}
+
+	// * Samples are sorted by time, so the hours come in order *
+	location := date.Location()
+	var hour []levelSample
+	var start time.Time
+	for _, s := range samples {
+		t := s.time.In(location)
+		bucket := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, location)
+		if len(hour) > 0 && !bucket.Equal(start) {
+			stats.Hours = append(stats.Hours, HourlyStats{Hour: start.Format(time.RFC3339), LevelStats: levelStats(hour)})
+			hour = nil
+		}
+		start = bucket
+		hour = append(hour, s)
+	}
+	if len(hour) > 0 {
+		stats.Hours = append(stats.Hours, HourlyStats{Hour: start.Format(time.RFC3339), LevelStats: levelStats(hour)})
+	}
+
+	return stats
+}
+
+// toSamples converts the readings, sorted by time, and works out how long each one counts for
+func toSamples(rows []*models.Data) []levelSample {
+	samples := make([]levelSample, 0, len(rows))
+	for _, row := range rows {
+		t, err := time.Parse(time.RFC3339, row.MeasureTime)
+		if err != nil {
+			continue
+		}
+		s := levelSample{
+			deviceID:  row.DeviceID,
+			time:      t,
+			level:     row.SoundLevel,
+			max:       row.SoundLevel,
+			min:       row.SoundLevel,
+			threshold: row.Threshold,
+			alert:     row.IsAlert,
+		}
+		// * Rows computed by the server carry the loudest and quietest sample of their window *
+		if row.MaxLevel > 0 {
+			s.max = row.MaxLevel
+		}
+		if row.MinLevel > 0 {
+			s.min = row.MinLevel
+		}
+		samples = append(samples, s)
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].time.Before(samples[j].time) })
+
+	// * A reading counts for the gap to the device's previous one, at most twice its usual interval,
+	// so a device that was offline doesn't stretch its last reading over the outage *
+	byDevice := make(map[string][]int)
+	for i, s := range samples {
+		byDevice[s.deviceID] = append(byDevice[s.deviceID], i)
+	}
+	for _, indexes := range byDevice {
+		gaps := make([]time.Duration, 0, len(indexes))
+		for k := 1; k < len(indexes); k++ {
+			gaps = append(gaps, samples[indexes[k]].time.Sub(samples[indexes[k-1]].time))
+		}
+		interval := medianDuration(gaps)
+		for k, i := range indexes {
+			if k == 0 {
+				samples[i].duration = interval
+			} else {
+				samples[i].duration = min(gaps[k-1], 2*interval)
+			}
+		}
+	}
+
+	return samples
+}
+
+// medianDuration returns the median of the gaps, DefaultSampleInterval if there are none
+func medianDuration(gaps []time.Duration) time.Duration {
+	if len(gaps) == 0 {
+		return DefaultSampleInterval
+	}
+	sorted := append([]time.Duration(nil), gaps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+func levelStats(samples []levelSample) LevelStats {
+	if len(samples) == 0 {
+		return LevelStats{}
+	}
+
+	stats := LevelStats{
+		Samples: len(samples),
+		LMax:    math.Inf(-1),
+		LMin:    math.Inf(1),
+	}
+	var energy, total, above float64
+	for _, s := range samples {
+		weight := s.duration.Seconds()
+		energy += toEnergy(s.level) * weight
+		total += weight
+		if s.threshold > 0 && s.level > s.threshold {
+			above += weight
+		}
+		if s.alert {
+			stats.Alerts++
+		}
+		stats.LMax = math.Max(stats.LMax, s.max)
+		stats.LMin = math.Min(stats.LMin, s.min)
+	}
+
+	loudestFirst := append([]levelSample(nil), samples...)
+	sort.SliceStable(loudestFirst, func(i, j int) bool { return loudestFirst[i].level > loudestFirst[j].level })
+
+	stats.LAeq = roundLevel(fromEnergy(energy / total))
+	stats.L10 = roundLevel(exceeded(loudestFirst, total, 0.10))
+	stats.L50 = roundLevel(exceeded(loudestFirst, total, 0.50))
+	stats.L90 = roundLevel(exceeded(loudestFirst, total, 0.90))
+	stats.MinutesAboveThreshold = math.Round(above/60*10) / 10
+	return stats
+}
+
+// exceeded returns the level exceeded for the given fraction of the time
+func exceeded(loudestFirst []levelSample, total float64, fraction float64) float64 {
+	var elapsed float64
+	for _, s := range loudestFirst {
+		elapsed += s.duration.Seconds()
+		if elapsed >= fraction*total {
+			return s.level
+		}
+	}
+	return loudestFirst[len(loudestFirst)-1].level
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func reading(deviceID, measureTime string, level float64, alert bool) *models.Data {
+	return &models.Data{DeviceID: deviceID, RoomName: "PlayRoom_A", SoundLevel: level, Threshold: 70, MeasureTime: measureTime, IsAlert: alert, IsPeriodic: true}
+}
+
+func TestDailyStatsEnergyAverage(t *testing.T) {
+	// * Two equally long readings 10 dB apart: the energy average is much closer to the loud one *
+	rows := []*models.Data{
+		reading("arduino_001", "2024-06-01T09:00:00Z", 60, false),
+		reading("arduino_001", "2024-06-01T09:01:00Z", 80, true),
+	}
+	stats := computeDailyStats(rows, "PlayRoom_A", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	want := 10 * math.Log10((math.Pow(10, 6)+math.Pow(10, 8))/2)
+	if math.Abs(stats.LAeq-want) > 0.01 {
+		t.Errorf("LAeq: got %v want %.2f", stats.LAeq, want)
+	}
+	if stats.LMax != 80 || stats.LMin != 60 || stats.Alerts != 1 || stats.Samples != 2 {
+		t.Errorf("unexpected stats: %+v", stats.LevelStats)
+	}
+	if stats.MinutesAboveThreshold != 1 {
+		t.Errorf("minutes above threshold: got %v want 1", stats.MinutesAboveThreshold)
+	}
+}
+
+func TestDailyStatsPercentilesAndHours(t *testing.T) {
+	// * One reading a minute: twelve minutes at 50 dB, then eight getting louder from 70 dB *
+	var rows []*models.Data
+	start := time.Date(2024, 6, 1, 8, 50, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		level := 50.0
+		if i >= 12 {
+			level = 70.0 + float64(i-12)
+		}
+		rows = append(rows, reading("arduino_001", start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339), level, false))
+	}
+	// * A server computed row carries its window's extremes *
+	rows[0].MinLevel = 40
+
+	// * Hours in UTC+2 *
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.FixedZone("", 2*3600))
+	stats := computeDailyStats(rows, "PlayRoom_A", date)
+
+	if stats.L90 != 50 || stats.L50 != 50 || stats.L10 != 76 {
+		t.Errorf("percentiles: got L10 %v L50 %v L90 %v", stats.L10, stats.L50, stats.L90)
+	}
+	if stats.LMin != 40 || stats.LMax != 77 {
+		t.Errorf("extremes: got %v %v", stats.LMin, stats.LMax)
+	}
+	if stats.MinutesAboveThreshold != 7 {
+		t.Errorf("minutes above threshold: got %v want 7", stats.MinutesAboveThreshold)
+	}
+
+	if len(stats.Hours) != 2 {
+		t.Fatalf("expected 2 hours, got %d", len(stats.Hours))
+	}
+	if stats.Hours[0].Hour != "2024-06-01T10:00:00+02:00" || stats.Hours[0].Samples != 10 || stats.Hours[0].LAeq != 50 {
+		t.Errorf("unexpected first hour: %+v", stats.Hours[0])
+	}
+	if stats.Hours[1].Hour != "2024-06-01T11:00:00+02:00" || stats.Hours[1].LMin != 50 || stats.Hours[1].LMax != 77 {
+		t.Errorf("unexpected second hour: %+v", stats.Hours[1])
+	}
+}
+
+func TestDailyStatsOfflineGap(t *testing.T) {
+	// * After a two hour outage the reading counts for twice the usual interval, not two hours *
+	rows := []*models.Data{
+		reading("arduino_001", "2024-06-01T09:00:00Z", 60, false),
+		reading("arduino_001", "2024-06-01T09:01:00Z", 60, false),
+		reading("arduino_001", "2024-06-01T09:02:00Z", 60, false),
+		reading("arduino_001", "2024-06-01T11:02:00Z", 90, false),
+	}
+	stats := computeDailyStats(rows, "PlayRoom_A", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	if stats.MinutesAboveThreshold != 2 {
+		t.Errorf("minutes above threshold: got %v want 2", stats.MinutesAboveThreshold)
+	}
+}
@@ -0,0 +1,28 @@
+package data
+
+import (
+	"goapi/internal/api/repository/models"
+	"math"
+	"testing"
+)
+
+func TestValidateDataRejectsNonFiniteLevels(t *testing.T) {
+	services := map[string]DataService{"SQLite": &DataServiceSQLite{}, "PostgreSQL": &DataServicePostgreSQL{}}
+	for name, ds := range services {
+		for _, change := range []func(d *models.Data){
+			func(d *models.Data) { d.SoundLevel = math.NaN() },
+			func(d *models.Data) { d.SoundLevel = math.Inf(1) },
+			func(d *models.Data) { d.Threshold = math.NaN() },
+			func(d *models.Data) { d.Bands = []float64{50, 50, 50, math.NaN(), 50, 50, 50, 50} },
+		} {
+			data := &models.Data{DeviceID: "arduino_001", RoomName: "Room1", SoundLevel: 60, Threshold: 70, MeasureTime: "2024-06-03T09:00:00Z"}
+			if err := ds.ValidateData(data); err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			change(data)
+			if err := ds.ValidateData(data); err == nil {
+				t.Errorf("%s: %+v should be rejected", name, data)
+			}
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deliveryTimeFormat has a fixed width, so the times of deliveries compare as strings
+const deliveryTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+const (
+	// DefaultWebhookAttempts is how often a delivery is tried before it is dead
+	DefaultWebhookAttempts = 10
+
+	// MaxDeliveries is the most deliveries returned at once
+	MaxDeliveries = 500
+
+	webhookBatchSize    = 50
+	webhookPollInterval = 5 * time.Second
+	deliveryRetention   = 30 * 24 * time.Hour
+)
+
+// Headers of webhook requests
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // sha256= and the hex HMAC-SHA256 of the body with the webhook's secret
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery" // ID of the delivery, the same for every attempt
+)
+
+type WebhookConfig struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// WebhookConfigFromEnv reads how often deliveries are tried from WEBHOOK_MAX_ATTEMPTS (default 10),
+// the retries back off from 10 seconds to an hour
+func WebhookConfigFromEnv() (WebhookConfig, error) {
+	cfg := WebhookConfig{
+		MaxAttempts: DefaultWebhookAttempts,
+		MinBackoff:  10 * time.Second,
+		MaxBackoff:  time.Hour,
+	}
+	if v := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 1 {
+			return cfg, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS %q, expected a positive number", v)
+		}
+		cfg.MaxAttempts = attempts
+	}
+	return cfg, nil
+}
+
+// WebhookDispatcher sends alert events to the webhooks.
+// It is a Publisher for the alerting readings and an IncidentListener for the incidents.
+// Events are queued in the database first, so they survive receiver outages and restarts,
+// failed deliveries are retried with exponential backoff until they are dead.
+type WebhookDispatcher struct {
+	repo   models.WebhookRepository
+	rules  RuleService // Severities of the alerts
+	cfg    WebhookConfig
+	client *http.Client
+	logger *log.Logger
+	wake   chan struct{}
+
+	mu       sync.Mutex
+	webhooks []*models.Webhook // Cached, nil until loaded
+}
+
+func NewWebhookDispatcher(repo models.WebhookRepository, rules RuleService, cfg WebhookConfig, logger *log.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:   repo,
+		rules:  rules,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Publish queues an alerting reading for the webhooks
+func (d *WebhookDispatcher) Publish(data *models.Data) {
+	if !data.IsAlert {
+		return
+	}
+	reading := *data
+	d.enqueue(&models.WebhookEvent{
+		Event:    models.EventAlert,
+		RoomName: data.RoomName,
+		Reading:  &reading,
+	}, data.AlertRuleID)
+}
+
+// IncidentChanged queues an incident that was opened, acknowledged or resolved for the webhooks
+func (d *WebhookDispatcher) IncidentChanged(incident *models.Incident) {
+	event := models.EventIncidentOpened
+	switch incident.State {
+	case models.IncidentAcknowledged:
+		event = models.EventIncidentAcknowledged
+	case models.IncidentResolved:
+		event = models.EventIncidentResolved
+	}
+	i := *incident
+	d.enqueue(&models.WebhookEvent{
+		Event:    event,
+		RoomName: incident.RoomName,
+		Incident: &i,
+	}, incident.AlertRuleID)
+}
+
+// severity returns the severity of the rule that raised an alert, warning for the threshold of the room
+func (d *WebhookDispatcher) severity(ruleID int, ctx context.Context) string {
+	if ruleID == 0 || d.rules == nil {
+		return defaultRuleSeverity
+	}
+	rule, err := d.rules.GetRule(ruleID, ctx)
+	if err != nil || rule == nil {
+		return defaultRuleSeverity
+	}
+	return rule.Severity
+}
+
+// enqueue adds a delivery of the event for every webhook it matches, ruleID raised the alert of the event
+func (d *WebhookDispatcher) enqueue(event *models.WebhookEvent, ruleID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	webhooks, err := d.load(ctx)
+	if err != nil {
+		d.logger.Println("Error reading webhooks:", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	event.Severity = d.severity(ruleID, ctx)
+
+	now := time.Now().UTC()
+	event.CreatedAt = now.Format(time.RFC3339)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Println("Error encoding webhook event:", err)
+		return
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		if webhookMatches(webhook, event) && d.queue(webhook, event, payload, now, ctx) {
+			queued = true
+		}
+	}
+	if queued {
+		d.wakeUp()
+	}
+}
+
+// DigestReady queues a digest for the webhooks listing the digest event,
+// a webhook of a room is sent the part of the digest about its room
+func (d *WebhookDispatcher) DigestReady(digest *models.Digest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	webhooks, err := d.load(ctx)
+	if err != nil {
+		d.logger.Println("Error reading webhooks:", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	queued := false
+	for _, webhook := range webhooks {
+		if webhook.Disabled || !slices.Contains(webhook.Events, models.EventDigest) {
+			continue
+		}
+		dg := *digest
+		if webhook.RoomName != "" {
+			dg.Locations = []models.LocationDigest{}
+			for _, location := range digest.Locations {
+				if location.RoomName == webhook.RoomName {
+					dg.Locations = append(dg.Locations, location)
+				}
+			}
+			if len(dg.Locations) == 0 {
+				continue
+			}
+		}
+		event := &models.WebhookEvent{
+			Event:     models.EventDigest,
+			RoomName:  webhook.RoomName,
+			CreatedAt: now.Format(time.RFC3339),
+			Digest:    &dg,
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			d.logger.Println("Error encoding webhook event:", err)
+			return
+		}
+		if d.queue(webhook, event, payload, now, ctx) {
+			queued = true
+		}
+	}
+	if queued {
+		d.wakeUp()
+	}
+}
+
+// queue adds a delivery of an event for a webhook, false if it could not be stored
+func (d *WebhookDispatcher) queue(webhook *models.Webhook, event *models.WebhookEvent, payload []byte, now time.Time, ctx context.Context) bool {
+	delivery := &models.WebhookDelivery{
+		WebhookID:   webhook.ID,
+		Event:       event.Event,
+		Payload:     string(payload),
+		Status:      models.DeliveryPending,
+		NextAttempt: now.Format(deliveryTimeFormat),
+		CreatedAt:   now.Format(deliveryTimeFormat),
+	}
+	if err := d.repo.Enqueue(delivery, ctx); err != nil {
+		d.logger.Printf("Error queueing %s of %s for webhook %d: %v", event.Event, event.RoomName, webhook.ID, err)
+		return false
+	}
+	return true
+}
+
+// wakeUp has Run send the queued deliveries now
+func (d *WebhookDispatcher) wakeUp() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// webhookMatches reports whether an event passes the filters of an enabled webhook
+func webhookMatches(webhook *models.Webhook, event *models.WebhookEvent) bool {
+	if webhook.Disabled {
+		return false
+	}
+	if webhook.RoomName != "" && webhook.RoomName != event.RoomName {
+		return false
+	}
+	if webhook.MinSeverity != "" && models.SeverityRank(event.Severity) < models.SeverityRank(webhook.MinSeverity) {
+		return false
+	}
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, e := range webhook.Events {
+		if e == event.Event {
+			return true
+		}
+	}
+	return false
+}
+
+// Run sends the queued deliveries until ctx is cancelled, old delivered and dead ones are pruned daily
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	poll := time.NewTicker(webhookPollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(24 * time.Hour)
+	defer prune.Stop()
+
+	for {
+		d.deliver(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-poll.C:
+		case <-prune.C:
+			before := time.Now().UTC().Add(-deliveryRetention).Format(deliveryTimeFormat)
+			if _, err := d.repo.PruneDeliveries(before, ctx); err != nil {
+				d.logger.Println("Error pruning webhook deliveries:", err)
+			}
+		}
+	}
+}
+
+// deliver sends the due deliveries, a failed one is tried again later or dead after the last attempt
+func (d *WebhookDispatcher) deliver(ctx context.Context) {
+	for ctx.Err() == nil {
+		webhooks, err := d.load(ctx)
+		if err != nil {
+			d.logger.Println("Error reading webhooks:", err)
+			return
+		}
+		byID := make(map[int]*models.Webhook, len(webhooks))
+		for _, webhook := range webhooks {
+			byID[webhook.ID] = webhook
+		}
+
+		now := time.Now().UTC()
+		deliveries, err := d.repo.GetDue(now.Format(deliveryTimeFormat), webhookBatchSize, ctx)
+		if err != nil {
+			d.logger.Println("Error reading webhook deliveries:", err)
+			return
+		}
+
+		for _, delivery := range deliveries {
+			webhook := byID[delivery.WebhookID]
+			if webhook == nil || webhook.Disabled {
+				delivery.Status = models.DeliveryDead
+				delivery.LastError = "The webhook is disabled."
+			} else if status, err := d.send(ctx, webhook, delivery); err == nil {
+				delivery.Attempts++
+				delivery.Status = models.DeliveryDelivered
+				delivery.ResponseStatus = status
+				delivery.DeliveredAt = time.Now().UTC().Format(deliveryTimeFormat)
+				delivery.LastError = ""
+			} else {
+				delivery.Attempts++
+				delivery.ResponseStatus = status
+				delivery.LastError = err.Error()
+				if delivery.Attempts >= d.cfg.MaxAttempts {
+					d.logger.Printf("Webhook %d delivery %d is dead after %d attempts: %v", webhook.ID, delivery.ID, delivery.Attempts, err)
+					delivery.Status = models.DeliveryDead
+				} else {
+					delivery.NextAttempt = now.Add(retryBackoff(delivery.Attempts, d.cfg.MinBackoff, d.cfg.MaxBackoff)).Format(deliveryTimeFormat)
+				}
+			}
+
+			if err := d.repo.UpdateDelivery(delivery, ctx); err != nil {
+				d.logger.Println("Error updating webhook delivery:", err)
+				return
+			}
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// send posts a delivery to its webhook, returning the status of the response (0 without one)
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.Secret, []byte(delivery.Payload)))
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header of a payload, receivers compute it the same way to verify it
+func SignWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryBackoff doubles the delay with every attempt, with jitter so retries don't come in bursts
+func retryBackoff(attempts int, min, max time.Duration) time.Duration {
+	d := time.Duration(float64(min) * math.Pow(2, float64(attempts-1)))
+	if d > max || d <= 0 {
+		d = max
+	}
+	return d/2 + time.Duration(mrand.Int63n(int64(d/2)+1))
+}
+
+// load returns the cached webhooks, reading them if a webhook changed
+func (d *WebhookDispatcher) load(ctx context.Context) ([]*models.Webhook, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.webhooks == nil {
+		webhooks, err := d.repo.ReadAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.webhooks = webhooks
+	}
+	return d.webhooks, nil
+}
+
+func (d *WebhookDispatcher) CreateWebhook(webhook *models.Webhook, ctx context.Context) error {
+	if webhook.Secret == "" {
+		webhook.Secret = newWebhookSecret()
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+	webhook.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.repo.Create(webhook, ctx); err != nil {
+		return err
+	}
+	d.webhooks = nil
+	return nil
+}
+
+// GetWebhooks returns the webhooks without their secrets
+func (d *WebhookDispatcher) GetWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	webhooks, err := d.repo.ReadAll(ctx)
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	return webhooks, err
+}
+
+// GetWebhook returns the webhook without its secret
+func (d *WebhookDispatcher) GetWebhook(id int, ctx context.Context) (*models.Webhook, error) {
+	webhook, err := d.repo.ReadOne(id, ctx)
+	if webhook != nil {
+		webhook.Secret = ""
+	}
+	return webhook, err
+}
+
+// UpdateWebhook replaces a webhook, false if there is no such webhook.
+// The secret is kept unless a new one is set, it is not returned either way.
+func (d *WebhookDispatcher) UpdateWebhook(webhook *models.Webhook, ctx context.Context) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stored, err := d.repo.ReadOne(webhook.ID, ctx)
+	if err != nil || stored == nil {
+		return false, err
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = stored.Secret
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return false, err
+	}
+	webhook.CreatedAt = stored.CreatedAt
+
+	n, err := d.repo.Update(webhook, ctx)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	d.webhooks = nil
+	webhook.Secret = ""
+	return true, nil
+}
+
+// DeleteWebhook removes a webhook and its deliveries, false if there is no such webhook
+func (d *WebhookDispatcher) DeleteWebhook(id int, ctx context.Context) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n, err := d.repo.Delete(id, ctx)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	d.webhooks = nil
+	return true, nil
+}
+
+// GetDeliveries returns the newest deliveries of a webhook, in one status if set
+func (d *WebhookDispatcher) GetDeliveries(webhookID int, status string, limit int, ctx context.Context) ([]*models.WebhookDelivery, error) {
+	if err := validateDeliveryQuery(status, limit); err != nil {
+		return nil, err
+	}
+	webhook, err := d.repo.ReadOne(webhookID, ctx)
+	if err != nil || webhook == nil {
+		return nil, err
+	}
+	return d.repo.GetDeliveries(webhookID, status, limit, ctx)
+}
+
+// validateWebhook checks where a webhook sends to and its filters
+func validateWebhook(webhook *models.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return DataError{Message: "The URL must be an http or https URL."}
+	}
+	if len(webhook.Secret) < 16 || len(webhook.Secret) > 200 {
+		return DataError{Message: "The secret must be between 16 and 200 characters."}
+	}
+	if len(webhook.RoomName) > 100 {
+		return DataError{Message: "The room name must be less than 100 characters."}
+	}
+	if webhook.MinSeverity != "" && models.SeverityRank(webhook.MinSeverity) == 0 {
+		return DataError{Message: "The minimum severity must be info, warning or critical."}
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	for _, event := range webhook.Events {
+		switch event {
+		case models.EventAlert, models.EventIncidentOpened, models.EventIncidentAcknowledged, models.EventIncidentResolved, models.EventDigest:
+		default:
+			return DataError{Message: "The events must be alert, incident.opened, incident.acknowledged, incident.resolved or digest."}
+		}
+	}
+	return nil
+}
+
+// validateDeliveryQuery checks the status and limit of the delivery log
+func validateDeliveryQuery(status string, limit int) error {
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return DataError{Message: "The status must be pending, delivered or dead."}
+	}
+	if limit <= 0 || limit > MaxDeliveries {
+		return DataError{Message: fmt.Sprintf("The limit must be between 1 and %d.", MaxDeliveries)}
+	}
+	return nil
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,265 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// * memoryWebhooks keeps the webhooks and their deliveries in memory *
+type memoryWebhooks struct {
+	webhooks   []*models.Webhook
+	deliveries []*models.WebhookDelivery
+}
+
+func (m *memoryWebhooks) Create(w *models.Webhook, ctx context.Context) error {
+	c := *w
+	m.webhooks = append(m.webhooks, &c)
+	w.ID = len(m.webhooks)
+	c.ID = w.ID
+	return nil
+}
+
+func (m *memoryWebhooks) ReadOne(id int, ctx context.Context) (*models.Webhook, error) {
+	if id < 1 || id > len(m.webhooks) || m.webhooks[id-1] == nil {
+		return nil, nil
+	}
+	w := *m.webhooks[id-1]
+	return &w, nil
+}
+
+func (m *memoryWebhooks) ReadAll(ctx context.Context) ([]*models.Webhook, error) {
+	webhooks := []*models.Webhook{}
+	for id := range m.webhooks {
+		if w, _ := m.ReadOne(id+1, ctx); w != nil {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks, nil
+}
+
+func (m *memoryWebhooks) Update(w *models.Webhook, ctx context.Context) (int64, error) {
+	if stored, _ := m.ReadOne(w.ID, ctx); stored == nil {
+		return 0, nil
+	}
+	c := *w
+	m.webhooks[w.ID-1] = &c
+	return 1, nil
+}
+
+func (m *memoryWebhooks) Delete(id int, ctx context.Context) (int64, error) {
+	if stored, _ := m.ReadOne(id, ctx); stored == nil {
+		return 0, nil
+	}
+	m.webhooks[id-1] = nil
+	return 1, nil
+}
+
+func (m *memoryWebhooks) Enqueue(d *models.WebhookDelivery, ctx context.Context) error {
+	c := *d
+	m.deliveries = append(m.deliveries, &c)
+	d.ID = len(m.deliveries)
+	c.ID = d.ID
+	return nil
+}
+
+func (m *memoryWebhooks) GetDue(now string, limit int, ctx context.Context) ([]*models.WebhookDelivery, error) {
+	due := []*models.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.Status == models.DeliveryPending && d.NextAttempt <= now && len(due) < limit {
+			c := *d
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryWebhooks) UpdateDelivery(d *models.WebhookDelivery, ctx context.Context) error {
+	c := *d
+	m.deliveries[d.ID-1] = &c
+	return nil
+}
+
+func (m *memoryWebhooks) GetDeliveries(webhookID int, status string, limit int, ctx context.Context) ([]*models.WebhookDelivery, error) {
+	deliveries := []*models.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (m *memoryWebhooks) PruneDeliveries(before string, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// * receiver is a webhook endpoint checking the signatures *
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	failures int // Requests to fail with 503 before accepting
+	events   []models.WebhookEvent
+	invalid  int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get(WebhookSignatureHeader) != SignWebhook(rc.secret, body) {
+		rc.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var event models.WebhookEvent
+	json.Unmarshal(body, &event)
+	rc.events = append(rc.events, event)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestDispatcher(t *testing.T, rc *receiver, webhook *models.Webhook) (*WebhookDispatcher, *memoryWebhooks) {
+	t.Helper()
+
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	repo := &memoryWebhooks{}
+	cfg := WebhookConfig{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	d := NewWebhookDispatcher(repo, &MockRuleService{}, cfg, log.Default())
+	webhook.URL = srv.URL
+	webhook.Secret = rc.secret
+	if err := d.CreateWebhook(webhook, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return d, repo
+}
+
+func TestWebhookDeliveriesAreSigned(t *testing.T) {
+	rc := &receiver{secret: "0123456789abcdef-secret"}
+	d, repo := newTestDispatcher(t, rc, &models.Webhook{RoomName: "Office"})
+	ctx := context.Background()
+
+	// * Rule 1 of the mock is a warning *
+	d.Publish(&models.Data{RoomName: "Office", SoundLevel: 82, IsAlert: true, AlertRuleID: 1, MeasureTime: "2024-06-01T10:00:00Z"})
+	d.Publish(&models.Data{RoomName: "Office", SoundLevel: 60, MeasureTime: "2024-06-01T10:00:05Z"})
+	d.Publish(&models.Data{RoomName: "Hall", SoundLevel: 90, IsAlert: true, MeasureTime: "2024-06-01T10:00:05Z"})
+	d.IncidentChanged(&models.Incident{ID: 1, RoomName: "Office", State: models.IncidentAcknowledged, AcknowledgedBy: "Ms. Smith"})
+	d.deliver(ctx)
+
+	if rc.invalid != 0 || len(rc.events) != 2 {
+		t.Fatalf("got %d events and %d invalid signatures, want 2 and 0", len(rc.events), rc.invalid)
+	}
+	alert, acknowledged := rc.events[0], rc.events[1]
+	if alert.Event != models.EventAlert || alert.Severity != models.SeverityWarning || alert.Reading == nil || alert.Reading.SoundLevel != 82 {
+		t.Errorf("unexpected alert event: %+v", alert)
+	}
+	if acknowledged.Event != models.EventIncidentAcknowledged || acknowledged.Incident == nil || acknowledged.Incident.AcknowledgedBy != "Ms. Smith" {
+		t.Errorf("unexpected incident event: %+v", acknowledged)
+	}
+	deliveries, _ := d.GetDeliveries(1, models.DeliveryDelivered, 10, ctx)
+	if len(deliveries) != 2 || deliveries[0].ResponseStatus != http.StatusNoContent || len(repo.deliveries) != 2 {
+		t.Errorf("unexpected deliveries: %+v", deliveries)
+	}
+}
+
+func TestWebhookRetriesUntilDead(t *testing.T) {
+	rc := &receiver{secret: "0123456789abcdef-secret", failures: 2}
+	d, repo := newTestDispatcher(t, rc, &models.Webhook{})
+	ctx := context.Background()
+
+	d.Publish(&models.Data{RoomName: "Office", SoundLevel: 82, IsAlert: true, MeasureTime: "2024-06-01T10:00:00Z"})
+	for i := 0; i < 3; i++ {
+		d.deliver(ctx)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if first := repo.deliveries[0]; first.Status != models.DeliveryDelivered || first.Attempts != 3 || len(rc.events) != 1 {
+		t.Fatalf("the delivery should succeed on the third attempt: %+v", first)
+	}
+
+	// * The receiver is down for longer than the attempts *
+	rc.failures = 5
+	d.Publish(&models.Data{RoomName: "Office", SoundLevel: 84, IsAlert: true, MeasureTime: "2024-06-01T10:01:00Z"})
+	for i := 0; i < 5; i++ {
+		d.deliver(ctx)
+		time.Sleep(5 * time.Millisecond)
+	}
+	dead := repo.deliveries[1]
+	if dead.Status != models.DeliveryDead || dead.Attempts != 3 || dead.ResponseStatus != http.StatusServiceUnavailable || dead.LastError == "" {
+		t.Errorf("the delivery should be dead after 3 attempts: %+v", dead)
+	}
+	if rc.failures != 2 {
+		t.Errorf("a dead delivery should not be tried again, %d failures left", rc.failures)
+	}
+}
+
+func TestWebhookFilters(t *testing.T) {
+	for _, tc := range []struct {
+		webhook models.Webhook
+		event   models.WebhookEvent
+		want    bool
+	}{
+		{models.Webhook{}, models.WebhookEvent{Event: models.EventAlert, Severity: models.SeverityInfo, RoomName: "Office"}, true},
+		{models.Webhook{RoomName: "Hall"}, models.WebhookEvent{Event: models.EventAlert, RoomName: "Office"}, false},
+		{models.Webhook{MinSeverity: models.SeverityWarning}, models.WebhookEvent{Event: models.EventAlert, Severity: models.SeverityInfo}, false},
+		{models.Webhook{MinSeverity: models.SeverityWarning}, models.WebhookEvent{Event: models.EventAlert, Severity: models.SeverityCritical}, true},
+		{models.Webhook{Events: []string{models.EventIncidentOpened}}, models.WebhookEvent{Event: models.EventAlert}, false},
+		{models.Webhook{Events: []string{models.EventIncidentOpened}}, models.WebhookEvent{Event: models.EventIncidentOpened}, true},
+		{models.Webhook{Disabled: true}, models.WebhookEvent{Event: models.EventAlert}, false},
+	} {
+		if got := webhookMatches(&tc.webhook, &tc.event); got != tc.want {
+			t.Errorf("%+v with %+v: got %v, want %v", tc.webhook, tc.event, got, tc.want)
+		}
+	}
+}
+
+func TestWebhookDigests(t *testing.T) {
+	rc := &receiver{secret: "0123456789abcdef-secret"}
+	d, repo := newTestDispatcher(t, rc, &models.Webhook{RoomName: "Office", MinSeverity: models.SeverityCritical, Events: []string{models.EventDigest}})
+	ctx := context.Background()
+	// * Webhooks without the digest event are not sent digests *
+	if err := d.CreateWebhook(&models.Webhook{URL: repo.webhooks[0].URL, Secret: rc.secret}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	d.DigestReady(&models.Digest{Period: models.DigestDaily, Locations: []models.LocationDigest{{RoomName: "Office"}, {RoomName: "Hall"}}})
+	d.DigestReady(&models.Digest{Period: models.DigestDaily, Locations: []models.LocationDigest{{RoomName: "Hall"}}})
+	d.deliver(ctx)
+
+	if len(rc.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(rc.events))
+	}
+	if event := rc.events[0]; event.Event != models.EventDigest || event.Digest == nil || len(event.Digest.Locations) != 1 || event.Digest.Locations[0].RoomName != "Office" {
+		t.Errorf("unexpected digest event: %+v", event)
+	}
+}
+
+func TestUpdateWebhookKeepsSecret(t *testing.T) {
+	rc := &receiver{secret: "0123456789abcdef-secret"}
+	d, repo := newTestDispatcher(t, rc, &models.Webhook{})
+	ctx := context.Background()
+
+	webhook := &models.Webhook{ID: 1, URL: repo.webhooks[0].URL, MinSeverity: models.SeverityCritical}
+	if updated, err := d.UpdateWebhook(webhook, ctx); !updated || err != nil {
+		t.Fatalf("got %v, %v", updated, err)
+	}
+	if repo.webhooks[0].Secret != rc.secret || webhook.Secret != "" {
+		t.Errorf("the secret should be kept and not returned: %+v", repo.webhooks[0])
+	}
+	if stored, _ := d.GetWebhook(1, ctx); stored.Secret != "" {
+		t.Errorf("the secret should not be returned: %+v", stored)
+	}
+}
@@ -2,9 +2,13 @@ package service
 
 import (
 	"context"
+	"goapi/internal/api/notify"
+	"goapi/internal/api/pubsub"
+	"goapi/internal/api/relay"
 	"goapi/internal/api/repository/DAL"
 	"goapi/internal/api/repository/DAL/PostgreSQL"
 	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
 	"log"
 	"os"
@@ -29,18 +33,72 @@ func (d DataServiceType) String() string {
 }
 
 type ServiceFactory struct {
-	db     DAL.SQLDatabase
-	logger *log.Logger
-	ctx    context.Context
+	db          DAL.SQLDatabase
+	logger      *log.Logger
+	ctx         context.Context
+	hub         *pubsub.Hub
+	data        models.DataRepository         // Shared by every service reading or writing readings
+	clock       service.ClockService          // Shared by the data service and the device handlers
+	dose        service.DoseService           // Shared by the location service and the dose handler
+	calibration service.CalibrationService    // Shared by the data service and the calibration handlers
+	rules       service.RuleService           // Shared by the data service and the rule handlers
+	schedules   *service.ThresholdScheduler   // Shared by the data and location services and the schedule handlers
+	incidents   *service.AlertIncidentService // Notified by the data service, shared with the alert handlers
+	webhooks    *service.WebhookDispatcher    // Notified by the data and incident services, shared with the webhook handlers
+	notifier    *notify.Notifier              // Notified by the incident service, nil until created
+	digests     *service.Digester             // Sends the scheduled digests, shared with the digest handler
 }
 
 // * Factory for creating data service *
 func NewServiceFactory(db DAL.SQLDatabase, logger *log.Logger, ctx context.Context) *ServiceFactory {
-	return &ServiceFactory{
+	sf := &ServiceFactory{
 		db:     db,
 		logger: logger,
 		ctx:    ctx,
+		hub:    pubsub.NewHub(1000),
 	}
+
+	// * Close the live streams when the server shuts down *
+	go func() {
+		<-ctx.Done()
+		sf.hub.Close()
+	}()
+
+	return sf
+}
+
+// Hub returns the hub the data services publish stored readings to
+func (sf *ServiceFactory) Hub() *pubsub.Hub {
+	return sf.hub
+}
+
+// dataRepository returns the repository of the readings, it is created once
+// so its tables are migrated and, for PostgreSQL, its connection pool opened only once
+func (sf *ServiceFactory) dataRepository(serviceType DataServiceType) (models.DataRepository, error) {
+	if sf.data != nil {
+		return sf.data, nil
+	}
+
+	var repo models.DataRepository
+	var err error
+	switch serviceType {
+	case SQLiteDataService:
+		repo, err = SQLite.NewDataRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		repo, err = PostgreSQL.NewDataRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid data service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sf.data = repo
+	return sf.data, nil
 }
 
 // CreateDataService returns the appropriate DataService based on the serviceType
@@ -49,7 +107,7 @@ func (sf *ServiceFactory) CreateDataService(serviceType DataServiceType) (servic
 	sf.logger.Printf("Creating DataService of type: %s", dsType)
 	switch serviceType {
 	case SQLiteDataService:
-		repo, err := SQLite.NewDataRepository(sf.db, sf.ctx)
+		repo, err := sf.dataRepository(serviceType)
 		if err != nil {
 			return nil, err
 		}
@@ -57,7 +115,27 @@ func (sf *ServiceFactory) CreateDataService(serviceType DataServiceType) (servic
 		if err != nil {
 			return nil, err
 		}
-		ds := service.NewDataServiceSQLite(repo, locationRepo)
+		clock, err := sf.CreateClockService(serviceType)
+		if err != nil {
+			return nil, err
+		}
+		calibration, err := sf.CreateCalibrationService(serviceType)
+		if err != nil {
+			return nil, err
+		}
+		rules, err := sf.CreateRuleService(serviceType)
+		if err != nil {
+			return nil, err
+		}
+		schedules, err := sf.CreateScheduleService(serviceType)
+		if err != nil {
+			return nil, err
+		}
+		publishers, err := sf.publishers(serviceType, repo)
+		if err != nil {
+			return nil, err
+		}
+		ds := service.NewDataServiceSQLite(repo, locationRepo, clock, calibration, rules, schedules, publishers...)
 		return ds, nil
 	case PostgreSQLDataService:
 		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
@@ -65,7 +143,7 @@ func (sf *ServiceFactory) CreateDataService(serviceType DataServiceType) (servic
 			sf.logger.Println("Error setting up database: DATABASE_URL environment variable is not set")
 			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
 		}
-		repo, err := PostgreSQL.NewDataRepository(connStr, sf.db, sf.ctx)
+		repo, err := sf.dataRepository(serviceType)
 		if err != nil {
 			return nil, err
 		}
@@ -73,23 +151,99 @@ func (sf *ServiceFactory) CreateDataService(serviceType DataServiceType) (servic
 		if err != nil {
 			return nil, err
 		}
+		clock, err := sf.CreateClockService(serviceType)
+		if err != nil {
+			return nil, err
+		}
+		calibration, err := sf.CreateCalibrationService(serviceType)
+		if err != nil {
+			return nil, err
+		}
+		rules, err := sf.CreateRuleService(serviceType)
+		if err != nil {
+			return nil, err
+		}
+		schedules, err := sf.CreateScheduleService(serviceType)
+		if err != nil {
+			return nil, err
+		}
 		// You need to implement NewDataServicePostgreSQL in your service/data package
-		ds := service.NewDataServicePostgreSQL(repo, locationRepo)
+		publishers, err := sf.publishers(serviceType, repo)
+		if err != nil {
+			return nil, err
+		}
+		ds := service.NewDataServicePostgreSQL(repo, locationRepo, clock, calibration, rules, schedules, publishers...)
 		return ds, nil
 	default:
 		return nil, service.DataError{Message: "Invalid data service type."}
 	}
 }
 
+// publishers returns what the data service notifies about stored readings
+func (sf *ServiceFactory) publishers(serviceType DataServiceType, repo models.DataRepository) ([]service.Publisher, error) {
+	incidents, err := sf.CreateIncidentService(serviceType)
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := sf.CreateWebhookService(serviceType)
+	if err != nil {
+		return nil, err
+	}
+	publishers := []service.Publisher{sf.hub, incidents, webhooks}
+	// * Incidents and webhooks count the alerts of the readings, not again of the rows computed from them *
+	rowPublishers := []service.Publisher{sf.hub}
+
+	// * Relay mode: stored readings are also queued for the upstream instance *
+	if cfg, ok := relay.ConfigFromEnv(); ok {
+		outbox, err := SQLite.NewOutboxRepository(sf.db, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		r, err := relay.New(cfg, outbox, sf.logger)
+		if err != nil {
+			return nil, err
+		}
+		go r.Run(sf.ctx)
+		publishers = append(publishers, r)
+		rowPublishers = append(rowPublishers, r)
+	}
+
+	window, ok, err := service.AggregateWindowFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		sf.logger.Printf("Computing periodic rows over %s windows", window)
+		// * The computed rows go to the live streams and the upstream instance *
+		aggregator := service.NewAggregator(repo, window, sf.logger, rowPublishers...)
+		go aggregator.Run(sf.ctx)
+		publishers = append(publishers, aggregator)
+	}
+
+	return publishers, nil
+}
+
 // Optionally, add a similar CreateLocationService for PostgreSQL if needed
-func (sf *ServiceFactory) CreateLocationService(serviceType DataServiceType) (service.LocationService, error) {
+// The listeners are notified of threshold changes, e.g. the connected devices
+func (sf *ServiceFactory) CreateLocationService(serviceType DataServiceType, listeners ...service.ThresholdListener) (service.LocationService, error) {
+	dose, err := sf.CreateDoseService(serviceType)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := sf.CreateScheduleService(serviceType)
+	if err != nil {
+		return nil, err
+	}
+	// * The listeners are also told when a schedule changes the threshold *
+	go schedules.Run(sf.ctx, listeners...)
+
 	switch serviceType {
 	case SQLiteDataService:
 		repo, err := SQLite.NewLocationRepository(sf.db, sf.ctx)
 		if err != nil {
 			return nil, err
 		}
-		return service.NewLocationServiceSQLite(repo), nil
+		return service.NewLocationServiceSQLite(repo, dose, schedules, listeners...), nil
 	case PostgreSQLDataService:
 		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
 		if connStr == "" {
@@ -101,8 +255,346 @@ func (sf *ServiceFactory) CreateLocationService(serviceType DataServiceType) (se
 			return nil, err
 		}
 		// You need to implement NewLocationServicePostgreSQL in your service/data package
-		return service.NewLocationServicePostgreSQL(repo), nil
+		return service.NewLocationServicePostgreSQL(repo, dose, schedules, listeners...), nil
 	default:
 		return nil, service.DataError{Message: "Invalid location service type."}
 	}
 }
+
+// CreateClockService returns the service tracking device clocks,
+// it is created once so the data service and the handlers share it
+func (sf *ServiceFactory) CreateClockService(serviceType DataServiceType) (service.ClockService, error) {
+	if sf.clock != nil {
+		return sf.clock, nil
+	}
+
+	policy, err := service.ClockPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var repo models.DeviceClockRepository
+	switch serviceType {
+	case SQLiteDataService:
+		repo, err = SQLite.NewDeviceClockRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		repo, err = PostgreSQL.NewDeviceClockRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid clock service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sf.clock = service.NewDeviceClockService(repo, policy)
+	return sf.clock, nil
+}
+
+// CreateDoseService returns the service computing noise doses,
+// it is created once so the location service and the handlers share it
+func (sf *ServiceFactory) CreateDoseService(serviceType DataServiceType) (service.DoseService, error) {
+	if sf.dose != nil {
+		return sf.dose, nil
+	}
+
+	cfg, err := service.DoseConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := sf.dataRepository(serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	sf.dose = service.NewNoiseDoseService(repo, cfg)
+	return sf.dose, nil
+}
+
+// CreateCalibrationService returns the service correcting the levels devices measure,
+// it is created once so the data service and the handlers share it
+func (sf *ServiceFactory) CreateCalibrationService(serviceType DataServiceType) (service.CalibrationService, error) {
+	if sf.calibration != nil {
+		return sf.calibration, nil
+	}
+
+	dataRepo, err := sf.dataRepository(serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo models.CalibrationRepository
+	switch serviceType {
+	case SQLiteDataService:
+		repo, err = SQLite.NewCalibrationRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		repo, err = PostgreSQL.NewCalibrationRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid calibration service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sf.calibration = service.NewDeviceCalibrationService(repo, dataRepo)
+	return sf.calibration, nil
+}
+
+// CreateRuleService returns the engine deciding which readings are alerts,
+// it is created once so the data service and the handlers share it
+func (sf *ServiceFactory) CreateRuleService(serviceType DataServiceType) (service.RuleService, error) {
+	if sf.rules != nil {
+		return sf.rules, nil
+	}
+
+	var repo models.RuleRepository
+	var err error
+	switch serviceType {
+	case SQLiteDataService:
+		repo, err = SQLite.NewRuleRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		repo, err = PostgreSQL.NewRuleRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid rule service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sf.rules = service.NewRuleEngine(repo)
+	return sf.rules, nil
+}
+
+// CreateScheduleService returns the service setting thresholds from the locations' weekly schedules,
+// it is created once so the data and location services and the handlers share it
+func (sf *ServiceFactory) CreateScheduleService(serviceType DataServiceType) (*service.ThresholdScheduler, error) {
+	if sf.schedules != nil {
+		return sf.schedules, nil
+	}
+
+	var repo models.ScheduleRepository
+	var locationRepo models.LocationRepository
+	var err error
+	switch serviceType {
+	case SQLiteDataService:
+		if repo, err = SQLite.NewScheduleRepository(sf.db, sf.ctx); err != nil {
+			return nil, err
+		}
+		locationRepo, err = SQLite.NewLocationRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		if repo, err = PostgreSQL.NewScheduleRepository(connStr, sf.db, sf.ctx); err != nil {
+			return nil, err
+		}
+		locationRepo, err = PostgreSQL.NewLocationRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid schedule service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sf.schedules = service.NewThresholdScheduler(repo, locationRepo, sf.logger)
+	return sf.schedules, nil
+}
+
+// CreateIncidentService returns the service grouping alerts into incidents,
+// it is created once so the data service and the handlers share it
+func (sf *ServiceFactory) CreateIncidentService(serviceType DataServiceType) (*service.AlertIncidentService, error) {
+	if sf.incidents != nil {
+		return sf.incidents, nil
+	}
+
+	quiet, err := service.QuietPeriodFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := sf.CreateWebhookService(serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo models.IncidentRepository
+	switch serviceType {
+	case SQLiteDataService:
+		repo, err = SQLite.NewIncidentRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		repo, err = PostgreSQL.NewIncidentRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid incident service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := []service.IncidentListener{webhooks}
+	notifier, err := sf.CreateNotifier()
+	if err != nil {
+		return nil, err
+	}
+	if notifier != nil {
+		listeners = append(listeners, notifier)
+	}
+
+	sf.incidents = service.NewAlertIncidentService(repo, quiet, sf.logger, listeners...)
+	go sf.incidents.Run(sf.ctx)
+	return sf.incidents, nil
+}
+
+// CreateNotifier returns the notifier emailing alerts, nil if SMTP_HOST is not set.
+// It is created once, its rate limits apply to everything it sends.
+func (sf *ServiceFactory) CreateNotifier() (*notify.Notifier, error) {
+	if sf.notifier != nil {
+		return sf.notifier, nil
+	}
+
+	cfg, ok, err := notify.ConfigFromEnv()
+	if err != nil || !ok {
+		return nil, err
+	}
+	if len(cfg.Recipients) == 0 {
+		sf.logger.Println("SMTP_HOST is set but NOTIFY_RECIPIENTS is empty, no alert emails will be sent")
+	}
+	if sf.notifier, err = notify.New(cfg, sf.logger); err != nil {
+		return nil, err
+	}
+	go sf.notifier.Run(sf.ctx)
+	return sf.notifier, nil
+}
+
+// CreateDigestService returns the service summarising the locations over a day or a week,
+// it is created once and sends the scheduled digests to the webhooks and the notifier
+func (sf *ServiceFactory) CreateDigestService(serviceType DataServiceType) (*service.Digester, error) {
+	if sf.digests != nil {
+		return sf.digests, nil
+	}
+
+	cfg, err := service.DigestConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := sf.CreateWebhookService(serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := sf.dataRepository(serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var locationRepo models.LocationRepository
+	switch serviceType {
+	case SQLiteDataService:
+		locationRepo, err = SQLite.NewLocationRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		locationRepo, err = PostgreSQL.NewLocationRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid digest service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := []service.DigestListener{webhooks}
+	notifier, err := sf.CreateNotifier()
+	if err != nil {
+		return nil, err
+	}
+	if notifier != nil {
+		listeners = append(listeners, notifier)
+	}
+
+	sf.digests = service.NewDigester(repo, locationRepo, cfg, sf.logger, listeners...)
+	go sf.digests.Run(sf.ctx)
+	return sf.digests, nil
+}
+
+// CreateWebhookService returns the service sending alert events to webhooks,
+// it is created once so the data service, the incident service and the handlers share it
+func (sf *ServiceFactory) CreateWebhookService(serviceType DataServiceType) (*service.WebhookDispatcher, error) {
+	if sf.webhooks != nil {
+		return sf.webhooks, nil
+	}
+
+	cfg, err := service.WebhookConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := sf.CreateRuleService(serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo models.WebhookRepository
+	switch serviceType {
+	case SQLiteDataService:
+		repo, err = SQLite.NewWebhookRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		repo, err = PostgreSQL.NewWebhookRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid webhook service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sf.webhooks = service.NewWebhookDispatcher(repo, rules, cfg, sf.logger)
+	go sf.webhooks.Run(sf.ctx)
+	return sf.webhooks, nil
+}
+
+// CreateAudioService returns the service analyzing uploaded WAV recordings,
+// they are stored as readings through the DataService
+func (sf *ServiceFactory) CreateAudioService(serviceType DataServiceType, ds service.DataService) (service.AudioService, error) {
+	fullScale, err := service.FullScaleFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var repo models.AudioRepository
+	switch serviceType {
+	case SQLiteDataService:
+		repo, err = SQLite.NewAudioRepository(sf.db, sf.ctx)
+	case PostgreSQLDataService:
+		connStr := os.Getenv("EXTERNAL_DATABASE_URL")
+		if connStr == "" {
+			return nil, service.DataError{Message: "EXTERNAL_DATABASE_URL environment variable is not set"}
+		}
+		repo, err = PostgreSQL.NewAudioRepository(connStr, sf.db, sf.ctx)
+	default:
+		return nil, service.DataError{Message: "Invalid audio service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewAudioAnalysisService(ds, repo, fullScale), nil
+}
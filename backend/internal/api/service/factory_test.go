@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAggregatedRowsAreNotCountedAsAlerts(t *testing.T) {
+	t.Setenv("AGGREGATE_WINDOW", "10m")
+	// * The webhook and incident services write from their own goroutines *
+	db, err := SQLite.NewSqlite(t.TempDir() + "/factory.db?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sf := NewServiceFactory(db, log.Default(), ctx)
+
+	ds, err := sf.CreateDataService(SQLiteDataService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	webhooks, err := sf.CreateWebhookService(SQLiteDataService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook := &models.Webhook{URL: receiver.URL, Events: []string{models.EventAlert}}
+	if err := webhooks.CreateWebhook(webhook, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// * Two alerts in the 10:00 window, the sample at 10:10 closes it and the row is an alert too *
+	for _, measureTime := range []string{"2024-10-27T10:01:00Z", "2024-10-27T10:05:00Z", "2024-10-27T10:10:05Z"} {
+		level := 80.0
+		if measureTime == "2024-10-27T10:10:05Z" {
+			level = 50
+		}
+		data := &models.Data{DeviceID: "arduino_001", RoomName: "PlayRoom_A", SoundLevel: level, Threshold: 70, MeasureTime: measureTime}
+		if err := ds.CreateLatest(data, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := ds.ReadMany(1, 10, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || !rows[0].IsAlert {
+		t.Fatalf("expected 1 alerting periodic row, got %+v", rows)
+	}
+
+	incidents, err := sf.incidents.GetIncidents("", "PlayRoom_A", 10, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 1 {
+		t.Fatalf("expected 1 incident, got %d", len(incidents))
+	}
+	if incidents[0].Alerts != 2 {
+		t.Errorf("expected 2 alerts in the incident, got %d", incidents[0].Alerts)
+	}
+
+	// * Alert events are queued as the readings are stored *
+	deliveries, err := webhooks.GetDeliveries(webhook.ID, "", 10, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 {
+		t.Errorf("expected 2 alert deliveries, got %d", len(deliveries))
+	}
+}
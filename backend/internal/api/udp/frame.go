@@ -0,0 +1,146 @@
+// Package udp implements a compact binary protocol for low-power sensors
+// that send their readings as single UDP datagrams.
+//
+// A frame (version 1) is laid out as follows, all integers big-endian:
+//
+//	offset  size  field
+//	0       2     magic "SB"
+//	2       1     version, 1
+//	3       1     flags: bit 0 set = periodic reading (chart), clear = latest reading (current level card)
+//	              the other bits are reserved and must be 0
+//	4       8     measure time, signed Unix time in milliseconds, required; it must be within
+//	              ReplayWindow of the server's clock and after the device's previous frame
+//	              of the same kind, so a captured frame can't be replayed
+//	12      4     sound level in dB, IEEE 754 float32
+//	16      1     length n of the device ID, 1-50
+//	17      n     device ID, UTF-8
+//	17+n    16    tag: the first 16 bytes of HMAC-SHA256(key, bytes 0 to 17+n)
+//
+// A reading from "arduino_001" is 44 bytes.
+package udp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"math"
+	"time"
+)
+
+const (
+	Version = 1
+
+	FlagPeriodic byte = 1 << 0
+
+	headerSize  = 17
+	TagSize     = 16
+	MaxDeviceID = 50
+	MaxFrame    = headerSize + MaxDeviceID + TagSize
+)
+
+var magic = [2]byte{'S', 'B'}
+
+var (
+	ErrShortFrame = errors.New("udp: frame is too short")
+	ErrMagic      = errors.New("udp: not a Sound Bridge frame")
+	ErrVersion    = errors.New("udp: unsupported frame version")
+	ErrFlags      = errors.New("udp: reserved flags are set")
+	ErrDeviceID   = errors.New("udp: device ID must be 1-50 bytes")
+	ErrTag        = errors.New("udp: invalid tag")
+	ErrSoundLevel = errors.New("udp: sound level is not a finite number")
+	ErrMeasureAt  = errors.New("udp: measure time is required")
+)
+
+// Frame is a single reading
+type Frame struct {
+	DeviceID   string
+	MeasureAt  time.Time // Required, also orders the frames of a device
+	SoundLevel float32
+	Periodic   bool
+}
+
+// Encode returns the signed frame
+func Encode(f Frame, key []byte) ([]byte, error) {
+	if len(f.DeviceID) == 0 || len(f.DeviceID) > MaxDeviceID {
+		return nil, ErrDeviceID
+	}
+
+	b := make([]byte, headerSize+len(f.DeviceID), headerSize+len(f.DeviceID)+TagSize)
+	copy(b[0:2], magic[:])
+	b[2] = Version
+	if f.Periodic {
+		b[3] |= FlagPeriodic
+	}
+	if !f.MeasureAt.IsZero() {
+		binary.BigEndian.PutUint64(b[4:12], uint64(f.MeasureAt.UnixMilli()))
+	}
+	binary.BigEndian.PutUint32(b[12:16], math.Float32bits(f.SoundLevel))
+	b[16] = byte(len(f.DeviceID))
+	copy(b[headerSize:], f.DeviceID)
+
+	return append(b, tag(b, key)...), nil
+}
+
+// Decode verifies the tag of a frame and returns its reading
+func Decode(b []byte, key []byte) (Frame, error) {
+	if len(b) < headerSize+1+TagSize {
+		return Frame{}, ErrShortFrame
+	}
+	if b[0] != magic[0] || b[1] != magic[1] {
+		return Frame{}, ErrMagic
+	}
+	if b[2] != Version {
+		return Frame{}, ErrVersion
+	}
+
+	n := int(b[16])
+	if n == 0 || n > MaxDeviceID {
+		return Frame{}, ErrDeviceID
+	}
+	if len(b) != headerSize+n+TagSize {
+		return Frame{}, ErrShortFrame
+	}
+
+	// * Check the tag before looking at the content any further *
+	signed := b[:headerSize+n]
+	if !hmac.Equal(b[headerSize+n:], tag(signed, key)) {
+		return Frame{}, ErrTag
+	}
+	if b[3]&^FlagPeriodic != 0 {
+		return Frame{}, ErrFlags
+	}
+
+	f := Frame{
+		DeviceID:   string(b[headerSize : headerSize+n]),
+		SoundLevel: math.Float32frombits(binary.BigEndian.Uint32(b[12:16])),
+		Periodic:   b[3]&FlagPeriodic != 0,
+	}
+	if level := float64(f.SoundLevel); math.IsNaN(level) || math.IsInf(level, 0) {
+		return Frame{}, ErrSoundLevel
+	}
+	ms := int64(binary.BigEndian.Uint64(b[4:12]))
+	if ms == 0 {
+		return Frame{}, ErrMeasureAt
+	}
+	f.MeasureAt = time.UnixMilli(ms).UTC()
+	return f, nil
+}
+
+// Data converts the frame to a reading for the DataService
+func (f Frame) Data() *models.Data {
+	return &models.Data{
+		DeviceID: f.DeviceID,
+		// float32 can't hold 67.45 exactly, a hundredth of a dB is more than sensors resolve
+		SoundLevel:  math.Round(float64(f.SoundLevel)*100) / 100,
+		MeasureTime: f.MeasureAt.Format(time.RFC3339),
+		IsPeriodic:  f.Periodic,
+	}
+}
+
+func tag(b []byte, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return mac.Sum(nil)[:TagSize]
+}
@@ -0,0 +1,156 @@
+package udp
+
+import (
+	"context"
+	service "goapi/internal/api/service/data"
+	"log"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+func TestEncodeDecode(t *testing.T) {
+	measureAt := time.Date(2024, 10, 27, 9, 30, 0, 0, time.UTC)
+	b, err := Encode(Frame{DeviceID: "arduino_001", MeasureAt: measureAt, SoundLevel: 67.45, Periodic: true}, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 44 {
+		t.Errorf("expected a 44 byte frame, got %d", len(b))
+	}
+
+	f, err := Decode(b, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := f.Data()
+	if data.DeviceID != "arduino_001" || data.SoundLevel != 67.45 || !data.IsPeriodic || data.MeasureTime != "2024-10-27T09:30:00Z" {
+		t.Errorf("unexpected reading: %+v", data)
+	}
+}
+
+func TestDecodeRejectsInvalidFrames(t *testing.T) {
+	measureAt := time.Date(2024, 10, 27, 9, 30, 0, 0, time.UTC)
+	b, _ := Encode(Frame{DeviceID: "arduino_001", MeasureAt: measureAt, SoundLevel: 60}, testKey)
+
+	tampered := append([]byte(nil), b...)
+	tampered[12] ^= 0x01
+	if _, err := Decode(tampered, testKey); err != ErrTag {
+		t.Errorf("tampered frame: expected %v, got %v", ErrTag, err)
+	}
+
+	if _, err := Decode(b, []byte("another key 1234")); err != ErrTag {
+		t.Errorf("wrong key: expected %v, got %v", ErrTag, err)
+	}
+
+	if _, err := Decode(b[:len(b)-1], testKey); err != ErrShortFrame {
+		t.Errorf("truncated frame: expected %v, got %v", ErrShortFrame, err)
+	}
+
+	wrongMagic := append([]byte(nil), b...)
+	wrongMagic[0] = 'X'
+	if _, err := Decode(wrongMagic, testKey); err != ErrMagic {
+		t.Errorf("wrong magic: expected %v, got %v", ErrMagic, err)
+	}
+
+	for _, level := range []float32{float32(math.NaN()), float32(math.Inf(1)), float32(math.Inf(-1))} {
+		b, _ := Encode(Frame{DeviceID: "arduino_001", MeasureAt: measureAt, SoundLevel: level}, testKey)
+		if _, err := Decode(b, testKey); err != ErrSoundLevel {
+			t.Errorf("sound level %v: expected %v, got %v", level, ErrSoundLevel, err)
+		}
+	}
+
+	noTime, _ := Encode(Frame{DeviceID: "arduino_001", SoundLevel: 60}, testKey)
+	if _, err := Decode(noTime, testKey); err != ErrMeasureAt {
+		t.Errorf("no measure time: expected %v, got %v", ErrMeasureAt, err)
+	}
+
+	if _, err := Encode(Frame{SoundLevel: 60}, testKey); err != ErrDeviceID {
+		t.Errorf("empty device ID: expected %v, got %v", ErrDeviceID, err)
+	}
+}
+
+func TestServerStoresReadings(t *testing.T) {
+	ds := service.NewMockDataServiceRecording()
+	srv, err := NewServer(Config{Key: testKey}, ds, log.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- srv.Serve(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// * A frame with a bad tag is dropped, the valid one after it is stored *
+	now := time.Now().Truncate(time.Second)
+	bad, _ := Encode(Frame{DeviceID: "arduino_666", MeasureAt: now, SoundLevel: 99}, []byte("not the right key"))
+	good, _ := Encode(Frame{DeviceID: "arduino_002", MeasureAt: now, SoundLevel: 58.5}, testKey)
+	client.Write(bad)
+	client.Write(good)
+
+	select {
+	case d := <-ds.Stored:
+		if d.DeviceID != "arduino_002" || d.SoundLevel != 58.5 || d.IsPeriodic || d.MeasureTime != now.UTC().Format(time.RFC3339) {
+			t.Errorf("unexpected reading: %+v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading was not stored")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancel")
+	}
+}
+
+func TestServerRefusesReplays(t *testing.T) {
+	srv, err := NewServer(Config{Key: testKey}, &service.MockDataServiceSuccessful{}, log.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 10, 27, 9, 30, 0, 0, time.UTC)
+	frame := Frame{DeviceID: "arduino_001", MeasureAt: now.Add(-time.Second), SoundLevel: 60}
+
+	if err := srv.accept(frame, now); err != nil {
+		t.Fatalf("first frame: %v", err)
+	}
+	if err := srv.accept(frame, now); err != errReplayed {
+		t.Errorf("same frame again: expected %v, got %v", errReplayed, err)
+	}
+	periodic := frame
+	periodic.Periodic = true
+	if err := srv.accept(periodic, now); err != nil {
+		t.Errorf("periodic frame of the same time: %v", err)
+	}
+
+	for _, at := range []time.Time{now.Add(-ReplayWindow - time.Second), now.Add(ReplayWindow + time.Second)} {
+		if err := srv.accept(Frame{DeviceID: "arduino_002", MeasureAt: at}, now); err != errOutsideWindow {
+			t.Errorf("frame of %v: expected %v, got %v", at, errOutsideWindow, err)
+		}
+	}
+}
+
+func TestNewServerRequiresKey(t *testing.T) {
+	if _, err := NewServer(Config{Addr: ":0", Key: []byte("short")}, &service.MockDataServiceSuccessful{}, log.Default()); err == nil {
+		t.Error("expected an error for a short key")
+	}
+}
@@ -0,0 +1,135 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// ReplayWindow is how far the measure time of a frame may be from the server's clock
+var ReplayWindow = 5 * time.Minute
+
+var (
+	errOutsideWindow = errors.New("measure time is outside the replay window")
+	errReplayed      = errors.New("frame isn't newer than the device's previous one, possibly replayed")
+)
+
+// Config holds the UDP listener settings
+type Config struct {
+	Addr string // e.g. :5683
+	Key  []byte // Shared HMAC key of the sensors
+}
+
+// ConfigFromEnv reads the listener settings from the environment.
+// ok is false when UDP_ADDR is not set, in which case the listener stays disabled.
+func ConfigFromEnv() (cfg Config, ok bool) {
+	cfg = Config{
+		Addr: os.Getenv("UDP_ADDR"),
+		Key:  []byte(os.Getenv("UDP_HMAC_KEY")),
+	}
+	return cfg, cfg.Addr != ""
+}
+
+// Server receives frames and stores the readings through the DataService
+type Server struct {
+	cfg    Config
+	ds     service.DataService
+	logger *log.Logger
+
+	// * Only used by Serve's goroutine *
+	latest map[string]time.Time // Measure time of the last accepted frame by device and kind
+	pruned time.Time
+}
+
+// NewServer creates a new UDP server
+func NewServer(cfg Config, ds service.DataService, logger *log.Logger) (*Server, error) {
+	if len(cfg.Key) < 16 {
+		return nil, fmt.Errorf("udp: the HMAC key must be at least 16 bytes")
+	}
+	return &Server{cfg: cfg, ds: ds, logger: logger, latest: make(map[string]time.Time)}, nil
+}
+
+// ListenAndServe listens on the configured address until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Println("Listening for UDP frames on " + conn.LocalAddr().String())
+	return s.Serve(ctx, conn)
+}
+
+// Serve reads frames from conn until ctx is cancelled, conn is closed when Serve returns
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	defer conn.Close()
+
+	// * One byte more than the largest frame, so oversized datagrams are noticed *
+	buf := make([]byte, MaxFrame+1)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.logger.Println("Error reading UDP frame:", err)
+			continue
+		}
+
+		frame, err := Decode(buf[:n], s.cfg.Key)
+		if err != nil {
+			s.logger.Printf("Discarding UDP frame from %s: %v", addr, err)
+			continue
+		}
+		if err := s.accept(frame, time.Now()); err != nil {
+			s.logger.Printf("Discarding UDP frame of %s from %s: %v", frame.DeviceID, addr, err)
+			continue
+		}
+
+		s.store(ctx, frame, addr)
+	}
+}
+
+// accept checks a frame isn't a replay: its measure time must be within ReplayWindow of now
+// and after the one of the last frame accepted from the device, periodic and latest frames apart
+func (s *Server) accept(frame Frame, now time.Time) error {
+	if frame.MeasureAt.Before(now.Add(-ReplayWindow)) || frame.MeasureAt.After(now.Add(ReplayWindow)) {
+		return errOutsideWindow
+	}
+	key := frame.DeviceID
+	if frame.Periodic {
+		key += "|periodic"
+	}
+	if last, ok := s.latest[key]; ok && !frame.MeasureAt.After(last) {
+		return errReplayed
+	}
+	s.latest[key] = frame.MeasureAt
+
+	// * Frames before the window are refused anyway, so older times needn't be kept *
+	if now.Sub(s.pruned) > ReplayWindow {
+		for key, last := range s.latest {
+			if last.Before(now.Add(-ReplayWindow)) {
+				delete(s.latest, key)
+			}
+		}
+		s.pruned = now
+	}
+	return nil
+}
+
+func (s *Server) store(ctx context.Context, frame Frame, addr net.Addr) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if _, err := service.Ingest(s.ds, frame.Data(), "", ctx); err != nil {
+		s.logger.Printf("Error storing UDP reading from %s (%s): %v", frame.DeviceID, addr, err)
+	}
+}
@@ -0,0 +1,43 @@
+package ws
+
+import "goapi/internal/api/repository/models"
+
+// Message types, devices send readings and get acks, everything else comes from the server
+const (
+	TypeReading   = "reading"    // device -> server, Data is the reading
+	TypeAck       = "ack"        // server -> device, answers a reading with the same ID
+	TypeError     = "error"      // server -> device, a message that couldn't be handled
+	TypeThreshold = "threshold"  // server -> device, Location has the new threshold
+	TypePlayAlert = "play_alert" // server -> device, Alert tells what to play
+)
+
+// Ack statuses, like the batch upload
+const (
+	AckCreated  = "created"  // Periodic reading stored
+	AckExisting = "existing" // Periodic reading was already stored, e.g. resent after a reconnect
+	AckLatest   = "latest"   // Latest reading stored
+	AckInvalid  = "invalid"  // Reading refused, Error tells why
+	AckFailed   = "failed"   // Reading could not be stored, the device may resend it
+)
+
+// Message is what is sent in either direction, one JSON object per WebSocket text message.
+// Type decides which of the other fields are set.
+type Message struct {
+	Type     string           `json:"type"`
+	ID       string           `json:"id,omitempty"` // Chosen by the device, also makes resent readings idempotent
+	Data     *models.Data     `json:"data,omitempty"`
+	Status   string           `json:"status,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Location *models.Location `json:"location,omitempty"`
+	Alert    *Alert           `json:"alert,omitempty"`
+}
+
+// Alert is a command to play an alert on the device
+type Alert struct {
+	Sound      string `json:"sound,omitempty"`       // Name of the sound, the device picks its default if empty
+	DurationMs int    `json:"duration_ms,omitempty"` // 0 lets the device decide
+	Message    string `json:"message,omitempty"`     // Text shown on devices with a display
+}
+
+// MaxAlertDurationMs is the longest alert that can be requested
+const MaxAlertDurationMs = 60000
@@ -0,0 +1,316 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// PongWait is how long a connection may stay silent before it is considered dead
+	PongWait = 60 * time.Second
+	// PingInterval is how often the server pings, it has to be shorter than PongWait
+	PingInterval = 50 * time.Second
+)
+
+const (
+	writeWait      = 10 * time.Second
+	maxMessageSize = 64 * 1024
+	sendBuffer     = 32 // Messages queued for a device before it is considered too slow and dropped
+)
+
+// OriginsFromEnv returns the origins of the browser pages allowed to connect, WS_ALLOWED_ORIGINS separated by commas,
+// e.g. https://kiosk.example.com
+func OriginsFromEnv() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// Server keeps the device WebSocket connections.
+// Devices send readings over it, which are stored through the DataService,
+// and get threshold changes and alert commands back.
+type Server struct {
+	ds       service.DataService
+	logger   *log.Logger
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+// client is one connected device or display
+type client struct {
+	conn     *websocket.Conn
+	deviceID string // From the upgrade request, empty for a display, which can't send readings
+	roomName string // Guarded by Server.mu, empty until known from the URL or a stored reading
+	send     chan *Message
+	cancel   context.CancelFunc
+}
+
+// NewServer creates a new server for device connections.
+// Browsers may only connect from the server's own origin or from allowedOrigins.
+func NewServer(ds service.DataService, allowedOrigins []string, logger *log.Logger) *Server {
+	return &Server{
+		ds:     ds,
+		logger: logger,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				return checkOrigin(r, allowedOrigins)
+			},
+		},
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// checkOrigin accepts requests without an Origin, which don't come from a browser,
+// and those from the server's own host or an allowed origin
+func checkOrigin(r *http.Request, allowedOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve upgrades the request to a WebSocket and handles the connection until it is closed
+// or rootCtx (the server's root context) is cancelled.
+// The connection is bound to the device of the device_id query parameter, only its readings are accepted,
+// the room is the room_name query parameter or else the one of the stored readings.
+// Example: websocat -H "Authorization: Basic ..." "ws://localhost:8080/api/ws/device?device_id=arduino_001"
+func (s *Server) Serve(w http.ResponseWriter, r *http.Request, rootCtx context.Context) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// * The upgrader already answered with an error status *
+		s.logger.Println("WebSocket upgrade failed:", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(rootCtx)
+	defer cancel()
+
+	c := &client{
+		conn:     conn,
+		deviceID: r.URL.Query().Get("device_id"),
+		roomName: r.URL.Query().Get("room_name"),
+		send:     make(chan *Message, sendBuffer),
+		cancel:   cancel,
+	}
+	s.add(c)
+	defer s.remove(c)
+
+	go s.writeLoop(ctx, c)
+	s.readLoop(ctx, c)
+}
+
+// ThresholdChanged sends the location's new threshold to the connections in that room
+func (s *Server) ThresholdChanged(location *models.Location) {
+	msg := &Message{Type: TypeThreshold, Location: location}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		if c.roomName == location.Name {
+			s.queue(c, msg)
+		}
+	}
+}
+
+// PlayAlert sends an alert command to the connections of the device
+// and returns how many there were
+func (s *Server) PlayAlert(deviceID string, alert *Alert) int {
+	msg := &Message{Type: TypePlayAlert, Alert: alert}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := 0
+	for c := range s.clients {
+		if c.deviceID == deviceID {
+			s.queue(c, msg)
+			sent++
+		}
+	}
+	return sent
+}
+
+func (s *Server) add(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c] = struct{}{}
+}
+
+func (s *Server) remove(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, c)
+}
+
+// queue hands a message to the client's writer, a client that doesn't keep up is disconnected.
+// Must be called with s.mu held.
+func (s *Server) queue(c *client, msg *Message) {
+	select {
+	case c.send <- msg:
+	default:
+		s.logger.Printf("WebSocket client %q is too slow, disconnecting", c.deviceID)
+		c.cancel()
+	}
+}
+
+// readLoop handles the messages of the device until the connection fails
+func (s *Server) readLoop(ctx context.Context, c *client) {
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(PongWait))
+	})
+
+	for {
+		_, payload, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && ctx.Err() == nil {
+				s.logger.Println("WebSocket read error:", err)
+			}
+			return
+		}
+		// * Any message proves the connection is alive *
+		c.conn.SetReadDeadline(time.Now().Add(PongWait))
+
+		var msg Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			// * A malformed message doesn't end the connection *
+			s.reply(c, &Message{Type: TypeError, Error: "Invalid JSON message."})
+			continue
+		}
+
+		switch msg.Type {
+		case TypeReading:
+			s.reply(c, s.handleReading(ctx, c, &msg))
+		default:
+			s.reply(c, &Message{Type: TypeError, ID: msg.ID, Error: "Unknown message type."})
+		}
+	}
+}
+
+// handleReading stores a reading and returns the ack
+func (s *Server) handleReading(ctx context.Context, c *client, msg *Message) *Message {
+	ack := &Message{Type: TypeAck, ID: msg.ID}
+	if msg.Data == nil {
+		ack.Status = AckInvalid
+		ack.Error = "Reading has no data."
+		return ack
+	}
+	data := msg.Data
+
+	if c.deviceID == "" {
+		ack.Status = AckInvalid
+		ack.Error = "Connect with device_id to send readings."
+		return ack
+	}
+	if data.DeviceID != "" && data.DeviceID != c.deviceID {
+		ack.Status = AckInvalid
+		ack.Error = "The device_id of the reading isn't the connection's device."
+		return ack
+	}
+	data.DeviceID = c.deviceID
+
+	// * A message ID makes a reading resent after a reconnect idempotent *
+	key := ""
+	if msg.ID != "" {
+		key = "ws-" + data.DeviceID + "-" + msg.ID
+	}
+
+	storeCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	created, err := service.Ingest(s.ds, data, key, storeCtx)
+	if err == nil && data.RoomName != "" {
+		// * The room of the latest reading decides which thresholds the connection is sent *
+		s.mu.Lock()
+		c.roomName = data.RoomName
+		s.mu.Unlock()
+	}
+	switch {
+	case err != nil:
+		switch err.(type) {
+		case service.DataError, service.ConflictError:
+			ack.Status = AckInvalid
+			ack.Error = err.Error()
+		default:
+			s.logger.Println("Error storing WebSocket reading:", err)
+			ack.Status = AckFailed
+			ack.Error = "Internal server error."
+		}
+	case !data.IsPeriodic:
+		ack.Status = AckLatest
+	case created:
+		ack.Status = AckCreated
+		ack.Data = data
+	default:
+		ack.Status = AckExisting
+		ack.Data = data
+	}
+	return ack
+}
+
+// reply queues a message for the device
+func (s *Server) reply(c *client, msg *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue(c, msg)
+}
+
+// writeLoop is the only writer of the connection, it sends the queued messages and the pings.
+// When ctx is done it closes the connection, which also ends readLoop.
+func (s *Server) writeLoop(ctx context.Context, c *client) {
+	ping := time.NewTicker(PingInterval)
+	defer ping.Stop()
+	defer c.conn.Close()
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.cancel()
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.cancel()
+				return
+			}
+		case <-ctx.Done():
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(writeWait))
+			return
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package ws
+
+import (
+	"context"
+	"encoding/base64"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// * startServer serves the WebSocket behind the API's authentication *
+func startServer(t *testing.T) (*Server, *service.MockDataServiceRecording, string) {
+	t.Helper()
+
+	ds := service.NewMockDataServiceRecording()
+	ds.Keys = make(chan string, 10)
+	s := NewServer(ds, []string{"https://kiosk.example.com"}, log.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := middleware.BasicAuthenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Serve(w, r, ctx)
+	}))
+	ts := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		cancel()
+		ts.Close()
+	})
+
+	return s, ds, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, authHeader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func authHeader() http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("kids_noisemeter_admin:passwordkids")))
+	return header
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestUpgradeRequiresAuthentication(t *testing.T) {
+	_, _, url := startServer(t)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("expected the upgrade to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %v", http.StatusUnauthorized, resp)
+	}
+}
+
+func TestReadingIsStoredAndAcked(t *testing.T) {
+	_, ds, url := startServer(t)
+	conn := dial(t, url+"?device_id=arduino_ws")
+
+	reading := Message{Type: TypeReading, ID: "42", Data: &models.Data{SoundLevel: 67.5, IsPeriodic: true}}
+	if err := conn.WriteJSON(reading); err != nil {
+		t.Fatal(err)
+	}
+
+	ack := readMessage(t, conn)
+	if ack.Type != TypeAck || ack.ID != "42" || ack.Status != AckCreated {
+		t.Errorf("unexpected ack: %+v", ack)
+	}
+
+	stored := <-ds.Stored
+	if stored.DeviceID != "arduino_ws" || stored.SoundLevel != 67.5 {
+		t.Errorf("unexpected stored reading: %+v", stored)
+	}
+	if key := <-ds.Keys; key != "ws-arduino_ws-42" {
+		t.Errorf("unexpected idempotency key: %q", key)
+	}
+}
+
+func TestUpgradeChecksOrigin(t *testing.T) {
+	_, _, url := startServer(t)
+
+	header := authHeader()
+	header.Set("Origin", "https://evil.example.com")
+	_, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the upgrade from another origin to be refused, got %v", resp)
+	}
+
+	header.Set("Origin", "https://kiosk.example.com")
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("expected the allowed origin to connect: %v", err)
+	}
+	conn.Close()
+}
+
+func TestReadingsOfOtherDevicesAreRejected(t *testing.T) {
+	_, _, url := startServer(t)
+	conn := dial(t, url+"?device_id=arduino_001")
+
+	if err := conn.WriteJSON(Message{Type: TypeReading, Data: &models.Data{DeviceID: "arduino_002", SoundLevel: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := readMessage(t, conn); ack.Status != AckInvalid {
+		t.Errorf("expected the reading of another device to be rejected, got %+v", ack)
+	}
+
+	// * A display without a device can't send readings *
+	display := dial(t, url+"?room_name=PlayRoom_A")
+	if err := display.WriteJSON(Message{Type: TypeReading, Data: &models.Data{DeviceID: "arduino_001", SoundLevel: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := readMessage(t, display); ack.Status != AckInvalid {
+		t.Errorf("expected the reading of a display to be rejected, got %+v", ack)
+	}
+}
+
+func TestInvalidMessageKeepsConnection(t *testing.T) {
+	_, _, url := startServer(t)
+	conn := dial(t, url+"?device_id=arduino_002")
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readMessage(t, conn); msg.Type != TypeError {
+		t.Errorf("expected an error message, got %+v", msg)
+	}
+
+	if err := conn.WriteJSON(Message{Type: TypeReading, Data: &models.Data{DeviceID: "arduino_002", SoundLevel: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := readMessage(t, conn); ack.Status != AckLatest {
+		t.Errorf("unexpected ack: %+v", ack)
+	}
+}
+
+func TestServerMessages(t *testing.T) {
+	s, _, url := startServer(t)
+	device := dial(t, url+"?device_id=arduino_001&room_name=PlayRoom_A")
+	display := dial(t, url+"?room_name=PlayRoom_A")
+	other := dial(t, url+"?device_id=arduino_002&room_name=PlayRoom_B")
+
+	// * Wait until every connection is registered *
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.clients)
+		s.mu.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 connections, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.ThresholdChanged(&models.Location{ID: 1, Name: "PlayRoom_A", Threshold: 75})
+	for _, conn := range []*websocket.Conn{device, display} {
+		msg := readMessage(t, conn)
+		if msg.Type != TypeThreshold || msg.Location == nil || msg.Location.Threshold != 75 {
+			t.Errorf("unexpected threshold message: %+v", msg)
+		}
+	}
+	// * Connections in other rooms are not sent the threshold *
+	other.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, _, err := other.ReadMessage(); err == nil {
+		t.Error("expected no threshold message for another room")
+	}
+
+	if sent := s.PlayAlert("arduino_404", &Alert{}); sent != 0 {
+		t.Errorf("expected no connection for an unknown device, got %d", sent)
+	}
+	if sent := s.PlayAlert("arduino_001", &Alert{Sound: "chime", DurationMs: 3000}); sent != 1 {
+		t.Fatalf("expected 1 connection, got %d", sent)
+	}
+	msg := readMessage(t, device)
+	if msg.Type != TypePlayAlert || msg.Alert == nil || msg.Alert.Sound != "chime" || msg.Alert.DurationMs != 3000 {
+		t.Errorf("unexpected alert message: %+v", msg)
+	}
+}